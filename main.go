@@ -7,7 +7,10 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/whiteblock/genesis/pkg/config"
 	"github.com/whiteblock/genesis/pkg/controller"
@@ -22,34 +25,161 @@ import (
 	queue "github.com/whiteblock/amqp"
 )
 
-func getRestServer() (controller.RestController, error) {
-	conf, err := config.NewConfig()
+func getTeardown(conf config.Config, reaper usecase.ReaperUseCase) (handAux.Teardown, error) {
+	if conf.LocalMode {
+		return handAux.NewLocalTeardown(reaper, conf.GetLogger()), nil
+	}
+	complConf, err := conf.CompletionAMQP()
+	if err != nil {
+		return nil, err
+	}
+
+	complConn, err := queue.OpenAMQPConnection(complConf.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return handAux.NewAMQPTeardown(
+		queue.NewAMQPService(complConf, queue.NewAMQPRepository(complConn), conf.GetLogger()),
+		conf.GetLogger()), nil
+}
+
+func getDockerService(conf config.Config, repo repository.DockerRepository,
+	remote file.RemoteSources) (service.DockerService, error) {
+	if len(conf.Docker.RecordDir) == 0 {
+		return service.NewDockerService(repo, conf.Docker, remote, conf.GetLogger()), nil
+	}
+	recordings, err := repository.NewRecordingRepository(conf.Docker.RecordDir)
+	if err != nil {
+		return nil, err
+	}
+	return service.NewRecordingDockerService(repo, conf.Docker, remote, recordings, conf.GetLogger()), nil
+}
+
+// getStats gets the StatsUseCase for sampling the resource usage of tests, or nil if it is disabled
+func getStats(conf config.Config, dockerService service.DockerService) (usecase.StatsUseCase, error) {
+	if conf.Stats.Interval <= 0 {
+		return nil, nil
+	}
+	var sink repository.StatsSink
+	if conf.LocalMode {
+		var err error
+		sink, err = repository.NewFileStatsSink(conf.Stats.Dir)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		statsConf, err := conf.StatsAMQP()
+		if err != nil {
+			return nil, err
+		}
+		statsConn, err := queue.OpenAMQPConnection(statsConf.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		sink = repository.NewAMQPStatsSink(
+			queue.NewAMQPService(statsConf, queue.NewAMQPRepository(statsConn), conf.GetLogger()))
+	}
+	return usecase.NewStatsUseCase(conf.Stats, dockerService, sink, conf.GetLogger()), nil
+}
+
+func getLogSink(conf config.Config) (repository.LogSink, error) {
+	switch conf.Logs.Sink {
+	case config.FileLogSink:
+		return repository.NewFileLogSink(conf.Logs.Dir)
+	case config.HTTPLogSink:
+		return repository.NewHTTPLogSink(conf.Logs.URL), nil
+	}
+	logConf, err := conf.LogsAMQP()
+	if err != nil {
+		return nil, err
+	}
+	logConn, err := queue.OpenAMQPConnection(logConf.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewAMQPLogSink(
+		queue.NewAMQPService(logConf, queue.NewAMQPRepository(logConn), conf.GetLogger())), nil
+}
+
+// getLogs gets the LogUseCase for forwarding the logs of tests, or nil if it is disabled
+func getLogs(conf config.Config) (usecase.LogUseCase, error) {
+	if len(conf.Logs.Sink) == 0 {
+		return nil, nil
+	}
+	sink, err := getLogSink(conf)
+	if err != nil {
+		return nil, err
+	}
+	dockerService, err := getDockerService(conf, repository.NewDockerRepository(conf.GetLogger()),
+		file.NewRemoteSources(conf, conf.GetLogger()))
 	if err != nil {
 		return nil, err
 	}
+	return usecase.NewLogUseCase(conf.Logs, dockerService, sink, conf.GetLogger()), nil
+}
+
+func getRestServer(queued repository.QueuedTestRepository, logs usecase.LogUseCase) (
+	controller.RestController, usecase.ReaperUseCase, error) {
+	conf, err := config.NewConfig()
+	if err != nil {
+		return nil, nil, err
+	}
 	config.SanityCheck(conf)
 
-	return controller.NewRestController(
-		conf.GetRestConfig(),
-		handler.NewRestHandler(
-			handAux.NewExecutor(
-				conf.Execution,
-				usecase.NewDockerUseCase(
-					service.NewDockerService(
-						repository.NewDockerRepository(conf.GetLogger()),
-						conf.Docker,
-						file.NewRemoteSources(
-							conf,
-							conf.GetLogger()),
-						conf.GetLogger()),
-					conf.GetLogger()),
+	runs := repository.NewRunRepository()
+	if len(conf.Rest.JournalPath) > 0 {
+		runs, err = repository.NewRunJournal(conf.Rest.JournalPath, conf.Rest.JournalRetention)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	dockerRepo := repository.NewDockerRepository(conf.GetLogger())
+	remote := file.NewRemoteSources(conf, conf.GetLogger())
+	dockerService, err := getDockerService(conf, dockerRepo, remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	reaper := usecase.NewReaperUseCase(conf.Reaper, dockerService, runs, queued, conf.GetLogger())
+
+	teardown, err := getTeardown(conf, reaper)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats, err := getStats(conf, dockerService)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hand := handler.NewRestHandler(handler.RestHandlerOptions{
+		Executor: handAux.NewExecutor(
+			conf.Execution,
+			usecase.NewDockerUseCase(
+				dockerService,
 				conf.GetLogger()),
 			conf.GetLogger()),
+		Runs:     runs,
+		Planner:  usecase.NewPlanUseCase(conf.Docker, dockerRepo, remote, conf.GetLogger()),
+		Reaper:   reaper,
+		Teardown: teardown,
+		Policy:   conf.Execution.TeardownPolicy,
+		Stats:    stats,
+		Logs:     logs,
+	}, conf.GetLogger())
+	err = hand.Recover(conf.Rest.ResumeRuns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return controller.NewRestController(
+		conf.GetRestConfig(),
+		hand,
 		mux.NewRouter(),
-		conf.GetLogger()), nil
+		conf.GetLogger()), reaper, nil
 }
 
-func getCommandController() (controller.CommandController, error) {
+func getCommandController(queued repository.QueuedTestRepository,
+	logs usecase.LogUseCase) (controller.CommandController, error) {
 	conf, err := config.NewConfig()
 	if err != nil {
 		return nil, err
@@ -81,6 +211,16 @@ func getCommandController() (controller.CommandController, error) {
 
 	queue.AssertUniqueQueues(conf.GetLogger(), complConf, cmdConf, errConf, statusConf)
 
+	dockerService, err := getDockerService(conf, repository.NewDockerRepository(conf.GetLogger()),
+		file.NewRemoteSources(conf, conf.GetLogger()))
+	if err != nil {
+		return nil, err
+	}
+	stats, err := getStats(conf, dockerService)
+	if err != nil {
+		return nil, err
+	}
+
 	cmdConn, err := queue.OpenAMQPConnection(cmdConf.Endpoint)
 	if err != nil {
 		return nil, err
@@ -111,21 +251,34 @@ func getCommandController() (controller.CommandController, error) {
 			handAux.NewExecutor(
 				conf.Execution,
 				usecase.NewDockerUseCase(
-					service.NewDockerService(
-						repository.NewDockerRepository(conf.GetLogger()),
-						conf.Docker,
-						file.NewRemoteSources(
-							conf,
-							conf.GetLogger()),
-						conf.GetLogger()),
+					dockerService,
 					conf.GetLogger()),
 				conf.GetLogger()),
 			conf,
 			conf.MaxMessageRetries,
+			queued,
+			stats,
+			logs,
 			conf.GetLogger()),
 		conf.GetLogger())
 }
 
+type stoppable interface {
+	Stop(ctx context.Context) error
+}
+
+// shutdown stops each of the given controllers in order, sharing the grace period between them
+func shutdown(conf config.Config, controllers ...stoppable) {
+	ctx, cancel := context.WithTimeout(context.Background(), conf.ShutdownGracePeriod)
+	defer cancel()
+	for _, cntl := range controllers {
+		err := cntl.Stop(ctx)
+		if err != nil {
+			conf.GetLogger().WithField("error", err).Warn("failed to stop gracefully")
+		}
+	}
+}
+
 func main() {
 
 	if len(os.Args) == 2 && os.Args[1] == "test" { //Run some basic docker functionality tests
@@ -138,24 +291,81 @@ func main() {
 		os.Exit(0)
 	}
 
-	restServer, err := getRestServer()
+	if len(os.Args) == 4 && os.Args[1] == "replay" { //Replay a recording against the given instructions
+		ok, err := replay(os.Args[2], os.Args[3])
+		if err != nil {
+			panic(err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if len(os.Args) == 3 && os.Args[1] == "plan" { //Print what the given instructions would do
+		valid, err := plan(os.Args[2])
+		if err != nil {
+			panic(err)
+		}
+		if !valid {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	conf, err := config.NewConfig()
 	if err != nil {
 		panic(err)
 	}
 
-	conf, err := config.NewConfig()
+	logs, err := getLogs(conf)
 	if err != nil {
 		panic(err)
 	}
+	queued := repository.NewQueuedTestRepository()
+	restServer, reaper, err := getRestServer(queued, logs)
+	if err != nil {
+		panic(err)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+	errs := make(chan error, 2)
+	controllers := []stoppable{}
+
+	if conf.Reaper.SweepInterval > 0 {
+		reaperCntl, err := controller.NewReaperController(conf.Reaper, reaper, conf.GetLogger())
+		if err != nil {
+			panic(err)
+		}
+		go reaperCntl.Start()
+		controllers = append(controllers, reaperCntl)
+	}
 
 	if !conf.LocalMode {
-		cmdCntl, err := getCommandController()
+		cmdCntl, err := getCommandController(queued, logs)
 		if err != nil {
 			panic(err)
 		}
-		go cmdCntl.Start()
+		go func() { errs <- cmdCntl.Start() }()
+		controllers = append(controllers, cmdCntl)
 	}
 
 	conf.GetLogger().Info("starting the rest server")
-	restServer.Start()
+	go func() { errs <- restServer.Start() }()
+	controllers = append(controllers, restServer)
+	if logs != nil { // once the tests have stopped being run
+		controllers = append(controllers, controller.NewLogController(logs, conf.GetLogger()))
+	}
+
+	exitCode := 0
+	select {
+	case sig := <-sigs:
+		conf.GetLogger().WithField("signal", sig).Info("shutting down")
+	case err = <-errs:
+		conf.GetLogger().WithField("error", err).Error("shutting down due to an error")
+		exitCode = 1
+	}
+	shutdown(conf, controllers...)
+	os.Exit(exitCode)
 }
@@ -7,6 +7,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/whiteblock/genesis/pkg/entity"
 
 	joonix "github.com/joonix/log"
@@ -27,6 +29,8 @@ type Config struct {
 	CommandQueueName    string `mapstructure:"commandQueueName"`
 	ErrorQueueName      string `mapstructure:"errorQueueName"`
 	StatusQueueName     string `mapstructure:"statusQueueName"`
+	LogQueueName        string `mapstructure:"logQueueName"`
+	StatsQueueName      string `mapstructure:"statsQueueName"`
 
 	// LocalMode indicates that Genesis is operating in standalone mode
 	LocalMode        bool              `mapstructure:"localMode"`
@@ -36,9 +40,16 @@ type Config struct {
 	FluentDLogging   bool              `mapstructure:"fluentDLogging"`
 	Listen           string            `mapstructure:"listen"`
 
+	// ShutdownGracePeriod is how long to wait for in-flight work to finish on shutdown
+	ShutdownGracePeriod time.Duration `mapstructure:"shutdownGracePeriod"`
+
 	Execution   Execution   `mapstructure:"-"`
 	Docker      Docker      `mapstructure:"-"`
 	FileHandler FileHandler `mapstructure:"-"`
+	Rest        Rest        `mapstructure:"-"`
+	Reaper      Reaper      `mapstructure:"-"`
+	Logs        Logs        `mapstructure:"-"`
+	Stats       Stats       `mapstructure:"-"`
 }
 
 // GetLogger gets a logger according to the config
@@ -91,13 +102,35 @@ func (c Config) StatusAMQP() (config.Config, error) {
 	return conf, err
 }
 
+// LogsAMQP gets the AMQP for the log queue
+func (c Config) LogsAMQP() (config.Config, error) {
+	conf, err := config.New(viper.GetViper())
+	conf.QueueName = c.LogQueueName
+	return conf, err
+}
+
+// StatsAMQP gets the AMQP for the stats queue
+func (c Config) StatsAMQP() (config.Config, error) {
+	conf, err := config.New(viper.GetViper())
+	conf.QueueName = c.StatsQueueName
+	return conf, err
+}
+
 // GetRestConfig extracts the fields of this object representing RestConfig
 func (c Config) GetRestConfig() entity.RestConfig {
-	return entity.RestConfig{Listen: c.Listen}
+	return entity.RestConfig{
+		Listen:           c.Listen,
+		CertPath:         c.Rest.CertPath,
+		KeyPath:          c.Rest.KeyPath,
+		ClientCACertPath: c.Rest.ClientCACertPath,
+		AuthTokenPath:    c.Rest.AuthTokenPath,
+	}
 }
 
 func setViperEnvBindings() {
 	viper.BindEnv("statusQueueName", "STATUS_QUEUE_NAME")
+	viper.BindEnv("logQueueName", "LOG_QUEUE_NAME")
+	viper.BindEnv("statsQueueName", "STATS_QUEUE_NAME")
 	viper.BindEnv("fluentDLogging", "FLUENT_D_LOGGING")
 	viper.BindEnv("maxMessageRetries", "MAX_MESSAGE_RETRIES")
 	viper.BindEnv("queueMaxConcurrency", "QUEUE_MAX_CONCURRENCY")
@@ -110,13 +143,20 @@ func setViperEnvBindings() {
 	viper.BindEnv("completionQueueName", "COMPLETION_QUEUE_NAME")
 	viper.BindEnv("commandQueueName", "COMMAND_QUEUE_NAME")
 	viper.BindEnv("errorQueueName", "ERROR_QUEUE_NAME")
+	viper.BindEnv("shutdownGracePeriod", "SHUTDOWN_GRACE_PERIOD")
 	setExecutionBindings(viper.GetViper())
 	setDockerBindings(viper.GetViper())
 	setFileHandlerBindings(viper.GetViper())
+	setRestBindings(viper.GetViper())
+	setReaperBindings(viper.GetViper())
+	setLogsBindings(viper.GetViper())
+	setStatsBindings(viper.GetViper())
 }
 
 func setViperDefaults() {
 	viper.SetDefault("statusQueueName", "status")
+	viper.SetDefault("logQueueName", "logs")
+	viper.SetDefault("statsQueueName", "stats")
 	viper.SetDefault("fluentDLogging", true)
 	viper.SetDefault("completionQueueName", "teardownRequests")
 	viper.SetDefault("commandQueueName", "commands")
@@ -126,10 +166,15 @@ func setViperDefaults() {
 	viper.SetDefault("listen", "0.0.0.0:8000")
 	viper.SetDefault("localMode", true)
 	viper.SetDefault("errorQueueName", "errors")
+	viper.SetDefault("shutdownGracePeriod", "30s")
 
 	setExecutionDefaults(viper.GetViper())
 	setDockerDefaults(viper.GetViper())
 	setFileHandlerDefaults(viper.GetViper())
+	setRestDefaults(viper.GetViper())
+	setReaperDefaults(viper.GetViper())
+	setLogsDefaults(viper.GetViper())
+	setStatsDefaults(viper.GetViper())
 }
 
 func init() {
@@ -160,6 +205,25 @@ func NewConfig() (conf Config, err error) {
 	if err != nil {
 		return
 	}
+	conf.Rest, err = NewRest(viper.GetViper())
+	if err != nil {
+		return
+	}
+	conf.Reaper, err = NewReaper(viper.GetViper())
+	if err != nil {
+		return
+	}
+	if conf.LocalMode && len(conf.Reaper.Hosts) == 0 {
+		conf.Reaper.Hosts = []string{"localhost"}
+	}
+	conf.Logs, err = NewLogs(viper.GetViper())
+	if err != nil {
+		return
+	}
+	conf.Stats, err = NewStats(viper.GetViper())
+	if err != nil {
+		return
+	}
 
 	conf.Docker, err = NewDocker(viper.GetViper())
 	return
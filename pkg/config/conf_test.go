@@ -29,6 +29,24 @@ func TestConfig_GetRestConfig(t *testing.T) {
 				Listen: "129.9.9.0:3000",
 			},
 		},
+		{
+			conf: Config{
+				Listen: "0.0.0.0:8000",
+				Rest: Rest{
+					CertPath:         "/cert.pem",
+					KeyPath:          "/key.pem",
+					ClientCACertPath: "/ca.pem",
+					AuthTokenPath:    "/tokens",
+				},
+			},
+			expectedRestConf: entity.RestConfig{
+				Listen:           "0.0.0.0:8000",
+				CertPath:         "/cert.pem",
+				KeyPath:          "/key.pem",
+				ClientCACertPath: "/ca.pem",
+				AuthTokenPath:    "/tokens",
+			},
+		},
 	}
 
 	for i, tt := range tests {
@@ -74,3 +92,19 @@ func TestConfig_CommandAMQP(t *testing.T) {
 	res, _ := conf.CommandAMQP()
 	assert.Equal(t, conf.CommandQueueName, res.QueueName)
 }
+
+func TestConfig_LogsAMQP(t *testing.T) {
+	conf := Config{
+		LogQueueName: "logs",
+	}
+	res, _ := conf.LogsAMQP()
+	assert.Equal(t, conf.LogQueueName, res.QueueName)
+}
+
+func TestConfig_StatsAMQP(t *testing.T) {
+	conf := Config{
+		StatsQueueName: "stats",
+	}
+	res, _ := conf.StatsAMQP()
+	assert.Equal(t, conf.StatsQueueName, res.QueueName)
+}
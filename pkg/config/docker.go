@@ -34,6 +34,18 @@ type Docker struct {
 	GlusterImage string `mapstructure:"dockerGlusterImage"`
 
 	GlusterDriver string `mapstructure:"dockerGlusterDriver"`
+
+	// RecordDir, if set, is the directory where the docker calls made on behalf of each
+	// test are recorded
+	RecordDir string `mapstructure:"dockerRecordDir"`
+
+	// ExecOutputLimit is the most bytes of stdout, and of stderr, kept from a command run by an
+	// exec order. There is no limit if it is not positive.
+	ExecOutputLimit int `mapstructure:"dockerExecOutputLimit"`
+
+	// ExitLogTail is the number of lines from the end of the logs of an attached container which
+	// are placed in the result when it exits with a non-zero exit code
+	ExitLogTail int `mapstructure:"dockerExitLogTail"`
 }
 
 // NewDocker creates a new docker configuration from viper
@@ -57,7 +69,7 @@ func setDockerBindings(v *viper.Viper) error {
 		return err
 	}
 
-	err = v.BindEnv("dockerLogLabels", "DOCKER_LOG_DRIVER")
+	err = v.BindEnv("dockerLogLabels", "DOCKER_LOG_LABELS")
 	if err != nil {
 		return err
 	}
@@ -82,6 +94,21 @@ func setDockerBindings(v *viper.Viper) error {
 		return err
 	}
 
+	err = v.BindEnv("dockerRecordDir", "DOCKER_RECORD_DIR")
+	if err != nil {
+		return err
+	}
+
+	err = v.BindEnv("dockerExecOutputLimit", "DOCKER_EXEC_OUTPUT_LIMIT")
+	if err != nil {
+		return err
+	}
+
+	err = v.BindEnv("dockerExitLogTail", "DOCKER_EXIT_LOG_TAIL")
+	if err != nil {
+		return err
+	}
+
 	return v.BindEnv("dockerKeyPath", "DOCKER_KEY_PATH")
 }
 
@@ -92,4 +119,6 @@ func setDockerDefaults(v *viper.Viper) {
 	v.SetDefault("dockerDaemonPort", "2376")
 	v.SetDefault("dockerGlusterImage", "gcr.io/whiteblock/gluster:latest")
 	v.SetDefault("dockerGlusterDriver", "glusterfs")
+	v.SetDefault("dockerExecOutputLimit", 16*1024)
+	v.SetDefault("dockerExitLogTail", 20)
 }
@@ -9,6 +9,8 @@ package config
 import (
 	"time"
 
+	"github.com/whiteblock/genesis/pkg/entity"
+
 	"github.com/spf13/viper"
 )
 
@@ -22,6 +24,9 @@ type Execution struct {
 	// not signal completion
 	DebugMode         bool          `mapstructure:"debugMode"`
 	DMCompletionDelay time.Duration `mapstructure:"dmCompletionDelay"`
+	// TeardownPolicy decides which of the runs submitted through the REST API have their
+	// resources destroyed once they finish
+	TeardownPolicy entity.TeardownPolicy `mapstructure:"teardownPolicy"`
 }
 
 // NewExecution creates a new Execution config from the given viper
@@ -50,6 +55,10 @@ func setExecutionBindings(v *viper.Viper) error {
 	if err != nil {
 		return err
 	}
+	err = v.BindEnv("teardownPolicy", "TEARDOWN_POLICY")
+	if err != nil {
+		return err
+	}
 	return v.BindEnv("executionConnectionRetries", "EXECUTION_CONNECTION_RETRIES")
 }
 
@@ -60,4 +69,5 @@ func setExecutionDefaults(v *viper.Viper) {
 	v.SetDefault("executionTimeLimit", 10*time.Minute)
 	v.SetDefault("debugMode", false)
 	v.SetDefault("dmCompletionDelay", 2*time.Hour)
+	v.SetDefault("teardownPolicy", entity.TeardownNever)
 }
@@ -15,6 +15,13 @@ import (
 type FileHandler struct {
 	APIEndpoint string        `mapstructure:"apiEndpoint"`
 	APITimeout  time.Duration `mapstructure:"apiTimeout"`
+
+	// S3Endpoint is the endpoint of the S3 compatible object storage which s3:// files are fetched
+	// from. Defaults to AWS S3 in S3Region.
+	S3Endpoint        string `mapstructure:"s3Endpoint"`
+	S3Region          string `mapstructure:"s3Region"`
+	S3AccessKeyID     string `mapstructure:"s3AccessKeyID"`
+	S3SecretAccessKey string `mapstructure:"s3SecretAccessKey"`
 }
 
 //NewFileHandler creates a new FileHandler config from the given viper
@@ -27,10 +34,27 @@ func setFileHandlerBindings(v *viper.Viper) error {
 	if err != nil {
 		return err
 	}
-	return v.BindEnv("apiEndpoint", "API_ENDPOINT")
+	err = v.BindEnv("apiEndpoint", "API_ENDPOINT")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("s3Endpoint", "S3_ENDPOINT")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("s3Region", "S3_REGION")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("s3AccessKeyID", "S3_ACCESS_KEY_ID")
+	if err != nil {
+		return err
+	}
+	return v.BindEnv("s3SecretAccessKey", "S3_SECRET_ACCESS_KEY")
 }
 
 func setFileHandlerDefaults(v *viper.Viper) {
 	v.SetDefault("apiEndpoint", "https://www.infra.whiteblock.io")
 	v.SetDefault("apiTimeout", 10*time.Second)
+	v.SetDefault("s3Region", "us-east-1")
 }
@@ -0,0 +1,70 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// FileLogSink writes the logs of each container to a file
+	FileLogSink = "file"
+	// AMQPLogSink sends each line logged to the log queue
+	AMQPLogSink = "amqp"
+	// HTTPLogSink posts each line logged to an HTTP endpoint
+	HTTPLogSink = "http"
+)
+
+// Logs represents the configuration for forwarding the logs of the containers of tests
+type Logs struct {
+	// Sink is where the logs are forwarded to, one of FileLogSink, AMQPLogSink or HTTPLogSink.
+	// If empty, the logs are not forwarded.
+	Sink string `mapstructure:"logSink"`
+	// Dir is the directory the FileLogSink writes to
+	Dir string `mapstructure:"logSinkDir"`
+	// URL is the endpoint the HTTPLogSink posts to
+	URL string `mapstructure:"logSinkURL"`
+	// PollInterval is the interval at which the hosts targeted by a running test are checked
+	// for containers of the test to follow
+	PollInterval time.Duration `mapstructure:"logPollInterval"`
+	// IdleTimeout is how long a test is followed for while it has no containers, or its hosts
+	// cannot be checked. If 0, it is followed until it finishes.
+	IdleTimeout time.Duration `mapstructure:"logIdleTimeout"`
+}
+
+// NewLogs creates a new log forwarding configuration from viper
+func NewLogs(v *viper.Viper) (out Logs, err error) {
+	return out, v.Unmarshal(&out)
+}
+
+func setLogsBindings(v *viper.Viper) error {
+	err := v.BindEnv("logSink", "LOG_SINK")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("logSinkDir", "LOG_SINK_DIR")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("logSinkURL", "LOG_SINK_URL")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("logPollInterval", "LOG_POLL_INTERVAL")
+	if err != nil {
+		return err
+	}
+	return v.BindEnv("logIdleTimeout", "LOG_IDLE_TIMEOUT")
+}
+
+func setLogsDefaults(v *viper.Viper) {
+	v.SetDefault("logSink", "")
+	v.SetDefault("logPollInterval", "5s")
+	v.SetDefault("logIdleTimeout", 10*time.Minute)
+}
@@ -0,0 +1,56 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Reaper represents the configuration for the removal of resources left behind by tests
+type Reaper struct {
+	// SweepInterval is the interval at which the hosts are swept for resources belonging
+	// to tests without a live run. If 0, the periodic sweep is disabled.
+	SweepInterval time.Duration `mapstructure:"reaperSweepInterval"`
+	// DryRun causes the sweep to only report what it would remove
+	DryRun bool `mapstructure:"reaperDryRun"`
+	// Hosts are the docker hosts to sweep. In local mode, the local docker daemon is
+	// swept if none are given.
+	Hosts []string `mapstructure:"reaperHosts"`
+	// GracePeriod is how old the newest resource of a test without a run submitted through
+	// the REST API must be for the test to be swept. Whether such tests are live is only known
+	// to the replica handling them, and only since it started.
+	GracePeriod time.Duration `mapstructure:"reaperGracePeriod"`
+}
+
+// NewReaper creates a new reaper configuration from viper
+func NewReaper(v *viper.Viper) (out Reaper, err error) {
+	return out, v.Unmarshal(&out)
+}
+
+func setReaperBindings(v *viper.Viper) error {
+	err := v.BindEnv("reaperSweepInterval", "REAPER_SWEEP_INTERVAL")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("reaperDryRun", "REAPER_DRY_RUN")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("reaperGracePeriod", "REAPER_GRACE_PERIOD")
+	if err != nil {
+		return err
+	}
+	return v.BindEnv("reaperHosts", "REAPER_HOSTS")
+}
+
+func setReaperDefaults(v *viper.Viper) {
+	v.SetDefault("reaperSweepInterval", 0)
+	v.SetDefault("reaperDryRun", false)
+	v.SetDefault("reaperGracePeriod", time.Hour)
+}
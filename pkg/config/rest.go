@@ -0,0 +1,74 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Rest represents the configuration for the REST API
+type Rest struct {
+	// CertPath is the filepath to the certificate to serve the REST API over TLS with
+	CertPath string `mapstructure:"restCertPath"`
+	// KeyPath is the filepath to the private key for TLS
+	KeyPath string `mapstructure:"restKeyPath"`
+	// ClientCACertPath is the filepath to the CA Certificate which client certificates
+	// must be signed by. If empty, client certificates are not verified.
+	ClientCACertPath string `mapstructure:"restClientCACertPath"`
+	// AuthTokenPath is the filepath to a file containing the accepted bearer tokens,
+	// one per line. If empty, bearer token auth is disabled.
+	AuthTokenPath string `mapstructure:"restAuthTokenPath"`
+	// JournalPath is the directory in which the state of runs is kept, so that they survive
+	// a restart. If empty, runs are only kept in memory.
+	JournalPath string `mapstructure:"restJournalPath"`
+	// JournalRetention is how long finished runs are kept in the journal for. If 0, they
+	// are kept forever.
+	JournalRetention time.Duration `mapstructure:"restJournalRetention"`
+	// ResumeRuns causes runs which were interrupted by a restart to be resumed, instead of
+	// being marked as failed
+	ResumeRuns bool `mapstructure:"restResumeRuns"`
+}
+
+// NewRest creates a new rest configuration from viper
+func NewRest(v *viper.Viper) (out Rest, err error) {
+	return out, v.Unmarshal(&out)
+}
+
+func setRestBindings(v *viper.Viper) error {
+	err := v.BindEnv("restCertPath", "REST_CERT_PATH")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("restKeyPath", "REST_KEY_PATH")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("restClientCACertPath", "REST_CLIENT_CACERT_PATH")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("restAuthTokenPath", "REST_AUTH_TOKEN_PATH")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("restJournalPath", "REST_JOURNAL_PATH")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("restJournalRetention", "REST_JOURNAL_RETENTION")
+	if err != nil {
+		return err
+	}
+	return v.BindEnv("restResumeRuns", "REST_RESUME_RUNS")
+}
+
+func setRestDefaults(v *viper.Viper) {
+	v.SetDefault("restJournalRetention", 7*24*time.Hour)
+	v.SetDefault("restResumeRuns", true)
+}
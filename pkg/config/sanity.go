@@ -24,6 +24,12 @@ func SanityCheck(conf Config) {
 
 	dockerSanityCheck(conf.Docker)
 	log.Info("docker configuration checks passed")
+	restSanityCheck(conf.Rest)
+	log.Info("rest configuration checks passed")
+	executionSanityCheck(conf.Execution)
+	log.Info("execution configuration checks passed")
+	logsSanityCheck(conf.Logs)
+	log.Info("log forwarding configuration checks passed")
 }
 
 var portRegexp = regexp.MustCompile(`[0-9]+`)
@@ -60,3 +66,45 @@ func dockerFilesConfCheck(conf Docker) {
 		panic(err)
 	}
 }
+
+func restSanityCheck(conf Rest) {
+	if (len(conf.CertPath) == 0) != (len(conf.KeyPath) == 0) {
+		panic("both the rest cert and key must be given to enable TLS")
+	}
+	if len(conf.ClientCACertPath) > 0 && len(conf.CertPath) == 0 {
+		panic("client certificate verification requires TLS to be enabled")
+	}
+	for _, path := range []string{conf.CertPath, conf.KeyPath, conf.ClientCACertPath, conf.AuthTokenPath} {
+		if len(path) == 0 {
+			continue
+		}
+		_, err := os.Lstat(path)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func executionSanityCheck(conf Execution) {
+	err := conf.TeardownPolicy.Validate()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func logsSanityCheck(conf Logs) {
+	switch conf.Sink {
+	case "":
+		return
+	case FileLogSink:
+		assertNotEmpty(conf.Dir, "the file log sink requires a directory")
+	case HTTPLogSink:
+		assertNotEmpty(conf.URL, "the http log sink requires a url")
+	case AMQPLogSink:
+	default:
+		panic(fmt.Sprintf(`unknown log sink: "%s"`, conf.Sink))
+	}
+	if conf.PollInterval <= 0 {
+		panic("the log poll interval must be positive")
+	}
+}
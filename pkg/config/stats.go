@@ -0,0 +1,49 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Stats represents the configuration for sampling the resource usage of the containers of tests
+type Stats struct {
+	// Interval is the interval at which the containers of a running test are sampled.
+	// If 0, the containers are not sampled.
+	Interval time.Duration `mapstructure:"statsInterval"`
+	// Dir is the directory the samples are written to in local mode, instead of being sent
+	// to the stats queue
+	Dir string `mapstructure:"statsDir"`
+	// IdleTimeout is how long a test is sampled for while it has no containers, or its hosts
+	// cannot be sampled. If 0, it is sampled until it finishes.
+	IdleTimeout time.Duration `mapstructure:"statsIdleTimeout"`
+}
+
+// NewStats creates a new stats configuration from viper
+func NewStats(v *viper.Viper) (out Stats, err error) {
+	return out, v.Unmarshal(&out)
+}
+
+func setStatsBindings(v *viper.Viper) error {
+	err := v.BindEnv("statsInterval", "STATS_INTERVAL")
+	if err != nil {
+		return err
+	}
+	err = v.BindEnv("statsDir", "STATS_DIR")
+	if err != nil {
+		return err
+	}
+	return v.BindEnv("statsIdleTimeout", "STATS_IDLE_TIMEOUT")
+}
+
+func setStatsDefaults(v *viper.Viper) {
+	v.SetDefault("statsInterval", 0)
+	v.SetDefault("statsDir", "/var/lib/genesis/stats")
+	v.SetDefault("statsIdleTimeout", 10*time.Minute)
+}
@@ -0,0 +1,101 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package controller
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+)
+
+// unauthenticatedPaths are the paths which can be accessed without any credentials, such as probes
+// and scrapes
+var unauthenticatedPaths = map[string]bool{
+	"/health":  true,
+	"/metrics": true,
+}
+
+// readTokens reads the accepted bearer tokens from the file at the given path, one per line
+func readTokens(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		token := strings.TrimSpace(line)
+		if len(token) > 0 {
+			out = append(out, token)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no tokens found in \"%s\"", path)
+	}
+	return out, nil
+}
+
+// getTLSConfig creates the TLS config for the server. Client certificates are only verified
+// if they are given, so that probes can still reach the unauthenticated paths; authenticate
+// enforces their presence everywhere else.
+func getTLSConfig(conf entity.RestConfig) (*tls.Config, error) {
+	if len(conf.ClientCACertPath) == 0 {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(conf.ClientCACertPath)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in \"%s\"", conf.ClientCACertPath)
+	}
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+	}, nil
+}
+
+func hasToken(tokens []string, r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	given := []byte(strings.TrimPrefix(header, "Bearer "))
+	for _, token := range tokens {
+		if subtle.ConstantTimeCompare(given, []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate rejects requests which lack a verified client certificate, if requireCert is true,
+// or a valid bearer token, if any tokens are given.
+func authenticate(requireCert bool, tokens []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unauthenticatedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if requireCert && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			http.Error(w, "a valid client certificate is required", http.StatusUnauthorized)
+			return
+		}
+		if len(tokens) > 0 && !hasToken(tokens, r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "a valid bearer token is required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,83 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package controller
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTokens(t *testing.T) {
+	f, err := ioutil.TempFile("", "tokens")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("foo\n\n  bar  \n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	tokens, err := readTokens(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo", "bar"}, tokens)
+
+	_, err = readTokens(f.Name() + "dne")
+	assert.Error(t, err)
+}
+
+func TestAuthenticate(t *testing.T) {
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{&x509.Certificate{}}}}
+	var tests = []struct {
+		requireCert  bool
+		tokens       []string
+		path         string
+		header       string
+		tls          *tls.ConnectionState
+		expectedCode int
+	}{
+		{path: "/command", expectedCode: 200},
+		{tokens: []string{"foo"}, path: "/health", expectedCode: 200},
+		{tokens: []string{"foo"}, path: "/metrics", expectedCode: 200},
+		{tokens: []string{"foo"}, path: "/command", expectedCode: 401},
+		{tokens: []string{"foo"}, path: "/command", header: "Bearer bar", expectedCode: 401},
+		{tokens: []string{"foo"}, path: "/command", header: "foo", expectedCode: 401},
+		{tokens: []string{"foo", "bar"}, path: "/command", header: "Bearer bar", expectedCode: 200},
+		{requireCert: true, path: "/health", expectedCode: 200},
+		{requireCert: true, path: "/metrics", expectedCode: 200},
+		{requireCert: true, path: "/command", expectedCode: 401},
+		{requireCert: true, path: "/command", tls: &tls.ConnectionState{}, expectedCode: 401},
+		{requireCert: true, path: "/command", tls: verified, expectedCode: 200},
+		{requireCert: true, tokens: []string{"foo"}, path: "/command", tls: verified, expectedCode: 401},
+		{requireCert: true, tokens: []string{"foo"}, path: "/command", tls: verified,
+			header: "Bearer foo", expectedCode: 200},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.path, nil)
+			require.NoError(t, err)
+			if len(tt.header) > 0 {
+				req.Header.Set("Authorization", tt.header)
+			}
+			req.TLS = tt.tls
+
+			recorder := httptest.NewRecorder()
+			authenticate(tt.requireCert, tt.tokens, next).ServeHTTP(recorder, req)
+			assert.Equal(t, tt.expectedCode, recorder.Code)
+		})
+	}
+}
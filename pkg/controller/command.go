@@ -8,10 +8,13 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/whiteblock/genesis/pkg/handler"
+	"github.com/whiteblock/genesis/pkg/metrics"
 
 	"github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
@@ -21,10 +24,22 @@ import (
 
 // CommandController is a controller which brings in from an AMQP compatible provider
 type CommandController interface {
-	// Start starts the client. This function should be called only once and does not return
-	Start()
+	// Start starts the client. This function should be called only once, and does not return
+	// until Stop is called or consumption fails
+	Start() error
+
+	// Stop stops the consumption of new messages, and waits for the messages being handled
+	// to finish. Once ctx is done, the messages which remain are canceled and requeued.
+	Stop(ctx context.Context) error
 }
 
+// ErrConsumptionEnded is returned when the command queue stops delivering messages
+var ErrConsumptionEnded = errors.New("the command queue stopped delivering messages")
+
+// cancelTimeout is how long Stop waits for the messages in flight to stop once they are canceled.
+// Messages which still haven't stopped are left unacknowledged, to be redelivered by the broker.
+var cancelTimeout = 5 * time.Second
+
 type consumer struct {
 	completion queue.AMQPService
 	cmds       queue.AMQPService
@@ -34,6 +49,15 @@ type consumer struct {
 	log        logrus.Ext1FieldLogger
 	once       *sync.Once
 	sem        *semaphore.Weighted
+
+	// stopping is done once Stop is called, and cancel cancels the messages still being handled
+	stopping context.Context
+	stop     context.CancelFunc
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mux      sync.Mutex
+	inFlight sync.WaitGroup
 }
 
 // NewCommandController creates a new CommandController
@@ -60,14 +84,68 @@ func NewCommandController(
 		once:       &sync.Once{},
 		sem:        semaphore.NewWeighted(maxConcurreny),
 	}
+	out.stopping, out.stop = context.WithCancel(context.Background())
+	out.ctx, out.cancel = context.WithCancel(context.Background())
 	queue.TryCreateQueues(log, cmds, completion, errors, status)
+	metrics.ConcurrencyLimit.Set(float64(maxConcurreny))
 
 	return out, nil
 }
 
-// Start starts the client. This function should be called only once and does not return
-func (c *consumer) Start() {
-	c.once.Do(func() { c.loop() })
+// Start starts the client. This function should be called only once, and does not return
+// until Stop is called or consumption fails
+func (c *consumer) Start() (err error) {
+	c.once.Do(func() { err = c.loop() })
+	return
+}
+
+// Stop stops the consumption of new messages, and waits for the messages being handled
+// to finish. Once ctx is done, the messages which remain are canceled and requeued.
+func (c *consumer) Stop(ctx context.Context) error {
+	c.mux.Lock()
+	c.stop()
+	c.mux.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.inFlight.Wait()
+		close(done)
+	}()
+	c.log.Info("waiting for the messages in flight to finish")
+	select {
+	case <-done:
+		c.log.Info("all messages in flight have finished")
+		return nil
+	case <-ctx.Done():
+	}
+	c.log.Warn("the grace period has expired, canceling the messages in flight")
+	c.cancel()
+	select {
+	case <-done:
+	case <-time.After(cancelTimeout):
+		c.log.Error("the messages in flight did not stop once canceled, abandoning them")
+	}
+	return ctx.Err()
+}
+
+// track marks a message as being in flight, it returns false if the consumer is stopping
+func (c *consumer) track() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.stopping.Err() != nil {
+		return false
+	}
+	c.inFlight.Add(1)
+	return true
+}
+
+func (c *consumer) requeue(msg amqp.Delivery) {
+	err := msg.Nack(false, true)
+	if err != nil {
+		c.log.WithField("err", err).Error("failed to return a message to the queue")
+		return
+	}
+	metrics.MessagesRequeued.WithLabelValues("Canceled").Inc()
 }
 
 func (c *consumer) reportStatus(status amqp.Publishing) {
@@ -79,17 +157,26 @@ func (c *consumer) reportStatus(status amqp.Publishing) {
 }
 
 func (c *consumer) handleMessage(msg amqp.Delivery) {
+	defer c.inFlight.Done()
 	defer c.sem.Release(1)
+	defer metrics.ConcurrencyInUse.Dec()
 
-	pub, status, res := c.handle.Process(msg)
+	pub, status, res := c.handle.Process(c.ctx, msg)
+	if c.ctx.Err() != nil { // whatever the result, it was cut short
+		c.log.WithField("result", res).Warn("returning a canceled message to the queue")
+		c.requeue(msg)
+		return
+	}
 	go c.reportStatus(status)
 	if res.IsIgnore() {
 		c.log.WithField("payload", string(msg.Body)).Error("ignoring a message")
+		metrics.MessagesAcked.WithLabelValues(res.Type.String()).Inc()
 		msg.Ack(false)
 		return
 	}
 	if res.IsTrap() {
 		c.log.Info("falling through due to trap")
+		metrics.MessagesAcked.WithLabelValues(res.Type.String()).Inc()
 		msg.Ack(false)
 		return
 	}
@@ -98,7 +185,9 @@ func (c *consumer) handleMessage(msg amqp.Delivery) {
 		err := c.cmds.Requeue(msg, pub)
 		if err != nil {
 			c.log.WithField("err", err).Error("failed to re-queue")
+			return
 		}
+		metrics.MessagesRequeued.WithLabelValues(res.Type.String()).Inc()
 		return
 	}
 	if res.IsAllDone() || res.IsFatal() {
@@ -124,18 +213,41 @@ func (c *consumer) handleMessage(msg amqp.Delivery) {
 		}
 	}
 	c.log.Info("successfully completed a message")
-
+	metrics.MessagesAcked.WithLabelValues(res.Type.String()).Inc()
 	msg.Ack(false)
 }
 
-func (c *consumer) loop() {
+func (c *consumer) loop() error {
 	msgs, err := c.cmds.Consume()
 	if err != nil {
-		c.log.Fatal(err)
+		return err
 	}
-	for msg := range msgs {
+	for {
+		var msg amqp.Delivery
+		var ok bool
+		select {
+		case <-c.stopping.Done():
+			c.log.Info("no longer consuming messages")
+			return nil
+		case msg, ok = <-msgs:
+		}
+		if !ok {
+			return ErrConsumptionEnded
+		}
 		c.log.Info("received a message")
-		c.sem.Acquire(context.Background(), 1)
+		metrics.MessagesConsumed.Inc()
+		if c.sem.Acquire(c.stopping, 1) != nil {
+			c.requeue(msg)
+			c.log.Info("no longer consuming messages")
+			return nil
+		}
+		if !c.track() {
+			c.sem.Release(1)
+			c.requeue(msg)
+			c.log.Info("no longer consuming messages")
+			return nil
+		}
+		metrics.ConcurrencyInUse.Inc()
 		go c.handleMessage(msg)
 	}
 }
@@ -7,7 +7,9 @@
 package controller
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -19,6 +21,7 @@ import (
 	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewCommandController_Failure(t *testing.T) {
@@ -70,7 +73,7 @@ func TestCommandController_Consumption(t *testing.T) {
 	serv4.On("CreateExchange").Return(nil).Once()
 	serv4.On("Send", mock.Anything).Return(nil)
 	hand := new(handler.DeliveryHandler)
-	hand.On("Process", mock.Anything).Run(func(_ mock.Arguments) {
+	hand.On("Process", mock.Anything, mock.Anything).Run(func(_ mock.Arguments) {
 		processedChan <- true
 	}).Return(amqp.Publishing{}, amqp.Publishing{}, entity.NewSuccessResult()).Times(items)
 
@@ -120,7 +123,7 @@ func TestCommandController_ConsumptionAllDone(t *testing.T) {
 	})
 
 	hand := new(handler.DeliveryHandler)
-	hand.On("Process", mock.Anything).Return(amqp.Publishing{}, amqp.Publishing{},
+	hand.On("Process", mock.Anything, mock.Anything).Return(amqp.Publishing{}, amqp.Publishing{},
 		entity.NewAllDoneResult()).Times(items)
 
 	control, err := NewCommandController(2, serv, serv3, serv2, serv4, hand, logrus.New())
@@ -168,7 +171,7 @@ func TestCommandController_ConsumptionAllDone_Send_Err(t *testing.T) {
 	serv4.On("CreateExchange").Return(nil).Once()
 
 	hand := new(handler.DeliveryHandler)
-	hand.On("Process", mock.Anything).Return(amqp.Publishing{}, amqp.Publishing{},
+	hand.On("Process", mock.Anything, mock.Anything).Return(amqp.Publishing{}, amqp.Publishing{},
 		entity.NewAllDoneResult()).Times(items)
 
 	control, err := NewCommandController(2, serv, serv3, serv2, serv4, hand, logrus.New())
@@ -216,7 +219,7 @@ func TestCommandController_Requeue(t *testing.T) {
 	serv4.On("Send", mock.Anything).Return(nil)
 
 	hand := new(handler.DeliveryHandler)
-	hand.On("Process", mock.Anything).Return(amqp.Publishing{}, amqp.Publishing{},
+	hand.On("Process", mock.Anything, mock.Anything).Return(amqp.Publishing{}, amqp.Publishing{},
 		entity.NewErrorResult(fmt.Errorf("some non-fatal error"))).Times(items)
 
 	control, err := NewCommandController(2, serv, serv3, serv2, serv4, hand, logrus.New())
@@ -238,3 +241,149 @@ func TestCommandController_Requeue(t *testing.T) {
 	hand.AssertExpectations(t)
 	serv.AssertExpectations(t)
 }
+
+type testAcknowledger struct {
+	mux      sync.Mutex
+	acked    int
+	requeued int
+}
+
+func (ta *testAcknowledger) Ack(tag uint64, multiple bool) error {
+	ta.mux.Lock()
+	defer ta.mux.Unlock()
+	ta.acked++
+	return nil
+}
+
+func (ta *testAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	ta.mux.Lock()
+	defer ta.mux.Unlock()
+	if requeue {
+		ta.requeued++
+	}
+	return nil
+}
+
+func (ta *testAcknowledger) Reject(tag uint64, requeue bool) error {
+	return ta.Nack(tag, false, requeue)
+}
+
+func newStoppableController(t *testing.T, deliveryChan chan amqp.Delivery,
+	hand *handler.DeliveryHandler) CommandController {
+
+	serv := new(queue.AMQPService)
+	other := new(queue.AMQPService)
+	serv.On("Consume").Return((<-chan amqp.Delivery)(deliveryChan), nil).Once()
+	serv.On("CreateQueue").Return(nil)
+	serv.On("CreateExchange").Return(nil)
+	other.On("CreateQueue").Return(nil)
+	other.On("CreateExchange").Return(nil)
+	other.On("Send", mock.Anything).Return(nil)
+
+	control, err := NewCommandController(2, serv, other, other, other, hand, logrus.New())
+	require.NoError(t, err)
+	return control
+}
+
+func TestCommandController_Stop_Drains(t *testing.T) {
+	deliveryChan := make(chan amqp.Delivery, 1)
+	started := make(chan bool)
+	release := make(chan bool)
+
+	hand := new(handler.DeliveryHandler)
+	hand.On("Process", mock.Anything, mock.Anything).Run(func(_ mock.Arguments) {
+		started <- true
+		<-release
+	}).Return(amqp.Publishing{}, amqp.Publishing{}, entity.NewSuccessResult()).Once()
+
+	control := newStoppableController(t, deliveryChan, hand)
+	startErr := make(chan error)
+	go func() { startErr <- control.Start() }()
+
+	ack := &testAcknowledger{}
+	deliveryChan <- amqp.Delivery{Acknowledger: ack}
+	<-started
+
+	stopErr := make(chan error)
+	go func() { stopErr <- control.Stop(context.Background()) }()
+	require.NoError(t, <-startErr)
+
+	select {
+	case <-stopErr:
+		t.Fatal("stop returned before the message in flight finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	require.NoError(t, <-stopErr)
+
+	assert.Equal(t, 1, ack.acked)
+	assert.Equal(t, 0, ack.requeued)
+	hand.AssertExpectations(t)
+}
+
+func TestCommandController_Stop_GracePeriodExpired(t *testing.T) {
+	deliveryChan := make(chan amqp.Delivery, 1)
+	started := make(chan bool)
+
+	hand := new(handler.DeliveryHandler)
+	hand.On("Process", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		started <- true
+		<-args.Get(0).(context.Context).Done()
+	}).Return(amqp.Publishing{}, amqp.Publishing{}, entity.NewFatalResult("canceled")).Once()
+
+	control := newStoppableController(t, deliveryChan, hand)
+	go control.Start()
+
+	ack := &testAcknowledger{}
+	deliveryChan <- amqp.Delivery{Acknowledger: ack}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, control.Stop(ctx))
+
+	assert.Equal(t, 0, ack.acked)
+	assert.Equal(t, 1, ack.requeued)
+	hand.AssertExpectations(t)
+}
+
+func TestCommandController_Stop_CancelIgnored(t *testing.T) {
+	deliveryChan := make(chan amqp.Delivery, 1)
+	started := make(chan bool)
+	release := make(chan bool)
+	defer close(release)
+
+	hand := new(handler.DeliveryHandler)
+	hand.On("Process", mock.Anything, mock.Anything).Run(func(_ mock.Arguments) {
+		started <- true
+		<-release
+	}).Return(amqp.Publishing{}, amqp.Publishing{}, entity.NewSuccessResult()).Once()
+
+	control := newStoppableController(t, deliveryChan, hand)
+	go control.Start()
+
+	ack := &testAcknowledger{}
+	deliveryChan <- amqp.Delivery{Acknowledger: ack}
+	<-started
+
+	defer func(timeout time.Duration) { cancelTimeout = timeout }(cancelTimeout)
+	cancelTimeout = 50 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	stopErr := make(chan error)
+	go func() { stopErr <- control.Stop(ctx) }()
+	select {
+	case err := <-stopErr:
+		assert.Equal(t, context.DeadlineExceeded, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop did not return once the message in flight was abandoned")
+	}
+}
+
+func TestCommandController_ConsumptionEnded(t *testing.T) {
+	deliveryChan := make(chan amqp.Delivery)
+	control := newStoppableController(t, deliveryChan, new(handler.DeliveryHandler))
+	close(deliveryChan)
+	assert.Equal(t, ErrConsumptionEnded, control.Start())
+}
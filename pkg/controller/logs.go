@@ -0,0 +1,50 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package controller
+
+import (
+	"context"
+
+	"github.com/whiteblock/genesis/pkg/usecase"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogController stops the forwarding of the logs of tests on shutdown. The logs of each test
+// are followed from when its instructions are received until it finishes.
+type LogController interface {
+	// Stop stops forwarding the logs, waiting for the lines being forwarded until ctx is done
+	Stop(ctx context.Context) error
+}
+
+type logController struct {
+	logs usecase.LogUseCase
+	log  logrus.Ext1FieldLogger
+}
+
+// NewLogController creates a new LogController
+func NewLogController(logs usecase.LogUseCase, log logrus.Ext1FieldLogger) LogController {
+	return &logController{logs: logs, log: log}
+}
+
+// Stop stops forwarding the logs, waiting for the lines being forwarded until ctx is done
+func (lc *logController) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := lc.logs.Close()
+		if err != nil {
+			lc.log.WithField("error", err).Error("failed to close the log sink")
+		}
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,40 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	usecase "github.com/whiteblock/genesis/mocks/pkg/usecase"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLogController_Stop(t *testing.T) {
+	logs := new(usecase.LogUseCase)
+	logs.On("Close").Return(nil).Once()
+
+	lc := NewLogController(logs, logrus.New())
+	assert.NoError(t, lc.Stop(context.Background()))
+	logs.AssertExpectations(t)
+}
+
+func TestLogController_Stop_Timeout(t *testing.T) {
+	release := make(chan bool)
+	defer close(release)
+	logs := new(usecase.LogUseCase)
+	logs.On("Close").Return(nil).Run(func(_ mock.Arguments) { <-release }).Once()
+
+	lc := NewLogController(logs, logrus.New())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, lc.Stop(ctx))
+}
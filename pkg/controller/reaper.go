@@ -0,0 +1,94 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/usecase"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReaperController periodically sweeps the hosts for resources left behind by tests
+type ReaperController interface {
+	// Start starts the periodic sweep. It does not return until Stop is called
+	Start() error
+	// Stop stops the periodic sweep, waiting for a sweep in progress until ctx is done
+	Stop(ctx context.Context) error
+}
+
+type reaperController struct {
+	conf   config.Reaper
+	reaper usecase.ReaperUseCase
+	log    logrus.Ext1FieldLogger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   *sync.Once
+}
+
+// NewReaperController creates a new ReaperController
+func NewReaperController(
+	conf config.Reaper,
+	reaper usecase.ReaperUseCase,
+	log logrus.Ext1FieldLogger) (ReaperController, error) {
+
+	if conf.SweepInterval <= 0 {
+		return nil, fmt.Errorf("the sweep interval must be positive")
+	}
+	out := &reaperController{conf: conf, reaper: reaper, log: log,
+		done: make(chan struct{}), once: &sync.Once{}}
+	out.ctx, out.cancel = context.WithCancel(context.Background())
+	return out, nil
+}
+
+// Start starts the periodic sweep. It does not return until Stop is called
+func (rc *reaperController) Start() error {
+	rc.once.Do(func() {
+		defer close(rc.done)
+		rc.loop()
+	})
+	return nil
+}
+
+// Stop stops the periodic sweep, waiting for a sweep in progress until ctx is done
+func (rc *reaperController) Stop(ctx context.Context) error {
+	rc.cancel()
+	select {
+	case <-rc.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (rc *reaperController) loop() {
+	ticker := time.NewTicker(rc.conf.SweepInterval)
+	defer ticker.Stop()
+	rc.log.WithFields(logrus.Fields{"interval": rc.conf.SweepInterval, "dryRun": rc.conf.DryRun,
+		"hosts": rc.conf.Hosts}).Info("starting the periodic sweep")
+	for {
+		select {
+		case <-rc.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reaped, err := rc.reaper.Sweep(rc.ctx, rc.conf.DryRun)
+		entry := rc.log.WithField("tests", len(reaped))
+		if err != nil {
+			entry.WithField("error", err).Error("the sweep failed")
+			continue
+		}
+		entry.Debug("finished a sweep")
+	}
+}
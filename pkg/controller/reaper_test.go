@@ -0,0 +1,51 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	usecase "github.com/whiteblock/genesis/mocks/pkg/usecase"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReaperController_Failure(t *testing.T) {
+	rc, err := NewReaperController(config.Reaper{}, nil, logrus.New())
+	assert.Nil(t, rc)
+	assert.Error(t, err)
+}
+
+func TestReaperController(t *testing.T) {
+	swept := make(chan bool, 10)
+	reaper := new(usecase.ReaperUseCase)
+	reaper.On("Sweep", mock.Anything, true).Return([]entity.TestResources{}, nil).Run(
+		func(_ mock.Arguments) { swept <- true })
+
+	rc, err := NewReaperController(config.Reaper{SweepInterval: 10 * time.Millisecond, DryRun: true},
+		reaper, logrus.New())
+	require.NoError(t, err)
+
+	stopped := make(chan error)
+	go func() { stopped <- rc.Start() }()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-swept:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the sweep did not happen within 5 seconds")
+		}
+	}
+	require.NoError(t, rc.Stop(context.Background()))
+	assert.NoError(t, <-stopped)
+}
@@ -7,6 +7,7 @@
 package controller
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -14,20 +15,25 @@ import (
 	"github.com/whiteblock/genesis/pkg/handler"
 	"github.com/whiteblock/genesis/pkg/helper"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
 //RestController handles the REST API server
 type RestController interface {
-	//Start attempts to start the server
-	Start()
+	//Start attempts to start the server, it does not return until Stop is called or the server fails
+	Start() error
+	//Stop gracefully shuts down the server, waiting for the runs in flight and open requests
+	//until ctx is done
+	Stop(ctx context.Context) error
 }
 
 type restController struct {
-	conf entity.RestConfig
-	hand handler.RestHandler
-	mux  helper.Router
-	log  logrus.Ext1FieldLogger
+	conf   entity.RestConfig
+	hand   handler.RestHandler
+	mux    helper.Router
+	log    logrus.Ext1FieldLogger
+	server *http.Server
 }
 
 //NewRestController creates a new rest controller
@@ -38,17 +44,69 @@ func NewRestController(
 	log logrus.Ext1FieldLogger) RestController {
 
 	log.Trace("creating a new rest controller")
-	return &restController{conf: conf, hand: hand, mux: mux, log: log}
+	return &restController{conf: conf, hand: hand, mux: mux, log: log,
+		server: &http.Server{Addr: conf.Listen}}
 }
 
-// Start starts the rest server, blocking the calling thread from returning
-func (rc restController) Start() {
+// Start starts the rest server, blocking the calling thread from returning until
+// Stop is called or the server fails
+func (rc *restController) Start() error {
 
 	rc.mux.HandleFunc("/command", rc.hand.AddCommands).Methods("POST")
+	rc.mux.HandleFunc("/command", rc.hand.GetCommands).Methods("GET")
+	rc.mux.HandleFunc("/command/{id}", rc.hand.GetCommand).Methods("GET")
+	rc.mux.HandleFunc("/command/{id}", rc.hand.CancelCommand).Methods("DELETE")
+	rc.mux.HandleFunc("/test/sweep", rc.hand.Sweep).Methods("POST")
+	rc.mux.HandleFunc("/test/{id}", rc.hand.DestroyTest).Methods("DELETE")
 	rc.mux.HandleFunc("/health", rc.hand.HealthCheck).Methods("GET")
+	rc.mux.HandleFunc("/metrics", promhttp.Handler().ServeHTTP).Methods("GET")
 
-	rc.log.WithFields(logrus.Fields{"socket": rc.conf.Listen}).Info("listening for requests")
-	rc.log.Fatal(http.ListenAndServe(rc.conf.Listen, removeTrailingSlash(rc.mux)))
+	var tokens []string
+	if len(rc.conf.AuthTokenPath) > 0 {
+		var err error
+		tokens, err = readTokens(rc.conf.AuthTokenPath)
+		if err != nil {
+			return err
+		}
+	}
+	tlsConfig, err := getTLSConfig(rc.conf)
+	if err != nil {
+		return err
+	}
+	rc.server.Handler = removeTrailingSlash(authenticate(tlsConfig != nil, tokens, rc.mux))
+	rc.server.TLSConfig = tlsConfig
+
+	rc.log.WithFields(logrus.Fields{
+		"socket":     rc.conf.Listen,
+		"tls":        len(rc.conf.CertPath) > 0,
+		"clientCert": tlsConfig != nil,
+		"tokenAuth":  len(tokens) > 0,
+	}).Info("listening for requests")
+	if len(rc.conf.CertPath) > 0 {
+		err = rc.server.ListenAndServeTLS(rc.conf.CertPath, rc.conf.KeyPath)
+	} else {
+		err = rc.server.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Stop gracefully shuts down the server, waiting for the runs in flight and then open requests
+// until ctx is done, after which the remaining runs are interrupted and connections are closed
+func (rc *restController) Stop(ctx context.Context) error {
+	rc.log.Info("shutting down the rest server")
+	err := rc.hand.Stop(ctx)
+	if err != nil {
+		rc.log.WithField("error", err).Warn("failed to finish the runs in flight")
+	}
+	err = rc.server.Shutdown(ctx)
+	if err != nil {
+		rc.log.WithField("error", err).Warn("failed to shut down gracefully, closing the rest server")
+		rc.server.Close()
+	}
+	return err
 }
 
 func removeTrailingSlash(next http.Handler) http.Handler {
@@ -7,14 +7,46 @@
 package controller
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	handler "github.com/whiteblock/genesis/mocks/pkg/handler"
 	"github.com/whiteblock/genesis/pkg/entity"
 
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRestController(t *testing.T) {
 	assert.NotNil(t, NewRestController(entity.RestConfig{}, nil, nil, logrus.New()))
 }
+
+func TestRestController_Stop(t *testing.T) {
+	hand := new(handler.RestHandler)
+	hand.On("Stop", mock.Anything).Return(nil).Once()
+	rc := NewRestController(entity.RestConfig{Listen: "127.0.0.1:0"},
+		hand, mux.NewRouter(), logrus.New())
+
+	startErr := make(chan error)
+	go func() { startErr <- rc.Start() }()
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, rc.Stop(context.Background()))
+	select {
+	case err := <-startErr:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server did not stop")
+	}
+	hand.AssertExpectations(t)
+}
+
+func TestRestController_Start_Failure(t *testing.T) {
+	rc := NewRestController(entity.RestConfig{Listen: "127.0.0.1:0", AuthTokenPath: "/does/not/exist"},
+		new(handler.RestHandler), mux.NewRouter(), logrus.New())
+	assert.Error(t, rc.Start())
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -46,21 +47,53 @@ type Client interface {
 	// ContainerExecStart starts an exec process already created in the docker host.
 	ContainerExecStart(ctx context.Context, execID string, config types.ExecStartCheck) error
 
+	// ContainerKill terminates the container process but does not remove the container from the docker host.
+	ContainerKill(ctx context.Context, containerID, signal string) error
+
 	// ContainerInspect returns the container information.
 	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
 
 	// ContainerList returns the list of containers in the docker host.
 	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
 
+	// ContainerLogs returns the logs generated by a container in an io.ReadCloser.
+	// It's up to the caller to close the stream.
+	ContainerLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error)
+
+	// ContainerPause pauses the main process of a given container without terminating it.
+	ContainerPause(ctx context.Context, containerID string) error
+
 	// ContainerRemove kills and removes a container from the docker host.
 	ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error
 
+	// ContainerRestart stops and starts a container again. It makes the daemon to wait for the
+	// container to be up again for a specific amount of time, given the timeout.
+	ContainerRestart(ctx context.Context, containerID string, timeout *time.Duration) error
+
 	// ContainerStart sends a request to the docker daemon to start a container.
 	ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error
 
+	// ContainerStats returns near realtime stats for a given container.
+	// It's up to the caller to close the io.ReadCloser returned.
+	ContainerStats(ctx context.Context, container string, stream bool) (types.ContainerStats, error)
+
 	// ContainerStatPath returns Stat information about a path inside the container filesystem.
 	ContainerStatPath(ctx context.Context, containerID, path string) (types.ContainerPathStat, error)
 
+	// ContainerStop stops a container. In case the container fails to stop gracefully within a
+	// time frame specified by the timeout argument, it is forcefully terminated (killed).
+	ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error
+
+	// ContainerUnpause resumes the process execution within the container
+	ContainerUnpause(ctx context.Context, containerID string) error
+
+	// ContainerWait waits until the specified container is in a certain state indicated by the given
+	// condition, either "not-running" (default), "next-exit", or "removed". It blocks until the
+	// request has been acknowledged by the server, then returns two channels on which the caller can
+	// wait for the exit status of the container or an error.
+	ContainerWait(ctx context.Context, containerID string,
+		condition container.WaitCondition) (<-chan container.ContainerWaitOKBody, <-chan error)
+
 	// CopyToContainer copies content into the container filesystem. Note that `content` must be a Reader for a TAR archive
 	CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader,
 		options types.CopyToContainerOptions) error
@@ -0,0 +1,79 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"strings"
+
+	"github.com/whiteblock/definition/command"
+)
+
+const (
+	// ClearEmulationOrder is the order type for removing the network emulation of a container
+	// on a network
+	ClearEmulationOrder command.OrderType = "clearemulation"
+
+	// ReadEmulationOrder is the order type for reading back the queueing disciplines of a
+	// container on a network
+	ReadEmulationOrder command.OrderType = "reademulation"
+
+	// PeerEmulationOrder is the order type for applying network conditions to the traffic
+	// between containers and each of their peers
+	PeerEmulationOrder command.OrderType = "peeremulation"
+)
+
+// EmulationTarget is the payload of the clearEmulation and readEmulation orders
+type EmulationTarget struct {
+	// Container is the name of the container
+	Container string `json:"container"`
+	// Network is the name of the network, whose interface in the container is targeted
+	Network string `json:"network"`
+}
+
+// PeerEmulation is the payload of the peerEmulation order
+type PeerEmulation struct {
+	// Network is the name of the network the conditions are applied on
+	Network string `json:"network"`
+	// Matrix maps each container to the conditions of its traffic to each of its peers. A peer is
+	// either the name of a container on the network, or an IP address. The container and network
+	// of the conditions are ignored. Traffic to any other address is left as is.
+	Matrix map[string]map[string]command.Netconf `json:"matrix"`
+}
+
+// Qdisc is a queueing discipline of a network interface, as reported by tc
+type Qdisc struct {
+	// Kind is the kind of qdisc, such as netem
+	Kind string `json:"kind"`
+	// Handle is the handle of the qdisc, such as 8001:
+	Handle string `json:"handle"`
+	// Parent is either root, or the handle of the parent class
+	Parent string `json:"parent"`
+	// Options are the rest of the settings of the qdisc, such as "limit 1000 delay 100us"
+	Options string `json:"options"`
+}
+
+// ParseQdiscs parses the output of tc qdisc show
+func ParseQdiscs(out string) []Qdisc {
+	qdiscs := []Qdisc{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "qdisc" {
+			continue
+		}
+		qdisc := Qdisc{Kind: fields[1], Handle: fields[2]}
+		rest := fields[3:]
+		switch {
+		case rest[0] == "root":
+			qdisc.Parent, rest = "root", rest[1:]
+		case rest[0] == "parent" && len(rest) > 1:
+			qdisc.Parent, rest = rest[1], rest[2:]
+		}
+		qdisc.Options = strings.Join(rest, " ")
+		qdiscs = append(qdiscs, qdisc)
+	}
+	return qdiscs
+}
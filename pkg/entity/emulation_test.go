@@ -0,0 +1,25 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQdiscs(t *testing.T) {
+	qdiscs := ParseQdiscs("qdisc netem 8001: root refcnt 2 limit 1000 delay 100us loss 1%\n" +
+		"qdisc pfifo 10: parent 1:1 limit 100p\n" +
+		"RTNETLINK answers: Operation not permitted\n\n")
+	assert.Equal(t, []Qdisc{
+		{Kind: "netem", Handle: "8001:", Parent: "root", Options: "refcnt 2 limit 1000 delay 100us loss 1%"},
+		{Kind: "pfifo", Handle: "10:", Parent: "1:1", Options: "limit 100p"},
+	}, qdiscs)
+
+	assert.Empty(t, ParseQdiscs(""))
+}
@@ -6,7 +6,14 @@
 
 package entity
 
-import "time"
+import (
+	"time"
+
+	"github.com/whiteblock/definition/command"
+)
+
+// ExecOrder is the order type for running a command in a container and returning its output
+const ExecOrder command.OrderType = "exec"
 
 // Exec contains the information for an exec call
 type Exec struct {
@@ -15,3 +22,31 @@ type Exec struct {
 	Retries    int
 	Delay      time.Duration
 }
+
+// ExecCommand is the payload of the exec order
+type ExecCommand struct {
+	// Container is the name of the container to run the command in
+	Container string `json:"container"`
+	// Cmd is the command to run, along with its arguments
+	Cmd []string `json:"cmd"`
+	// Env is the environment variables to set for the command, besides those of the container
+	Env map[string]string `json:"env"`
+	// User is the user to run the command as, defaults to the user of the container
+	User string `json:"user"`
+	// Workdir is the directory to run the command in, defaults to the working directory of the container
+	Workdir string `json:"workdir"`
+	// Timeout is how long the command may run for. If it is not given, the command may run
+	// for as long as the order may.
+	Timeout command.Duration `json:"timeout"`
+	// ExitCode is the exit code the command is expected to exit with
+	ExitCode int `json:"exitCode"`
+}
+
+// ExecOutput is the outcome of a command run by an exec order
+type ExecOutput struct {
+	ExitCode int    `json:"exitCode"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	// Truncated is true if stdout or stderr was cut short at the size limit
+	Truncated bool `json:"truncated"`
+}
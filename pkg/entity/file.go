@@ -0,0 +1,80 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"net/url"
+	"path"
+
+	"github.com/whiteblock/definition/command"
+)
+
+// File is a file which is placed inside a container. Its ID is either the ID of the file in the
+// files API or, if it has a scheme, the URI of the file.
+type File struct {
+	command.File
+	// Headers are added to the request for the file, when it is fetched over http or https
+	Headers map[string]string `json:"headers,omitempty"`
+	// Sha256 is the expected sha256 digest of the file, in hex. The file is not placed in the
+	// container if it does not match. For a template, it is the digest of the template.
+	Sha256 string `json:"sha256,omitempty"`
+	// Template makes the file be rendered as a Go template, with a TemplateContext, before it is
+	// placed in the container
+	Template bool `json:"template,omitempty"`
+}
+
+// Filename gets the name of the file, which is the one in its meta, or else the last element of the
+// path of its URI. It is empty if the file has neither.
+func (f File) Filename() string {
+	if len(f.Meta.Filename) > 0 {
+		return f.Meta.Filename
+	}
+	uri, err := url.Parse(f.ID)
+	if err != nil || len(uri.Scheme) == 0 || len(uri.Path) == 0 {
+		return ""
+	}
+	name := path.Base(uri.Path)
+	if name == "/" || name == "." {
+		return ""
+	}
+	return name
+}
+
+// FileAndContainer is the payload of the putFileInContainer order
+type FileAndContainer struct {
+	// ContainerName is the name of the container to place the file in
+	ContainerName string `json:"container"`
+	// File is the file to place in the container
+	File File `json:"file"`
+}
+
+// FileCopy is the outcome of placing a file in a container
+type FileCopy struct {
+	// Sha256 is the sha256 digest of the file which was copied, in hex
+	Sha256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// TemplateContainer is a container, as it is seen by templates
+type TemplateContainer struct {
+	Name string
+	// IP is the address of the container on the first of its networks, by name
+	IP string
+	// IPs are the addresses of the container, keyed by the name of their network
+	IPs map[string]string
+}
+
+// TemplateContext is what files are rendered with, when they are templates
+type TemplateContext struct {
+	// Labels are the labels of the command, such as the ID of its test
+	Labels map[string]string
+	// Container is the container the file is placed in
+	Container TemplateContainer
+	// Peers are the other containers on the networks of the test which the container is on,
+	// sorted by name. Their IPs are only those on the networks they share with the container.
+	Peers []TemplateContainer
+}
@@ -0,0 +1,30 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/whiteblock/definition/command"
+	"github.com/whiteblock/utility/common"
+)
+
+func TestFile_Filename(t *testing.T) {
+	for id, name := range map[string]string{
+		"2f0d8aa4-2ffb-4e2a-a4ff-5b66d1de5e24":       "",
+		"https://example.com/files/genesis.json?v=1": "genesis.json",
+		"s3://bucket/chain/snapshot.tar":             "snapshot.tar",
+		"file:///etc/genesis/":                       "genesis",
+		"https://example.com":                        "",
+		"data:;base64,aGVsbG8=":                      "",
+	} {
+		assert.Equal(t, name, File{File: command.File{ID: id}}.Filename(), id)
+	}
+	file := File{File: command.File{ID: "s3://bucket/key", Meta: common.Metadata{Filename: "meta.json"}}}
+	assert.Equal(t, "meta.json", file.Filename())
+}
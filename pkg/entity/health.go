@@ -0,0 +1,75 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"github.com/docker/docker/api/types/container"
+	"github.com/whiteblock/definition/command"
+)
+
+// Healthcheck is a command which docker periodically runs in a container to check that it is healthy
+type Healthcheck struct {
+	// Command is the command to run, along with its arguments. It is run through the shell of the
+	// container if it starts with CMD-SHELL, and directly otherwise. NONE disables the healthcheck
+	// of the image.
+	Command []string `json:"command"`
+	// Interval is the time to wait between checks. Defaults to the docker default of 30s.
+	Interval command.Duration `json:"interval"`
+	// Timeout is how long a check may run for before it is considered to have failed. Defaults
+	// to the docker default of 30s.
+	Timeout command.Duration `json:"timeout"`
+	// StartPeriod is the time the container has to start up, during which failed checks are not
+	// counted towards the retries
+	StartPeriod command.Duration `json:"startPeriod"`
+	// Retries is the number of consecutive failed checks needed to consider the container unhealthy.
+	// Defaults to the docker default of 3.
+	Retries int `json:"retries"`
+}
+
+// HealthConfig gets the healthcheck in the form expected by docker
+func (hc Healthcheck) HealthConfig() *container.HealthConfig {
+	test := hc.Command
+	if len(test) > 0 {
+		switch test[0] {
+		case "NONE", "CMD", "CMD-SHELL":
+		default:
+			test = append([]string{"CMD"}, test...)
+		}
+	}
+	return &container.HealthConfig{
+		Test:        test,
+		Interval:    hc.Interval.Duration,
+		Timeout:     hc.Timeout.Duration,
+		StartPeriod: hc.StartPeriod.Duration,
+		Retries:     hc.Retries,
+	}
+}
+
+// Container is the payload of the createContainer order
+type Container struct {
+	command.Container
+	// Healthcheck overrides the healthcheck of the image, if given
+	Healthcheck *Healthcheck `json:"healthcheck,omitempty"`
+}
+
+// StartContainer is the payload of the startContainer order. With attach, the order waits for the
+// container to exit, for at most the timeout, and fails if it exits with a non-zero exit code.
+type StartContainer struct {
+	command.StartContainer
+	// Healthy makes the order wait until the container reports that it is healthy, for at most
+	// the timeout. It cannot be combined with attach.
+	Healthy bool `json:"healthy"`
+	// AllowFailure makes an attached container exiting with a non-zero exit code not fail the order
+	AllowFailure bool `json:"allowFailure"`
+}
+
+// ContainerExit is the outcome of an attached container
+type ContainerExit struct {
+	ExitCode int `json:"exitCode"`
+	// Logs are the last lines logged by the container, if it failed
+	Logs string `json:"logs,omitempty"`
+}
@@ -0,0 +1,45 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"github.com/whiteblock/definition/command"
+)
+
+const (
+	// StopContainerOrder is the order type for stopping a container, without removing it
+	StopContainerOrder command.OrderType = "stopcontainer"
+
+	// RestartContainerOrder is the order type for restarting a container
+	RestartContainerOrder command.OrderType = "restartcontainer"
+
+	// KillContainerOrder is the order type for sending a signal to a container
+	KillContainerOrder command.OrderType = "killcontainer"
+
+	// PauseContainerOrder is the order type for pausing the processes of a container
+	PauseContainerOrder command.OrderType = "pausecontainer"
+
+	// UnpauseContainerOrder is the order type for resuming the processes of a paused container
+	UnpauseContainerOrder command.OrderType = "unpausecontainer"
+)
+
+// StopContainer is the payload of the stopContainer and restartContainer orders
+type StopContainer struct {
+	// Name is the name of the container
+	Name string `json:"name"`
+	// Timeout is how long to wait for the container to exit before killing it. If it is not
+	// given, the stop timeout of the container is used.
+	Timeout command.Duration `json:"timeout"`
+}
+
+// KillContainer is the payload of the killContainer order
+type KillContainer struct {
+	// Name is the name of the container
+	Name string `json:"name"`
+	// Signal is the signal to send, such as SIGKILL, SIGTERM or SIGSTOP. Defaults to SIGKILL.
+	Signal string `json:"signal"`
+}
@@ -0,0 +1,23 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"time"
+)
+
+// LogLine is a line logged by a container
+type LogLine struct {
+	// Container is the name of the container which logged the line
+	Container string `json:"container"`
+	// Labels are the labels of the container which are passed on to its log driver
+	Labels map[string]string `json:"labels"`
+	// Stream is the stream the line was logged to, stdout or stderr
+	Stream string    `json:"stream"`
+	Time   time.Time `json:"time"`
+	Line   string    `json:"line"`
+}
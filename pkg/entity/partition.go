@@ -0,0 +1,28 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"github.com/whiteblock/definition/command"
+)
+
+const (
+	// PartitionOrder is the order type for blocking the traffic between groups of containers
+	PartitionOrder command.OrderType = "partition"
+
+	// HealOrder is the order type for removing what a partition order put in place
+	HealOrder command.OrderType = "heal"
+)
+
+// Partition is the payload of the partition and heal orders
+type Partition struct {
+	// Network is the name of the network the traffic is blocked on
+	Network string `json:"network"`
+	// Groups are the names of the containers in each group. Containers can only reach the
+	// containers in their own group, and those which are not in any group.
+	Groups [][]string `json:"groups"`
+}
@@ -0,0 +1,47 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"github.com/whiteblock/definition/command"
+)
+
+// DockerCall is a call to the docker API, as it would be made by the docker service
+type DockerCall struct {
+	// Host is the docker host the call is made against
+	Host string `json:"host"`
+	// Method is the name of the docker client method called
+	Method string `json:"method"`
+	// Args are the arguments of the call, by name
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// PlannedCommand is a command of a plan, along with what executing it would do
+type PlannedCommand struct {
+	ID     string            `json:"id"`
+	Order  command.OrderType `json:"order"`
+	Target string            `json:"target"`
+	// Calls are the docker API calls executing the command would make, in order
+	Calls []DockerCall `json:"calls"`
+	// Result is the result the command would have
+	Result Result `json:"result"`
+}
+
+// PlannedRound is a round of a plan
+type PlannedRound struct {
+	Round    int              `json:"round"`
+	Commands []PlannedCommand `json:"commands"`
+}
+
+// Plan is what executing a set of instructions would do, worked out without touching docker
+type Plan struct {
+	// Valid is false if a round of the instructions would fail
+	Valid bool `json:"valid"`
+	// Rounds are the rounds which would execute. A plan stops at the first round
+	// which would fail or trap.
+	Rounds []PlannedRound `json:"rounds"`
+}
@@ -0,0 +1,64 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"time"
+
+	"github.com/whiteblock/definition/command"
+)
+
+// DestroyTestOrder is the order type for removing every resource belonging to a test from a host
+const DestroyTestOrder command.OrderType = "destroytest"
+
+// DestroyTest is the payload of a destroyTest order
+type DestroyTest struct {
+	// TestID is the id of the test whose resources are to be removed. If empty, the
+	// test id in the meta of the command is used.
+	TestID string `json:"testID,omitempty"`
+
+	// DryRun causes the resources to only be listed, rather than removed
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// TestResources are the docker resources on a host which are labeled as belonging to a test
+type TestResources struct {
+	// TestID is the id of the test the resources belong to
+	TestID string `json:"testID"`
+
+	// Host is the host the resources are on
+	Host string `json:"host"`
+
+	// Containers are the names of the containers belonging to the test
+	Containers []string `json:"containers"`
+
+	// Networks are the names of the networks belonging to the test
+	Networks []string `json:"networks"`
+
+	// Volumes are the names of the volumes belonging to the test
+	Volumes []string `json:"volumes"`
+
+	// Created is when the newest of the resources was created, if it is known
+	Created time.Time `json:"created"`
+}
+
+// NewTestResources creates an empty TestResources for the given test
+func NewTestResources(testID string) TestResources {
+	return TestResources{TestID: testID, Containers: []string{}, Networks: []string{}, Volumes: []string{}}
+}
+
+// AddCreated updates Created with the creation time of one of the resources
+func (tr *TestResources) AddCreated(created time.Time) {
+	if created.After(tr.Created) {
+		tr.Created = created
+	}
+}
+
+// IsEmpty checks whether there are no resources
+func (tr TestResources) IsEmpty() bool {
+	return len(tr.Containers) == 0 && len(tr.Networks) == 0 && len(tr.Volumes) == 0
+}
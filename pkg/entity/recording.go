@@ -0,0 +1,24 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RecordedCall is a call to the docker API made on behalf of a test, along with its outcome
+type RecordedCall struct {
+	DockerCall
+	// Test is the id of the test the call was made for
+	Test string    `json:"test"`
+	Time time.Time `json:"time"`
+	// Result is what the call returned, if it returns anything besides an error
+	Result json.RawMessage `json:"result,omitempty"`
+	// Error is the error the call returned, if any
+	Error string `json:"error,omitempty"`
+}
@@ -10,4 +10,14 @@ package entity
 type RestConfig struct {
 	//Listen is the socket to listen on
 	Listen string `json:"listen"`
+	//CertPath is the path to the TLS certificate, TLS is disabled if empty
+	CertPath string `json:"certPath"`
+	//KeyPath is the path to the TLS private key
+	KeyPath string `json:"keyPath"`
+	//ClientCACertPath is the path to the CA certificate for verifying client certificates,
+	//client certificates are not required if empty
+	ClientCACertPath string `json:"clientCACertPath"`
+	//AuthTokenPath is the path to the file containing the accepted bearer tokens, one per line.
+	//Bearer token auth is disabled if empty
+	AuthTokenPath string `json:"authTokenPath"`
 }
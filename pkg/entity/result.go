@@ -8,6 +8,7 @@ package entity
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"runtime"
 	"time"
@@ -36,6 +37,16 @@ type Result struct {
 	Delay time.Duration
 }
 
+const (
+	// OutputKey is the meta key under which a command places what it outputs, such as the
+	// settings it reads back
+	OutputKey = "output"
+
+	// OutputsKey is the meta key under which the outputs of the commands of a round are
+	// gathered, by command id
+	OutputsKey = "outputs"
+)
+
 // IsAllDone checks whether the request is completely finished. If true, then the completion
 // protocol should be followed
 func (res Result) IsAllDone() bool {
@@ -110,32 +121,52 @@ func (res Result) InjectMeta(meta map[string]interface{}) (out Result) {
 	return out
 }
 
-// MarshalJSON allows Result to customize the marshaling into JSON
-func (res Result) MarshalJSON() ([]byte, error) {
-	resType := ""
-	switch res.Type {
+// StatusMessage gets the message to report in the status of a run after this result: the error
+// of a failed result, or the outputs of the commands of a successful round, if there are any
+func (res Result) StatusMessage() string {
+	if !res.IsSuccess() {
+		return res.Error.Error()
+	}
+	outputs, ok := res.Meta[OutputsKey]
+	if !ok {
+		return ""
+	}
+	data, err := json.Marshal(outputs)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// String gets the name of the result type
+func (rt ResultType) String() string {
+	switch rt {
 	case SuccessType:
-		resType = "Success"
+		return "Success"
 	case AllDoneType:
-		resType = "AllDone"
+		return "AllDone"
 	case TooSoonType:
-		resType = "TooSoon"
+		return "TooSoon"
 	case FatalType:
-		resType = "Fatal"
+		return "Fatal"
 	case ErrorType:
-		resType = "Error"
+		return "Error"
 	case RequeueType:
-		resType = "Requeue"
+		return "Requeue"
 	case TrapType:
-		resType = "Trap"
+		return "Trap"
+	case IgnoreType:
+		return "Ignore"
 	case DelayType:
-		resType = "Delay"
-	default:
-		resType = "Unknown"
+		return "Delay"
 	}
+	return "Unknown"
+}
 
+// MarshalJSON allows Result to customize the marshaling into JSON
+func (res Result) MarshalJSON() ([]byte, error) {
 	jRes := map[string]interface{}{
-		"type":   resType,
+		"type":   res.Type.String(),
 		"meta":   res.Meta,
 		"caller": res.Caller,
 	}
@@ -147,6 +178,31 @@ func (res Result) MarshalJSON() ([]byte, error) {
 	return json.Marshal(jRes)
 }
 
+// UnmarshalJSON allows Result to be recovered from the JSON created by MarshalJSON
+func (res *Result) UnmarshalJSON(data []byte) error {
+	var jRes struct {
+		Type   string                 `json:"type"`
+		Meta   map[string]interface{} `json:"meta"`
+		Caller string                 `json:"caller"`
+		Error  *string                `json:"error"`
+	}
+	err := json.Unmarshal(data, &jRes)
+	if err != nil {
+		return err
+	}
+	*res = Result{Meta: jRes.Meta, Caller: jRes.Caller}
+	if jRes.Error != nil {
+		res.Error = errors.New(*jRes.Error)
+	}
+	for rt := SuccessType; rt <= DelayType; rt++ {
+		if rt.String() == jRes.Type {
+			res.Type = rt
+			break
+		}
+	}
+	return nil
+}
+
 const (
 	//SuccessType is the type of a successful result
 	SuccessType ResultType = iota + 1
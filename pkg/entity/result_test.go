@@ -7,6 +7,7 @@
 package entity
 
 import (
+	"encoding/json"
 	"errors"
 	"strconv"
 	"testing"
@@ -161,3 +162,38 @@ func TestNewErrorResult(t *testing.T) {
 func TestNewAllDoneResult(t *testing.T) {
 	assert.True(t, NewAllDoneResult().IsAllDone())
 }
+
+func TestResult_JSON(t *testing.T) {
+	var tests = []Result{
+		NewSuccessResult(),
+		NewAllDoneResult(),
+		NewFatalResult("fatal").InjectMeta(map[string]interface{}{"foo": "bar"}),
+		NewErrorResult("error"),
+		NewIgnoreResult("ignore"),
+	}
+
+	for i, term := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			data, err := json.Marshal(term)
+			assert.NoError(t, err)
+
+			var res Result
+			assert.NoError(t, json.Unmarshal(data, &res))
+			assert.Equal(t, term.Type, res.Type)
+			assert.Equal(t, term.Meta, res.Meta)
+			assert.Equal(t, term.Caller, res.Caller)
+			assert.Equal(t, term.IsSuccess(), res.IsSuccess())
+			if !term.IsSuccess() {
+				assert.Equal(t, term.Error.Error(), res.Error.Error())
+			}
+		})
+	}
+}
+
+func TestResult_StatusMessage(t *testing.T) {
+	assert.Equal(t, "", NewSuccessResult().StatusMessage())
+	assert.Equal(t, "error", NewErrorResult("error").StatusMessage())
+	res := NewSuccessResult().InjectMeta(map[string]interface{}{OutputsKey: map[string]interface{}{
+		"cmd0": ExecOutput{Stdout: "0x1"}}})
+	assert.Equal(t, `{"cmd0":{"exitCode":0,"stdout":"0x1","stderr":"","truncated":false}}`, res.StatusMessage())
+}
@@ -0,0 +1,97 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/whiteblock/definition/command"
+	"github.com/whiteblock/utility/common"
+)
+
+// MaxRounds is the number of the most recent rounds whose results are kept in a run
+const MaxRounds = 100
+
+// Run represents a set of instructions submitted for execution, along with its progress
+type Run struct {
+	// ID is the unique identifier for this run
+	ID string `json:"id"`
+
+	// Status is the current status of the run
+	Status common.Status `json:"status"`
+
+	// Result is the result of the last round executed, nil if no rounds have executed yet
+	Result *Result `json:"result,omitempty"`
+
+	// Rounds contains the result of the last MaxRounds rounds executed, including retries
+	Rounds []Result `json:"rounds"`
+
+	// DroppedRounds is the number of older rounds which were dropped from Rounds
+	DroppedRounds int `json:"droppedRounds,omitempty"`
+
+	// Instructions are the instructions which remain to be executed
+	Instructions command.Instructions `json:"instructions"`
+
+	// Started is the time at which the run was accepted
+	Started time.Time `json:"started"`
+
+	// Ended is the time at which the run finished, zero if it is still running
+	Ended time.Time `json:"ended"`
+}
+
+// NewRun creates a new Run for the given instructions
+func NewRun(id string, inst command.Instructions) Run {
+	return Run{ID: id, Status: inst.Status(), Rounds: []Result{}, Started: time.Now(),
+		Instructions: copyInstructions(inst)}
+}
+
+// copyInstructions creates a deep copy of the given instructions, as they are modified in
+// place as they are executed
+func copyInstructions(inst command.Instructions) command.Instructions {
+	data, err := json.Marshal(inst)
+	if err != nil {
+		return inst
+	}
+	var out command.Instructions
+	if json.Unmarshal(data, &out) != nil {
+		return inst
+	}
+	return out
+}
+
+// Duration gets how long the run has been running for, or how long it ran for if it has finished
+func (run Run) Duration() time.Duration {
+	if run.Ended.IsZero() {
+		return time.Since(run.Started)
+	}
+	return run.Ended.Sub(run.Started)
+}
+
+// Update updates the run with the current state of the instructions and the
+// result of the latest round
+func (run *Run) Update(inst command.Instructions, res Result) {
+	run.Status = inst.Status()
+	run.Instructions = copyInstructions(inst)
+	if res.IsAllDone() || res.IsTrap() || res.IsFatal() || res.IsIgnore() {
+		run.Status.Finished = true
+		run.Status.StepsLeft = 0
+		run.Ended = time.Now()
+	}
+	run.Status.Message = res.StatusMessage()
+	run.Result = &res
+	run.AddRound(res)
+}
+
+// AddRound adds the result of a round to Rounds, dropping the oldest rounds beyond MaxRounds
+func (run *Run) AddRound(res Result) {
+	run.Rounds = append(run.Rounds, res)
+	if extra := len(run.Rounds) - MaxRounds; extra > 0 {
+		run.Rounds = append([]Result{}, run.Rounds[extra:]...)
+		run.DroppedRounds += extra
+	}
+}
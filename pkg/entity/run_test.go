@@ -0,0 +1,30 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/whiteblock/definition/command"
+)
+
+func TestRun_Update_CapsRounds(t *testing.T) {
+	inst := command.Instructions{Commands: [][]command.Command{{{ID: "1"}}}}
+	run := NewRun("foo", inst)
+	for i := 0; i < MaxRounds+5; i++ {
+		run.Update(inst, NewErrorResult("err"))
+	}
+	run.Update(inst, NewAllDoneResult())
+
+	require.Len(t, run.Rounds, MaxRounds)
+	assert.Equal(t, 6, run.DroppedRounds)
+	assert.True(t, run.Rounds[MaxRounds-1].IsAllDone())
+	assert.True(t, run.Result.IsAllDone())
+	assert.True(t, run.Status.Finished)
+}
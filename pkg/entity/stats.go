@@ -0,0 +1,31 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"time"
+)
+
+// StatsSample is a sample of the resource usage of a container
+type StatsSample struct {
+	// Test is the id of the test the container belongs to
+	Test      string    `json:"test"`
+	Host      string    `json:"host"`
+	Container string    `json:"container"`
+	Time      time.Time `json:"time"`
+	// CPU is the cpu usage since the previous sample taken by the daemon, in percent of one cpu
+	CPU float64 `json:"cpu"`
+	// Memory is the memory used, in bytes, not counting the page cache
+	Memory      uint64 `json:"mem"`
+	MemoryLimit uint64 `json:"memLimit"`
+	// NetRx and NetTx are the bytes received and sent over every network
+	NetRx uint64 `json:"netRx"`
+	NetTx uint64 `json:"netTx"`
+	// BlockRead and BlockWrite are the bytes read from and written to block devices
+	BlockRead  uint64 `json:"blkRead"`
+	BlockWrite uint64 `json:"blkWrite"`
+}
@@ -0,0 +1,46 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"fmt"
+)
+
+// TeardownPolicy decides whether the resources created for a run are destroyed once it finishes
+type TeardownPolicy string
+
+const (
+	// TeardownAlways destroys the resources of every run which finishes
+	TeardownAlways TeardownPolicy = "always"
+	// TeardownOnSuccess destroys the resources of the runs which complete successfully
+	TeardownOnSuccess TeardownPolicy = "on-success"
+	// TeardownOnFailure destroys the resources of the runs which fail
+	TeardownOnFailure TeardownPolicy = "on-failure"
+	// TeardownNever leaves the resources of every run in place
+	TeardownNever TeardownPolicy = "never"
+)
+
+// Validate checks that the policy is one of the known policies
+func (tp TeardownPolicy) Validate() error {
+	switch tp {
+	case TeardownAlways, TeardownOnSuccess, TeardownOnFailure, TeardownNever:
+		return nil
+	}
+	return fmt.Errorf(`unknown teardown policy "%s"`, tp)
+}
+
+// Applies returns true if a run which finished with the given result should be torn down.
+// Runs which trapped are still running, and ignored runs have nothing to tear down.
+func (tp TeardownPolicy) Applies(res Result) bool {
+	switch {
+	case res.IsAllDone():
+		return tp == TeardownAlways || tp == TeardownOnSuccess
+	case res.IsFatal():
+		return tp == TeardownAlways || tp == TeardownOnFailure
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package entity
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeardownPolicy_Validate(t *testing.T) {
+	for _, tp := range []TeardownPolicy{TeardownAlways, TeardownOnSuccess, TeardownOnFailure, TeardownNever} {
+		assert.NoError(t, tp.Validate())
+	}
+	assert.Error(t, TeardownPolicy("sometimes").Validate())
+	assert.Error(t, TeardownPolicy("").Validate())
+}
+
+func TestTeardownPolicy_Applies(t *testing.T) {
+	done := NewAllDoneResult()
+	fatal := NewFatalResult(fmt.Errorf("err"))
+	trap := NewFatalResult(fmt.Errorf("err")).Trap()
+	ignore := NewIgnoreResult(fmt.Errorf("err"))
+
+	var tests = []struct {
+		policy   TeardownPolicy
+		expected []bool // done, fatal, trap, ignore
+	}{
+		{policy: TeardownAlways, expected: []bool{true, true, false, false}},
+		{policy: TeardownOnSuccess, expected: []bool{true, false, false, false}},
+		{policy: TeardownOnFailure, expected: []bool{false, true, false, false}},
+		{policy: TeardownNever, expected: []bool{false, false, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			for i, res := range []Result{done, fatal, trap, ignore} {
+				assert.Equal(t, tt.expected[i], tt.policy.Applies(res), res.Type.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package file
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+)
+
+// apiSource gets files by their ID from the files API
+type apiSource struct {
+	fetcher
+	endpoint string
+}
+
+// NewAPISource creates a source for the files of the files API
+func NewAPISource(conf config.FileHandler) Source {
+	return apiSource{fetcher: newFetcher(conf.APITimeout), endpoint: conf.APIEndpoint}
+}
+
+// request creates a request for the file of the given definition, with the given method
+func (as apiSource) request(method string, testnetID string,
+	file entity.File) func(ctx context.Context) (*http.Request, error) {
+
+	return func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, method,
+			fmt.Sprintf("%s/api/v1/files/definitions/%s/%s", as.endpoint, testnetID, file.ID), nil)
+	}
+}
+
+func (as apiSource) Open(testnetID string, file entity.File) (io.ReadCloser, int64, error) {
+	return as.fetch(file.ID, as.request("GET", testnetID, file))
+}
+
+func (as apiSource) Stat(testnetID string, file entity.File) (int64, error) {
+	return as.stat(file.ID, as.request("HEAD", testnetID, file))
+}
@@ -0,0 +1,66 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package file
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+)
+
+// dataSource gets the content of files inlined in their data URI, such as
+// data:;base64,aGVsbG8= or data:text/plain,hello%20world
+type dataSource struct{}
+
+// NewDataSource creates a source for files given as data URIs
+func NewDataSource() Source {
+	return dataSource{}
+}
+
+// decode gets the content inlined in the data URI of the file
+func (ds dataSource) decode(file entity.File) ([]byte, error) {
+	uri := strings.TrimPrefix(file.ID, SchemeData+":")
+	comma := strings.IndexByte(uri, ',')
+	if comma == -1 {
+		return nil, fmt.Errorf("%w: the data URI is missing a comma", ErrInvalidSource)
+	}
+	var data []byte
+	var err error
+	if strings.HasSuffix(uri[:comma], ";base64") {
+		data, err = base64.StdEncoding.DecodeString(uri[comma+1:])
+	} else {
+		var text string
+		text, err = url.PathUnescape(uri[comma+1:])
+		data = []byte(text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSource, err)
+	}
+	return data, nil
+}
+
+func (ds dataSource) Open(_ string, file entity.File) (io.ReadCloser, int64, error) {
+	data, err := ds.decode(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+func (ds dataSource) Stat(_ string, file entity.File) (int64, error) {
+	data, err := ds.decode(file)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
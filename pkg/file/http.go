@@ -0,0 +1,48 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package file
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+)
+
+// httpSource gets files from their http or https URL, with the headers of the file
+type httpSource struct {
+	fetcher
+}
+
+// NewHTTPSource creates a source for files served over http or https
+func NewHTTPSource(conf config.FileHandler) Source {
+	return httpSource{fetcher: newFetcher(conf.APITimeout)}
+}
+
+// request creates a request for the file, with the given method
+func (hs httpSource) request(method string, file entity.File) func(ctx context.Context) (*http.Request, error) {
+	return func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, file.ID, nil)
+		if err != nil {
+			return nil, err
+		}
+		for key, val := range file.Headers {
+			req.Header.Set(key, val)
+		}
+		return req, nil
+	}
+}
+
+func (hs httpSource) Open(_ string, file entity.File) (io.ReadCloser, int64, error) {
+	return hs.fetch(file.ID, hs.request("GET", file))
+}
+
+func (hs httpSource) Stat(_ string, file entity.File) (int64, error) {
+	return hs.stat(file.ID, hs.request("HEAD", file))
+}
@@ -0,0 +1,70 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package file
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+)
+
+// localSource gets files from the filesystem of the host Genesis runs on
+type localSource struct{}
+
+// NewLocalSource creates a source for the files on the host. It gives tests access to every file
+// Genesis can read, so it is only meant for local mode.
+func NewLocalSource() Source {
+	return localSource{}
+}
+
+// path gets the path of the file on the host
+func (ls localSource) path(file entity.File) (string, error) {
+	if scheme(file.ID) != SchemeFile {
+		return file.ID, nil
+	}
+	uri, err := url.Parse(file.ID)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidSource, err)
+	}
+	if uri.Host != "" && uri.Host != "localhost" {
+		return "", fmt.Errorf("%w: files can only be read from the local host, not %q",
+			ErrInvalidSource, uri.Host)
+	}
+	return uri.Path, nil
+}
+
+func (ls localSource) Open(_ string, file entity.File) (io.ReadCloser, int64, error) {
+	path, err := ls.path(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+func (ls localSource) Stat(_ string, file entity.File) (int64, error) {
+	path, err := ls.path(file)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -8,41 +8,63 @@ package file
 
 import (
 	"archive/tar"
-	"bytes"
-	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net/http"
-	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
 
 	"github.com/sirupsen/logrus"
-	"github.com/whiteblock/definition/command"
 )
 
 //RemoteSources represents a remote file source
 type RemoteSources interface {
-	GetTarReader(testnetID string, file command.File) (io.Reader, error)
+	// GetTarReader gets a tar archive containing the file, which fails if the file does not have
+	// its sha256, when it is given. The returned reader must be closed.
+	GetTarReader(testnetID string, file entity.File) (TarReader, error)
+	// GetTemplateTarReader gets a tar archive containing the file rendered as a Go template with
+	// the given context. The returned reader must be closed.
+	GetTemplateTarReader(testnetID string, file entity.File, data entity.TemplateContext) (TarReader, error)
+	// Stat looks the file up at its source without fetching it, and gets its size, which is -1
+	// if the source does not give it
+	Stat(testnetID string, file entity.File) (int64, error)
 }
 
 type remoteSources struct {
-	log  logrus.Ext1FieldLogger
-	conf config.Config
+	log     logrus.Ext1FieldLogger
+	conf    config.Config
+	sources map[string]Source
 }
 
-//NewRemoteSources creates a new instance of RemoteSources
+//NewRemoteSources creates a new instance of RemoteSources, with a source for each of the default
+//schemes. The file scheme is only available in local mode.
 func NewRemoteSources(conf config.Config, log logrus.Ext1FieldLogger) RemoteSources {
-	return &remoteSources{conf: conf, log: log}
+	sources := map[string]Source{
+		SchemeAPI:   NewAPISource(conf.FileHandler),
+		SchemeHTTP:  NewHTTPSource(conf.FileHandler),
+		SchemeHTTPS: NewHTTPSource(conf.FileHandler),
+		SchemeS3:    NewS3Source(conf.FileHandler),
+		SchemeData:  NewDataSource(),
+	}
+	if conf.LocalMode {
+		sources[SchemeAPI] = NewLocalSource()
+		sources[SchemeFile] = NewLocalSource()
+	}
+	return NewRemoteSourcesWithSources(conf, sources, log)
+}
+
+// NewRemoteSourcesWithSources creates a new instance of RemoteSources, which gets each file from
+// the source for the scheme of its ID
+func NewRemoteSourcesWithSources(conf config.Config, sources map[string]Source,
+	log logrus.Ext1FieldLogger) RemoteSources {
+	return &remoteSources{conf: conf, sources: sources, log: log}
 }
 
-func (rf remoteSources) getTarHeader(file command.File, size int64) *tar.Header {
+func (rf remoteSources) getTarHeader(file entity.File, size int64) *tar.Header {
 	name := filepath.Base(file.Destination)
 	if file.Destination[len(file.Destination)-1] == '/' {
-		name = filepath.Base(file.Meta.Filename)
+		name = filepath.Base(file.Filename())
 	}
 	rf.log.WithFields(logrus.Fields{
 		"name": name,
@@ -56,83 +78,69 @@ func (rf remoteSources) getTarHeader(file command.File, size int64) *tar.Header
 	}
 }
 
-func (rf remoteSources) getClient() *http.Client {
-	return &http.Client{Timeout: rf.conf.FileHandler.APITimeout}
-}
-
-func (rf remoteSources) getRequest(ctx context.Context, testnetID, id string) (*http.Request, error) {
-
-	return http.NewRequestWithContext(ctx, "GET",
-		fmt.Sprintf("%s/api/v1/files/definitions/%s/%s", rf.conf.FileHandler.APIEndpoint, testnetID, id),
-		strings.NewReader(""))
-}
-
-func (rf remoteSources) getContext() (context.Context, context.CancelFunc) {
-	if rf.conf.FileHandler.APITimeout.Nanoseconds() == 0 {
-		return context.WithCancel(context.Background())
+// getSource gets the source for the scheme of the file, along with a logger for the file
+func (rf remoteSources) getSource(testnetID string, file entity.File) (Source, logrus.Ext1FieldLogger, error) {
+	scheme := scheme(file.ID)
+	src, ok := rf.sources[scheme]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnsupportedScheme, scheme)
 	}
-	return context.WithTimeout(context.Background(), rf.conf.FileHandler.APITimeout)
+	return src, rf.log.WithFields(logrus.Fields{
+		"scheme":     scheme,
+		"file":       file.ID,
+		"dest":       file.Destination,
+		"definition": testnetID,
+	}), nil
 }
 
-func (rf remoteSources) getReader(testnetID string, file command.File) (io.ReadCloser, error) {
-	if rf.conf.LocalMode {
-		rf.log.Info("reading a file locally")
-		f, err := os.Open(file.ID)
-		return f, err
+// getReader gets the content of the file from the source for its scheme, along with its size,
+// which is -1 if it is not known
+func (rf remoteSources) getReader(testnetID string, file entity.File) (io.ReadCloser, int64, error) {
+	src, log, err := rf.getSource(testnetID, file)
+	if err != nil {
+		return nil, 0, err
 	}
-	client := rf.getClient()
-	ctx, cancel := rf.getContext()
-	defer cancel()
-	req, err := rf.getRequest(ctx, testnetID, file.ID)
+	rdr, size, err := src.Open(testnetID, file)
 	if err != nil {
-		return nil, err
+		log.WithField("error", err).Warn("failed to get a file")
+		return nil, 0, err
 	}
+	log.WithField("size", size).Debug("copying a file")
+	return rdr, size, nil
+}
 
-	resp, err := client.Do(req)
+// GetTarReader gets a tar archive containing the file, which is streamed from its source as the
+// archive is read. When the size of the file is not given by the source, the file is first spooled
+// to a temporary file. The returned reader must be closed.
+func (rf remoteSources) GetTarReader(testnetID string, file entity.File) (TarReader, error) {
+	fileReader, size, err := rf.getReader(testnetID, file)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != 200 {
-		rf.log.WithFields(logrus.Fields{
-			"file":       file.ID,
-			"dest":       file.Destination,
-			"code":       resp.StatusCode,
-			"definition": testnetID}).Warn("got back a non-200 http code")
-		res, _ := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		return nil, fmt.Errorf(string(res))
-
+	if size < 0 {
+		fileReader, size, err = spool(fileReader)
+		if err != nil {
+			return nil, err
+		}
 	}
-	rf.log.WithFields(logrus.Fields{
-		"file": file.ID, "Destination": file.Destination}).Debug("copying a file")
-	return resp.Body, nil
-
+	return newTarStream(fileReader, rf.getTarHeader(file, size), file.Sha256, rf.log.WithFields(logrus.Fields{
+		"file": file.ID,
+		"dest": file.Destination,
+	})), nil
 }
 
-// GetTarReader fetches the file from the file handler service and converts it to a tar reader
-func (rf remoteSources) GetTarReader(testnetID string, file command.File) (io.Reader, error) {
-	fileReader, err := rf.getReader(testnetID, file)
+// Stat looks the file up at the source for its scheme without fetching it, and gets its size,
+// which is -1 if the source does not give it
+func (rf remoteSources) Stat(testnetID string, file entity.File) (int64, error) {
+	src, log, err := rf.getSource(testnetID, file)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	defer fileReader.Close()
-	res, _ := ioutil.ReadAll(fileReader)
-	rdr := bytes.NewReader(res)
-
-	var buf bytes.Buffer
-	buf.Grow(len(res))
-	//might want to make a custom reader here for memory sake
-
-	tr := tar.NewWriter(&buf)
-	tr.WriteHeader(rf.getTarHeader(file, int64(len(res))))
-	n, err := io.Copy(tr, rdr)
-	rf.log.WithFields(logrus.Fields{
-		"file":  file.ID,
-		"dest":  file.Destination,
-		"bytes": n,
-		"error": err,
-	}).Info("copy has been completed")
-
-	return &buf, nil
-
+	size, err := src.Stat(testnetID, file)
+	if err != nil {
+		log.WithField("error", err).Warn("failed to look up a file")
+		return 0, err
+	}
+	log.WithField("size", size).Debug("looked up a file")
+	return size, nil
 }
@@ -0,0 +1,391 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package file
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/whiteblock/definition/command"
+	"github.com/whiteblock/utility/common"
+)
+
+func readTar(t *testing.T, rdr io.Reader) (*tar.Header, string, error) {
+	tr := tar.NewReader(rdr)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := ioutil.ReadAll(tr)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = tr.Next()
+	if err != io.EOF {
+		require.Error(t, err, "the archive has more than one file")
+		return nil, "", err
+	}
+	return hdr, string(data), nil
+}
+
+func testFile(id string, dest string) entity.File {
+	return entity.File{File: command.File{ID: id, Destination: dest}}
+}
+
+func withMode(file entity.File, mode int64) entity.File {
+	file.Mode = mode
+	return file
+}
+
+func tempFiles(t *testing.T) []string {
+	matches, err := filepath.Glob(filepath.Join(os.TempDir(), "genesis-file-*"))
+	require.NoError(t, err)
+	return matches
+}
+
+func TestRemoteSources_GetTarReader(t *testing.T) {
+	content := "the contents of the file"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/files/definitions/def0/sized":
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		case "/api/v1/files/definitions/def0/short":
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)+10))
+		case "/api/v1/files/definitions/def0/chunked":
+			w.(http.Flusher).Flush()
+		default:
+			http.Error(w, "no such file", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	rs := NewRemoteSources(config.Config{FileHandler: config.FileHandler{APIEndpoint: srv.URL,
+		APITimeout: time.Second}}, logrus.New())
+	before := tempFiles(t)
+
+	for _, id := range []string{"sized", "chunked"} {
+		rdr, err := rs.GetTarReader("def0", withMode(testFile(id, "/opt/genesis"), 0600))
+		require.NoError(t, err)
+		hdr, data, err := readTar(t, rdr)
+		require.NoError(t, err)
+		assert.Equal(t, "genesis", hdr.Name)
+		assert.Equal(t, int64(0600), hdr.Mode)
+		assert.Equal(t, int64(len(content)), hdr.Size)
+		assert.Equal(t, content, data)
+		assert.NoError(t, rdr.Close())
+	}
+	assert.Equal(t, before, tempFiles(t))
+
+	rdr, err := rs.GetTarReader("def0", testFile("short", "/opt/genesis"))
+	require.NoError(t, err)
+	_, _, err = readTar(t, rdr)
+	assert.Error(t, err)
+	assert.Equal(t, io.ErrUnexpectedEOF, rdr.Close())
+	assert.Empty(t, rdr.Sha256())
+
+	_, err = rs.GetTarReader("def0", testFile("missing", "/opt/genesis"))
+	assert.EqualError(t, err, `failed to get file "missing": 404 Not Found: no such file`)
+}
+
+func TestRemoteSources_GetTarReader_Close(t *testing.T) {
+	unblock := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1048576")
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(unblock)
+
+	rs := NewRemoteSources(config.Config{FileHandler: config.FileHandler{APIEndpoint: srv.URL,
+		APITimeout: 50 * time.Millisecond}}, logrus.New())
+	rdr, err := rs.GetTarReader("def0", testFile("big", "/opt/genesis"))
+	require.NoError(t, err)
+
+	// the body is not cut off by the API timeout
+	time.Sleep(100 * time.Millisecond)
+	buf := make([]byte, 512+len("partial"))
+	_, err = io.ReadFull(rdr, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "partial", string(buf[512:]))
+
+	assert.NoError(t, rdr.Close())
+	_, err = rdr.Read(buf)
+	assert.Error(t, err)
+}
+
+func TestRemoteSources_GetTarReader_Local(t *testing.T) {
+	f, err := ioutil.TempFile("", "genesis-test-")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("local")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	rs := NewRemoteSources(config.Config{LocalMode: true}, logrus.New())
+	rdr, err := rs.GetTarReader("def0", entity.File{File: command.File{ID: f.Name(), Destination: "/opt/",
+		Meta: common.Metadata{Filename: "local.txt"}}})
+	require.NoError(t, err)
+	hdr, data, err := readTar(t, rdr)
+	require.NoError(t, err)
+	assert.Equal(t, "local.txt", hdr.Name)
+	assert.Equal(t, "local", data)
+	assert.NoError(t, rdr.Close())
+
+	rdr, err = rs.GetTarReader("def0", testFile("file://"+f.Name(), "/opt/local"))
+	require.NoError(t, err)
+	_, data, err = readTar(t, rdr)
+	require.NoError(t, err)
+	assert.Equal(t, "local", data)
+	assert.NoError(t, rdr.Close())
+
+	_, err = rs.GetTarReader("def0", testFile(f.Name()+"-missing", "/opt/a"))
+	assert.Error(t, err)
+	_, err = rs.GetTarReader("def0", testFile("file://example.com"+f.Name(), "/opt/a"))
+	assert.True(t, errors.Is(err, ErrInvalidSource))
+
+	_, err = NewRemoteSources(config.Config{}, logrus.New()).GetTarReader("def0",
+		testFile("file://"+f.Name(), "/opt/a"))
+	assert.True(t, errors.Is(err, ErrUnsupportedScheme))
+}
+
+func TestRemoteSources_GetTarReader_HTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	rs := NewRemoteSources(config.Config{}, logrus.New())
+	file := testFile(srv.URL+"/files/genesis.json", "/opt/")
+	_, err := rs.GetTarReader("def0", file)
+	assert.EqualError(t, err, fmt.Sprintf(`failed to get file "%s/files/genesis.json": 401 Unauthorized: `+
+		"unauthorized", srv.URL))
+
+	file.Headers = map[string]string{"Authorization": "Bearer token"}
+	rdr, err := rs.GetTarReader("def0", file)
+	require.NoError(t, err)
+	hdr, data, err := readTar(t, rdr)
+	require.NoError(t, err)
+	assert.Equal(t, "genesis.json", hdr.Name)
+	assert.Equal(t, "/files/genesis.json", data)
+	assert.NoError(t, rdr.Close())
+}
+
+func TestRemoteSources_GetTarReader_Data(t *testing.T) {
+	rs := NewRemoteSources(config.Config{}, logrus.New())
+	for _, tc := range []struct {
+		id   string
+		data string
+		err  error
+	}{
+		{id: "data:;base64,aGVsbG8gd29ybGQ=", data: "hello world"},
+		{id: "data:application/octet-stream;base64,", data: ""},
+		{id: "data:text/plain,hello%20world", data: "hello world"},
+		{id: "DATA:,a,b", data: "a,b"},
+		{id: "data:;base64,not base64", err: ErrInvalidSource},
+		{id: "data:hello", err: ErrInvalidSource},
+	} {
+		rdr, err := rs.GetTarReader("def0", testFile(tc.id, "/opt/data"))
+		if tc.err != nil {
+			assert.True(t, errors.Is(err, tc.err), tc.id)
+			continue
+		}
+		require.NoError(t, err, tc.id)
+		_, data, err := readTar(t, rdr)
+		require.NoError(t, err)
+		assert.Equal(t, tc.data, data)
+		assert.NoError(t, rdr.Close())
+	}
+
+	_, err := rs.GetTarReader("def0", testFile("ftp://example.com/file", "/opt/data"))
+	assert.True(t, errors.Is(err, ErrUnsupportedScheme))
+}
+
+func TestRemoteSources_GetTarReader_Sha256(t *testing.T) {
+	rs := NewRemoteSources(config.Config{}, logrus.New())
+	file := testFile("data:,hello%20world", "/opt/data")
+	digest := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+
+	for _, expected := range []string{"", digest, strings.ToUpper(digest)} {
+		file.Sha256 = expected
+		rdr, err := rs.GetTarReader("def0", file)
+		require.NoError(t, err)
+		_, data, err := readTar(t, rdr)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", data)
+		assert.NoError(t, rdr.Close())
+		assert.Equal(t, digest, rdr.Sha256())
+		assert.Equal(t, int64(len("hello world")), rdr.Size())
+	}
+
+	file.Sha256 = strings.Repeat("0", 64)
+	rdr, err := rs.GetTarReader("def0", file)
+	require.NoError(t, err)
+	_, _, err = readTar(t, rdr)
+	assert.True(t, errors.Is(err, ErrDigestMismatch))
+	err = rdr.Close()
+	assert.EqualError(t, err, "sha256 mismatch: the file has sha256 "+digest+", instead of "+file.Sha256)
+	assert.Equal(t, err, rdr.Close())
+	assert.Equal(t, digest, rdr.Sha256())
+}
+
+func TestRemoteSources_GetTarReader_Sha256_FinalBlock(t *testing.T) {
+	content := strings.Repeat("a", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+	before := len(tempFiles(t))
+
+	rs := NewRemoteSources(config.Config{}, logrus.New())
+	file := testFile(srv.URL+"/big", "/opt/data")
+	file.Sha256 = strings.Repeat("0", 64)
+	rdr, err := rs.GetTarReader("def0", file)
+	require.NoError(t, err)
+	assert.Len(t, tempFiles(t), before, "a file with a known size is not spooled")
+
+	data, err := ioutil.ReadAll(rdr)
+	assert.True(t, errors.Is(err, ErrDigestMismatch))
+	assert.Len(t, data, 512+512, "the archive is cut short before the final block of the file")
+	assert.Equal(t, content[:512], string(data[512:]))
+	assert.Error(t, rdr.Close())
+
+	sum := sha256.Sum256([]byte(content))
+	file.Sha256 = hex.EncodeToString(sum[:])
+	rdr, err = rs.GetTarReader("def0", file)
+	require.NoError(t, err)
+	_, data2, err := readTar(t, rdr)
+	require.NoError(t, err)
+	assert.Equal(t, content, data2)
+	assert.NoError(t, rdr.Close())
+}
+
+func TestRemoteSources_Stat(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		switch r.URL.Path {
+		case "/api/v1/files/definitions/def0/sized":
+			w.Header().Set("Content-Length", "1048576")
+		case "/api/v1/files/definitions/def0/chunked":
+			w.(http.Flusher).Flush()
+		default:
+			http.Error(w, "no such file", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	f, err := ioutil.TempFile("", "genesis-test-")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("local")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	rs := NewRemoteSources(config.Config{FileHandler: config.FileHandler{APIEndpoint: srv.URL,
+		APITimeout: time.Second}}, logrus.New())
+	for _, tc := range []struct {
+		id   string
+		size int64
+	}{
+		{id: "sized", size: 1048576},
+		{id: "chunked", size: -1},
+		{id: srv.URL + "/api/v1/files/definitions/def0/sized", size: 1048576},
+		{id: "data:,hello%20world", size: int64(len("hello world"))},
+	} {
+		size, err := rs.Stat("def0", testFile(tc.id, "/opt/genesis"))
+		require.NoError(t, err, tc.id)
+		assert.Equal(t, tc.size, size, tc.id)
+	}
+	assert.Equal(t, []string{"HEAD", "HEAD", "HEAD"}, methods, "the files are not fetched")
+
+	_, err = rs.Stat("def0", testFile("missing", "/opt/genesis"))
+	assert.EqualError(t, err, `failed to get file "missing": 404 Not Found`)
+	_, err = rs.Stat("def0", testFile("data:hello", "/opt/genesis"))
+	assert.True(t, errors.Is(err, ErrInvalidSource))
+	_, err = rs.Stat("def0", testFile("ftp://example.com/file", "/opt/genesis"))
+	assert.True(t, errors.Is(err, ErrUnsupportedScheme))
+
+	size, err := NewLocalSource().Stat("def0", testFile("file://"+f.Name(), "/opt/genesis"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("local")), size)
+	_, err = NewLocalSource().Stat("def0", testFile(f.Name()+"-missing", "/opt/genesis"))
+	assert.Error(t, err)
+	_, err = NewLocalSource().Stat("def0", testFile("file://example.com"+f.Name(), "/opt/genesis"))
+	assert.True(t, errors.Is(err, ErrInvalidSource))
+}
+
+func TestRemoteSources_GetTemplateTarReader(t *testing.T) {
+	rs := NewRemoteSources(config.Config{}, logrus.New())
+	data := entity.TemplateContext{
+		Labels:    map[string]string{"testRun": "test0"},
+		Container: entity.TemplateContainer{Name: "node0", IP: "10.1.0.2"},
+		Peers:     []entity.TemplateContainer{{Name: "node1", IP: "10.1.0.3"}},
+	}
+	tmpl := `{"name": "{{.Container.Name}}", "ip": "{{.Container.IP}}", "test": "{{.Labels.testRun}}", ` +
+		`"peers": [{{range $i, $peer := .Peers}}{{if $i}}, {{end}}"{{$peer.IP}}"{{end}}]}`
+	file := testFile("data:;base64,"+base64.StdEncoding.EncodeToString([]byte(tmpl)), "/etc/genesis.json")
+	file.Template = true
+	expected := `{"name": "node0", "ip": "10.1.0.2", "test": "test0", "peers": ["10.1.0.3"]}`
+
+	rdr, err := rs.GetTemplateTarReader("def0", file, data)
+	require.NoError(t, err)
+	hdr, rendered, err := readTar(t, rdr)
+	require.NoError(t, err)
+	assert.Equal(t, "genesis.json", hdr.Name)
+	assert.Equal(t, expected, rendered)
+	assert.NoError(t, rdr.Close())
+	sum := sha256.Sum256([]byte(expected))
+	assert.Equal(t, hex.EncodeToString(sum[:]), rdr.Sha256(), "the digest is of the rendered file")
+
+	sum = sha256.Sum256([]byte(tmpl))
+	file.Sha256 = hex.EncodeToString(sum[:])
+	rdr, err = rs.GetTemplateTarReader("def0", file, data)
+	require.NoError(t, err, "the digest given is of the template")
+	assert.NoError(t, rdr.Close())
+
+	file.Sha256 = strings.Repeat("0", 64)
+	_, err = rs.GetTemplateTarReader("def0", file, data)
+	assert.True(t, errors.Is(err, ErrDigestMismatch))
+
+	for _, tmpl := range []string{"{{.Container.Name", "{{.Labels.missing}}", "{{.Missing}}",
+		strings.Repeat("a", maxTemplateSize+1)} {
+		_, err = rs.GetTemplateTarReader("def0", entity.File{File: command.File{
+			ID: "data:," + tmpl, Destination: "/etc/genesis.json"}, Template: true}, data)
+		assert.True(t, errors.Is(err, ErrInvalidTemplate), err)
+	}
+}
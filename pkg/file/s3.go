@@ -0,0 +1,157 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package file
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+)
+
+// emptySHA256 is the sha256 of an empty payload, which is what a GET or HEAD request has
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Source gets files from S3 compatible object storage, given as s3://bucket/key. The objects are
+// addressed by path, so that storage other than AWS S3 works without DNS for each bucket. Requests
+// are signed with signature version 4, unless there are no credentials.
+type s3Source struct {
+	fetcher
+	conf config.FileHandler
+	now  func() time.Time
+}
+
+// NewS3Source creates a source for the objects of S3 compatible object storage
+func NewS3Source(conf config.FileHandler) Source {
+	return s3Source{fetcher: newFetcher(conf.APITimeout), conf: conf, now: time.Now}
+}
+
+// request creates a signed request for the object of the file, with the given method
+func (ss s3Source) request(method string,
+	file entity.File) (func(ctx context.Context) (*http.Request, error), error) {
+
+	uri, err := url.Parse(file.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSource, err)
+	}
+	key := strings.TrimPrefix(uri.Path, "/")
+	if len(uri.Host) == 0 || len(key) == 0 {
+		return nil, fmt.Errorf("%w: %q is not of the form s3://bucket/key", ErrInvalidSource, file.ID)
+	}
+	endpoint := ss.conf.S3Endpoint
+	if len(endpoint) == 0 {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", ss.conf.S3Region)
+	}
+	objURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	objURL.Path = strings.TrimSuffix(objURL.Path, "/") + "/" + uri.Host + "/" + key
+	objURL.RawPath = s3Escape(objURL.Path)
+
+	return func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, objURL.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(ss.conf.S3AccessKeyID) > 0 {
+			ss.sign(req, ss.now().UTC())
+		}
+		return req, nil
+	}, nil
+}
+
+func (ss s3Source) Open(_ string, file entity.File) (io.ReadCloser, int64, error) {
+	newReq, err := ss.request("GET", file)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ss.fetch(file.ID, newReq)
+}
+
+func (ss s3Source) Stat(_ string, file entity.File) (int64, error) {
+	newReq, err := ss.request("HEAD", file)
+	if err != nil {
+		return 0, err
+	}
+	return ss.stat(file.ID, newReq)
+}
+
+// sign signs the request with signature version 4, over its host and headers
+func (ss s3Source) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for key, vals := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(vals, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3Escape(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+	scope := date + "/" + ss.conf.S3Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + ss.conf.S3SecretAccessKey)
+	for _, part := range []string{date, ss.conf.S3Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		ss.conf.S3AccessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape escapes a path the way signature version 4 expects, which leaves only the unreserved
+// characters of RFC 3986 and the slashes unescaped
+func s3Escape(path string) string {
+	var out strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			strings.IndexByte("-_.~/", c) != -1 {
+			out.WriteByte(c)
+		} else {
+			fmt.Fprintf(&out, "%%%02X", c)
+		}
+	}
+	return out.String()
+}
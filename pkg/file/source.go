@@ -0,0 +1,130 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+)
+
+// The schemes of the file IDs which have a source by default. An ID without a scheme is the ID of
+// a file in the files API, or a path on the host in local mode.
+const (
+	SchemeAPI   = ""
+	SchemeFile  = "file"
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
+	SchemeS3    = "s3"
+	SchemeData  = "data"
+)
+
+// maxErrorSize is the most of the body of a failed response which is placed in the error
+const maxErrorSize = 4096
+
+var (
+	// ErrUnsupportedScheme is when there is no source for the scheme of a file ID
+	ErrUnsupportedScheme = errors.New("unsupported file source scheme")
+
+	// ErrInvalidSource is when the ID of a file is not a valid URI for its source
+	ErrInvalidSource = errors.New("invalid file source")
+
+	// ErrDigestMismatch is when the sha256 of a file is not the expected one
+	ErrDigestMismatch = errors.New("sha256 mismatch")
+
+	// ErrInvalidTemplate is when a file cannot be rendered as a template
+	ErrInvalidTemplate = errors.New("invalid template")
+)
+
+// Source is a backend which the content of files is fetched from
+type Source interface {
+	// Open gets the content of the file, along with its size, which is -1 if it is not known
+	Open(testnetID string, file entity.File) (io.ReadCloser, int64, error)
+
+	// Stat looks the file up without fetching its content, and gets its size, which is -1 if it
+	// is not known
+	Stat(testnetID string, file entity.File) (int64, error)
+}
+
+// scheme gets the scheme of the file ID, in lower case, which is empty if it does not have one
+func scheme(id string) string {
+	for i, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		case i > 0 && c == ':':
+			return strings.ToLower(id[:i])
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// fetcher gets files over http. The timeout only applies to getting the response, the body is
+// read for as long as it takes, until it is closed.
+type fetcher struct {
+	client  *http.Client
+	timeout time.Duration
+}
+
+func newFetcher(timeout time.Duration) fetcher {
+	return fetcher{client: &http.Client{}, timeout: timeout}
+}
+
+// stat gets the size of the body of the response to the request made by newReq, which must be a
+// 200, without reading the body. The request is meant to be a HEAD request.
+func (ft fetcher) stat(id string, newReq func(ctx context.Context) (*http.Request, error)) (int64, error) {
+	body, size, err := ft.fetch(id, newReq)
+	if err != nil {
+		return 0, err
+	}
+	return size, body.Close()
+}
+
+// fetch gets the body of the response to the request made by newReq, which must be a 200
+func (ft fetcher) fetch(id string,
+	newReq func(ctx context.Context) (*http.Request, error)) (io.ReadCloser, int64, error) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := newReq(ctx)
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+
+	var timer *time.Timer
+	if ft.timeout.Nanoseconds() != 0 {
+		timer = time.AfterFunc(ft.timeout, cancel)
+	}
+	resp, err := ft.client.Do(req)
+	if timer != nil {
+		timer.Stop()
+	}
+	if err != nil {
+		cancel()
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		res, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorSize))
+		resp.Body.Close()
+		cancel()
+		if len(strings.TrimSpace(string(res))) == 0 { // such as the response to a HEAD request
+			return nil, 0, fmt.Errorf("failed to get file %q: %s", id, resp.Status)
+		}
+		return nil, 0, fmt.Errorf("failed to get file %q: %s: %s", id, resp.Status,
+			strings.TrimSpace(string(res)))
+	}
+	return &cancelCloser{ReadCloser: resp.Body, cancel: cancel}, resp.ContentLength, nil
+}
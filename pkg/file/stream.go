@@ -0,0 +1,190 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package file
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// cancelCloser cancels the context of a response once its body is closed
+type cancelCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (cc *cancelCloser) Close() error {
+	defer cc.cancel()
+	return cc.ReadCloser.Close()
+}
+
+// tempFile is a temporary file which is removed once it is closed
+type tempFile struct {
+	*os.File
+}
+
+func (tf *tempFile) Close() error {
+	err := tf.File.Close()
+	if e := os.Remove(tf.Name()); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// spool copies the content into a temporary file, so that its size is known, and closes it.
+// The returned file is removed once it is closed.
+func spool(content io.ReadCloser) (io.ReadCloser, int64, error) {
+	defer content.Close()
+	f, err := ioutil.TempFile("", "genesis-file-")
+	if err != nil {
+		return nil, 0, err
+	}
+	tf := &tempFile{File: f}
+	size, err := io.Copy(tf, content)
+	if err == nil {
+		_, err = tf.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tf.Close()
+		return nil, 0, err
+	}
+	return tf, size, nil
+}
+
+// checkSha256 checks that the digest is the expected one, if there is one
+func checkSha256(digest string, expected string) error {
+	if len(expected) > 0 && !strings.EqualFold(digest, expected) {
+		return fmt.Errorf("%w: the file has sha256 %s, instead of %s", ErrDigestMismatch,
+			digest, strings.ToLower(expected))
+	}
+	return nil
+}
+
+// TarReader is a tar archive of a single file, which is written as it is read
+type TarReader interface {
+	io.ReadCloser
+	// Sha256 gets the sha256 digest of the file, in hex, once the archive has been closed. It is
+	// empty unless all of the file was read.
+	Sha256() string
+	// Size gets the size of the file
+	Size() int64
+}
+
+type tarStream struct {
+	*io.PipeReader
+	content io.Closer
+	size    int64
+	done    chan struct{}
+	once    sync.Once
+	closed  int32
+
+	// digest is the sha256 of the content, once it has all been read
+	digest string
+	// err is the error the archive failed with before it was closed
+	err      error
+	closeErr error
+}
+
+// finalBlockSize is how much of the end of the content is held back until all of the content has
+// been read. A tar archive is made of blocks of 512 bytes.
+const finalBlockSize = 512
+
+// newTarStream creates a tar archive of the content, which must be exactly the size given in the
+// header and, if the expected sha256 is given, have that digest. The content is hashed as it is
+// streamed, and the final block of it is only written once it is known to be as expected. Failing
+// to read the content, or it not being as expected, fails the read of the archive before the
+// final block, so that the archive is cut short. Closing the archive closes the content.
+func newTarStream(content io.ReadCloser, hdr *tar.Header, expected string,
+	log logrus.Ext1FieldLogger) TarReader {
+
+	pr, pw := io.Pipe()
+	ts := &tarStream{PipeReader: pr, content: content, size: hdr.Size, done: make(chan struct{})}
+	go func() {
+		defer close(ts.done)
+		tw := tar.NewWriter(pw)
+		hash := sha256.New()
+		src := io.TeeReader(content, hash)
+		held := hdr.Size % finalBlockSize
+		if held == 0 && hdr.Size > 0 {
+			held = finalBlockSize
+		}
+		final := make([]byte, held)
+		var n int64
+		err := tw.WriteHeader(hdr)
+		if err == nil {
+			n, err = io.CopyN(tw, src, hdr.Size-held)
+		}
+		if err == nil {
+			var m int
+			m, err = io.ReadFull(src, final)
+			n += int64(m)
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil {
+			if extra, _ := io.CopyN(ioutil.Discard, content, 1); extra > 0 {
+				err = tar.ErrWriteTooLong
+			}
+		}
+		if err == nil {
+			ts.digest = hex.EncodeToString(hash.Sum(nil))
+			err = checkSha256(ts.digest, expected)
+		}
+		if err == nil {
+			_, err = tw.Write(final)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		log.WithFields(logrus.Fields{
+			"bytes":  n,
+			"size":   hdr.Size,
+			"sha256": ts.digest,
+			"error":  err,
+		}).Info("copy has been completed")
+		if atomic.LoadInt32(&ts.closed) == 0 {
+			ts.err = err
+		}
+		pw.CloseWithError(err)
+	}()
+	return ts
+}
+
+// Close stops the writing of the archive, if it is not done yet, and closes the content. It returns
+// the error the archive failed with, if it failed before being closed.
+func (ts *tarStream) Close() error {
+	ts.once.Do(func() {
+		atomic.StoreInt32(&ts.closed, 1)
+		ts.PipeReader.Close()
+		ts.closeErr = ts.content.Close()
+		<-ts.done
+		if ts.err != nil {
+			ts.closeErr = ts.err
+		}
+	})
+	return ts.closeErr
+}
+
+func (ts *tarStream) Sha256() string {
+	return ts.digest
+}
+
+func (ts *tarStream) Size() int64 {
+	return ts.size
+}
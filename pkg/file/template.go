@@ -0,0 +1,74 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package file
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"text/template"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxTemplateSize is the size of the largest file which can be rendered as a template, as templates
+// are rendered in memory
+const maxTemplateSize = 4 << 20
+
+// GetTemplateTarReader gets a tar archive containing the file rendered as a template with the
+// given context. The template is read in full before it is rendered, and must have its sha256, when
+// it is given.
+func (rf remoteSources) GetTemplateTarReader(testnetID string, file entity.File,
+	data entity.TemplateContext) (TarReader, error) {
+
+	content, _, err := rf.getReader(testnetID, file)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	hash := sha256.New()
+	src, err := ioutil.ReadAll(io.LimitReader(io.TeeReader(content, hash), maxTemplateSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(src) > maxTemplateSize {
+		return nil, fmt.Errorf("%w: templates cannot be larger than %d bytes", ErrInvalidTemplate,
+			maxTemplateSize)
+	}
+	err = checkSha256(hex.EncodeToString(hash.Sum(nil)), file.Sha256)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(path.Base(file.Destination)).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+	}
+	rf.log.WithFields(logrus.Fields{
+		"file":      file.ID,
+		"container": data.Container.Name,
+		"size":      buf.Len(),
+	}).Debug("rendered a template")
+
+	return newTarStream(ioutil.NopCloser(&buf), rf.getTarHeader(file, int64(buf.Len())), "",
+		rf.log.WithFields(logrus.Fields{
+			"file": file.ID,
+			"dest": file.Destination,
+		})), nil
+}
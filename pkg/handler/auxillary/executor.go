@@ -14,6 +14,7 @@ import (
 
 	"github.com/whiteblock/genesis/pkg/config"
 	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/metrics"
 	"github.com/whiteblock/genesis/pkg/usecase"
 
 	"github.com/sirupsen/logrus"
@@ -23,7 +24,8 @@ import (
 
 // Executor handles the  processing of mutliple commands
 type Executor interface {
-	ExecuteCommands(cmds []command.Command) entity.Result
+	// ExecuteCommands executes the given commands concurrently, stopping early if ctx is canceled
+	ExecuteCommands(ctx context.Context, cmds []command.Command) entity.Result
 }
 
 type executor struct {
@@ -44,10 +46,12 @@ func NewExecutor(
 	return &executor{usecase: usecase, conf: conf, log: log}
 }
 
-func (exec executor) ExecuteCommands(cmds []command.Command) entity.Result {
+func (exec executor) ExecuteCommands(ctx context.Context, cmds []command.Command) (out entity.Result) {
+	defer func(start time.Time) { metrics.ObserveExecution(start, out) }(time.Now())
+
 	resultChan := make(chan entity.Result, len(cmds))
 	sem := semaphore.NewWeighted(exec.conf.LimitPerTest)
-	ctx, cancelFn := context.WithTimeout(context.Background(), exec.conf.TimeLimit)
+	ctx, cancelFn := context.WithTimeout(ctx, exec.conf.TimeLimit)
 	defer cancelFn()
 	for _, cmd := range cmds {
 		go func(cmd command.Command) {
@@ -88,10 +92,14 @@ func (exec executor) ExecuteCommands(cmds []command.Command) entity.Result {
 	var err error
 	isTrap := false
 	failed := []string{}
+	outputs := map[string]interface{}{}
 	var propagatedResult entity.Result
 	for range cmds {
 		result := <-resultChan
 		entry := exec.log.WithField("result", result)
+		if output, ok := result.Meta[entity.OutputKey]; ok && result.IsSuccess() {
+			outputs[result.Meta["command"].(command.Command).ID] = output
+		}
 
 		entry.Trace("finished processing a command")
 		if result.IsDelayed() {
@@ -123,8 +131,12 @@ func (exec executor) ExecuteCommands(cmds []command.Command) entity.Result {
 			"failed": failed,
 		})
 	}
+	out = entity.NewSuccessResult()
 	if isTrap {
-		return entity.NewTrapResult()
+		out = entity.NewTrapResult()
+	}
+	if len(outputs) > 0 {
+		out = out.InjectMeta(map[string]interface{}{entity.OutputsKey: outputs})
 	}
-	return entity.NewSuccessResult()
+	return out
 }
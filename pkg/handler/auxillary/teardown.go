@@ -0,0 +1,77 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package auxillary
+
+import (
+	"context"
+
+	"github.com/whiteblock/genesis/pkg/helper"
+	"github.com/whiteblock/genesis/pkg/usecase"
+
+	"github.com/sirupsen/logrus"
+	queue "github.com/whiteblock/amqp"
+	"github.com/whiteblock/definition/command"
+	"github.com/whiteblock/definition/command/biome"
+)
+
+// Teardown handles the destruction of the resources created for a set of instructions
+type Teardown interface {
+	// Teardown destroys the resources created for the given instructions
+	Teardown(ctx context.Context, inst command.Instructions) error
+}
+
+type amqpTeardown struct {
+	completion queue.AMQPService
+	log        logrus.Ext1FieldLogger
+}
+
+// NewAMQPTeardown creates a Teardown which requests the destruction of the biome
+// through the completion queue, the same way the command consumer does
+func NewAMQPTeardown(completion queue.AMQPService, log logrus.Ext1FieldLogger) Teardown {
+	return &amqpTeardown{completion: completion, log: log}
+}
+
+// Teardown sends the DestroyBiome message for the given instructions
+func (at amqpTeardown) Teardown(ctx context.Context, inst command.Instructions) error {
+	msg, err := queue.CreateMessage(biome.DestroyBiome{
+		TestID:       inst.ID,
+		DefinitionID: inst.DefinitionID,
+	})
+	if err != nil {
+		return err
+	}
+	at.log.WithField("testnet", inst.ID).Info("requesting the destruction of the biome")
+	return at.completion.Send(msg)
+}
+
+type localTeardown struct {
+	reaper usecase.ReaperUseCase
+	log    logrus.Ext1FieldLogger
+}
+
+// NewLocalTeardown creates a Teardown which destroys the resources of the test itself, on
+// every host targeted by the instructions
+func NewLocalTeardown(reaper usecase.ReaperUseCase, log logrus.Ext1FieldLogger) Teardown {
+	return &localTeardown{reaper: reaper, log: log}
+}
+
+// Teardown removes the containers, networks and volumes of the test from each of its hosts
+func (lt localTeardown) Teardown(ctx context.Context, inst command.Instructions) (err error) {
+	for _, host := range usecase.TargetHosts(inst) {
+		res, e := lt.reaper.Destroy(ctx, host, inst.ID, false)
+		if e != nil {
+			lt.log.WithFields(logrus.Fields{"testnet": inst.ID, "host": host,
+				"error": e}).Error("failed to tear down a test")
+			err = helper.JoinErrors(err, e)
+			continue
+		}
+		lt.log.WithFields(logrus.Fields{"testnet": inst.ID, "host": res.Host,
+			"containers": res.Containers, "networks": res.Networks,
+			"volumes": res.Volumes}).Info("tore down a test")
+	}
+	return
+}
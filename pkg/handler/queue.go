@@ -7,6 +7,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"time"
@@ -14,6 +15,8 @@ import (
 	"github.com/whiteblock/genesis/pkg/config"
 	"github.com/whiteblock/genesis/pkg/entity"
 	"github.com/whiteblock/genesis/pkg/handler/auxillary"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/usecase"
 
 	"github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
@@ -24,25 +27,35 @@ import (
 
 // DeliveryHandler handles the initial processing of a amqp delivery
 type DeliveryHandler interface {
-	// Process attempts to extract the command and execute it
-	Process(msg amqp.Delivery) (amqp.Publishing, amqp.Publishing, entity.Result)
+	// Process attempts to extract the command and execute it, stopping early if ctx is canceled
+	Process(ctx context.Context, msg amqp.Delivery) (amqp.Publishing, amqp.Publishing, entity.Result)
 }
 
 type deliveryHandler struct {
 	maxRetries int64
 	aux        auxillary.Executor
+	queued     repository.QueuedTestRepository
+	stats      usecase.StatsUseCase
+	logs       usecase.LogUseCase
 	log        logrus.Ext1FieldLogger
 	conf       config.Config
 }
 
 // NewDeliveryHandler creates a new DeliveryHandler which uses the given usecase for
-// executing the extracted command
+// executing the extracted command. The tests are kept in queued until they finish, so that
+// they are known to be live. Tests which trap are kept, as they are still running. queued may
+// be nil, if they are not kept track of. stats may be nil, if the resource usage of tests is
+// not sampled, and logs may be nil, if the logs of tests are not forwarded.
 func NewDeliveryHandler(
 	aux auxillary.Executor,
 	conf config.Config,
 	maxRetries int64,
+	queued repository.QueuedTestRepository,
+	stats usecase.StatsUseCase,
+	logs usecase.LogUseCase,
 	log logrus.Ext1FieldLogger) DeliveryHandler {
-	return &deliveryHandler{aux: aux, conf: conf, log: log, maxRetries: maxRetries}
+	return &deliveryHandler{aux: aux, conf: conf, log: log, maxRetries: maxRetries,
+		queued: queued, stats: stats, logs: logs}
 }
 
 func (dh deliveryHandler) sleepy(msg amqp.Delivery) {
@@ -82,7 +95,7 @@ func (dh deliveryHandler) destructMsg(inst *command.Instructions) amqp.Publishin
 	return out
 }
 
-func (dh deliveryHandler) process(msg amqp.Delivery,
+func (dh deliveryHandler) process(ctx context.Context, msg amqp.Delivery,
 	inst *command.Instructions) (out amqp.Publishing, result entity.Result) {
 
 	cmds, err := inst.Peek()
@@ -102,7 +115,7 @@ func (dh deliveryHandler) process(msg amqp.Delivery,
 		isLastOne = true
 	}
 
-	result = dh.aux.ExecuteCommands(cmds)
+	result = dh.aux.ExecuteCommands(ctx, cmds)
 	if result.IsDelayed() {
 		inst.Next()
 		out, err = queue.GetNextMessage(msg, inst)
@@ -157,6 +170,19 @@ func (dh deliveryHandler) process(msg amqp.Delivery,
 	return
 }
 
+// stopTracking forgets the given test once it has stopped running
+func (dh deliveryHandler) stopTracking(testID string) {
+	if dh.queued != nil {
+		dh.queued.Remove(testID)
+	}
+	if dh.stats != nil {
+		dh.stats.Stop(testID)
+	}
+	if dh.logs != nil {
+		dh.logs.Stop(testID)
+	}
+}
+
 func (dh deliveryHandler) isDebugMode(inst *command.Instructions) bool {
 	if dh.conf.Execution.DebugMode {
 		return true
@@ -169,8 +195,8 @@ func (dh deliveryHandler) isDebugMode(inst *command.Instructions) bool {
 	return boolVal && typeOK
 }
 
-//Process attempts to extract the command and execute it
-func (dh deliveryHandler) Process(msg amqp.Delivery) (out amqp.Publishing,
+//Process attempts to extract the command and execute it, stopping early if ctx is canceled
+func (dh deliveryHandler) Process(ctx context.Context, msg amqp.Delivery) (out amqp.Publishing,
 	status amqp.Publishing, result entity.Result) {
 	dh.sleepy(msg)
 
@@ -183,7 +209,16 @@ func (dh deliveryHandler) Process(msg amqp.Delivery) (out amqp.Publishing,
 				"data": msg.Body,
 			})
 	}
-	out, result = dh.process(msg, &inst)
+	if dh.queued != nil && len(inst.ID) > 0 {
+		dh.queued.Add(inst.ID)
+	}
+	if dh.stats != nil {
+		dh.stats.Sample(inst)
+	}
+	if dh.logs != nil {
+		dh.logs.Follow(inst)
+	}
+	out, result = dh.process(ctx, msg, &inst)
 
 	stat := inst.Status()
 	if result.IsFatal() && dh.isDebugMode(&inst) {
@@ -195,10 +230,11 @@ func (dh deliveryHandler) Process(msg amqp.Delivery) (out amqp.Publishing,
 	if result.IsAllDone() || result.IsTrap() || result.IsFatal() || result.IsIgnore() {
 		stat.Finished = true
 		stat.StepsLeft = 0
+		if !result.IsTrap() { // trapped tests are still running
+			dh.stopTracking(inst.ID)
+		}
 	}
-	if !result.IsSuccess() {
-		stat.Message = result.Error.Error()
-	}
+	stat.Message = result.StatusMessage()
 	if result.IsDelayed() {
 		dh.log.WithFields(logrus.Fields{
 			"result": result,
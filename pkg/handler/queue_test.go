@@ -7,12 +7,18 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
+	entityMocks "github.com/whiteblock/genesis/mocks/pkg/entity"
 	auxMocks "github.com/whiteblock/genesis/mocks/pkg/handler/auxillary"
+	serviceMocks "github.com/whiteblock/genesis/mocks/pkg/service"
+	usecaseMocks "github.com/whiteblock/genesis/mocks/pkg/usecase"
 	"github.com/whiteblock/genesis/pkg/config"
 	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/usecase"
 
 	"github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
@@ -23,14 +29,14 @@ import (
 )
 
 func TestNewDeliveryHandler(t *testing.T) {
-	assert.NotNil(t, NewDeliveryHandler(nil, config.Config{}, 1, nil))
+	assert.NotNil(t, NewDeliveryHandler(nil, config.Config{}, 1, nil, nil, nil, nil))
 }
 
 func TestDeliveryHandler_Process_Successful(t *testing.T) {
 	aux := new(auxMocks.Executor)
-	aux.On("ExecuteCommands", mock.Anything).Return(entity.NewSuccessResult()).Once()
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewSuccessResult()).Once()
 
-	dh := NewDeliveryHandler(aux, config.Config{}, 1, logrus.New())
+	dh := NewDeliveryHandler(aux, config.Config{}, 1, nil, nil, nil, logrus.New())
 
 	cmd := command.Instructions{Commands: [][]command.Command{{command.Command{
 		Order: command.Order{
@@ -45,43 +51,155 @@ func TestDeliveryHandler_Process_Successful(t *testing.T) {
 	body, err := json.Marshal(cmd)
 	require.NoError(t, err)
 
-	_, _, res := dh.Process(amqp.Delivery{Body: body})
+	_, _, res := dh.Process(context.Background(), amqp.Delivery{Body: body})
 	assert.NoError(t, res.Error)
 
 	aux.AssertExpectations(t)
 
 }
 
+func TestDeliveryHandler_Process_Stats(t *testing.T) {
+	aux := new(auxMocks.Executor)
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewSuccessResult()).Twice()
+	stats := new(usecaseMocks.StatsUseCase)
+	stats.On("Sample", mock.MatchedBy(func(inst command.Instructions) bool {
+		return inst.ID == "test0"
+	})).Return().Twice()
+	stats.On("Stop", "test0").Return().Once()
+	logs := new(usecaseMocks.LogUseCase)
+	logs.On("Follow", mock.MatchedBy(func(inst command.Instructions) bool {
+		return inst.ID == "test0"
+	})).Return().Twice()
+	logs.On("Stop", "test0").Return().Once()
+
+	dh := NewDeliveryHandler(aux, config.Config{}, 1, nil, stats, logs, logrus.New())
+
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{{Order: command.Order{Type: "createContainer", Payload: map[string]interface{}{}}}},
+		{{Order: command.Order{Type: "startContainer", Payload: map[string]interface{}{}}}},
+	}}
+	body, err := json.Marshal(inst)
+	require.NoError(t, err)
+	out, _, res := dh.Process(context.Background(), amqp.Delivery{Body: body})
+	require.NoError(t, res.Error)
+	stats.AssertNotCalled(t, "Stop", "test0")
+	logs.AssertNotCalled(t, "Stop", "test0")
+
+	_, _, res = dh.Process(context.Background(), amqp.Delivery{Body: out.Body})
+	require.True(t, res.IsAllDone())
+	aux.AssertExpectations(t)
+	stats.AssertExpectations(t)
+	logs.AssertExpectations(t)
+}
+
+func TestDeliveryHandler_Process_QueuedTestSurvivesSweep(t *testing.T) {
+	aux := new(auxMocks.Executor)
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewSuccessResult()).Twice()
+
+	cli := new(entityMocks.Client)
+	cli.On("Close").Return(nil)
+	service := new(serviceMocks.DockerService)
+	service.On("CreateClient", "127.0.0.1", mock.Anything).Return(cli, nil)
+	service.On("ListTestResources", mock.Anything, mock.Anything, "").Return(
+		[]entity.TestResources{entity.NewTestResources("test0")}, nil)
+
+	queued := repository.NewQueuedTestRepository()
+	dh := NewDeliveryHandler(aux, config.Config{}, 1, queued, nil, nil, logrus.New())
+	reaper := usecase.NewReaperUseCase(config.Reaper{Hosts: []string{"127.0.0.1"}}, service,
+		repository.NewRunRepository(), queued, logrus.New())
+
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{{Order: command.Order{Type: "createContainer", Payload: map[string]interface{}{}}}},
+		{{Order: command.Order{Type: "startContainer", Payload: map[string]interface{}{}}}},
+	}}
+	body, err := json.Marshal(inst)
+	require.NoError(t, err)
+	out, _, res := dh.Process(context.Background(), amqp.Delivery{Body: body})
+	require.NoError(t, res.Error)
+
+	// the next round is waiting in the queue, so the test is still live
+	reaped, err := reaper.Sweep(context.Background(), true)
+	require.NoError(t, err)
+	assert.Empty(t, reaped)
+
+	_, _, res = dh.Process(context.Background(), amqp.Delivery{Body: out.Body})
+	require.True(t, res.IsAllDone())
+
+	reaped, err = reaper.Sweep(context.Background(), true)
+	require.NoError(t, err)
+	require.Len(t, reaped, 1)
+	assert.Equal(t, "test0", reaped[0].TestID)
+	aux.AssertExpectations(t)
+}
+
+func TestDeliveryHandler_Process_TrappedTestSurvivesSweep(t *testing.T) {
+	aux := new(auxMocks.Executor)
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewFatalResult("err")).Once()
+
+	cli := new(entityMocks.Client)
+	cli.On("Close").Return(nil)
+	service := new(serviceMocks.DockerService)
+	service.On("CreateClient", "127.0.0.1", mock.Anything).Return(cli, nil)
+	service.On("ListTestResources", mock.Anything, mock.Anything, "").Return(
+		[]entity.TestResources{entity.NewTestResources("test0")}, nil)
+
+	stats := new(usecaseMocks.StatsUseCase)
+	stats.On("Sample", mock.Anything).Return().Once()
+	logs := new(usecaseMocks.LogUseCase)
+	logs.On("Follow", mock.Anything).Return().Once()
+
+	queued := repository.NewQueuedTestRepository()
+	conf := config.Config{Execution: config.Execution{DebugMode: true}}
+	dh := NewDeliveryHandler(aux, conf, 1, queued, stats, logs, logrus.New())
+	reaper := usecase.NewReaperUseCase(config.Reaper{Hosts: []string{"127.0.0.1"}}, service,
+		repository.NewRunRepository(), queued, logrus.New())
+
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{{Order: command.Order{Type: "createContainer", Payload: map[string]interface{}{}}}},
+	}}
+	body, err := json.Marshal(inst)
+	require.NoError(t, err)
+	_, _, res := dh.Process(context.Background(), amqp.Delivery{Body: body})
+	require.True(t, res.IsTrap())
+
+	reaped, err := reaper.Sweep(context.Background(), true)
+	require.NoError(t, err)
+	assert.Empty(t, reaped)
+	aux.AssertExpectations(t)
+	stats.AssertExpectations(t) // the test is still sampled, and its logs still followed
+	logs.AssertExpectations(t)
+}
+
 func TestDeliveryHandler_Process_Unsuccessful(t *testing.T) {
 	aux := new(auxMocks.Executor)
 
-	dh := NewDeliveryHandler(aux, config.Config{}, 1, logrus.New())
+	dh := NewDeliveryHandler(aux, config.Config{}, 1, nil, nil, nil, logrus.New())
 
 	body := []byte("should be a failure")
 
-	_, _, res := dh.Process(amqp.Delivery{Body: body})
+	_, _, res := dh.Process(context.Background(), amqp.Delivery{Body: body})
 	assert.Error(t, res.Error)
 
 	aux.AssertExpectations(t)
 }
 
 func TestDeliveryHandler_Process_NoCmds_Failures(t *testing.T) {
-	dh := NewDeliveryHandler(nil, config.Config{}, 1, logrus.New())
+	dh := NewDeliveryHandler(nil, config.Config{}, 1, nil, nil, nil, logrus.New())
 
 	cmd := command.Instructions{}
 
 	body, err := json.Marshal(cmd)
 	assert.NoError(t, err)
 
-	_, _, res := dh.Process(amqp.Delivery{Body: body})
+	_, _, res := dh.Process(context.Background(), amqp.Delivery{Body: body})
 	assert.Error(t, res.Error)
 }
 
 func TestDeliveryHandler_Process_Multiple_Commands_Successful(t *testing.T) {
 	aux := new(auxMocks.Executor)
-	aux.On("ExecuteCommands", mock.Anything).Return(entity.NewSuccessResult()).Once()
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewSuccessResult()).Once()
 
-	dh := NewDeliveryHandler(aux, config.Config{}, 1, logrus.New())
+	dh := NewDeliveryHandler(aux, config.Config{}, 1, nil, nil, nil, logrus.New())
 
 	cmd := command.Instructions{Commands: [][]command.Command{
 		[]command.Command{
@@ -111,7 +229,7 @@ func TestDeliveryHandler_Process_Multiple_Commands_Successful(t *testing.T) {
 	body, err := json.Marshal(cmd)
 	assert.NoError(t, err)
 
-	_, _, res := dh.Process(amqp.Delivery{Body: body})
+	_, _, res := dh.Process(context.Background(), amqp.Delivery{Body: body})
 	assert.NoError(t, res.Error)
 
 	aux.AssertExpectations(t)
@@ -120,8 +238,8 @@ func TestDeliveryHandler_Process_Multiple_Commands_Successful(t *testing.T) {
 
 func TestDeliveryHandler_Process_Execute_Nonfatal_Failure(t *testing.T) {
 	aux := new(auxMocks.Executor)
-	aux.On("ExecuteCommands", mock.Anything).Return(entity.NewErrorResult("err")).Once()
-	dh := NewDeliveryHandler(aux, config.Config{}, 1, logrus.New())
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewErrorResult("err")).Once()
+	dh := NewDeliveryHandler(aux, config.Config{}, 1, nil, nil, nil, logrus.New())
 
 	cmd := command.Instructions{Commands: [][]command.Command{
 		[]command.Command{
@@ -140,7 +258,7 @@ func TestDeliveryHandler_Process_Execute_Nonfatal_Failure(t *testing.T) {
 	body, err := json.Marshal(cmd)
 	assert.NoError(t, err)
 
-	_, _, res := dh.Process(amqp.Delivery{Body: body})
+	_, _, res := dh.Process(context.Background(), amqp.Delivery{Body: body})
 	assert.Error(t, res.Error)
 
 	aux.AssertExpectations(t)
@@ -149,8 +267,8 @@ func TestDeliveryHandler_Process_Execute_Nonfatal_Failure(t *testing.T) {
 
 func TestDeliveryHandler_Process_Execute_Fatal_Failure(t *testing.T) {
 	aux := new(auxMocks.Executor)
-	aux.On("ExecuteCommands", mock.Anything).Return(entity.NewFatalResult("err")).Once()
-	dh := NewDeliveryHandler(aux, config.Config{}, 1, logrus.New())
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewFatalResult("err")).Once()
+	dh := NewDeliveryHandler(aux, config.Config{}, 1, nil, nil, nil, logrus.New())
 
 	cmd := command.Instructions{Commands: [][]command.Command{
 		[]command.Command{
@@ -169,7 +287,7 @@ func TestDeliveryHandler_Process_Execute_Fatal_Failure(t *testing.T) {
 	body, err := json.Marshal(cmd)
 	assert.NoError(t, err)
 
-	_, _, res := dh.Process(amqp.Delivery{Body: body})
+	_, _, res := dh.Process(context.Background(), amqp.Delivery{Body: body})
 	assert.Error(t, res.Error)
 
 	aux.AssertExpectations(t)
@@ -7,16 +7,24 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/whiteblock/definition/command"
 	"github.com/whiteblock/genesis/pkg/entity"
 	"github.com/whiteblock/genesis/pkg/handler/auxillary"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/usecase"
 	util "github.com/whiteblock/utility/utils"
 
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
@@ -26,27 +34,143 @@ const maxRetries = 5
 type RestHandler interface {
 	//AddCommands handles the addition of new commands
 	AddCommands(w http.ResponseWriter, r *http.Request)
+	//GetCommand handles the reporting of the progress of a single run
+	GetCommand(w http.ResponseWriter, r *http.Request)
+	//GetCommands handles the reporting of the progress of every run
+	GetCommands(w http.ResponseWriter, r *http.Request)
+	//CancelCommand handles the cancellation of a run, and optionally the teardown of what it created
+	CancelCommand(w http.ResponseWriter, r *http.Request)
 	//HealthCheck handles the reporting of the current health of this service
 	HealthCheck(w http.ResponseWriter, r *http.Request)
+	//DestroyTest handles the removal of the resources of a test from a host
+	DestroyTest(w http.ResponseWriter, r *http.Request)
+	//Sweep handles the removal of the resources of every test without a live run
+	Sweep(w http.ResponseWriter, r *http.Request)
+	//Recover handles the runs which were interrupted by a restart, resuming them if resume is true,
+	//otherwise marking them as failed
+	Recover(resume bool) error
+	//Stop refuses new runs, and waits for the runs in flight to finish. Once ctx is done, the
+	//runs which remain are interrupted, and left unfinished to be recovered on the next start.
+	Stop(ctx context.Context) error
+}
+
+var (
+	// ErrRunCanceled is the error given to runs which were canceled before they could finish
+	ErrRunCanceled = errors.New("the run was canceled")
+
+	// ErrTeardownUnavailable is returned when a teardown is requested, but no means of teardown is configured
+	ErrTeardownUnavailable = errors.New("teardown is not available")
+
+	// ErrPlanUnavailable is returned when a dry run is requested, but no planner is configured
+	ErrPlanUnavailable = errors.New("dry runs are not available")
+
+	// ErrReaperUnavailable is returned when the removal of the resources of tests is requested,
+	// but no reaper is configured
+	ErrReaperUnavailable = errors.New("the removal of test resources is not available")
+
+	// ErrRunInterrupted is given to runs which were interrupted by a restart and were not resumed
+	ErrRunInterrupted = errors.New("the run was interrupted by a restart")
+
+	// ErrRunFinished is returned when canceling a run which has already finished
+	ErrRunFinished = errors.New("the run has already finished")
+
+	// ErrShuttingDown is returned when a run is submitted once the handler has been stopped
+	ErrShuttingDown = errors.New("shutting down")
+)
+
+// cancelTimeout is how long Stop waits for the runs in flight to stop once they are interrupted
+var cancelTimeout = 5 * time.Second
+
+type activeRun struct {
+	// inst is the instructions as they were originally submitted
+	inst   command.Instructions
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 type restHandler struct {
-	aux auxillary.Executor
-	log logrus.Ext1FieldLogger
+	aux      auxillary.Executor
+	planner  usecase.PlanUseCase
+	reaper   usecase.ReaperUseCase
+	runs     repository.RunRepository
+	teardown auxillary.Teardown
+	policy   entity.TeardownPolicy
+	stats    usecase.StatsUseCase
+	logs     usecase.LogUseCase
+	log      logrus.Ext1FieldLogger
+
+	lock     sync.Mutex
+	active   map[string]*activeRun
+	stopping bool
 }
 
-//NewRestHandler creates a new rest handler
-func NewRestHandler(aux auxillary.Executor, log logrus.Ext1FieldLogger) RestHandler {
+//RestHandlerOptions are the dependencies of a RestHandler. Only Executor and Runs are required,
+//the others may be nil if what they are used for is not supported.
+type RestHandlerOptions struct {
+	//Executor executes the rounds of the runs
+	Executor auxillary.Executor
+	//Runs keeps the state of the runs
+	Runs repository.RunRepository
+	//Planner plans the dry runs. If nil, dry runs respond with 501.
+	Planner usecase.PlanUseCase
+	//Reaper removes the resources of tests. If nil, DestroyTest and Sweep respond with 501.
+	Reaper usecase.ReaperUseCase
+	//Teardown tears down the runs. If nil, runs are never torn down.
+	Teardown auxillary.Teardown
+	//Policy decides which runs are torn down once they finish
+	Policy entity.TeardownPolicy
+	//Stats samples the resource usage of the tests. If nil, it is not sampled.
+	Stats usecase.StatsUseCase
+	//Logs forwards the logs of the containers of the tests. If nil, they are not forwarded.
+	Logs usecase.LogUseCase
+}
+
+//NewRestHandler creates a new rest handler from the given dependencies
+func NewRestHandler(opts RestHandlerOptions, log logrus.Ext1FieldLogger) RestHandler {
 	log.Debug("creating a new rest handler")
 	out := &restHandler{
-		aux: aux,
-		log: log,
+		aux:      opts.Executor,
+		planner:  opts.Planner,
+		reaper:   opts.Reaper,
+		runs:     opts.Runs,
+		teardown: opts.Teardown,
+		policy:   opts.Policy,
+		stats:    opts.Stats,
+		logs:     opts.Logs,
+		log:      log,
+		active:   map[string]*activeRun{},
 	}
 	return out
 }
 
-//AddCommands handles the addition of new commands
+func (rh *restHandler) writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, util.LogError(err).Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, err = w.Write(data)
+	if err != nil {
+		rh.log.Error(err)
+	}
+}
+
+//AddCommands handles the addition of new commands. If the wait parameter is true, the response
+//is only sent once the run has finished. If the dryRun parameter is true, the commands are only
+//planned, and the plan is sent back instead.
 func (rh *restHandler) AddCommands(w http.ResponseWriter, r *http.Request) {
+	wait, err := boolParam(r, "wait")
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	dryRun, err := boolParam(r, "dryRun")
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
 	var cmds command.Instructions
 	data, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -60,11 +184,330 @@ func (rh *restHandler) AddCommands(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, util.LogError(err).Error(), 400)
 		return
 	}
-	go rh.run(&cmds)
-	w.Write([]byte("Success"))
+	if dryRun {
+		rh.plan(w, r, cmds)
+		return
+	}
+	if rh.isStopping() {
+		http.Error(w, ErrShuttingDown.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	run := entity.NewRun(util.GetUUIDString(), cmds)
+	err = rh.runs.Insert(run)
+	if err != nil {
+		http.Error(w, util.LogError(err).Error(), 500)
+		return
+	}
+	ar, err := rh.start(run, cmds)
+	if err != nil { // the run is left unfinished, to be recovered on the next start
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Location", "/command/"+run.ID)
+	if !wait {
+		rh.writeJSON(w, http.StatusAccepted, map[string]string{"id": run.ID})
+		return
+	}
+	select {
+	case <-ar.done:
+	case <-r.Context().Done(): // the run carries on without the client
+		return
+	}
+	run, err = rh.runs.Get(run.ID)
+	if err != nil {
+		http.Error(w, util.LogError(err).Error(), 500)
+		return
+	}
+	rh.writeJSON(w, statusCode(run), runSummary{
+		ID:       run.ID,
+		Result:   run.Result,
+		Rounds:   run.Rounds,
+		Duration: run.Duration().String(),
+	})
 }
 
-func (rh *restHandler) process(inst *command.Instructions) (result entity.Result) {
+// plan sends back the plan for the given instructions
+func (rh *restHandler) plan(w http.ResponseWriter, r *http.Request, inst command.Instructions) {
+	if rh.planner == nil {
+		http.Error(w, ErrPlanUnavailable.Error(), http.StatusNotImplemented)
+		return
+	}
+	plan, err := rh.planner.Plan(r.Context(), inst)
+	if errors.Is(err, command.ErrNoCommands) {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err != nil {
+		http.Error(w, util.LogError(err).Error(), 500)
+		return
+	}
+	if !plan.Valid {
+		rh.writeJSON(w, 422, plan)
+		return
+	}
+	rh.writeJSON(w, 200, plan)
+}
+
+// start executes the run in the background, from the given point in its instructions
+func (rh *restHandler) start(run entity.Run, inst command.Instructions) (*activeRun, error) {
+	ar := &activeRun{inst: run.Instructions, done: make(chan struct{})}
+	var ctx context.Context
+	ctx, ar.cancel = context.WithCancel(context.Background())
+
+	rh.lock.Lock()
+	if rh.stopping {
+		rh.lock.Unlock()
+		ar.cancel()
+		return nil, ErrShuttingDown
+	}
+	rh.active[run.ID] = ar
+	rh.lock.Unlock()
+
+	go func() {
+		defer close(ar.done)
+		defer rh.forget(run.ID)
+		defer ar.cancel()
+		if rh.stats != nil {
+			rh.stats.Sample(inst)
+		}
+		if rh.logs != nil {
+			rh.logs.Follow(inst)
+		}
+		finished := rh.run(ctx, run, &inst)
+		rh.stopWatching(run.ID, inst.ID)
+		if finished {
+			rh.finish(run.ID, ar.inst)
+		}
+	}()
+	return ar, nil
+}
+
+func (rh *restHandler) isStopping() bool {
+	rh.lock.Lock()
+	defer rh.lock.Unlock()
+	return rh.stopping
+}
+
+//Stop refuses new runs, and waits for the runs in flight to finish. Once ctx is done, the
+//runs which remain are interrupted, and left unfinished to be recovered on the next start.
+func (rh *restHandler) Stop(ctx context.Context) error {
+	rh.lock.Lock()
+	rh.stopping = true
+	inFlight := make([]*activeRun, 0, len(rh.active))
+	for _, ar := range rh.active {
+		inFlight = append(inFlight, ar)
+	}
+	rh.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for _, ar := range inFlight {
+			<-ar.done
+		}
+		close(done)
+	}()
+	rh.log.WithField("runs", len(inFlight)).Info("waiting for the runs in flight to finish")
+	select {
+	case <-done:
+		rh.log.Info("all runs in flight have finished")
+		return nil
+	case <-ctx.Done():
+	}
+	rh.log.Warn("the grace period has expired, interrupting the runs in flight")
+	for _, ar := range inFlight {
+		ar.cancel()
+	}
+	select {
+	case <-done:
+	case <-time.After(cancelTimeout):
+		rh.log.Error("the runs in flight did not stop once interrupted")
+	}
+	return ctx.Err()
+}
+
+// forget removes the given run from the active runs, once it has stopped
+func (rh *restHandler) forget(id string) {
+	rh.lock.Lock()
+	defer rh.lock.Unlock()
+	delete(rh.active, id)
+}
+
+//Recover handles the runs which were interrupted by a restart, resuming them if resume is true,
+//otherwise marking them as failed
+func (rh *restHandler) Recover(resume bool) error {
+	runs, err := rh.runs.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		if run.Status.Finished {
+			continue
+		}
+		if resume {
+			rh.log.WithFields(logrus.Fields{"run": run.ID, "round": run.DroppedRounds + len(run.Rounds),
+				"stepsLeft": run.Status.StepsLeft}).Info("resuming an interrupted run")
+			_, err = rh.start(run, run.Instructions)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		rh.log.WithField("run", run.ID).Warn("marking an interrupted run as failed")
+		rh.update(&run, &run.Instructions, entity.NewFatalResult(ErrRunInterrupted))
+		rh.finish(run.ID, run.Instructions)
+	}
+	return nil
+}
+
+// runSummary is the response given for a run executed synchronously
+type runSummary struct {
+	ID       string          `json:"id"`
+	Result   *entity.Result  `json:"result"`
+	Rounds   []entity.Result `json:"rounds"`
+	Duration string          `json:"duration"`
+}
+
+// statusCode gets the HTTP status code corresponding to the final result of a run
+func statusCode(run entity.Run) int {
+	switch {
+	case run.Result == nil:
+		return 500
+	case run.Result.IsIgnore():
+		return 400
+	case run.Result.IsFatal():
+		return 422
+	}
+	return 200
+}
+
+func boolParam(r *http.Request, name string) (bool, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(val)
+}
+
+//GetCommand handles the reporting of the progress of a single run
+func (rh *restHandler) GetCommand(w http.ResponseWriter, r *http.Request) {
+	run, err := rh.runs.Get(mux.Vars(r)["id"])
+	if errors.Is(err, repository.ErrRunNotFound) {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	if err != nil {
+		http.Error(w, util.LogError(err).Error(), 500)
+		return
+	}
+	rh.writeJSON(w, 200, run)
+}
+
+//GetCommands handles the reporting of the progress of every run
+func (rh *restHandler) GetCommands(w http.ResponseWriter, r *http.Request) {
+	runs, err := rh.runs.GetAll()
+	if err != nil {
+		http.Error(w, util.LogError(err).Error(), 500)
+		return
+	}
+	rh.writeJSON(w, 200, runs)
+}
+
+//CancelCommand handles the cancellation of a run, and optionally the teardown of what it created.
+//The run is stopped before its next round, and the response is sent once it has stopped. Runs which
+//have already finished cannot be canceled.
+func (rh *restHandler) CancelCommand(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	teardown, err := boolParam(r, "teardown")
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if teardown && rh.teardown == nil {
+		http.Error(w, ErrTeardownUnavailable.Error(), 400)
+		return
+	}
+
+	rh.lock.Lock()
+	ar, exists := rh.active[id]
+	rh.lock.Unlock()
+	if !exists {
+		_, err = rh.runs.Get(id)
+		if err == nil {
+			http.Error(w, ErrRunFinished.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, repository.ErrRunNotFound) {
+			http.Error(w, err.Error(), 404)
+			return
+		}
+		http.Error(w, util.LogError(err).Error(), 500)
+		return
+	}
+	rh.log.WithFields(logrus.Fields{"run": id, "teardown": teardown}).Info("canceling a run")
+	ar.cancel()
+
+	select {
+	case <-ar.done:
+	case <-r.Context().Done():
+		return
+	}
+
+	if teardown {
+		err = rh.teardown.Teardown(r.Context(), ar.inst)
+		if err != nil {
+			http.Error(w, util.LogError(err).Error(), 500)
+			return
+		}
+	}
+	rh.GetCommand(w, r)
+}
+
+//DestroyTest handles the removal of the resources of a test from the host given by the host parameter.
+//If the dryRun parameter is true, the resources are only listed.
+func (rh *restHandler) DestroyTest(w http.ResponseWriter, r *http.Request) {
+	if rh.reaper == nil {
+		http.Error(w, ErrReaperUnavailable.Error(), http.StatusNotImplemented)
+		return
+	}
+	dryRun, err := boolParam(r, "dryRun")
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	res, err := rh.reaper.Destroy(r.Context(), r.URL.Query().Get("host"), mux.Vars(r)["id"], dryRun)
+	if errors.Is(err, usecase.ErrHostRequired) {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err != nil {
+		http.Error(w, util.LogError(err).Error(), 500)
+		return
+	}
+	rh.writeJSON(w, 200, res)
+}
+
+//Sweep handles the removal of the resources of every test without a live run.
+//If the dryRun parameter is true, the resources are only listed.
+func (rh *restHandler) Sweep(w http.ResponseWriter, r *http.Request) {
+	if rh.reaper == nil {
+		http.Error(w, ErrReaperUnavailable.Error(), http.StatusNotImplemented)
+		return
+	}
+	dryRun, err := boolParam(r, "dryRun")
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	res, err := rh.reaper.Sweep(r.Context(), dryRun)
+	if err != nil {
+		http.Error(w, util.LogError(err).Error(), 500)
+		return
+	}
+	rh.writeJSON(w, 200, res)
+}
+
+func (rh *restHandler) process(ctx context.Context, inst *command.Instructions) (result entity.Result) {
 	cmds, err := inst.Peek()
 
 	isLastOne := false
@@ -82,7 +525,7 @@ func (rh *restHandler) process(inst *command.Instructions) (result entity.Result
 		isLastOne = true
 	}
 
-	result = rh.aux.ExecuteCommands(cmds)
+	result = rh.aux.ExecuteCommands(ctx, cmds)
 
 	if result.IsFatal() {
 		rh.log.WithFields(logrus.Fields{"result": result, "error": result.Error.Error(),
@@ -125,40 +568,96 @@ func (rh *restHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (rh *restHandler) run(inst *command.Instructions) {
+func (rh *restHandler) update(run *entity.Run, inst *command.Instructions, res entity.Result) {
+	run.Update(*inst, res)
+	err := rh.runs.Update(*run)
+	if err != nil {
+		rh.log.WithFields(logrus.Fields{
+			"run":   run.ID,
+			"error": err}).Error("failed to update the run")
+	}
+}
+
+// stopWatching stops sampling the test of the given run and following its logs once the run has
+// stopped, unless it trapped, as the test is then still running
+func (rh *restHandler) stopWatching(id string, testID string) {
+	run, err := rh.runs.Get(id)
+	if err == nil && run.Result != nil && run.Result.IsTrap() {
+		return
+	}
+	if rh.stats != nil {
+		rh.stats.Stop(testID)
+	}
+	if rh.logs != nil {
+		rh.logs.Stop(testID)
+	}
+}
+
+// finish tears down the given finished run if the teardown policy applies to its final result
+func (rh *restHandler) finish(id string, inst command.Instructions) {
+	if rh.teardown == nil {
+		return
+	}
+	run, err := rh.runs.Get(id)
+	if err != nil {
+		rh.log.WithFields(logrus.Fields{"run": id, "error": err}).Error("failed to get a finished run")
+		return
+	}
+	if run.Result == nil || !rh.policy.Applies(*run.Result) {
+		return
+	}
+	rh.log.WithFields(logrus.Fields{"run": id, "policy": rh.policy}).Info("tearing down a finished run")
+	err = rh.teardown.Teardown(context.Background(), inst)
+	if err != nil {
+		rh.log.WithFields(logrus.Fields{"run": id, "error": err}).Error("failed to tear down a finished run")
+	}
+}
+
+// run executes the rounds of the run until it finishes, returning false if it was canceled
+func (rh *restHandler) run(ctx context.Context, run entity.Run, inst *command.Instructions) bool {
 	retries := 0
 	for {
-		res := rh.process(inst)
+		res := rh.process(ctx, inst)
+		if ctx.Err() != nil && rh.isStopping() { // left as it was before the round, to be recovered
+			rh.log.WithField("run", run.ID).Warn("the run was interrupted by a shutdown")
+			return false
+		}
+		if ctx.Err() != nil { // whatever the round reported, it was cut short
+			rh.log.WithField("run", run.ID).Info("the run was canceled")
+			rh.update(&run, inst, entity.NewFatalResult(ErrRunCanceled))
+			return false
+		}
+		if res.IsSuccess() {
+			retries = 0
+		} else if !res.IsFatal() && !res.IsIgnore() && !res.IsTrap() {
+			retries++
+			if retries > maxRetries {
+				rh.log.Error("too many retries for command")
+				res = res.Fatal(fmt.Errorf("too many retries for command: %w", res.Error))
+			}
+		}
+		rh.update(&run, inst, res)
 
 		if res.IsAllDone() {
 			rh.log.Info("successfully completed")
-			return
+			return true
 		}
 		if res.IsFatal() {
 			rh.log.Error("a command could not execute")
-			return
+			return true
 		}
 
 		if res.IsIgnore() {
 			rh.log.Error("ignoring a message")
-			return
+			return true
 		}
 		if res.IsTrap() {
 			rh.log.Info("a trap was activated")
-			return
+			return true
 		}
 
-		if res.IsRequeue() || !res.IsSuccess() {
-			retries++
-			if retries > maxRetries {
-				rh.log.Error("too many retries for command")
-				return
-			}
+		if !res.IsSuccess() {
 			rh.log.Info("retrying command")
-			continue
-		} else {
-			retries = 0
 		}
-		continue
 	}
 }
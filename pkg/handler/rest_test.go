@@ -8,6 +8,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -17,11 +18,16 @@ import (
 
 	"github.com/whiteblock/definition/command"
 	auxMocks "github.com/whiteblock/genesis/mocks/pkg/handler/auxillary"
+	usecaseMocks "github.com/whiteblock/genesis/mocks/pkg/usecase"
 	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/usecase"
 
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 var testCommands = command.Instructions{Commands: [][]command.Command{{
@@ -54,13 +60,15 @@ func TestRestHandler(t *testing.T) {
 	runChan := make(chan []command.Command)
 
 	aux := new(auxMocks.Executor)
-	aux.On("ExecuteCommands", mock.Anything).Return(entity.NewSuccessResult()).Run(func(args mock.Arguments) {
-		cmds, ok := args.Get(0).([]command.Command)
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewSuccessResult()).Run(func(args mock.Arguments) {
+		cmds, ok := args.Get(1).([]command.Command)
 		assert.True(t, ok)
 		runChan <- cmds
 	}).Times(len(testCommands.Commands))
 
-	rh := NewRestHandler(aux, logrus.New())
+	rh := NewRestHandler(RestHandlerOptions{
+		Executor: aux, Runs: repository.NewRunRepository(),
+	}, logrus.New())
 
 	recorder := httptest.NewRecorder()
 	go rh.AddCommands(recorder, req)
@@ -87,14 +95,16 @@ func TestRestHandler_Requeue(t *testing.T) {
 	runChan := make(chan []command.Command)
 
 	aux := new(auxMocks.Executor)
-	aux.On("ExecuteCommands", mock.Anything).Return(entity.NewErrorResult("err")).Run(func(args mock.Arguments) {
-		cmds, ok := args.Get(0).([]command.Command)
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewErrorResult("err")).Run(func(args mock.Arguments) {
+		cmds, ok := args.Get(1).([]command.Command)
 		assert.True(t, ok)
 		runChan <- cmds
 
 	}).Times(len(testCommands.Commands) * (maxRetries + 1))
 
-	rh := NewRestHandler(aux, logrus.New())
+	rh := NewRestHandler(RestHandlerOptions{
+		Executor: aux, Runs: repository.NewRunRepository(),
+	}, logrus.New())
 
 	recorder := httptest.NewRecorder()
 	go rh.AddCommands(recorder, req)
@@ -120,15 +130,17 @@ func TestRestHandler_Fatal(t *testing.T) { //testCommands
 	runChan := make(chan []command.Command)
 
 	aux := new(auxMocks.Executor)
-	aux.On("ExecuteCommands", mock.Anything).Return(entity.NewFatalResult("err")).Run(func(args mock.Arguments) {
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewFatalResult("err")).Run(func(args mock.Arguments) {
 		t.Log("called run")
-		cmds, ok := args.Get(0).([]command.Command)
+		cmds, ok := args.Get(1).([]command.Command)
 		assert.True(t, ok)
 		runChan <- cmds
 
 	}).Times(len(testCommands.Commands))
 
-	rh := NewRestHandler(aux, logrus.New())
+	rh := NewRestHandler(RestHandlerOptions{
+		Executor: aux, Runs: repository.NewRunRepository(),
+	}, logrus.New())
 
 	recorder := httptest.NewRecorder()
 	rh.AddCommands(recorder, req)
@@ -147,9 +159,564 @@ func TestRestHandler_HealthCheck(t *testing.T) {
 	req, err := http.NewRequest("GET", "/health", bytes.NewReader([]byte{}))
 	assert.NoError(t, err)
 
-	rh := NewRestHandler(nil, logrus.New())
+	rh := NewRestHandler(RestHandlerOptions{Runs: repository.NewRunRepository()}, logrus.New())
 	recorder := httptest.NewRecorder()
 	rh.HealthCheck(recorder, req)
 
 	assert.Equal(t, "OK", recorder.Body.String())
 }
+
+func TestRestHandler_GetCommand(t *testing.T) {
+	data, err := json.Marshal(testCommands)
+	require.NoError(t, err)
+
+	done := make(chan bool)
+	aux := new(auxMocks.Executor)
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewSuccessResult()).Run(
+		func(args mock.Arguments) {
+			done <- true
+		}).Once()
+
+	runs := repository.NewRunRepository()
+	rh := NewRestHandler(RestHandlerOptions{Executor: aux, Runs: runs}, logrus.New())
+
+	req, err := http.NewRequest("POST", "/command", bytes.NewReader(data))
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	rh.AddCommands(recorder, req)
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+
+	var accepted map[string]string
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &accepted))
+	require.NotEmpty(t, accepted["id"])
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("execution did not happen within 5 seconds")
+	}
+	require.Eventually(t, func() bool {
+		run, err := runs.Get(accepted["id"])
+		return err == nil && run.Status.Finished
+	}, 5*time.Second, 10*time.Millisecond)
+
+	req, err = http.NewRequest("GET", "/command/"+accepted["id"], nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": accepted["id"]})
+	recorder = httptest.NewRecorder()
+	rh.GetCommand(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	var run map[string]interface{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &run))
+	assert.Equal(t, accepted["id"], run["id"])
+	require.Contains(t, run, "result")
+	assert.Equal(t, "AllDone", run["result"].(map[string]interface{})["type"])
+	assert.Equal(t, true, run["status"].(map[string]interface{})["finished"])
+
+	req, err = http.NewRequest("GET", "/command", nil)
+	require.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	rh.GetCommands(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	var all []map[string]interface{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &all))
+	require.Len(t, all, 1)
+	assert.Equal(t, accepted["id"], all[0]["id"])
+
+	aux.AssertExpectations(t)
+}
+
+func TestRestHandler_GetCommand_NotFound(t *testing.T) {
+	req, err := http.NewRequest("GET", "/command/foo", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": "foo"})
+
+	rh := NewRestHandler(RestHandlerOptions{Runs: repository.NewRunRepository()}, logrus.New())
+	recorder := httptest.NewRecorder()
+	rh.GetCommand(recorder, req)
+
+	assert.Equal(t, 404, recorder.Code)
+}
+
+func TestRestHandler_CancelCommand(t *testing.T) {
+	data, err := json.Marshal(testCommands)
+	require.NoError(t, err)
+
+	started := make(chan bool)
+	aux := new(auxMocks.Executor)
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewSuccessResult()).Run(
+		func(args mock.Arguments) {
+			ctx, ok := args.Get(0).(context.Context)
+			require.True(t, ok)
+			started <- true
+			<-ctx.Done()
+		}).Once()
+
+	teardown := new(auxMocks.Teardown)
+	teardown.On("Teardown", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		inst, ok := args.Get(1).(command.Instructions)
+		require.True(t, ok)
+		assert.Len(t, inst.Commands, len(testCommands.Commands))
+	}).Once()
+
+	// the policy doesn't apply to canceled runs, so the teardown happens only once
+	rh := NewRestHandler(RestHandlerOptions{
+		Executor: aux, Runs: repository.NewRunRepository(), Teardown: teardown, Policy: entity.TeardownAlways,
+	}, logrus.New())
+
+	req, err := http.NewRequest("POST", "/command", bytes.NewReader(data))
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	rh.AddCommands(recorder, req)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+
+	var accepted map[string]string
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &accepted))
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("execution did not happen within 5 seconds")
+	}
+
+	req, err = http.NewRequest("DELETE", "/command/"+accepted["id"]+"?teardown=true", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": accepted["id"]})
+	recorder = httptest.NewRecorder()
+	rh.CancelCommand(recorder, req)
+	require.Equal(t, 200, recorder.Code)
+
+	var run map[string]interface{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &run))
+	status := run["status"].(map[string]interface{})
+	assert.Equal(t, true, status["finished"])
+	assert.Equal(t, ErrRunCanceled.Error(), status["message"])
+
+	impl := rh.(*restHandler)
+	impl.lock.Lock()
+	assert.Empty(t, impl.active)
+	impl.lock.Unlock()
+
+	// the run is over, so canceling it again neither cancels nor tears down anything
+	recorder = httptest.NewRecorder()
+	rh.CancelCommand(recorder, req)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	aux.AssertExpectations(t)
+	teardown.AssertExpectations(t)
+}
+
+func TestRestHandler_CancelCommand_Failures(t *testing.T) {
+	rh := NewRestHandler(RestHandlerOptions{Runs: repository.NewRunRepository()}, logrus.New())
+
+	req, err := http.NewRequest("DELETE", "/command/foo?teardown=true", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": "foo"})
+	recorder := httptest.NewRecorder()
+	rh.CancelCommand(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	req, err = http.NewRequest("DELETE", "/command/foo", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": "foo"})
+	recorder = httptest.NewRecorder()
+	rh.CancelCommand(recorder, req)
+	assert.Equal(t, 404, recorder.Code)
+}
+
+func TestRestHandler_Stop(t *testing.T) {
+	data, err := json.Marshal(testCommands)
+	require.NoError(t, err)
+
+	started := make(chan bool)
+	aux := new(auxMocks.Executor)
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewSuccessResult()).Run(
+		func(args mock.Arguments) {
+			started <- true
+			<-args.Get(0).(context.Context).Done()
+		}).Once()
+
+	runs := repository.NewRunRepository()
+	rh := NewRestHandler(RestHandlerOptions{Executor: aux, Runs: runs}, logrus.New())
+
+	req, err := http.NewRequest("POST", "/command", bytes.NewReader(data))
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	rh.AddCommands(recorder, req)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+
+	var accepted map[string]string
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &accepted))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, rh.Stop(ctx))
+
+	// the interrupted run is left as it was, so that it is recovered on the next start
+	run, err := runs.Get(accepted["id"])
+	require.NoError(t, err)
+	assert.False(t, run.Status.Finished)
+	assert.Empty(t, run.Rounds)
+
+	req, err = http.NewRequest("POST", "/command", bytes.NewReader(data))
+	require.NoError(t, err)
+	recorder = httptest.NewRecorder()
+	rh.AddCommands(recorder, req)
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	aux.AssertExpectations(t)
+}
+
+func TestRestHandler_AddCommands_Wait(t *testing.T) {
+	var tests = []struct {
+		rounds       int
+		res          entity.Result
+		expectedCode int
+		expectedType string
+		expectedRuns int
+	}{
+		{
+			rounds:       1,
+			res:          entity.NewSuccessResult(),
+			expectedCode: 200,
+			expectedType: "AllDone",
+			expectedRuns: 1,
+		},
+		{
+			rounds:       maxRetries + 3,
+			res:          entity.NewSuccessResult(),
+			expectedCode: 200,
+			expectedType: "AllDone",
+			expectedRuns: maxRetries + 3,
+		},
+		{
+			rounds:       2,
+			res:          entity.NewFatalResult("err"),
+			expectedCode: 422,
+			expectedType: "Fatal",
+			expectedRuns: 1,
+		},
+		{
+			rounds:       1,
+			res:          entity.NewErrorResult("err"),
+			expectedCode: 422,
+			expectedType: "Fatal",
+			expectedRuns: maxRetries + 1,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			inst := command.Instructions{}
+			for j := 0; j < tt.rounds; j++ {
+				inst.Commands = append(inst.Commands, testCommands.Commands[0])
+			}
+			data, err := json.Marshal(inst)
+			require.NoError(t, err)
+
+			aux := new(auxMocks.Executor)
+			aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(tt.res).Times(tt.expectedRuns)
+
+			rh := NewRestHandler(RestHandlerOptions{
+				Executor: aux, Runs: repository.NewRunRepository(),
+			}, logrus.New())
+
+			req, err := http.NewRequest("POST", "/command?wait=true", bytes.NewReader(data))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+			rh.AddCommands(recorder, req)
+			assert.Equal(t, tt.expectedCode, recorder.Code)
+
+			var summary map[string]interface{}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &summary))
+			assert.NotEmpty(t, summary["id"])
+			assert.NotEmpty(t, summary["duration"])
+			assert.Equal(t, tt.expectedType, summary["result"].(map[string]interface{})["type"])
+			assert.Len(t, summary["rounds"], tt.expectedRuns)
+
+			aux.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRestHandler_Stats(t *testing.T) {
+	inst := testCommands
+	inst.ID = "test0"
+	data, err := json.Marshal(inst)
+	require.NoError(t, err)
+
+	aux := new(auxMocks.Executor)
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewSuccessResult())
+	stats := new(usecaseMocks.StatsUseCase)
+	stats.On("Sample", mock.MatchedBy(func(inst command.Instructions) bool {
+		return inst.ID == "test0"
+	})).Return().Once()
+	stats.On("Stop", "test0").Return().Once()
+	logs := new(usecaseMocks.LogUseCase)
+	logs.On("Follow", mock.MatchedBy(func(inst command.Instructions) bool {
+		return inst.ID == "test0"
+	})).Return().Once()
+	logs.On("Stop", "test0").Return().Once()
+
+	rh := NewRestHandler(RestHandlerOptions{
+		Executor: aux, Runs: repository.NewRunRepository(), Stats: stats, Logs: logs,
+	}, logrus.New())
+
+	req, err := http.NewRequest("POST", "/command?wait=true", bytes.NewReader(data))
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	rh.AddCommands(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+	stats.AssertExpectations(t)
+	logs.AssertExpectations(t)
+}
+
+func TestRestHandler_Stats_Trap(t *testing.T) {
+	inst := testCommands
+	inst.ID = "test0"
+	data, err := json.Marshal(inst)
+	require.NoError(t, err)
+
+	aux := new(auxMocks.Executor)
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewTrapResult())
+	stats := new(usecaseMocks.StatsUseCase)
+	stats.On("Sample", mock.Anything).Return().Once()
+	logs := new(usecaseMocks.LogUseCase)
+	logs.On("Follow", mock.Anything).Return().Once()
+
+	rh := NewRestHandler(RestHandlerOptions{
+		Executor: aux, Runs: repository.NewRunRepository(), Stats: stats, Logs: logs,
+	}, logrus.New())
+
+	req, err := http.NewRequest("POST", "/command?wait=true", bytes.NewReader(data))
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	rh.AddCommands(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+	stats.AssertExpectations(t) // the trapped test is still running, so it is still sampled
+	logs.AssertExpectations(t)
+}
+
+func TestRestHandler_Recover(t *testing.T) {
+	aux := new(auxMocks.Executor)
+	aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(entity.NewSuccessResult()).Once()
+
+	runs := repository.NewRunRepository()
+	require.NoError(t, runs.Insert(entity.NewRun("interrupted", testCommands)))
+	finished := entity.NewRun("finished", testCommands)
+	finished.Update(testCommands, entity.NewAllDoneResult())
+	require.NoError(t, runs.Insert(finished))
+
+	rh := NewRestHandler(RestHandlerOptions{Executor: aux, Runs: runs}, logrus.New())
+	require.NoError(t, rh.Recover(true))
+
+	require.Eventually(t, func() bool {
+		run, err := runs.Get("interrupted")
+		return err == nil && run.Status.Finished
+	}, 5*time.Second, 10*time.Millisecond)
+
+	run, err := runs.Get("interrupted")
+	require.NoError(t, err)
+	require.NotNil(t, run.Result)
+	assert.True(t, run.Result.IsAllDone())
+
+	run, err = runs.Get("finished")
+	require.NoError(t, err)
+	assert.Len(t, run.Rounds, 1)
+	aux.AssertExpectations(t)
+}
+
+func TestRestHandler_Recover_NoResume(t *testing.T) {
+	runs := repository.NewRunRepository()
+	require.NoError(t, runs.Insert(entity.NewRun("interrupted", testCommands)))
+
+	teardown := new(auxMocks.Teardown)
+	teardown.On("Teardown", mock.Anything, mock.Anything).Return(nil).Once()
+
+	rh := NewRestHandler(RestHandlerOptions{
+		Runs: runs, Teardown: teardown, Policy: entity.TeardownOnFailure,
+	}, logrus.New())
+	require.NoError(t, rh.Recover(false))
+	teardown.AssertExpectations(t)
+
+	run, err := runs.Get("interrupted")
+	require.NoError(t, err)
+	assert.True(t, run.Status.Finished)
+	require.NotNil(t, run.Result)
+	assert.True(t, run.Result.IsFatal())
+	assert.Equal(t, ErrRunInterrupted, run.Result.Error)
+}
+
+func TestRestHandler_AddCommands_DryRun(t *testing.T) {
+	data, err := json.Marshal(testCommands)
+	require.NoError(t, err)
+
+	planner := new(usecaseMocks.PlanUseCase)
+	planner.On("Plan", mock.Anything, mock.Anything).Return(entity.Plan{Valid: true,
+		Rounds: []entity.PlannedRound{{Commands: []entity.PlannedCommand{{ID: "TEST"}}}}}, nil).Run(
+		func(args mock.Arguments) {
+			inst, ok := args.Get(1).(command.Instructions)
+			require.True(t, ok)
+			assert.Len(t, inst.Commands, len(testCommands.Commands))
+		}).Once()
+	planner.On("Plan", mock.Anything, mock.Anything).Return(entity.Plan{Valid: false}, nil).Once()
+	planner.On("Plan", mock.Anything, mock.Anything).Return(entity.Plan{}, command.ErrNoCommands).Once()
+
+	runs := repository.NewRunRepository()
+	rh := NewRestHandler(RestHandlerOptions{Planner: planner, Runs: runs}, logrus.New())
+
+	for _, code := range []int{200, 422, 400} {
+		req, err := http.NewRequest("POST", "/command?dryRun=true", bytes.NewReader(data))
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		rh.AddCommands(recorder, req)
+		assert.Equal(t, code, recorder.Code)
+		if code == 200 {
+			var plan entity.Plan
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &plan))
+			require.Len(t, plan.Rounds, 1)
+			assert.Equal(t, "TEST", plan.Rounds[0].Commands[0].ID)
+		}
+	}
+
+	all, err := runs.GetAll()
+	require.NoError(t, err)
+	assert.Empty(t, all, "a dry run does not create a run")
+	planner.AssertExpectations(t)
+}
+
+func TestRestHandler_TeardownPolicy(t *testing.T) {
+	var tests = []struct {
+		policy   entity.TeardownPolicy
+		res      entity.Result
+		teardown bool
+	}{
+		{policy: entity.TeardownAlways, res: entity.NewSuccessResult(), teardown: true},
+		{policy: entity.TeardownAlways, res: entity.NewFatalResult("err"), teardown: true},
+		{policy: entity.TeardownOnSuccess, res: entity.NewSuccessResult(), teardown: true},
+		{policy: entity.TeardownOnSuccess, res: entity.NewFatalResult("err"), teardown: false},
+		{policy: entity.TeardownOnFailure, res: entity.NewSuccessResult(), teardown: false},
+		{policy: entity.TeardownOnFailure, res: entity.NewFatalResult("err"), teardown: true},
+		{policy: entity.TeardownNever, res: entity.NewSuccessResult(), teardown: false},
+		{policy: entity.TeardownNever, res: entity.NewFatalResult("err"), teardown: false},
+		{policy: entity.TeardownAlways, res: entity.NewFatalResult("err").Trap(), teardown: false},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			data, err := json.Marshal(command.Instructions{ID: "test",
+				Commands: testCommands.Commands[:1]})
+			require.NoError(t, err)
+
+			aux := new(auxMocks.Executor)
+			aux.On("ExecuteCommands", mock.Anything, mock.Anything).Return(tt.res).Once()
+
+			teardown := new(auxMocks.Teardown)
+			if tt.teardown {
+				teardown.On("Teardown", mock.Anything, mock.Anything).Return(nil).Run(
+					func(args mock.Arguments) {
+						inst, ok := args.Get(1).(command.Instructions)
+						require.True(t, ok)
+						assert.Equal(t, "test", inst.ID)
+					}).Once()
+			}
+
+			rh := NewRestHandler(RestHandlerOptions{
+				Executor: aux, Runs: repository.NewRunRepository(), Teardown: teardown, Policy: tt.policy,
+			}, logrus.New())
+
+			req, err := http.NewRequest("POST", "/command?wait=true", bytes.NewReader(data))
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+			rh.AddCommands(recorder, req)
+
+			aux.AssertExpectations(t)
+			teardown.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRestHandler_DestroyTest(t *testing.T) {
+	found := entity.NewTestResources("test")
+	found.Host = "10.0.0.2"
+	found.Containers = []string{"node0"}
+
+	reaper := new(usecaseMocks.ReaperUseCase)
+	reaper.On("Destroy", mock.Anything, "10.0.0.2", "test", true).Return(found, nil).Once()
+	reaper.On("Destroy", mock.Anything, "", "test", false).Return(
+		entity.TestResources{}, usecase.ErrHostRequired).Once()
+
+	rh := NewRestHandler(RestHandlerOptions{
+		Reaper: reaper, Runs: repository.NewRunRepository(),
+	}, logrus.New())
+
+	req := httptest.NewRequest("DELETE", "/test/test?host=10.0.0.2&dryRun=true", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "test"})
+	recorder := httptest.NewRecorder()
+	rh.DestroyTest(recorder, req)
+	assert.Equal(t, 200, recorder.Code)
+
+	var res entity.TestResources
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	assert.Equal(t, found, res)
+
+	req = httptest.NewRequest("DELETE", "/test/test", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "test"})
+	recorder = httptest.NewRecorder()
+	rh.DestroyTest(recorder, req)
+	assert.Equal(t, 400, recorder.Code)
+
+	reaper.AssertExpectations(t)
+}
+
+func TestRestHandler_Sweep(t *testing.T) {
+	reaper := new(usecaseMocks.ReaperUseCase)
+	reaper.On("Sweep", mock.Anything, true).Return(
+		[]entity.TestResources{entity.NewTestResources("test")}, nil).Once()
+	reaper.On("Sweep", mock.Anything, false).Return(nil, fmt.Errorf("err")).Once()
+
+	rh := NewRestHandler(RestHandlerOptions{
+		Reaper: reaper, Runs: repository.NewRunRepository(),
+	}, logrus.New())
+
+	recorder := httptest.NewRecorder()
+	rh.Sweep(recorder, httptest.NewRequest("POST", "/test/sweep?dryRun=true", nil))
+	assert.Equal(t, 200, recorder.Code)
+
+	var res []entity.TestResources
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	require.Len(t, res, 1)
+	assert.Equal(t, "test", res[0].TestID)
+
+	recorder = httptest.NewRecorder()
+	rh.Sweep(recorder, httptest.NewRequest("POST", "/test/sweep", nil))
+	assert.Equal(t, 500, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	rh.Sweep(recorder, httptest.NewRequest("POST", "/test/sweep?dryRun=maybe", nil))
+	assert.Equal(t, 400, recorder.Code)
+
+	reaper.AssertExpectations(t)
+}
+
+func TestRestHandler_NotImplemented(t *testing.T) {
+	data, err := json.Marshal(testCommands)
+	require.NoError(t, err)
+	rh := NewRestHandler(RestHandlerOptions{Runs: repository.NewRunRepository()}, logrus.New())
+
+	recorder := httptest.NewRecorder()
+	rh.AddCommands(recorder, httptest.NewRequest("POST", "/command?dryRun=true", bytes.NewReader(data)))
+	assert.Equal(t, http.StatusNotImplemented, recorder.Code)
+
+	req := mux.SetURLVars(httptest.NewRequest("DELETE", "/test/test", nil), map[string]string{"id": "test"})
+	recorder = httptest.NewRecorder()
+	rh.DestroyTest(recorder, req)
+	assert.Equal(t, http.StatusNotImplemented, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	rh.Sweep(recorder, httptest.NewRequest("POST", "/test/sweep", nil))
+	assert.Equal(t, http.StatusNotImplemented, recorder.Code)
+}
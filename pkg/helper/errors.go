@@ -0,0 +1,22 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package helper
+
+import (
+	"fmt"
+)
+
+// JoinErrors joins e onto err, keeping e in the chain. Either may be nil.
+func JoinErrors(err error, e error) error {
+	if err == nil {
+		return e
+	}
+	if e == nil {
+		return err
+	}
+	return fmt.Errorf("%v;%w", err, e)
+}
@@ -0,0 +1,27 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package helper
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinErrors(t *testing.T) {
+	first := errors.New("first")
+	second := errors.New("second")
+
+	assert.NoError(t, JoinErrors(nil, nil))
+	assert.Equal(t, first, JoinErrors(nil, first))
+	assert.Equal(t, first, JoinErrors(first, nil))
+
+	err := JoinErrors(first, second)
+	assert.EqualError(t, err, "first;second")
+	assert.True(t, errors.Is(err, second))
+}
@@ -0,0 +1,249 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package metrics
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+)
+
+type instrumentedClient struct {
+	entity.Client
+	host string
+}
+
+// InstrumentClient wraps the given client so that the latency and failures of its calls
+// to the docker api are recorded
+func InstrumentClient(cli entity.Client) entity.Client {
+	return &instrumentedClient{Client: cli, host: cli.DaemonHost()}
+}
+
+func (ic instrumentedClient) observe(method string, start time.Time, err error) {
+	DockerCallDuration.WithLabelValues(ic.host, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		DockerCallErrors.WithLabelValues(ic.host, method).Inc()
+	}
+}
+
+func (ic instrumentedClient) ContainerAttach(ctx context.Context, container string,
+	options types.ContainerAttachOptions) (out types.HijackedResponse, err error) {
+	defer func(start time.Time) { ic.observe("ContainerAttach", start, err) }(time.Now())
+	return ic.Client.ContainerAttach(ctx, container, options)
+}
+
+func (ic instrumentedClient) ContainerCreate(ctx context.Context, config *container.Config,
+	hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig,
+	containerName string) (out container.ContainerCreateCreatedBody, err error) {
+	defer func(start time.Time) { ic.observe("ContainerCreate", start, err) }(time.Now())
+	return ic.Client.ContainerCreate(ctx, config, hostConfig, networkingConfig, containerName)
+}
+
+func (ic instrumentedClient) ContainerExecAttach(ctx context.Context, execID string,
+	config types.ExecStartCheck) (out types.HijackedResponse, err error) {
+	defer func(start time.Time) { ic.observe("ContainerExecAttach", start, err) }(time.Now())
+	return ic.Client.ContainerExecAttach(ctx, execID, config)
+}
+
+func (ic instrumentedClient) ContainerExecCreate(ctx context.Context, container string,
+	config types.ExecConfig) (out types.IDResponse, err error) {
+	defer func(start time.Time) { ic.observe("ContainerExecCreate", start, err) }(time.Now())
+	return ic.Client.ContainerExecCreate(ctx, container, config)
+}
+
+func (ic instrumentedClient) ContainerExecInspect(ctx context.Context,
+	execID string) (out types.ContainerExecInspect, err error) {
+	defer func(start time.Time) { ic.observe("ContainerExecInspect", start, err) }(time.Now())
+	return ic.Client.ContainerExecInspect(ctx, execID)
+}
+
+func (ic instrumentedClient) ContainerExecStart(ctx context.Context, execID string,
+	config types.ExecStartCheck) (err error) {
+	defer func(start time.Time) { ic.observe("ContainerExecStart", start, err) }(time.Now())
+	return ic.Client.ContainerExecStart(ctx, execID, config)
+}
+
+func (ic instrumentedClient) ContainerInspect(ctx context.Context,
+	containerID string) (out types.ContainerJSON, err error) {
+	defer func(start time.Time) { ic.observe("ContainerInspect", start, err) }(time.Now())
+	return ic.Client.ContainerInspect(ctx, containerID)
+}
+
+func (ic instrumentedClient) ContainerKill(ctx context.Context, containerID, signal string) (err error) {
+	defer func(start time.Time) { ic.observe("ContainerKill", start, err) }(time.Now())
+	return ic.Client.ContainerKill(ctx, containerID, signal)
+}
+
+func (ic instrumentedClient) ContainerList(ctx context.Context,
+	options types.ContainerListOptions) (out []types.Container, err error) {
+	defer func(start time.Time) { ic.observe("ContainerList", start, err) }(time.Now())
+	return ic.Client.ContainerList(ctx, options)
+}
+
+func (ic instrumentedClient) ContainerLogs(ctx context.Context, container string,
+	options types.ContainerLogsOptions) (out io.ReadCloser, err error) {
+	defer func(start time.Time) { ic.observe("ContainerLogs", start, err) }(time.Now())
+	return ic.Client.ContainerLogs(ctx, container, options)
+}
+
+func (ic instrumentedClient) ContainerPause(ctx context.Context, containerID string) (err error) {
+	defer func(start time.Time) { ic.observe("ContainerPause", start, err) }(time.Now())
+	return ic.Client.ContainerPause(ctx, containerID)
+}
+
+func (ic instrumentedClient) ContainerRemove(ctx context.Context, containerID string,
+	options types.ContainerRemoveOptions) (err error) {
+	defer func(start time.Time) { ic.observe("ContainerRemove", start, err) }(time.Now())
+	return ic.Client.ContainerRemove(ctx, containerID, options)
+}
+
+func (ic instrumentedClient) ContainerRestart(ctx context.Context, containerID string,
+	timeout *time.Duration) (err error) {
+	defer func(start time.Time) { ic.observe("ContainerRestart", start, err) }(time.Now())
+	return ic.Client.ContainerRestart(ctx, containerID, timeout)
+}
+
+func (ic instrumentedClient) ContainerStart(ctx context.Context, containerID string,
+	options types.ContainerStartOptions) (err error) {
+	defer func(start time.Time) { ic.observe("ContainerStart", start, err) }(time.Now())
+	return ic.Client.ContainerStart(ctx, containerID, options)
+}
+
+func (ic instrumentedClient) ContainerStats(ctx context.Context, container string,
+	stream bool) (out types.ContainerStats, err error) {
+	defer func(start time.Time) { ic.observe("ContainerStats", start, err) }(time.Now())
+	return ic.Client.ContainerStats(ctx, container, stream)
+}
+
+func (ic instrumentedClient) ContainerStatPath(ctx context.Context, containerID,
+	path string) (out types.ContainerPathStat, err error) {
+	defer func(start time.Time) { ic.observe("ContainerStatPath", start, err) }(time.Now())
+	return ic.Client.ContainerStatPath(ctx, containerID, path)
+}
+
+func (ic instrumentedClient) ContainerStop(ctx context.Context, containerID string,
+	timeout *time.Duration) (err error) {
+	defer func(start time.Time) { ic.observe("ContainerStop", start, err) }(time.Now())
+	return ic.Client.ContainerStop(ctx, containerID, timeout)
+}
+
+func (ic instrumentedClient) ContainerUnpause(ctx context.Context, containerID string) (err error) {
+	defer func(start time.Time) { ic.observe("ContainerUnpause", start, err) }(time.Now())
+	return ic.Client.ContainerUnpause(ctx, containerID)
+}
+
+// ContainerWait only observes the time it takes for the wait to be acknowledged, not the wait itself
+func (ic instrumentedClient) ContainerWait(ctx context.Context, containerID string,
+	condition container.WaitCondition) (<-chan container.ContainerWaitOKBody, <-chan error) {
+	defer func(start time.Time) { ic.observe("ContainerWait", start, nil) }(time.Now())
+	return ic.Client.ContainerWait(ctx, containerID, condition)
+}
+
+func (ic instrumentedClient) CopyToContainer(ctx context.Context, containerID, dstPath string,
+	content io.Reader, options types.CopyToContainerOptions) (err error) {
+	defer func(start time.Time) { ic.observe("CopyToContainer", start, err) }(time.Now())
+	return ic.Client.CopyToContainer(ctx, containerID, dstPath, content, options)
+}
+
+func (ic instrumentedClient) ImageList(ctx context.Context,
+	options types.ImageListOptions) (out []types.ImageSummary, err error) {
+	defer func(start time.Time) { ic.observe("ImageList", start, err) }(time.Now())
+	return ic.Client.ImageList(ctx, options)
+}
+
+func (ic instrumentedClient) ImageLoad(ctx context.Context, input io.Reader,
+	quiet bool) (out types.ImageLoadResponse, err error) {
+	defer func(start time.Time) { ic.observe("ImageLoad", start, err) }(time.Now())
+	return ic.Client.ImageLoad(ctx, input, quiet)
+}
+
+func (ic instrumentedClient) ImagePull(ctx context.Context, refStr string,
+	options types.ImagePullOptions) (out io.ReadCloser, err error) {
+	defer func(start time.Time) { ic.observe("ImagePull", start, err) }(time.Now())
+	return ic.Client.ImagePull(ctx, refStr, options)
+}
+
+func (ic instrumentedClient) NetworkCreate(ctx context.Context, name string,
+	options types.NetworkCreate) (out types.NetworkCreateResponse, err error) {
+	defer func(start time.Time) { ic.observe("NetworkCreate", start, err) }(time.Now())
+	return ic.Client.NetworkCreate(ctx, name, options)
+}
+
+func (ic instrumentedClient) NetworkConnect(ctx context.Context, networkID, containerID string,
+	config *network.EndpointSettings) (err error) {
+	defer func(start time.Time) { ic.observe("NetworkConnect", start, err) }(time.Now())
+	return ic.Client.NetworkConnect(ctx, networkID, containerID, config)
+}
+
+func (ic instrumentedClient) NetworkDisconnect(ctx context.Context, networkID, containerID string,
+	force bool) (err error) {
+	defer func(start time.Time) { ic.observe("NetworkDisconnect", start, err) }(time.Now())
+	return ic.Client.NetworkDisconnect(ctx, networkID, containerID, force)
+}
+
+func (ic instrumentedClient) NetworkInspect(ctx context.Context, networkID string,
+	options types.NetworkInspectOptions) (out types.NetworkResource, err error) {
+	defer func(start time.Time) { ic.observe("NetworkInspect", start, err) }(time.Now())
+	return ic.Client.NetworkInspect(ctx, networkID, options)
+}
+
+func (ic instrumentedClient) NetworkRemove(ctx context.Context, networkID string) (err error) {
+	defer func(start time.Time) { ic.observe("NetworkRemove", start, err) }(time.Now())
+	return ic.Client.NetworkRemove(ctx, networkID)
+}
+
+func (ic instrumentedClient) NetworkList(ctx context.Context,
+	options types.NetworkListOptions) (out []types.NetworkResource, err error) {
+	defer func(start time.Time) { ic.observe("NetworkList", start, err) }(time.Now())
+	return ic.Client.NetworkList(ctx, options)
+}
+
+func (ic instrumentedClient) Ping(ctx context.Context) (out types.Ping, err error) {
+	defer func(start time.Time) { ic.observe("Ping", start, err) }(time.Now())
+	return ic.Client.Ping(ctx)
+}
+
+func (ic instrumentedClient) SwarmInit(ctx context.Context, req swarm.InitRequest) (out string, err error) {
+	defer func(start time.Time) { ic.observe("SwarmInit", start, err) }(time.Now())
+	return ic.Client.SwarmInit(ctx, req)
+}
+
+func (ic instrumentedClient) SwarmJoin(ctx context.Context, req swarm.JoinRequest) (err error) {
+	defer func(start time.Time) { ic.observe("SwarmJoin", start, err) }(time.Now())
+	return ic.Client.SwarmJoin(ctx, req)
+}
+
+func (ic instrumentedClient) SwarmInspect(ctx context.Context) (out swarm.Swarm, err error) {
+	defer func(start time.Time) { ic.observe("SwarmInspect", start, err) }(time.Now())
+	return ic.Client.SwarmInspect(ctx)
+}
+
+func (ic instrumentedClient) VolumeCreate(ctx context.Context,
+	options volume.VolumeCreateBody) (out types.Volume, err error) {
+	defer func(start time.Time) { ic.observe("VolumeCreate", start, err) }(time.Now())
+	return ic.Client.VolumeCreate(ctx, options)
+}
+
+func (ic instrumentedClient) VolumeList(ctx context.Context,
+	filter filters.Args) (out volume.VolumeListOKBody, err error) {
+	defer func(start time.Time) { ic.observe("VolumeList", start, err) }(time.Now())
+	return ic.Client.VolumeList(ctx, filter)
+}
+
+func (ic instrumentedClient) VolumeRemove(ctx context.Context, volumeID string, force bool) (err error) {
+	defer func(start time.Time) { ic.observe("VolumeRemove", start, err) }(time.Now())
+	return ic.Client.VolumeRemove(ctx, volumeID, force)
+}
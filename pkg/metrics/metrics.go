@@ -0,0 +1,150 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package metrics
+
+import (
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/whiteblock/definition/command"
+)
+
+const namespace = "genesis"
+
+// UnknownOrder is the type label given to orders of a type which is not executed
+const UnknownOrder = "unknown"
+
+// knownOrders are the order types which are used as labels as they are, anything else is
+// counted as UnknownOrder, so that the number of series can't grow without bound
+var knownOrders = map[command.OrderType]bool{
+	command.Createcontainer:      true,
+	command.Startcontainer:       true,
+	command.Removecontainer:      true,
+	command.Createnetwork:        true,
+	command.Attachnetwork:        true,
+	command.Detachnetwork:        true,
+	command.Removenetwork:        true,
+	command.Createvolume:         true,
+	command.Removevolume:         true,
+	command.Putfileincontainer:   true,
+	command.Emulation:            true,
+	command.SwarmInit:            true,
+	command.Pullimage:            true,
+	command.Volumeshare:          true,
+	command.Pauseexecution:       true,
+	command.Resumeexecution:      true,
+	entity.StopContainerOrder:    true,
+	entity.RestartContainerOrder: true,
+	entity.KillContainerOrder:    true,
+	entity.PauseContainerOrder:   true,
+	entity.UnpauseContainerOrder: true,
+	entity.ExecOrder:             true,
+	entity.DestroyTestOrder:      true,
+	entity.ClearEmulationOrder:   true,
+	entity.ReadEmulationOrder:    true,
+	entity.PeerEmulationOrder:    true,
+	entity.PartitionOrder:        true,
+	entity.HealOrder:             true,
+}
+
+var (
+	// MessagesConsumed counts the messages received from the command queue
+	MessagesConsumed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "messages_consumed_total",
+		Help:      "The number of messages consumed from the command queue",
+	})
+
+	// MessagesAcked counts the messages acked, by the type of their result
+	MessagesAcked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "messages_acked_total",
+		Help:      "The number of messages acked, by result type",
+	}, []string{"result"})
+
+	// MessagesRequeued counts the messages requeued, by the type of their result
+	MessagesRequeued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "messages_requeued_total",
+		Help:      "The number of messages requeued, by result type",
+	}, []string{"result"})
+
+	// ConcurrencyInUse is the number of messages currently being handled
+	ConcurrencyInUse = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "concurrency_in_use",
+		Help:      "The number of messages currently being handled",
+	})
+
+	// ConcurrencyLimit is the maximum number of messages which can be handled at once
+	ConcurrencyLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "concurrency_limit",
+		Help:      "The maximum number of messages which can be handled at once",
+	})
+
+	// ExecutionDuration is the time taken to execute a round of commands, by the type of the result
+	ExecutionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "executor",
+		Name:      "execute_commands_duration_seconds",
+		Help:      "The time taken to execute a round of commands, by result type",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 16),
+	}, []string{"result"})
+
+	// Orders counts the orders executed, by their type and whether they succeeded
+	Orders = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "usecase",
+		Name:      "orders_total",
+		Help:      "The number of orders executed, by order type and outcome",
+	}, []string{"type", "outcome"})
+
+	// DockerCallDuration is the latency of calls to the docker api, by host and method
+	DockerCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "docker",
+		Name:      "call_duration_seconds",
+		Help:      "The latency of calls to the docker api, by host and method",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 16),
+	}, []string{"host", "method"})
+
+	// DockerCallErrors counts the calls to the docker api which returned an error, by host and method
+	DockerCallErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "docker",
+		Name:      "call_errors_total",
+		Help:      "The number of calls to the docker api which failed, by host and method",
+	}, []string{"host", "method"})
+)
+
+// ObserveExecution records the duration of a round of commands which started at the given time
+func ObserveExecution(start time.Time, res entity.Result) {
+	ExecutionDuration.WithLabelValues(res.Type.String()).Observe(time.Since(start).Seconds())
+}
+
+// ObserveOrder records the outcome of executing an order of the given type. Unknown types
+// are recorded as UnknownOrder.
+func ObserveOrder(orderType command.OrderType, res entity.Result) {
+	outcome := "success"
+	if !res.IsSuccess() {
+		outcome = "failure"
+	}
+	label := UnknownOrder
+	if knownOrders[orderType] {
+		label = string(orderType)
+	}
+	Orders.WithLabelValues(label, outcome).Inc()
+}
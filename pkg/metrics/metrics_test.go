@@ -0,0 +1,58 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestObserveOrder(t *testing.T) {
+	success := Orders.WithLabelValues("createcontainer", "success")
+	failure := Orders.WithLabelValues("createcontainer", "failure")
+	before := testutil.ToFloat64(success)
+
+	ObserveOrder("createcontainer", entity.NewSuccessResult())
+	ObserveOrder("createcontainer", entity.NewErrorResult("err"))
+	ObserveOrder("createcontainer", entity.NewFatalResult("err"))
+
+	assert.Equal(t, before+1, testutil.ToFloat64(success))
+	assert.Equal(t, float64(2), testutil.ToFloat64(failure))
+}
+
+func TestObserveOrder_Unknown(t *testing.T) {
+	unknown := Orders.WithLabelValues(UnknownOrder, "failure")
+	before := testutil.ToFloat64(unknown)
+
+	ObserveOrder("made up", entity.NewFatalResult("err"))
+	ObserveOrder("also made up", entity.NewFatalResult("err"))
+
+	assert.Equal(t, before+2, testutil.ToFloat64(unknown))
+}
+
+func TestInstrumentClient(t *testing.T) {
+	cli := new(entityMock.Client)
+	cli.On("DaemonHost").Return("tcp://test:2376").Once()
+	cli.On("NetworkRemove", mock.Anything, "good").Return(nil).Once()
+	cli.On("NetworkRemove", mock.Anything, "bad").Return(errors.New("err")).Once()
+
+	instrumented := InstrumentClient(cli)
+	assert.NoError(t, instrumented.NetworkRemove(context.Background(), "good"))
+	assert.Error(t, instrumented.NetworkRemove(context.Background(), "bad"))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(
+		DockerCallErrors.WithLabelValues("tcp://test:2376", "NetworkRemove")))
+	cli.AssertExpectations(t)
+}
@@ -0,0 +1,252 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+)
+
+const (
+	journalExt = ".json"
+	updatesExt = ".log"
+)
+
+type runJournal struct {
+	*runRepository
+	dir       string
+	retention time.Duration
+
+	// logged is the number of updates in the log of each run since its entry was last written
+	logged map[string]int
+}
+
+// NewRunJournal creates a RunRepository which persists each run in the given directory, so
+// that runs survive a restart. The runs already in the directory are loaded. Finished runs are
+// removed once they ended more than retention ago, or kept forever if retention is 0.
+//
+// Each run has an entry holding its whole state, and a log to which each update is appended.
+// The log is folded into the entry once the run finishes, or once it holds entity.MaxRounds
+// updates.
+func NewRunJournal(dir string, retention time.Duration) (RunRepository, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	out := &runJournal{
+		runRepository: &runRepository{runs: map[string]entity.Run{}},
+		dir:           dir,
+		retention:     retention,
+		logged:        map[string]int{},
+	}
+	err = out.load()
+	if err != nil {
+		return nil, err
+	}
+	return out, out.prune()
+}
+
+func (rj *runJournal) path(id string, ext string) string {
+	return filepath.Join(rj.dir, filepath.Base(id)+ext)
+}
+
+func (rj *runJournal) load() error {
+	files, err := ioutil.ReadDir(rj.dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), journalExt) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(rj.dir, file.Name()))
+		if err != nil {
+			return err
+		}
+		var run entity.Run
+		err = json.Unmarshal(data, &run)
+		if err != nil {
+			return err
+		}
+		run, replayed, err := rj.replay(run)
+		if err != nil {
+			return err
+		}
+		if replayed {
+			// the log may end in a partial update, so it is not appended to again
+			err = rj.compact(run)
+			if err != nil {
+				return err
+			}
+		}
+		rj.runs[run.ID] = run
+	}
+	return nil
+}
+
+// replay applies the updates in the log of the given run, stopping at the first one which
+// is incomplete. It returns whether there was a log.
+func (rj *runJournal) replay(run entity.Run) (entity.Run, bool, error) {
+	file, err := os.Open(rj.path(run.ID, updatesExt))
+	if os.IsNotExist(err) {
+		return run, false, nil
+	}
+	if err != nil {
+		return run, false, err
+	}
+	defer file.Close()
+
+	rdr := bufio.NewReader(file)
+	for {
+		line, err := rdr.ReadBytes('\n')
+		if err == io.EOF { // a partial update, from being interrupted while it was written
+			return run, true, nil
+		}
+		if err != nil {
+			return run, true, err
+		}
+		var update entity.Run
+		if json.Unmarshal(line, &update) != nil {
+			return run, true, nil
+		}
+		run = apply(run, update)
+	}
+}
+
+// apply applies an update from the log onto the state of a run. Updates hold the whole state
+// of the run except for its rounds, the result of the round which caused the update is its result.
+func apply(run entity.Run, update entity.Run) entity.Run {
+	update.Rounds, update.DroppedRounds = run.Rounds, run.DroppedRounds
+	if update.Result != nil {
+		update.AddRound(*update.Result)
+	}
+	return update
+}
+
+// write atomically replaces the journal entry for the given run
+func (rj *runJournal) write(run entity.Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(rj.dir, run.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), rj.path(run.ID, journalExt))
+}
+
+// compact replaces the journal entry for the given run and removes its log
+func (rj *runJournal) compact(run entity.Run) error {
+	err := rj.write(run)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(rj.path(run.ID, updatesExt))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	rj.logged[run.ID] = 0
+	return nil
+}
+
+// log appends an update to the log of the given run. It isn't synced, as losing it in a crash
+// only causes the round which caused it to be executed again once the run is resumed.
+func (rj *runJournal) log(run entity.Run) error {
+	run.Rounds = nil
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(rj.path(run.ID, updatesExt), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	rj.logged[run.ID]++
+	return nil
+}
+
+// prune removes the runs which finished more than the retention period ago
+func (rj *runJournal) prune() error {
+	if rj.retention <= 0 {
+		return nil
+	}
+	for id, run := range rj.runs {
+		if !run.Status.Finished || time.Since(run.Ended) < rj.retention {
+			continue
+		}
+		for _, ext := range []string{journalExt, updatesExt} {
+			err := os.Remove(rj.path(id, ext))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		delete(rj.runs, id)
+		delete(rj.logged, id)
+	}
+	return nil
+}
+
+// Insert adds a new run, and removes the runs which have outlived the retention period
+func (rj *runJournal) Insert(run entity.Run) error {
+	rj.mux.Lock()
+	defer rj.mux.Unlock()
+	err := rj.write(run)
+	if err != nil {
+		return err
+	}
+	rj.runs[run.ID] = copyRun(run)
+	return rj.prune()
+}
+
+// Update replaces the stored state of an existing run. Only the latest round is written,
+// unless the run has finished or its log is full.
+func (rj *runJournal) Update(run entity.Run) error {
+	rj.mux.Lock()
+	defer rj.mux.Unlock()
+	if _, exists := rj.runs[run.ID]; !exists {
+		return ErrRunNotFound
+	}
+	var err error
+	if run.Status.Finished || rj.logged[run.ID] >= entity.MaxRounds {
+		err = rj.compact(run)
+	} else {
+		err = rj.log(run)
+	}
+	if err != nil {
+		return err
+	}
+	rj.runs[run.ID] = copyRun(run)
+	return nil
+}
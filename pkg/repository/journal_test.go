@@ -0,0 +1,150 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/whiteblock/definition/command"
+)
+
+func TestRunJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	journal, err := NewRunJournal(dir, 0)
+	require.NoError(t, err)
+
+	inst := command.Instructions{ID: "test", Commands: [][]command.Command{{{ID: "1"}}, {{ID: "2"}}}}
+	run := entity.NewRun("foo", inst)
+	require.NoError(t, journal.Insert(run))
+
+	inst.Next()
+	run.Update(inst, entity.NewRequeueResult())
+	require.NoError(t, journal.Update(run))
+	assert.Equal(t, ErrRunNotFound, journal.Update(entity.Run{ID: "bar"}))
+
+	reopened, err := NewRunJournal(dir, 0)
+	require.NoError(t, err)
+
+	recovered, err := reopened.Get("foo")
+	require.NoError(t, err)
+	assert.False(t, recovered.Status.Finished)
+	assert.Equal(t, "test", recovered.Instructions.ID)
+	require.Len(t, recovered.Instructions.Commands, 1)
+	assert.Equal(t, "2", recovered.Instructions.Commands[0][0].ID)
+	require.Len(t, recovered.Rounds, 1)
+	assert.Equal(t, entity.RequeueType, recovered.Rounds[0].Type)
+	assert.WithinDuration(t, run.Started, recovered.Started, time.Millisecond)
+
+	runs, err := reopened.GetAll()
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+}
+
+func TestRunJournal_Log(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	journal, err := NewRunJournal(dir, 0)
+	require.NoError(t, err)
+
+	inst := command.Instructions{ID: "test", Commands: [][]command.Command{{{ID: "1"}}}}
+	run := entity.NewRun("foo", inst)
+	require.NoError(t, journal.Insert(run))
+	for i := 0; i < entity.MaxRounds+10; i++ {
+		run.Update(inst, entity.NewErrorResult("err"))
+		require.NoError(t, journal.Update(run))
+	}
+	entry, err := ioutil.ReadFile(filepath.Join(dir, "foo"+journalExt))
+	require.NoError(t, err)
+
+	// an update which was interrupted while it was written is ignored
+	log, err := os.OpenFile(filepath.Join(dir, "foo"+updatesExt), os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = log.Write([]byte(`{"id":"foo","sta`))
+	require.NoError(t, err)
+	require.NoError(t, log.Close())
+
+	reopened, err := NewRunJournal(dir, 0)
+	require.NoError(t, err)
+	recovered, err := reopened.Get("foo")
+	require.NoError(t, err)
+	assert.Equal(t, run.Rounds, recovered.Rounds)
+	assert.Equal(t, run.DroppedRounds, recovered.DroppedRounds)
+	assert.False(t, recovered.Status.Finished)
+
+	// the log was folded into the entry when it was full, and again when it was reopened
+	compacted, err := ioutil.ReadFile(filepath.Join(dir, "foo"+journalExt))
+	require.NoError(t, err)
+	assert.NotEqual(t, entry, compacted)
+	_, err = os.Stat(filepath.Join(dir, "foo"+updatesExt))
+	assert.True(t, os.IsNotExist(err))
+
+	run.Update(inst, entity.NewAllDoneResult())
+	require.NoError(t, reopened.Update(run))
+	_, err = os.Stat(filepath.Join(dir, "foo"+updatesExt))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunJournal_Retention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	journal, err := NewRunJournal(dir, time.Hour)
+	require.NoError(t, err)
+
+	inst := command.Instructions{ID: "test", Commands: [][]command.Command{{{ID: "1"}}}}
+	old := entity.NewRun("old", inst)
+	require.NoError(t, journal.Insert(old))
+	old.Update(inst, entity.NewAllDoneResult())
+	old.Ended = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, journal.Update(old))
+
+	recent := entity.NewRun("recent", inst)
+	require.NoError(t, journal.Insert(recent))
+	recent.Update(inst, entity.NewAllDoneResult())
+	require.NoError(t, journal.Update(recent))
+
+	running := entity.NewRun("running", inst)
+	running.Started = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, journal.Insert(running))
+
+	_, err = journal.Get("old")
+	assert.Equal(t, ErrRunNotFound, err)
+	_, err = os.Stat(filepath.Join(dir, "old"+journalExt))
+	assert.True(t, os.IsNotExist(err))
+
+	reopened, err := NewRunJournal(dir, time.Hour)
+	require.NoError(t, err)
+	runs, err := reopened.GetAll()
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "running", runs[0].ID)
+	assert.Equal(t, "recent", runs[1].ID)
+}
+
+func TestRunJournal_Failure(t *testing.T) {
+	file, err := ioutil.TempFile("", "journal")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.Close()
+
+	_, err = NewRunJournal(file.Name(), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,148 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	queue "github.com/whiteblock/amqp"
+	"github.com/whiteblock/definition/command"
+)
+
+const logExt = ".jsonl"
+
+// httpLogSinkTimeout is how long the HTTP log sink waits for a line to be accepted
+const httpLogSinkTimeout = 10 * time.Second
+
+// LogSink is where the lines logged by the containers of tests are forwarded to
+type LogSink interface {
+	// Write forwards a line logged by a container
+	Write(line entity.LogLine) error
+
+	// Close releases the resources of the sink, once nothing more is written to it
+	Close() error
+}
+
+type fileLogSink struct {
+	mux   sync.Mutex
+	dir   string
+	files map[string]*os.File
+}
+
+// NewFileLogSink creates a LogSink which appends the lines logged by each container, as JSON,
+// to a file of its own in the directory of its test
+func NewFileLogSink(dir string) (LogSink, error) {
+	return &fileLogSink{dir: dir, files: map[string]*os.File{}}, os.MkdirAll(dir, 0700)
+}
+
+// LogPath gets the path of the logs of the given container of the given test, within the given directory
+func LogPath(dir string, testID string, container string) string {
+	return filepath.Join(dir, filepath.Base(testID), filepath.Base(container)+logExt)
+}
+
+// Write appends the line to the file of its container
+func (fls *fileLogSink) Write(line entity.LogLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	fls.mux.Lock()
+	defer fls.mux.Unlock()
+	path := LogPath(fls.dir, line.Labels[command.TestIDKey], line.Container)
+	file, exists := fls.files[path]
+	if !exists {
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			return err
+		}
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		fls.files[path] = file
+	}
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the files which were written to
+func (fls *fileLogSink) Close() (err error) {
+	fls.mux.Lock()
+	defer fls.mux.Unlock()
+	for path, file := range fls.files {
+		if e := file.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(fls.files, path)
+	}
+	return
+}
+
+type amqpLogSink struct {
+	queue queue.AMQPService
+}
+
+// NewAMQPLogSink creates a LogSink which sends each line, as JSON, to the given queue
+func NewAMQPLogSink(logs queue.AMQPService) LogSink {
+	return &amqpLogSink{queue: logs}
+}
+
+// Write sends the line to the queue
+func (als amqpLogSink) Write(line entity.LogLine) error {
+	msg, err := queue.CreateMessage(line)
+	if err != nil {
+		return err
+	}
+	return als.queue.Send(msg)
+}
+
+// Close does nothing, the connection to the queue is left open
+func (als amqpLogSink) Close() error {
+	return nil
+}
+
+type httpLogSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPLogSink creates a LogSink which posts each line, as JSON, to the given url
+func NewHTTPLogSink(url string) LogSink {
+	return &httpLogSink{url: url, client: &http.Client{Timeout: httpLogSinkTimeout}}
+}
+
+// Write posts the line to the url, and fails unless it is accepted with a 2xx status
+func (hls httpLogSink) Write(line entity.LogLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	resp, err := hls.client.Post(hls.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("the log sink responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Close closes the idle connections to the url
+func (hls httpLogSink) Close() error {
+	hls.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,90 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	queue "github.com/whiteblock/genesis/mocks/amqp"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/whiteblock/definition/command"
+)
+
+func logLine(container string, line string) entity.LogLine {
+	return entity.LogLine{Container: container, Stream: "stdout", Line: line,
+		Time:   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Labels: map[string]string{command.TestIDKey: "test0", command.PhaseKey: "setup"}}
+}
+
+func TestFileLogSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sink, err := NewFileLogSink(dir)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write(logLine("node0", "a")))
+	require.NoError(t, sink.Write(logLine("node1", "b")))
+	require.NoError(t, sink.Write(logLine("node0", "c")))
+	require.NoError(t, sink.Close())
+
+	data, err := ioutil.ReadFile(LogPath(dir, "test0", "node0"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	var line entity.LogLine
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &line))
+	assert.Equal(t, logLine("node0", "c"), line)
+
+	_, err = os.Stat(LogPath(dir, "test0", "node1"))
+	assert.NoError(t, err)
+}
+
+func TestHTTPLogSink(t *testing.T) {
+	received := []entity.LogLine{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var line entity.LogLine
+		err := json.NewDecoder(r.Body).Decode(&line)
+		if err != nil || line.Line == "reject" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, line)
+	}))
+	defer server.Close()
+
+	sink := NewHTTPLogSink(server.URL)
+	require.NoError(t, sink.Write(logLine("node0", "a")))
+	assert.EqualError(t, sink.Write(logLine("node0", "reject")), "the log sink responded with 400 Bad Request")
+	assert.NoError(t, sink.Close())
+	assert.Equal(t, []entity.LogLine{logLine("node0", "a")}, received)
+}
+
+func TestAMQPLogSink(t *testing.T) {
+	logs := new(queue.AMQPService)
+	logs.On("Send", mock.MatchedBy(func(pub amqp.Publishing) bool {
+		var line entity.LogLine
+		return json.Unmarshal(pub.Body, &line) == nil && line.Line == "a"
+	})).Return(nil).Once()
+
+	sink := NewAMQPLogSink(logs)
+	assert.NoError(t, sink.Write(logLine("node0", "a")))
+	assert.NoError(t, sink.Close())
+	logs.AssertExpectations(t)
+}
@@ -0,0 +1,61 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"sort"
+	"sync"
+)
+
+// QueuedTestRepository keeps track of the tests being run through the command queue which
+// have not finished yet
+type QueuedTestRepository interface {
+	// Add marks the given test as live
+	Add(testID string)
+
+	// Remove marks the given test as finished
+	Remove(testID string)
+
+	// GetAll gets the tests which are live, sorted
+	GetAll() []string
+}
+
+type queuedTestRepository struct {
+	mux   sync.RWMutex
+	tests map[string]bool
+}
+
+// NewQueuedTestRepository creates a new in memory QueuedTestRepository
+func NewQueuedTestRepository() QueuedTestRepository {
+	return &queuedTestRepository{tests: map[string]bool{}}
+}
+
+// Add marks the given test as live
+func (qtr *queuedTestRepository) Add(testID string) {
+	qtr.mux.Lock()
+	defer qtr.mux.Unlock()
+	qtr.tests[testID] = true
+}
+
+// Remove marks the given test as finished
+func (qtr *queuedTestRepository) Remove(testID string) {
+	qtr.mux.Lock()
+	defer qtr.mux.Unlock()
+	delete(qtr.tests, testID)
+}
+
+// GetAll gets the tests which are live, sorted
+func (qtr *queuedTestRepository) GetAll() []string {
+	qtr.mux.RLock()
+	defer qtr.mux.RUnlock()
+	out := make([]string, 0, len(qtr.tests))
+	for testID := range qtr.tests {
+		out = append(out, testID)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,27 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueuedTestRepository(t *testing.T) {
+	repo := NewQueuedTestRepository()
+	assert.Empty(t, repo.GetAll())
+
+	repo.Add("b")
+	repo.Add("a")
+	repo.Add("b")
+	assert.Equal(t, []string{"a", "b"}, repo.GetAll())
+
+	repo.Remove("b")
+	repo.Remove("c")
+	assert.Equal(t, []string{"a"}, repo.GetAll())
+}
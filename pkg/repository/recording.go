@@ -0,0 +1,93 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+)
+
+const recordingExt = ".jsonl"
+
+// RecordingRepository keeps the docker calls made on behalf of each test
+type RecordingRepository interface {
+	// Append adds a call to the recording of the test it was made for
+	Append(call entity.RecordedCall) error
+
+	// Load gets the calls recorded for the given test, in the order they were made
+	Load(testID string) ([]entity.RecordedCall, error)
+}
+
+type recordingRepository struct {
+	mux sync.Mutex
+	dir string
+}
+
+// NewRecordingRepository creates a RecordingRepository which keeps the recording of each test
+// as a file of JSON lines in the given directory
+func NewRecordingRepository(dir string) (RecordingRepository, error) {
+	return &recordingRepository{dir: dir}, os.MkdirAll(dir, 0700)
+}
+
+// RecordingPath gets the path of the recording of the given test, within the given directory
+func RecordingPath(dir string, testID string) string {
+	return filepath.Join(dir, filepath.Base(testID)+recordingExt)
+}
+
+// Append adds a call to the recording of the test it was made for
+func (rr *recordingRepository) Append(call entity.RecordedCall) error {
+	data, err := json.Marshal(call)
+	if err != nil {
+		return err
+	}
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	file, err := os.OpenFile(RecordingPath(rr.dir, call.Test), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Load gets the calls recorded for the given test, in the order they were made
+func (rr *recordingRepository) Load(testID string) ([]entity.RecordedCall, error) {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	file, err := os.Open(RecordingPath(rr.dir, testID))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadRecording(file)
+}
+
+// ReadRecording reads the JSON lines of a recording
+func ReadRecording(rdr io.Reader) ([]entity.RecordedCall, error) {
+	out := []entity.RecordedCall{}
+	dec := json.NewDecoder(bufio.NewReader(rdr))
+	for {
+		var call entity.RecordedCall
+		err := dec.Decode(&call)
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, call)
+	}
+}
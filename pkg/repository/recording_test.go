@@ -0,0 +1,66 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingRepository(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recordings")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recordings, err := NewRecordingRepository(dir)
+	require.NoError(t, err)
+
+	calls := []entity.RecordedCall{
+		{DockerCall: entity.DockerCall{Host: "10.0.0.1", Method: "NetworkCreate",
+			Args: map[string]interface{}{"name": "net0"}}, Test: "a", Result: json.RawMessage(`{"Id":"1"}`)},
+		{DockerCall: entity.DockerCall{Host: "10.0.0.1", Method: "ContainerStart"}, Test: "b",
+			Error: "Error: No such container: node0"},
+		{DockerCall: entity.DockerCall{Host: "10.0.0.2", Method: "NetworkRemove"}, Test: "a"},
+	}
+	for _, call := range calls {
+		require.NoError(t, recordings.Append(call))
+	}
+
+	loaded, err := recordings.Load("a")
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, "NetworkCreate", loaded[0].Method)
+	assert.Equal(t, "net0", loaded[0].Args["name"])
+	assert.JSONEq(t, `{"Id":"1"}`, string(loaded[0].Result))
+	assert.Equal(t, "NetworkRemove", loaded[1].Method)
+
+	loaded, err = recordings.Load("b")
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, "Error: No such container: node0", loaded[0].Error)
+
+	_, err = recordings.Load("c")
+	assert.Error(t, err)
+}
+
+func TestReadRecording(t *testing.T) {
+	calls, err := ReadRecording(strings.NewReader(
+		`{"host":"10.0.0.1","method":"Ping","test":"a"}` + "\n" + `{"host":"10.0.0.1","method":"Ping","test":"a"}`))
+	require.NoError(t, err)
+	assert.Len(t, calls, 2)
+
+	_, err = ReadRecording(strings.NewReader(`{"host":`))
+	assert.Error(t, err)
+}
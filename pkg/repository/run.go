@@ -0,0 +1,91 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+)
+
+// ErrRunNotFound is returned when there is no run with the given id
+var ErrRunNotFound = errors.New("run not found")
+
+// RunRepository keeps track of the runs submitted through the REST API
+type RunRepository interface {
+	// Insert adds a new run
+	Insert(run entity.Run) error
+
+	// Update replaces the stored state of an existing run
+	Update(run entity.Run) error
+
+	// Get gets the run with the given id
+	Get(id string) (entity.Run, error)
+
+	// GetAll gets every run, ordered by the time they were started
+	GetAll() ([]entity.Run, error)
+}
+
+type runRepository struct {
+	mux  sync.RWMutex
+	runs map[string]entity.Run
+}
+
+// NewRunRepository creates a new in memory RunRepository
+func NewRunRepository() RunRepository {
+	return &runRepository{runs: map[string]entity.Run{}}
+}
+
+// Insert adds a new run
+func (rr *runRepository) Insert(run entity.Run) error {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	rr.runs[run.ID] = copyRun(run)
+	return nil
+}
+
+// Update replaces the stored state of an existing run
+func (rr *runRepository) Update(run entity.Run) error {
+	rr.mux.Lock()
+	defer rr.mux.Unlock()
+	if _, exists := rr.runs[run.ID]; !exists {
+		return ErrRunNotFound
+	}
+	rr.runs[run.ID] = copyRun(run)
+	return nil
+}
+
+// copyRun prevents the stored run from sharing its rounds with the caller's copy
+func copyRun(run entity.Run) entity.Run {
+	run.Rounds = append([]entity.Result(nil), run.Rounds...)
+	return run
+}
+
+// Get gets the run with the given id
+func (rr *runRepository) Get(id string) (entity.Run, error) {
+	rr.mux.RLock()
+	defer rr.mux.RUnlock()
+	run, exists := rr.runs[id]
+	if !exists {
+		return entity.Run{}, ErrRunNotFound
+	}
+	return run, nil
+}
+
+// GetAll gets every run, ordered by the time they were started
+func (rr *runRepository) GetAll() ([]entity.Run, error) {
+	rr.mux.RLock()
+	defer rr.mux.RUnlock()
+	out := make([]entity.Run, 0, len(rr.runs))
+	for _, run := range rr.runs {
+		out = append(out, run)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.Before(out[j].Started) })
+	return out, nil
+}
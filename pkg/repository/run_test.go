@@ -0,0 +1,52 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRepository(t *testing.T) {
+	repo := NewRunRepository()
+	now := time.Now()
+
+	require.NoError(t, repo.Insert(entity.Run{ID: "2", Started: now.Add(time.Second)}))
+	require.NoError(t, repo.Insert(entity.Run{ID: "1", Started: now}))
+
+	run, err := repo.Get("1")
+	require.NoError(t, err)
+	assert.Equal(t, "1", run.ID)
+
+	run.Status.Finished = true
+	require.NoError(t, repo.Update(run))
+
+	run, err = repo.Get("1")
+	require.NoError(t, err)
+	assert.True(t, run.Status.Finished)
+
+	runs, err := repo.GetAll()
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "1", runs[0].ID)
+	assert.Equal(t, "2", runs[1].ID)
+}
+
+func TestRunRepository_NotFound(t *testing.T) {
+	repo := NewRunRepository()
+
+	_, err := repo.Get("foo")
+	assert.Equal(t, ErrRunNotFound, err)
+
+	err = repo.Update(entity.Run{ID: "foo"})
+	assert.Equal(t, ErrRunNotFound, err)
+}
@@ -0,0 +1,103 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	queue "github.com/whiteblock/amqp"
+)
+
+const statsExt = ".jsonl"
+
+// StatsSink is where the resource usage samples of the containers of tests are published
+type StatsSink interface {
+	// Write publishes a sample
+	Write(sample entity.StatsSample) error
+
+	// Close releases the resources of the sink, once nothing more is written to it
+	Close() error
+}
+
+type fileStatsSink struct {
+	mux   sync.Mutex
+	dir   string
+	files map[string]*os.File
+}
+
+// NewFileStatsSink creates a StatsSink which appends the samples of each test, as JSON, to a
+// file of its own in the given directory
+func NewFileStatsSink(dir string) (StatsSink, error) {
+	return &fileStatsSink{dir: dir, files: map[string]*os.File{}}, os.MkdirAll(dir, 0700)
+}
+
+// StatsPath gets the path of the samples of the given test, within the given directory
+func StatsPath(dir string, testID string) string {
+	return filepath.Join(dir, filepath.Base(testID)+statsExt)
+}
+
+// Write appends the sample to the file of its test
+func (fss *fileStatsSink) Write(sample entity.StatsSample) error {
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+	fss.mux.Lock()
+	defer fss.mux.Unlock()
+	path := StatsPath(fss.dir, sample.Test)
+	file, exists := fss.files[path]
+	if !exists {
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		fss.files[path] = file
+	}
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the files which were written to
+func (fss *fileStatsSink) Close() (err error) {
+	fss.mux.Lock()
+	defer fss.mux.Unlock()
+	for path, file := range fss.files {
+		if e := file.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(fss.files, path)
+	}
+	return
+}
+
+type amqpStatsSink struct {
+	queue queue.AMQPService
+}
+
+// NewAMQPStatsSink creates a StatsSink which sends each sample, as JSON, to the given queue
+func NewAMQPStatsSink(stats queue.AMQPService) StatsSink {
+	return &amqpStatsSink{queue: stats}
+}
+
+// Write sends the sample to the queue
+func (ass amqpStatsSink) Write(sample entity.StatsSample) error {
+	msg, err := queue.CreateMessage(sample)
+	if err != nil {
+		return err
+	}
+	return ass.queue.Send(msg)
+}
+
+// Close does nothing, the connection to the queue is left open
+func (ass amqpStatsSink) Close() error {
+	return nil
+}
@@ -0,0 +1,64 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package repository
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	queue "github.com/whiteblock/genesis/mocks/amqp"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStatsSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stats")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sink, err := NewFileStatsSink(dir)
+	require.NoError(t, err)
+	samples := []entity.StatsSample{
+		{Test: "a", Container: "node0", CPU: 1.5},
+		{Test: "b", Container: "node0"},
+		{Test: "a", Container: "node1", Memory: 100},
+	}
+	for _, sample := range samples {
+		require.NoError(t, sink.Write(sample))
+	}
+	require.NoError(t, sink.Close())
+
+	data, err := ioutil.ReadFile(StatsPath(dir, "a"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	for i, line := range lines {
+		var sample entity.StatsSample
+		require.NoError(t, json.Unmarshal([]byte(line), &sample))
+		assert.Equal(t, samples[i*2], sample)
+	}
+}
+
+func TestAMQPStatsSink(t *testing.T) {
+	stats := new(queue.AMQPService)
+	stats.On("Send", mock.MatchedBy(func(pub amqp.Publishing) bool {
+		var sample entity.StatsSample
+		return json.Unmarshal(pub.Body, &sample) == nil && sample.Container == "node0"
+	})).Return(nil).Once()
+
+	sink := NewAMQPStatsSink(stats)
+	assert.NoError(t, sink.Write(entity.StatsSample{Test: "a", Container: "node0"}))
+	assert.NoError(t, sink.Close())
+	stats.AssertExpectations(t)
+}
@@ -9,9 +9,14 @@ package service
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -19,6 +24,7 @@ import (
 	"github.com/whiteblock/genesis/pkg/config"
 	"github.com/whiteblock/genesis/pkg/entity"
 	"github.com/whiteblock/genesis/pkg/file"
+	"github.com/whiteblock/genesis/pkg/metrics"
 	"github.com/whiteblock/genesis/pkg/repository"
 
 	"github.com/docker/docker/api/types"
@@ -29,6 +35,7 @@ import (
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/docker/pkg/system"
 	"github.com/sirupsen/logrus"
 	"github.com/whiteblock/definition/command"
@@ -39,14 +46,33 @@ type DockerService interface {
 
 	// CreateContainer attempts to create a docker container
 	CreateContainer(ctx context.Context, cli entity.DockerCli,
-		container command.Container) entity.Result
+		container entity.Container) entity.Result
 
-	// StartContainer attempts to start an already created docker container
-	StartContainer(ctx context.Context, cli entity.DockerCli, sc command.StartContainer) entity.Result
+	// StartContainer attempts to start an already created docker container, and waits for it
+	// to exit if it is attached, or to become healthy
+	StartContainer(ctx context.Context, cli entity.DockerCli, sc entity.StartContainer) entity.Result
+
+	// WaitHealthy waits until a container reports that it is healthy, for at most the timeout
+	WaitHealthy(ctx context.Context, cli entity.DockerCli, name string, timeout command.Timeout) entity.Result
 
 	// RemoveContainer attempts to remove (a) container(s)
 	RemoveContainer(ctx context.Context, cli entity.DockerCli, names ...string) entity.Result
 
+	// StopContainer stops a container, without removing it
+	StopContainer(ctx context.Context, cli entity.DockerCli, sc entity.StopContainer) entity.Result
+
+	// RestartContainer stops a container, if it is running, and starts it again
+	RestartContainer(ctx context.Context, cli entity.DockerCli, sc entity.StopContainer) entity.Result
+
+	// KillContainer sends a signal to a container
+	KillContainer(ctx context.Context, cli entity.DockerCli, kc entity.KillContainer) entity.Result
+
+	// PauseContainer pauses the processes of a container
+	PauseContainer(ctx context.Context, cli entity.DockerCli, name string) entity.Result
+
+	// UnpauseContainer resumes the processes of a paused container
+	UnpauseContainer(ctx context.Context, cli entity.DockerCli, name string) entity.Result
+
 	// CreateNetwork attempts to create a network
 	CreateNetwork(ctx context.Context, cli entity.DockerCli, net command.Network) entity.Result
 
@@ -58,14 +84,52 @@ type DockerService interface {
 	CreateVolume(ctx context.Context, cli entity.DockerCli, volume command.Volume) entity.Result
 	RemoveVolume(ctx context.Context, cli entity.DockerCli, name string) entity.Result
 	PlaceFileInContainer(ctx context.Context, cli entity.DockerCli,
-		containerName string, file command.File) entity.Result
+		containerName string, file entity.File) entity.Result
 	Emulation(ctx context.Context, cli entity.DockerCli, netem command.Netconf) entity.Result
+
+	// ClearEmulation removes the network emulation of a container on a network, if there is any
+	ClearEmulation(ctx context.Context, cli entity.DockerCli, target entity.EmulationTarget) entity.Result
+
+	// ReadEmulation reads back the queueing disciplines of a container on a network. They are
+	// placed in the meta of the result, under entity.OutputKey.
+	ReadEmulation(ctx context.Context, cli entity.DockerCli, target entity.EmulationTarget) entity.Result
+
+	// PeerEmulation applies network conditions to the traffic between containers and each of
+	// their peers, replacing any which were applied to the containers before
+	PeerEmulation(ctx context.Context, cli entity.DockerCli, pe entity.PeerEmulation) entity.Result
+
+	// Partition blocks the traffic between groups of containers on a network
+	Partition(ctx context.Context, cli entity.DockerCli, part entity.Partition) entity.Result
+
+	// Heal removes the rules put in place by Partition for the containers of the given groups
+	Heal(ctx context.Context, cli entity.DockerCli, part entity.Partition) entity.Result
+
 	SwarmCluster(ctx context.Context, cli entity.DockerCli, swarm command.SetupSwarm) entity.Result
 	PullImage(ctx context.Context, cli entity.DockerCli, imagePull command.PullImage) entity.Result
 	VolumeShare(ctx context.Context, cli entity.DockerCli, vs command.VolumeShare) entity.Result
 
-	//CreateClient creates a new client for connecting to the docker daemon
-	CreateClient(host string) (entity.Client, error)
+	// Exec runs a command in a container, and places its output in the meta of the result
+	Exec(ctx context.Context, cli entity.DockerCli, ec entity.ExecCommand) entity.Result
+
+	// ListTestResources lists the resources on the host which are labeled as belonging to a test,
+	// grouped by test, along with when the newest of them was created. If testID is not empty,
+	// only the resources of that test are listed.
+	ListTestResources(ctx context.Context, cli entity.DockerCli, testID string) ([]entity.TestResources, error)
+
+	// RemoveTestResources removes the given containers, then networks, then volumes
+	RemoveTestResources(ctx context.Context, cli entity.DockerCli, res entity.TestResources) entity.Result
+
+	// FollowLogs forwards the lines logged by a container after the given time to the sink, until
+	// the container stops or ctx is done. It returns the time of the last line forwarded.
+	FollowLogs(ctx context.Context, cli entity.DockerCli, name string, since time.Time,
+		sink repository.LogSink) (time.Time, error)
+
+	// SampleStats takes a sample of the resource usage of a container
+	SampleStats(ctx context.Context, cli entity.DockerCli, name string) (entity.StatsSample, error)
+
+	//CreateClient creates a new client for connecting to the docker daemon, on behalf of the
+	//given test, if any
+	CreateClient(host string, testID string) (entity.Client, error)
 }
 
 var (
@@ -83,8 +147,14 @@ type dockerService struct {
 	conf   config.Docker
 	log    logrus.Ext1FieldLogger
 	remote file.RemoteSources
+	dial   ClientFactory
+	// recordings, if set, is where the calls made on behalf of each test are recorded
+	recordings repository.RecordingRepository
 }
 
+// ClientFactory creates a client for connecting to the docker daemon on the given host
+type ClientFactory func(host string) (entity.Client, error)
+
 //NewDockerService creates a new DockerService
 func NewDockerService(
 	repo repository.DockerRepository,
@@ -99,6 +169,23 @@ func NewDockerService(
 		log:    log}
 }
 
+//NewDockerServiceWithClients creates a new DockerService which gets its clients from the given
+//factory, instead of connecting to the docker daemons itself
+func NewDockerServiceWithClients(
+	repo repository.DockerRepository,
+	conf config.Docker,
+	remote file.RemoteSources,
+	dial ClientFactory,
+	log logrus.Ext1FieldLogger) DockerService {
+
+	return dockerService{
+		conf:   conf,
+		repo:   repo,
+		remote: remote,
+		dial:   dial,
+		log:    log}
+}
+
 func (ds dockerService) errorWhitelistHandler(err error, whitelist ...string) entity.Result {
 	if err == nil {
 		return entity.NewResult(nil, 1)
@@ -114,18 +201,32 @@ func (ds dockerService) errorWhitelistHandler(err error, whitelist ...string) en
 	return entity.NewResult(err, 1)
 }
 
-// CreateClient creates a new client for connecting to the docker daemon
-func (ds dockerService) CreateClient(host string) (entity.Client, error) {
-	if ds.conf.LocalMode {
-		return client.NewClientWithOpts(
-			client.WithAPIVersionNegotiation(),
+// CreateClient creates a new client for connecting to the docker daemon, on behalf of the
+// given test, if any. The calls made on behalf of a test are recorded if recording is enabled.
+func (ds dockerService) CreateClient(host string, testID string) (entity.Client, error) {
+	cli, err := ds.createClient(host)
+	if err != nil || ds.recordings == nil || len(testID) == 0 {
+		return cli, err
+	}
+	return NewRecordingClient(cli, host, testID, ds.recordings, ds.log), nil
+}
+
+func (ds dockerService) createClient(host string) (entity.Client, error) {
+	if ds.dial != nil {
+		return ds.dial(host)
+	}
+	opts := []client.Opt{client.WithAPIVersionNegotiation()}
+	if !ds.conf.LocalMode {
+		opts = append(opts,
+			client.WithHost("tcp://"+host+":"+ds.conf.DaemonPort),
+			ds.repo.WithTLSClientConfig(ds.conf.CACertPath, ds.conf.CertPath, ds.conf.KeyPath),
 		)
 	}
-	return client.NewClientWithOpts(
-		client.WithAPIVersionNegotiation(),
-		client.WithHost("tcp://"+host+":"+ds.conf.DaemonPort),
-		ds.repo.WithTLSClientConfig(ds.conf.CACertPath, ds.conf.CertPath, ds.conf.KeyPath),
-	)
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return metrics.InstrumentClient(cli), nil
 }
 
 func (ds dockerService) withFields(cli entity.DockerCli, fields logrus.Fields) *logrus.Entry {
@@ -141,7 +242,7 @@ func (ds dockerService) withField(cli entity.DockerCli, key string, value interf
 
 //CreateContainer attempts to create a docker container
 func (ds dockerService) CreateContainer(ctx context.Context, cli entity.DockerCli,
-	dContainer command.Container) entity.Result {
+	dContainer entity.Container) entity.Result {
 
 	ds.withFields(cli, logrus.Fields{"container": dContainer}).Trace("create container")
 	errChan := make(chan error)
@@ -164,6 +265,9 @@ func (ds dockerService) CreateContainer(ctx context.Context, cli entity.DockerCl
 		Entrypoint:   dContainer.GetEntryPoint(),
 		Labels:       cli.Labels,
 	}
+	if dContainer.Healthcheck != nil {
+		config.Healthcheck = dContainer.Healthcheck.HealthConfig()
+	}
 
 	mem, err := dContainer.GetMemory()
 	if err != nil {
@@ -220,11 +324,20 @@ func (ds dockerService) CreateContainer(ctx context.Context, cli entity.DockerCl
 	})
 }
 
-//StartContainer attempts to start an already created docker container
+//StartContainer attempts to start an already created docker container. With attach, it waits
+//for the container to exit, and places its exit code in the meta of the result, under entity.OutputKey.
 func (ds dockerService) StartContainer(ctx context.Context, cli entity.DockerCli,
-	sc command.StartContainer) entity.Result {
+	sc entity.StartContainer) entity.Result {
 
 	ds.withFields(cli, logrus.Fields{"name": sc.Name}).Trace("starting container")
+	var waitC <-chan container.ContainerWaitOKBody
+	var waitErrC <-chan error
+	if sc.Attach {
+		waitCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		// the wait starts before the container does, so that it cannot exit unnoticed
+		waitC, waitErrC = cli.ContainerWait(waitCtx, sc.Name, container.WaitConditionNextExit)
+	}
 	opts := types.ContainerStartOptions{}
 
 	err := cli.ContainerStart(ctx, sc.Name, opts)
@@ -235,47 +348,60 @@ func (ds dockerService) StartContainer(ctx context.Context, cli entity.DockerCli
 		})
 	}
 
+	if sc.Healthy {
+		return ds.WaitHealthy(ctx, cli, sc.Name, sc.Timeout)
+	}
 	if !sc.Attach {
 		return entity.NewSuccessResult()
 	}
+	return ds.waitExit(ctx, cli, sc, waitC, waitErrC)
+}
 
-	resChan := make(chan error)
-	ctx2, cancelFn := context.WithTimeout(context.Background(), sc.Timeout.Duration)
-	defer cancelFn()
-
-	go func() {
-		startTime := time.Now().Add(sc.Timeout.Duration)
-		cnt := 0
-		for time.Now().Unix() < startTime.Unix() {
-			if cnt%20 == 0 {
-				ds.withFields(cli, logrus.Fields{
-					"name": sc.Name}).Trace("checking container status")
-			}
-
-			_, err := cli.ContainerInspect(ctx2, sc.Name)
-			if err != nil {
-				ds.withFields(cli, logrus.Fields{
-					"name":  sc.Name,
-					"error": err.Error()}).Info("container finished execution")
-				resChan <- err
-				break
-			}
-			time.Sleep(1 * time.Second)
-			cnt++
+// waitExit waits for an attached container to exit, for at most the timeout of the order. The container
+// is left running if it does not exit in time. Unless failures are allowed, the order fails if the
+// container exits with a non-zero exit code, along with the tail of its logs.
+func (ds dockerService) waitExit(ctx context.Context, cli entity.DockerCli, sc entity.StartContainer,
+	waitC <-chan container.ContainerWaitOKBody, waitErrC <-chan error) entity.Result {
+
+	meta := map[string]interface{}{"name": sc.Name, "type": "StartContainer"}
+	var timeout <-chan time.Time
+	if !sc.Timeout.IsInfinite() {
+		dur := sc.Timeout.Duration
+		if dur == 0 {
+			dur = command.DefaultTimeout
 		}
-	}()
+		timer := time.NewTimer(dur)
+		defer timer.Stop()
+		timeout = timer.C
+	}
 
 	select {
-	case err := <-resChan:
-		return ds.errorWhitelistHandler(err, "No such container").InjectMeta(map[string]interface{}{
-			"name": sc.Name,
-			"type": "StartContainer",
-		})
-	case <-time.After(sc.Timeout.Duration):
+	case res := <-waitC:
+		if res.Error != nil && len(res.Error.Message) > 0 {
+			return entity.NewErrorResult(res.Error.Message).InjectMeta(meta)
+		}
+		out := entity.ContainerExit{ExitCode: int(res.StatusCode)}
+		ds.withFields(cli, logrus.Fields{"name": sc.Name, "exitCode": out.ExitCode}).Info(
+			"container finished execution")
+		if out.ExitCode == 0 || sc.AllowFailure {
+			meta[entity.OutputKey] = out
+			return entity.NewSuccessResult().InjectMeta(meta)
+		}
+		out.Logs = ds.logTail(ctx, cli, sc.Name)
+		meta[entity.OutputKey] = out
+		return entity.NewErrorResult(fmt.Errorf("container %q exited with %d: %s",
+			sc.Name, out.ExitCode, out.Logs)).InjectMeta(meta)
+
+	case err := <-waitErrC:
+		return entity.NewErrorResult(err).InjectMeta(meta)
+
+	case <-timeout:
 		ds.withFields(cli, logrus.Fields{"name": sc.Name}).Debug("timeout was reached")
+		return entity.NewSuccessResult().InjectMeta(meta)
 
+	case <-ctx.Done():
+		return entity.NewErrorResult(ctx.Err()).InjectMeta(meta)
 	}
-	return entity.NewSuccessResult()
 }
 
 // RemoveContainer attempts to remove a container
@@ -306,6 +432,67 @@ func (ds dockerService) RemoveContainer(ctx context.Context, cli entity.DockerCl
 	return entity.NewResult(err)
 }
 
+// stopTimeout gets the timeout to give the daemon for stopping a container, nil leaves it
+// up to the container
+func stopTimeout(sc entity.StopContainer) *time.Duration {
+	if sc.Timeout.Empty() {
+		return nil
+	}
+	timeout := sc.Timeout.Duration
+	return &timeout
+}
+
+// StopContainer stops a container, without removing it
+func (ds dockerService) StopContainer(ctx context.Context, cli entity.DockerCli,
+	sc entity.StopContainer) entity.Result {
+
+	ds.withFields(cli, logrus.Fields{"name": sc.Name, "timeout": sc.Timeout}).Debug("stopping container")
+	return entity.NewResult(cli.ContainerStop(ctx, sc.Name, stopTimeout(sc))).InjectMeta(
+		map[string]interface{}{"name": sc.Name})
+}
+
+// RestartContainer stops a container, if it is running, and starts it again
+func (ds dockerService) RestartContainer(ctx context.Context, cli entity.DockerCli,
+	sc entity.StopContainer) entity.Result {
+
+	ds.withFields(cli, logrus.Fields{"name": sc.Name, "timeout": sc.Timeout}).Debug("restarting container")
+	return entity.NewResult(cli.ContainerRestart(ctx, sc.Name, stopTimeout(sc))).InjectMeta(
+		map[string]interface{}{"name": sc.Name})
+}
+
+// KillContainer sends a signal to a container
+func (ds dockerService) KillContainer(ctx context.Context, cli entity.DockerCli,
+	kc entity.KillContainer) entity.Result {
+
+	if len(kc.Signal) == 0 {
+		kc.Signal = "SIGKILL"
+	}
+	ds.withFields(cli, logrus.Fields{"name": kc.Name, "signal": kc.Signal}).Debug("killing container")
+	return entity.NewResult(cli.ContainerKill(ctx, kc.Name, kc.Signal)).InjectMeta(
+		map[string]interface{}{"name": kc.Name, "signal": kc.Signal})
+}
+
+// PauseContainer pauses the processes of a container. Pausing a paused container does nothing.
+func (ds dockerService) PauseContainer(ctx context.Context, cli entity.DockerCli, name string) entity.Result {
+	ds.withFields(cli, logrus.Fields{"name": name}).Debug("pausing container")
+	err := cli.ContainerPause(ctx, name)
+	if err != nil && strings.Contains(err.Error(), "is already paused") {
+		err = nil
+	}
+	return entity.NewResult(err).InjectMeta(map[string]interface{}{"name": name})
+}
+
+// UnpauseContainer resumes the processes of a paused container. Unpausing a container which is not
+// paused does nothing.
+func (ds dockerService) UnpauseContainer(ctx context.Context, cli entity.DockerCli, name string) entity.Result {
+	ds.withFields(cli, logrus.Fields{"name": name}).Debug("unpausing container")
+	err := cli.ContainerUnpause(ctx, name)
+	if err != nil && strings.Contains(err.Error(), "is not paused") {
+		err = nil
+	}
+	return entity.NewResult(err).InjectMeta(map[string]interface{}{"name": name})
+}
+
 // CreateNetwork attempts to create a network
 func (ds dockerService) CreateNetwork(ctx context.Context, cli entity.DockerCli,
 	net command.Network) entity.Result {
@@ -378,6 +565,7 @@ func (ds dockerService) AttachNetwork(ctx context.Context, cli entity.DockerCli,
 	})
 	return ds.errorWhitelistHandler(err,
 		"is already attached to network",
+		"already exists in network",
 		"Address already in use")
 }
 
@@ -393,8 +581,14 @@ func (ds dockerService) CreateVolume(ctx context.Context, ecli entity.DockerCli,
 	vol command.Volume) entity.Result {
 
 	if !vol.Global {
+		labels := map[string]string{}
+		for _, from := range []map[string]string{ecli.Labels, vol.Labels} {
+			for key, val := range from {
+				labels[key] = val
+			}
+		}
 		volConfig := volume.VolumeCreateBody{
-			Labels: vol.Labels,
+			Labels: labels,
 			Name:   vol.Name,
 		}
 
@@ -405,7 +599,7 @@ func (ds dockerService) CreateVolume(ctx context.Context, ecli entity.DockerCli,
 	clients := make([]entity.Client, len(vol.Hosts))
 
 	for i, host := range vol.Hosts {
-		cli, err := ds.CreateClient(host)
+		cli, err := ds.CreateClient(host, ecli.Labels[command.TestIDKey])
 		if err != nil {
 			return entity.NewErrorResult(err)
 		}
@@ -484,8 +678,9 @@ func (ds dockerService) CreateVolume(ctx context.Context, ecli entity.DockerCli,
 
 	for i := range clients {
 		go func(i int) {
-			_, err = clients[i].VolumeCreate(ctx, volume.VolumeCreateBody{
+			_, err := clients[i].VolumeCreate(ctx, volume.VolumeCreateBody{
 				Driver: ds.conf.GlusterDriver,
+				Labels: ecli.Labels,
 				Name:   vol.Name,
 				DriverOpts: map[string]string{
 					"glusteropts": fmt.Sprintf("--volfile-server=%s --volfile-id=/%s", ds.hostName(ecli, i), vol.Name),
@@ -511,29 +706,67 @@ func (ds dockerService) RemoveVolume(ctx context.Context, cli entity.DockerCli,
 	return entity.NewResult(cli.VolumeRemove(ctx, name, true))
 }
 
+// fileArchive is the archive of a file being placed in a container, which declares where the file
+// comes from and its size, so that they can be recorded without reading the archive
+type fileArchive struct {
+	io.Reader
+	source string
+	size   int64
+}
+
+func (fa *fileArchive) Source() string {
+	return fa.source
+}
+
+func (fa *fileArchive) Size() int64 {
+	return fa.size
+}
+
+// fileSource gets where a file comes from. The content of a data URI is left out.
+func fileSource(f entity.File) string {
+	if strings.HasPrefix(f.ID, file.SchemeData+":") {
+		return file.SchemeData + ":"
+	}
+	return f.ID
+}
+
+// fileErrorResult gets the result of failing to get a file, which is fatal if the ID of the file
+// can never be fetched, or the file is not a valid template
+func fileErrorResult(err error) entity.Result {
+	if errors.Is(err, file.ErrUnsupportedScheme) || errors.Is(err, file.ErrInvalidSource) ||
+		errors.Is(err, file.ErrInvalidTemplate) {
+		return entity.NewFatalResult(err)
+	}
+	return entity.NewErrorResult(err)
+}
+
+// PlaceFileInContainer streams the file into the container. The sha256 of the file is placed in the
+// meta of the result, under entity.OutputKey, and the result is an error, which can be retried, if
+// the file does not have the sha256 it is expected to have.
 func (ds dockerService) PlaceFileInContainer(ctx context.Context, cli entity.DockerCli,
-	containerName string, file command.File) entity.Result {
+	containerName string, file entity.File) entity.Result {
 
 	ds.withFields(cli, logrus.Fields{
 		"container": containerName,
-		"file":      file,
+		"file":      file.File,
 	}).Debug("copying file to container")
-	rdr, err := ds.remote.GetTarReader(cli.Labels[command.DefinitionIDKey], file)
+	rdr, err := ds.getTarReader(ctx, cli, containerName, file)
 	if err != nil {
-		return entity.NewErrorResult(err).InjectMeta(map[string]interface{}{
+		return fileErrorResult(err).InjectMeta(map[string]interface{}{
 			"labels": cli.Labels,
 		})
 	}
+	defer rdr.Close()
 
+	dstPath := file.Destination
+	if dstPath[len(dstPath)-1] == '/' {
+		dstPath += filepath.Base(file.Filename())
+	}
 	srcInfo := archive.CopyInfo{ //appease the Docker Gods
-		Path:   file.Meta.Filename,
+		Path:   filepath.Base(dstPath), // the name of the file in the archive
 		Exists: true,
 		IsDir:  false,
 	}
-	dstPath := file.Destination
-	if !srcInfo.IsDir && dstPath[len(dstPath)-1] == '/' {
-		dstPath += filepath.Base(file.Meta.Filename)
-	}
 
 	// Prepare destination copy info by stat-ing the container path.
 	dstInfo := archive.CopyInfo{Path: dstPath}
@@ -573,87 +806,422 @@ func (ds dockerService) PlaceFileInContainer(ctx context.Context, cli entity.Doc
 		"container":  containerName,
 	}).Debug("got the destination for the file")
 
-	err = cli.CopyToContainer(ctx, containerName, dstDir, preparedArchive, types.CopyToContainerOptions{
+	content := &fileArchive{Reader: preparedArchive, source: fileSource(file), size: rdr.Size()}
+	err = cli.CopyToContainer(ctx, containerName, dstDir, content, types.CopyToContainerOptions{
 		AllowOverwriteDirWithFile: true,
 		CopyUIDGID:                false,
 	})
+	if e := rdr.Close(); e != nil {
+		// the archive failing, such as on a sha256 mismatch, is why the copy failed
+		err = e
+	}
 
-	return entity.NewResult(err).InjectMeta(map[string]interface{}{
+	meta := map[string]interface{}{
 		"labels":    cli.Labels,
 		"container": containerName,
-	})
+	}
+	if len(rdr.Sha256()) > 0 {
+		meta[entity.OutputKey] = entity.FileCopy{Sha256: rdr.Sha256(), Size: rdr.Size()}
+	}
+	return entity.NewResult(err).InjectMeta(meta)
 }
 
-func (ds dockerService) Emulation(ctx context.Context, cli entity.DockerCli,
-	netem command.Netconf) entity.Result {
+// netemImage is the image of the containers which manage the network emulation of other containers
+const netemImage = "gaiadocker/iproute2:latest"
+
+// netemDevice is a shell expression for the interface of a container which is on the given subnet
+func netemDevice(subnet string) string {
+	return fmt.Sprintf("$(ip -o addr show to %s | sed -n 's/.*\\(eth[0-9]*\\).*/\\1/p')", subnet)
+}
+
+// partitionImage is the image of the containers which manage the iptables rules of other containers
+const partitionImage = "nicolaka/netshoot:latest"
+
+// sidecarKind is a kind of container which manages the network of another container
+type sidecarKind struct {
+	image string
+	// suffix tells apart the names of the sidecars of each kind
+	suffix string
+}
+
+var (
+	netemSidecar     = sidecarKind{image: netemImage}
+	partitionSidecar = sidecarKind{image: partitionImage, suffix: "-partition"}
+)
+
+// sidecar prepares a container of the given kind which runs the given script in the network namespace
+// of the given container, on the interface of the given network. The script can refer to the interface
+// as $dev. The name of the prepared container is returned.
+func (ds dockerService) sidecar(ctx context.Context, cli entity.DockerCli, kind sidecarKind,
+	containerName string, networkName string, script string, autoRemove bool) (string, error) {
 
-	netemImage := "gaiadocker/iproute2:latest"
 	errChan := make(chan error, 1)
 	go func() {
-		errChan <- ds.repo.EnsureImagePulled(ctx, cli, netemImage, "")
+		errChan <- ds.repo.EnsureImagePulled(ctx, cli, kind.image, "")
 	}()
 
-	net, err := ds.repo.GetNetworkByName(ctx, cli, netem.Network)
+	net, err := ds.repo.GetNetworkByName(ctx, cli, networkName)
 	if err != nil {
-		return entity.NewErrorResult(err)
+		return "", err
 	}
 
 	err = <-errChan
 	if err != nil {
-		return entity.NewErrorResult(err)
+		return "", err
 	}
 
-	name := netem.Container + "-" + net.ID
-	netemCmd := fmt.Sprintf(
-		"tc qdisc add dev $(ip -o addr show to %s | sed -n 's/.*\\(eth[0-9]*\\).*/\\1/p') root netem",
-		net.IPAM.Config[0].Subnet)
+	// a sidecar left over from an earlier order would cause a name conflict
+	name := containerName + kind.suffix + "-" + net.ID
+	res := ds.RemoveContainer(ctx, cli, name)
+	if !res.IsSuccess() {
+		return "", res.Error
+	}
+
+	config := &container.Config{
+		Image: kind.image,
+		Entrypoint: strslice.StrSlice([]string{"/bin/sh", "-c",
+			fmt.Sprintf("dev=%s; %s", netemDevice(net.IPAM.Config[0].Subnet), script)}),
+		AttachStdout: true,
+		AttachStderr: true,
+		Labels:       cli.Labels,
+	}
+
+	hostConfig := &container.HostConfig{
+		AutoRemove:  autoRemove,
+		NetworkMode: container.NetworkMode(fmt.Sprintf("container:%s", containerName)),
+		CapAdd:      strslice.StrSlice([]string{"NET_ADMIN"}),
+	}
+
+	_, err = cli.ContainerCreate(ctx, config, hostConfig, &network.NetworkingConfig{}, name)
+	return name, err
+}
+
+// runSidecar starts a prepared sidecar and waits for its script to finish. The result is an error,
+// along with what the script wrote to stderr, if the script exits with a non-zero exit code.
+func (ds dockerService) runSidecar(ctx context.Context, cli entity.DockerCli, name string) entity.Result {
+	meta := map[string]interface{}{"name": name, "type": "StartContainer"}
+	stream, err := cli.ContainerAttach(ctx, name, types.ContainerAttachOptions{
+		Stream: true, Stdout: true, Stderr: true})
+	if err != nil {
+		return entity.NewErrorResult(err).InjectMeta(meta)
+	}
+	defer stream.Close()
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// the wait starts before the sidecar does, so that it cannot exit, and be removed, unnoticed
+	waitC, waitErrC := cli.ContainerWait(waitCtx, name, container.WaitConditionNextExit)
+
+	err = cli.ContainerStart(ctx, name, types.ContainerStartOptions{})
+	if err != nil {
+		return entity.NewErrorResult(err).InjectMeta(meta)
+	}
+	stderr := new(strings.Builder)
+	_, err = stdcopy.StdCopy(ioutil.Discard, stderr, stream.Reader)
+	if err != nil {
+		return entity.NewErrorResult(err).InjectMeta(meta)
+	}
+
+	select {
+	case res := <-waitC:
+		if res.Error != nil && len(res.Error.Message) > 0 {
+			return entity.NewErrorResult(res.Error.Message).InjectMeta(meta)
+		}
+		if res.StatusCode != 0 {
+			return entity.NewErrorResult(fmt.Errorf("sidecar %q exited with %d: %s", name,
+				res.StatusCode, strings.TrimSpace(stderr.String()))).InjectMeta(meta)
+		}
+		return entity.NewSuccessResult()
+	case err := <-waitErrC:
+		return entity.NewErrorResult(err).InjectMeta(meta)
+	}
+}
+
+// netemOptions gets the options of a netem qdisc which applies the given conditions
+func netemOptions(netem command.Netconf) string {
+	opts := "netem"
 
 	if netem.Limit > 0 {
-		netemCmd += fmt.Sprintf(" limit %d", netem.Limit)
+		opts += fmt.Sprintf(" limit %d", netem.Limit)
 	}
 
 	if netem.Loss > 0 {
-		netemCmd += fmt.Sprintf(" loss %.4f", netem.Loss)
+		opts += fmt.Sprintf(" loss %.4f", netem.Loss)
 	}
 
 	if netem.Delay > 0 {
-		netemCmd += fmt.Sprintf(" delay %dus", netem.Delay)
+		opts += fmt.Sprintf(" delay %dus", netem.Delay)
 	}
 
 	if len(netem.Rate) > 0 {
-		netemCmd += fmt.Sprintf(" rate %s", netem.Rate)
+		opts += fmt.Sprintf(" rate %s", netem.Rate)
 	}
 
 	if netem.Duplication > 0 {
-		netemCmd += fmt.Sprintf(" duplicate %.4f", netem.Duplication)
+		opts += fmt.Sprintf(" duplicate %.4f", netem.Duplication)
 	}
 
 	if netem.Corrupt > 0 {
-		netemCmd += fmt.Sprintf(" corrupt %.4f", netem.Duplication)
+		opts += fmt.Sprintf(" corrupt %.4f", netem.Corrupt)
 	}
 
 	if netem.Reorder > 0 {
-		netemCmd += fmt.Sprintf(" reorder %.4f", netem.Reorder)
+		opts += fmt.Sprintf(" reorder %.4f", netem.Reorder)
 	}
+	return opts
+}
 
-	config := &container.Config{
-		Image:      netemImage,
-		Entrypoint: strslice.StrSlice([]string{"/bin/sh", "-c", netemCmd}),
+// Emulation applies the given network conditions to a container, replacing any
+// which were applied before
+func (ds dockerService) Emulation(ctx context.Context, cli entity.DockerCli,
+	netem command.Netconf) entity.Result {
+
+	name, err := ds.sidecar(ctx, cli, netemSidecar, netem.Container, netem.Network,
+		"tc qdisc replace dev $dev root "+netemOptions(netem), true)
+	if err != nil {
+		return entity.NewErrorResult(err)
 	}
+	return ds.runSidecar(ctx, cli, name)
+}
 
-	hostConfig := &container.HostConfig{
-		AutoRemove:  true,
-		NetworkMode: container.NetworkMode(fmt.Sprintf("container:%s", netem.Container)),
-		CapAdd:      strslice.StrSlice([]string{"NET_ADMIN"}),
+// peerNetemRate is the rate of the htb classes used for per peer emulation. It is high enough
+// to leave the traffic unshaped, the rate of each peer is limited by its netem qdisc instead.
+const peerNetemRate = "100gbit"
+
+// peerNetemScript gets a script which replaces the root qdisc of $dev with an htb qdisc, with
+// a class and netem qdisc for each peer. The traffic to each peer is sent to its class by a
+// u32 filter on its address. The rest of the traffic goes to the default class, 1:1.
+func peerNetemScript(peers []string, conds map[string]command.Netconf) string {
+	cmds := []string{
+		"tc qdisc replace dev $dev root handle 1: htb default 1",
+		"tc class add dev $dev parent 1: classid 1:1 htb rate " + peerNetemRate,
+	}
+	for i, peer := range peers {
+		minor := fmt.Sprintf("%x", i+2)
+		cmds = append(cmds,
+			fmt.Sprintf("tc class add dev $dev parent 1: classid 1:%s htb rate %s", minor, peerNetemRate),
+			fmt.Sprintf("tc qdisc add dev $dev parent 1:%s handle %s: %s", minor, minor, netemOptions(conds[peer])),
+			fmt.Sprintf("tc filter add dev $dev protocol ip parent 1: prio 1 u32 match ip dst %s/32 flowid 1:%s",
+				peer, minor))
+	}
+	// the classes and filters of a previous tree are removed along with its root
+	return "tc qdisc del dev $dev root 2>/dev/null; " + strings.Join(cmds, " && ")
+}
+
+// peerAddress gets the address of a peer on the given network. The peer is either an IP address,
+// or the name of a container.
+func (ds dockerService) peerAddress(ctx context.Context, cli entity.DockerCli,
+	network string, peer string) (string, error) {
+
+	if ip := net.ParseIP(peer); ip != nil && ip.To4() != nil {
+		return ip.String(), nil
+	}
+	cntr, err := cli.ContainerInspect(ctx, peer)
+	if err != nil {
+		return "", err
+	}
+	if cntr.NetworkSettings != nil {
+		if endpoint, ok := cntr.NetworkSettings.Networks[network]; ok && len(endpoint.IPAddress) > 0 {
+			return endpoint.IPAddress, nil
+		}
+	}
+	return "", fmt.Errorf("peer %s is not on the network %s", peer, network)
+}
+
+// PeerEmulation applies network conditions to the traffic between containers and each of
+// their peers, replacing any which were applied to the containers before
+func (ds dockerService) PeerEmulation(ctx context.Context, cli entity.DockerCli,
+	pe entity.PeerEmulation) entity.Result {
+
+	containers := []string{}
+	for name := range pe.Matrix {
+		containers = append(containers, name)
 	}
+	sort.Strings(containers)
+
+	for _, name := range containers {
+		given := []string{}
+		for peer := range pe.Matrix[name] {
+			given = append(given, peer)
+		}
+		sort.Strings(given)
 
-	networkConfig := &network.NetworkingConfig{}
+		peers := []string{}
+		conds := map[string]command.Netconf{}
+		for _, peer := range given {
+			addr, err := ds.peerAddress(ctx, cli, pe.Network, peer)
+			if err != nil {
+				return entity.NewErrorResult(err).InjectMeta(map[string]interface{}{
+					"container": name, "peer": peer})
+			}
+			if _, dup := conds[addr]; dup {
+				return entity.NewFatalResult(fmt.Errorf("peer %s of %s was given more than once", addr, name))
+			}
+			peers = append(peers, addr)
+			conds[addr] = pe.Matrix[name][peer]
+		}
+		sort.Strings(peers)
+		ds.withFields(cli, logrus.Fields{"container": name, "network": pe.Network,
+			"peers": peers}).Debug("applying per peer emulation")
 
-	_, err = cli.ContainerCreate(ctx, config, hostConfig, networkConfig, name)
+		sidecar, err := ds.sidecar(ctx, cli, netemSidecar, name, pe.Network,
+			peerNetemScript(peers, conds), true)
+		if err != nil {
+			return entity.NewErrorResult(err)
+		}
+		res := ds.runSidecar(ctx, cli, sidecar)
+		if !res.IsSuccess() {
+			return res
+		}
+	}
+	return entity.NewSuccessResult()
+}
+
+// partitionChain is the iptables chain which holds the partition rules of $dev
+const partitionChain = "GENESIS-PARTITION-$dev"
+
+// partitionScript gets a script which drops the traffic to and from the given addresses on $dev.
+// The rules are kept in their own chain, which is emptied first, so that the rules of an earlier
+// partition are replaced.
+func partitionScript(blocked []string) string {
+	cmds := []string{
+		fmt.Sprintf("(iptables -N %s 2>/dev/null || iptables -F %s)", partitionChain, partitionChain),
+		fmt.Sprintf("(iptables -C INPUT -i $dev -j %s 2>/dev/null || iptables -I INPUT -i $dev -j %s)",
+			partitionChain, partitionChain),
+		fmt.Sprintf("(iptables -C OUTPUT -o $dev -j %s 2>/dev/null || iptables -I OUTPUT -o $dev -j %s)",
+			partitionChain, partitionChain),
+	}
+	for _, addr := range blocked {
+		cmds = append(cmds,
+			fmt.Sprintf("iptables -A %s -s %s -j DROP", partitionChain, addr),
+			fmt.Sprintf("iptables -A %s -d %s -j DROP", partitionChain, addr))
+	}
+	return strings.Join(cmds, " && ")
+}
+
+// healScript is a script which removes the partition rules of $dev, if there are any
+var healScript = fmt.Sprintf("while iptables -D INPUT -i $dev -j %s 2>/dev/null; do :; done; "+
+	"while iptables -D OUTPUT -o $dev -j %s 2>/dev/null; do :; done; "+
+	"iptables -F %s 2>/dev/null; iptables -X %s 2>/dev/null; true",
+	partitionChain, partitionChain, partitionChain, partitionChain)
+
+// partitionSidecars runs a partition sidecar with the given script for each container
+func (ds dockerService) partitionSidecars(ctx context.Context, cli entity.DockerCli, network string,
+	scripts map[string]string) entity.Result {
+
+	containers := []string{}
+	for name := range scripts {
+		containers = append(containers, name)
+	}
+	sort.Strings(containers)
+	for _, name := range containers {
+		sidecar, err := ds.sidecar(ctx, cli, partitionSidecar, name, network, scripts[name], true)
+		if err != nil {
+			return entity.NewErrorResult(err).InjectMeta(map[string]interface{}{"container": name})
+		}
+		res := ds.runSidecar(ctx, cli, sidecar)
+		if !res.IsSuccess() {
+			return res
+		}
+	}
+	return entity.NewSuccessResult()
+}
+
+// Partition blocks the traffic between groups of containers on a network, replacing any
+// partition the containers were in before
+func (ds dockerService) Partition(ctx context.Context, cli entity.DockerCli,
+	part entity.Partition) entity.Result {
+
+	addrs := make([][]string, len(part.Groups))
+	for i, group := range part.Groups {
+		for _, name := range group {
+			addr, err := ds.peerAddress(ctx, cli, part.Network, name)
+			if err != nil {
+				return entity.NewErrorResult(err).InjectMeta(map[string]interface{}{"container": name})
+			}
+			addrs[i] = append(addrs[i], addr)
+		}
+	}
+
+	scripts := map[string]string{}
+	for i, group := range part.Groups {
+		blocked := []string{}
+		for j := range addrs {
+			if i != j {
+				blocked = append(blocked, addrs[j]...)
+			}
+		}
+		for _, name := range group {
+			scripts[name] = partitionScript(blocked)
+		}
+	}
+	ds.withFields(cli, logrus.Fields{"network": part.Network, "groups": part.Groups}).Info("partitioning")
+	return ds.partitionSidecars(ctx, cli, part.Network, scripts)
+}
+
+// Heal removes the rules put in place by Partition for the containers of the given groups
+func (ds dockerService) Heal(ctx context.Context, cli entity.DockerCli,
+	part entity.Partition) entity.Result {
+
+	scripts := map[string]string{}
+	for _, group := range part.Groups {
+		for _, name := range group {
+			scripts[name] = healScript
+		}
+	}
+	ds.withFields(cli, logrus.Fields{"network": part.Network, "groups": part.Groups}).Info("healing")
+	return ds.partitionSidecars(ctx, cli, part.Network, scripts)
+}
+
+// ClearEmulation removes the network emulation of a container on a network, if there is any
+func (ds dockerService) ClearEmulation(ctx context.Context, cli entity.DockerCli,
+	target entity.EmulationTarget) entity.Result {
+
+	name, err := ds.sidecar(ctx, cli, netemSidecar, target.Container, target.Network,
+		"tc qdisc del dev $dev root 2>/dev/null || true", true)
+	if err != nil {
+		return entity.NewErrorResult(err)
+	}
+	return ds.runSidecar(ctx, cli, name)
+}
+
+// ReadEmulation reads back the queueing disciplines of a container on a network. They are
+// placed in the meta of the result, under entity.OutputKey.
+func (ds dockerService) ReadEmulation(ctx context.Context, cli entity.DockerCli,
+	target entity.EmulationTarget) entity.Result {
+
+	name, err := ds.sidecar(ctx, cli, netemSidecar, target.Container, target.Network,
+		"tc qdisc show dev $dev", false)
+	if err != nil {
+		return entity.NewErrorResult(err)
+	}
+	defer ds.RemoveContainer(ctx, cli, name)
+
+	stream, err := cli.ContainerAttach(ctx, name, types.ContainerAttachOptions{
+		Stream: true, Stdout: true, Stderr: true})
+	if err != nil {
+		return entity.NewErrorResult(err)
+	}
+	defer stream.Close()
+
+	err = cli.ContainerStart(ctx, name, types.ContainerStartOptions{})
 	if err != nil {
 		return entity.NewErrorResult(err)
 	}
-	return ds.StartContainer(ctx, cli, command.StartContainer{Name: name})
+	stdout := new(strings.Builder)
+	stderr := new(strings.Builder)
+	_, err = stdcopy.StdCopy(stdout, stderr, stream.Reader)
+	if err != nil {
+		return entity.NewErrorResult(err)
+	}
+	if stderr.Len() > 0 {
+		return entity.NewErrorResult(fmt.Errorf("failed to read the qdiscs of %s: %s", target.Container,
+			strings.TrimSpace(stderr.String())))
+	}
+	qdiscs := entity.ParseQdiscs(stdout.String())
+	ds.withFields(cli, logrus.Fields{"container": target.Container, "network": target.Network,
+		"qdiscs": qdiscs}).Debug("read back the qdiscs")
+	return entity.NewSuccessResult().InjectMeta(map[string]interface{}{entity.OutputKey: qdiscs})
 }
 
 func (ds dockerService) SwarmCluster(ctx context.Context, entryCLI entity.DockerCli,
@@ -662,7 +1230,7 @@ func (ds dockerService) SwarmCluster(ctx context.Context, entryCLI entity.Docker
 	if len(dswarm.Hosts) == 0 {
 		return ErrNoHost
 	}
-	cli, err := ds.CreateClient(dswarm.Hosts[0])
+	cli, err := ds.CreateClient(dswarm.Hosts[0], entryCLI.Labels[command.TestIDKey])
 	if err != nil {
 		ds.withField(entryCLI, "error", err).Error("creating the manager client")
 		return entity.NewErrorResult(err)
@@ -690,7 +1258,7 @@ func (ds dockerService) SwarmCluster(ctx context.Context, entryCLI entity.Docker
 	}
 
 	for _, host := range dswarm.Hosts[1:] {
-		cli, err := ds.CreateClient(host)
+		cli, err := ds.CreateClient(host, entryCLI.Labels[command.TestIDKey])
 		if err != nil {
 			return entity.NewErrorResult(err)
 		}
@@ -755,7 +1323,7 @@ func (ds dockerService) VolumeShare(ctx context.Context, ecli entity.DockerCli,
 	clients := make([]entity.Client, len(vs.Hosts))
 
 	for i, host := range vs.Hosts {
-		cli, err := ds.CreateClient(host)
+		cli, err := ds.CreateClient(host, ecli.Labels[command.TestIDKey])
 		if err != nil {
 			return entity.NewErrorResult(err)
 		}
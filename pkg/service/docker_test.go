@@ -7,22 +7,30 @@
 package service
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	//"strings"
 	"testing"
+	"time"
 
 	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
 	externalsMock "github.com/whiteblock/genesis/mocks/pkg/externals"
-	//fileMock "github.com/whiteblock/genesis/mocks/pkg/file"
+	fileMock "github.com/whiteblock/genesis/mocks/pkg/file"
 	repoMock "github.com/whiteblock/genesis/mocks/pkg/repository"
 	"github.com/whiteblock/genesis/pkg/config"
 	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/file"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
 	dockerVolume "github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -37,7 +45,7 @@ func TestNewDockerService(t *testing.T) {
 
 func TestDockerService_CreateContainer(t *testing.T) {
 	testNetwork := types.NetworkResource{Name: "Testnet", ID: "id1"}
-	testContainer := command.Container{
+	testContainer := entity.Container{Container: command.Container{
 		EntryPoint: "/bin/bash",
 		Environment: map[string]string{
 			"FOO": "BAR",
@@ -48,9 +56,10 @@ func TestDockerService_CreateContainer(t *testing.T) {
 		Volumes: []command.Mount{{Name: "volume1", Directory: "/foo/bar", ReadOnly: false}},
 		Image:   "alpine",
 		Args:    []string{"test"},
-	}
+	}, Healthcheck: &entity.Healthcheck{Command: []string{"curl", "-f", "localhost:8545"}, Retries: 5}}
 	testContainer.Cpus = "2.5"
 	testContainer.Memory = "5gb"
+	testContainer.Healthcheck.Interval.Duration = 2 * time.Second
 
 	cli := new(entityMock.Client)
 	cli.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
@@ -68,6 +77,10 @@ func TestDockerService_CreateContainer(t *testing.T) {
 			assert.Equal(t, testContainer.Name, config.Hostname)
 			assert.NotNil(t, config.Labels)
 			assert.Equal(t, testContainer.Image, config.Image)
+			require.NotNil(t, config.Healthcheck)
+			assert.Equal(t, []string{"CMD", "curl", "-f", "localhost:8545"}, config.Healthcheck.Test)
+			assert.Equal(t, 2*time.Second, config.Healthcheck.Interval)
+			assert.Equal(t, 5, config.Healthcheck.Retries)
 			{
 				_, exists := config.ExposedPorts["8889/tcp"]
 				assert.True(t, exists)
@@ -144,7 +157,7 @@ func TestDockerService_CreateContainer(t *testing.T) {
 }
 
 func TestDockerService_StartContainer_Success(t *testing.T) {
-	scCommand := command.StartContainer{Name: "TEST"}
+	scCommand := entity.StartContainer{StartContainer: command.StartContainer{Name: "TEST"}}
 	cli := new(entityMock.Client)
 	cli.On("ContainerStart", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(
 		func(args mock.Arguments) {
@@ -175,6 +188,131 @@ func TestDockerService_StartContainer_Success(t *testing.T) {
 	conn.AssertExpectations(t)
 }
 
+func TestDockerService_StartContainer_Attach(t *testing.T) {
+	var logs bytes.Buffer
+	stdcopy.NewStdWriter(&logs, stdcopy.Stderr).Write([]byte("genesis.json: permission denied\n"))
+
+	for _, tc := range []struct {
+		exitCode     int64
+		waitErr      error
+		allowFailure bool
+		timeout      time.Duration
+		err          string
+	}{
+		{exitCode: 0},
+		{exitCode: 1, err: `container "gen" exited with 1: genesis.json: permission denied`},
+		{exitCode: 1, allowFailure: true},
+		{waitErr: errors.New("connection reset"), err: "connection reset"},
+		{timeout: 10 * time.Millisecond},
+	} {
+		waitC := make(chan container.ContainerWaitOKBody, 1)
+		errC := make(chan error, 1)
+		if tc.waitErr != nil {
+			errC <- tc.waitErr
+		} else if tc.timeout == 0 {
+			waitC <- container.ContainerWaitOKBody{StatusCode: tc.exitCode}
+		}
+		cli := new(entityMock.Client)
+		cli.On("ContainerWait", mock.Anything, "gen", container.WaitConditionNextExit).Return(
+			(<-chan container.ContainerWaitOKBody)(waitC), (<-chan error)(errC)).Once()
+		cli.On("ContainerStart", mock.Anything, "gen", mock.Anything).Return(nil).Once()
+		if len(tc.err) > 0 && tc.waitErr == nil {
+			cli.On("ContainerInspect", mock.Anything, "gen").Return(types.ContainerJSON{
+				Config: &container.Config{}}, nil).Once()
+			cli.On("ContainerLogs", mock.Anything, "gen", types.ContainerLogsOptions{ShowStdout: true,
+				ShowStderr: true, Tail: "20"}).Return(ioutil.NopCloser(bytes.NewReader(logs.Bytes())), nil).Once()
+		}
+
+		sc := entity.StartContainer{StartContainer: command.StartContainer{Name: "gen", Attach: true},
+			AllowFailure: tc.allowFailure}
+		sc.Timeout.Duration = tc.timeout
+		ds := NewDockerService(nil, config.Docker{ExitLogTail: 20}, nil, logrus.New())
+		res := ds.StartContainer(context.Background(), entity.DockerCli{Client: cli}, sc)
+		if len(tc.err) > 0 {
+			assert.EqualError(t, res.Error, tc.err)
+			assert.False(t, res.IsFatal())
+		} else {
+			assert.NoError(t, res.Error)
+		}
+		if tc.waitErr == nil && tc.timeout == 0 {
+			out, ok := res.Meta[entity.OutputKey].(entity.ContainerExit)
+			require.True(t, ok, res.Meta)
+			assert.Equal(t, int(tc.exitCode), out.ExitCode)
+		}
+		cli.AssertExpectations(t)
+	}
+}
+
+func TestDockerService_StartContainer_Attach_Canceled(t *testing.T) {
+	cli := new(entityMock.Client)
+	cli.On("ContainerWait", mock.Anything, "gen", container.WaitConditionNextExit).Return(
+		(<-chan container.ContainerWaitOKBody)(make(chan container.ContainerWaitOKBody)),
+		(<-chan error)(make(chan error))).Once()
+	cli.On("ContainerStart", mock.Anything, "gen", mock.Anything).Return(nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ds := NewDockerService(nil, config.Docker{}, nil, logrus.New())
+	res := ds.StartContainer(ctx, entity.DockerCli{Client: cli}, entity.StartContainer{
+		StartContainer: command.StartContainer{Name: "gen", Attach: true}})
+	assert.Equal(t, context.Canceled, res.Error)
+	cli.AssertExpectations(t)
+}
+
+func TestDockerService_ReadEmulation(t *testing.T) {
+	target := entity.EmulationTarget{Container: "node0", Network: "net0"}
+	testNetwork := types.NetworkResource{Name: "net0", ID: "id1", IPAM: network.IPAM{
+		Config: []network.IPAMConfig{{Subnet: "10.1.0.0/16"}}}}
+
+	repo := new(repoMock.DockerRepository)
+	for _, tc := range []struct {
+		stdout string
+		stderr string
+	}{
+		{stdout: "qdisc netem 8001: root refcnt 2 limit 1000 delay 100us\n"},
+		{stderr: "Cannot find device \"\"\n"},
+	} {
+		var out bytes.Buffer
+		if len(tc.stdout) > 0 {
+			stdcopy.NewStdWriter(&out, stdcopy.Stdout).Write([]byte(tc.stdout))
+		}
+		if len(tc.stderr) > 0 {
+			stdcopy.NewStdWriter(&out, stdcopy.Stderr).Write([]byte(tc.stderr))
+		}
+		conn := new(externalsMock.NetConn)
+		conn.On("Close").Return(nil).Once()
+
+		cli := new(entityMock.Client)
+		cli.On("ContainerRemove", mock.Anything, "node0-id1", mock.Anything).Return(nil).Twice()
+		cli.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			"node0-id1").Return(container.ContainerCreateCreatedBody{}, nil).Run(func(args mock.Arguments) {
+			config := args.Get(1).(*container.Config)
+			assert.Contains(t, config.Entrypoint[2], "10.1.0.0/16")
+			assert.Contains(t, config.Entrypoint[2], "tc qdisc show dev $dev")
+			assert.False(t, args.Get(2).(*container.HostConfig).AutoRemove)
+		}).Once()
+		cli.On("ContainerAttach", mock.Anything, "node0-id1", mock.Anything).Return(
+			types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(&out)}, nil).Once()
+		cli.On("ContainerStart", mock.Anything, "node0-id1", mock.Anything).Return(nil).Once()
+
+		repo.On("EnsureImagePulled", mock.Anything, mock.Anything, netemImage, "").Return(nil).Once()
+		repo.On("GetNetworkByName", mock.Anything, mock.Anything, "net0").Return(testNetwork, nil).Once()
+
+		ds := NewDockerService(repo, config.Docker{}, nil, logrus.New())
+		res := ds.ReadEmulation(nil, entity.DockerCli{Client: cli}, target)
+		if len(tc.stderr) > 0 {
+			assert.EqualError(t, res.Error, "failed to read the qdiscs of node0: Cannot find device \"\"")
+		} else {
+			require.NoError(t, res.Error)
+			assert.Equal(t, []entity.Qdisc{{Kind: "netem", Handle: "8001:", Parent: "root",
+				Options: "refcnt 2 limit 1000 delay 100us"}}, res.Meta[entity.OutputKey])
+		}
+		cli.AssertExpectations(t)
+		conn.AssertExpectations(t)
+	}
+	repo.AssertExpectations(t)
+}
+
 func TestDockerService_CreateNetwork_Success(t *testing.T) {
 	testNetwork := command.Network{
 		Name:    "testnet",
@@ -469,3 +607,101 @@ func TestRandomMacAddress(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestPeerNetemScript(t *testing.T) {
+	script := peerNetemScript([]string{"10.1.0.3", "10.1.0.4"}, map[string]command.Netconf{
+		"10.1.0.3": {Delay: 100},
+		"10.1.0.4": {Loss: 1, Rate: "1mbit"},
+	})
+	assert.Equal(t, "tc qdisc del dev $dev root 2>/dev/null; "+
+		"tc qdisc replace dev $dev root handle 1: htb default 1 && "+
+		"tc class add dev $dev parent 1: classid 1:1 htb rate 100gbit && "+
+		"tc class add dev $dev parent 1: classid 1:2 htb rate 100gbit && "+
+		"tc qdisc add dev $dev parent 1:2 handle 2: netem delay 100us && "+
+		"tc filter add dev $dev protocol ip parent 1: prio 1 u32 match ip dst 10.1.0.3/32 flowid 1:2 && "+
+		"tc class add dev $dev parent 1: classid 1:3 htb rate 100gbit && "+
+		"tc qdisc add dev $dev parent 1:3 handle 3: netem loss 1.0000 rate 1mbit && "+
+		"tc filter add dev $dev protocol ip parent 1: prio 1 u32 match ip dst 10.1.0.4/32 flowid 1:3", script)
+}
+
+func TestPartitionScript(t *testing.T) {
+	assert.Equal(t, "(iptables -N GENESIS-PARTITION-$dev 2>/dev/null || iptables -F GENESIS-PARTITION-$dev) && "+
+		"(iptables -C INPUT -i $dev -j GENESIS-PARTITION-$dev 2>/dev/null || "+
+		"iptables -I INPUT -i $dev -j GENESIS-PARTITION-$dev) && "+
+		"(iptables -C OUTPUT -o $dev -j GENESIS-PARTITION-$dev 2>/dev/null || "+
+		"iptables -I OUTPUT -o $dev -j GENESIS-PARTITION-$dev) && "+
+		"iptables -A GENESIS-PARTITION-$dev -s 10.1.0.3 -j DROP && "+
+		"iptables -A GENESIS-PARTITION-$dev -d 10.1.0.3 -j DROP", partitionScript([]string{"10.1.0.3"}))
+}
+
+func TestDockerService_StopContainer(t *testing.T) {
+	cli := new(entityMock.Client)
+	cli.On("ContainerStop", mock.Anything, "node0", (*time.Duration)(nil)).Return(nil).Once()
+	cli.On("ContainerRestart", mock.Anything, "node0", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		require.NotNil(t, args.Get(2))
+		assert.Equal(t, 5*time.Second, *args.Get(2).(*time.Duration))
+	}).Once()
+
+	ds := NewDockerService(nil, config.Docker{}, nil, logrus.New())
+	res := ds.StopContainer(nil, entity.DockerCli{Client: cli}, entity.StopContainer{Name: "node0"})
+	assert.NoError(t, res.Error)
+
+	sc := entity.StopContainer{Name: "node0"}
+	sc.Timeout.Duration = 5 * time.Second
+	res = ds.RestartContainer(nil, entity.DockerCli{Client: cli}, sc)
+	assert.NoError(t, res.Error)
+	cli.AssertExpectations(t)
+}
+
+func TestDockerService_KillContainer(t *testing.T) {
+	cli := new(entityMock.Client)
+	cli.On("ContainerKill", mock.Anything, "node0", "SIGKILL").Return(nil).Once()
+	cli.On("ContainerKill", mock.Anything, "node0", "SIGSTOP").Return(
+		fmt.Errorf("Error response from daemon: Container node0 is not running")).Once()
+
+	ds := NewDockerService(nil, config.Docker{}, nil, logrus.New())
+	res := ds.KillContainer(nil, entity.DockerCli{Client: cli}, entity.KillContainer{Name: "node0"})
+	assert.NoError(t, res.Error)
+	res = ds.KillContainer(nil, entity.DockerCli{Client: cli}, entity.KillContainer{Name: "node0", Signal: "SIGSTOP"})
+	assert.Error(t, res.Error)
+	assert.Equal(t, "SIGSTOP", res.Meta["signal"])
+	cli.AssertExpectations(t)
+}
+
+func TestDockerService_PauseContainer(t *testing.T) {
+	cli := new(entityMock.Client)
+	cli.On("ContainerPause", mock.Anything, "node0").Return(
+		fmt.Errorf("Error response from daemon: Container node0 is already paused")).Once()
+	cli.On("ContainerUnpause", mock.Anything, "node0").Return(
+		fmt.Errorf("Error response from daemon: Container node0 is not paused")).Once()
+	cli.On("ContainerUnpause", mock.Anything, "node1").Return(
+		fmt.Errorf("Error: No such container: node1")).Once()
+
+	ds := NewDockerService(nil, config.Docker{}, nil, logrus.New())
+	assert.NoError(t, ds.PauseContainer(nil, entity.DockerCli{Client: cli}, "node0").Error)
+	assert.NoError(t, ds.UnpauseContainer(nil, entity.DockerCli{Client: cli}, "node0").Error)
+	assert.Error(t, ds.UnpauseContainer(nil, entity.DockerCli{Client: cli}, "node1").Error)
+	cli.AssertExpectations(t)
+}
+
+func TestDockerService_PlaceFileInContainer_SourceError(t *testing.T) {
+	for _, tc := range []struct {
+		err   error
+		fatal bool
+	}{
+		{err: fmt.Errorf("%w: \"ftp\"", file.ErrUnsupportedScheme), fatal: true},
+		{err: fmt.Errorf("%w: the data URI is missing a comma", file.ErrInvalidSource), fatal: true},
+		{err: errors.New("connection refused")},
+	} {
+		remote := new(fileMock.RemoteSources)
+		remote.On("GetTarReader", "def0", mock.Anything).Return(nil, tc.err).Once()
+
+		ds := NewDockerService(nil, config.Docker{}, remote, logrus.New())
+		res := ds.PlaceFileInContainer(context.Background(), entity.DockerCli{
+			Labels: map[string]string{command.DefinitionIDKey: "def0"},
+		}, "node0", entity.File{File: command.File{ID: "ftp://example.com/file", Destination: "/opt/"}})
+		assert.EqualError(t, res.Error, tc.err.Error())
+		assert.Equal(t, tc.fatal, res.IsFatal())
+		remote.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,136 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+)
+
+// execPollInterval is how often an exec is inspected, once its output has been read, until it
+// is no longer running
+const execPollInterval = 100 * time.Millisecond
+
+// cappedBuffer keeps the first limit bytes written to it, and discards the rest.
+// There is no limit if limit is not positive.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (cb *cappedBuffer) Write(p []byte) (int, error) {
+	if cb.limit > 0 && cb.Len()+len(p) > cb.limit {
+		cb.truncated = true
+		cb.Buffer.Write(p[:cb.limit-cb.Len()])
+		return len(p), nil
+	}
+	return cb.Buffer.Write(p)
+}
+
+// execEnv gets the environment variables of an exec, sorted by name
+func execEnv(env map[string]string) []string {
+	out := []string{}
+	for key, val := range env {
+		out = append(out, key+"="+val)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Exec runs a command in a container, and places its output in the meta of the result, under
+// entity.OutputKey. The result is fatal if the command does not exit with the expected exit code, and
+// an error, which can be retried, if the command could not be run or its outcome could not be read.
+func (ds dockerService) Exec(ctx context.Context, cli entity.DockerCli, ec entity.ExecCommand) entity.Result {
+	if !ec.Timeout.Empty() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ec.Timeout.Duration)
+		defer cancel()
+	}
+	log := ds.withFields(cli, logrus.Fields{"container": ec.Container, "cmd": ec.Cmd})
+	log.Debug("running a command")
+
+	idRes, err := cli.ContainerExecCreate(ctx, ec.Container, types.ExecConfig{
+		User:         ec.User,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          execEnv(ec.Env),
+		WorkingDir:   ec.Workdir,
+		Cmd:          ec.Cmd,
+	})
+	if err != nil {
+		return entity.NewErrorResult(err)
+	}
+	stream, err := cli.ContainerExecAttach(ctx, idRes.ID, types.ExecStartCheck{})
+	if err != nil {
+		return entity.NewErrorResult(err)
+	}
+	defer stream.Close()
+
+	limit := ds.conf.ExecOutputLimit
+	stdout := &cappedBuffer{limit: limit}
+	stderr := &cappedBuffer{limit: limit}
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(stdout, stderr, stream.Reader)
+		errChan <- err
+	}()
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		return entity.NewErrorResult(fmt.Errorf("failed to read the output of %q: %w",
+			strings.Join(ec.Cmd, " "), err))
+	}
+
+	var inspect types.ContainerExecInspect
+	for {
+		inspect, err = cli.ContainerExecInspect(ctx, idRes.ID)
+		if err != nil {
+			return entity.NewErrorResult(err)
+		}
+		if !inspect.Running {
+			break
+		}
+		select {
+		case <-time.After(execPollInterval):
+		case <-ctx.Done():
+			return entity.NewErrorResult(ctx.Err())
+		}
+	}
+
+	out := entity.ExecOutput{
+		ExitCode:  inspect.ExitCode,
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Truncated: stdout.truncated || stderr.truncated,
+	}
+	log.WithField("exitCode", out.ExitCode).Debug("the command finished")
+	meta := map[string]interface{}{entity.OutputKey: out}
+	if out.ExitCode == ec.ExitCode {
+		return entity.NewSuccessResult().InjectMeta(meta)
+	}
+	shown := out.Stderr
+	if len(strings.TrimSpace(shown)) == 0 {
+		shown = out.Stdout
+	}
+	// running the command again would not change its outcome
+	return entity.NewFatalResult(fmt.Errorf("command %q exited with %d instead of %d: %s",
+		strings.Join(ec.Cmd, " "), out.ExitCode, ec.ExitCode, strings.TrimSpace(shown))).InjectMeta(meta)
+}
@@ -0,0 +1,81 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"testing"
+
+	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
+	externalsMock "github.com/whiteblock/genesis/mocks/pkg/externals"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	buf := &cappedBuffer{limit: 4}
+	n, err := buf.Write([]byte("abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	n, err = buf.Write([]byte("def"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, "abcd", buf.String())
+	assert.True(t, buf.truncated)
+
+	buf = &cappedBuffer{}
+	buf.Write([]byte("abcdef"))
+	assert.Equal(t, "abcdef", buf.String())
+	assert.False(t, buf.truncated)
+}
+
+func TestDockerService_Exec(t *testing.T) {
+	ec := entity.ExecCommand{Container: "node0", Cmd: []string{"geth", "attach", "--exec", "eth.blockNumber"},
+		Env: map[string]string{"B": "2", "A": "1"}, User: "root", Workdir: "/data"}
+
+	for _, exitCode := range []int{0, 1} {
+		var out bytes.Buffer
+		stdcopy.NewStdWriter(&out, stdcopy.Stdout).Write([]byte("1234567890\n"))
+		stdcopy.NewStdWriter(&out, stdcopy.Stderr).Write([]byte("warning\n"))
+		conn := new(externalsMock.NetConn)
+		conn.On("Close").Return(nil).Once()
+
+		cli := new(entityMock.Client)
+		cli.On("ContainerExecCreate", mock.Anything, "node0", types.ExecConfig{User: "root",
+			AttachStdout: true, AttachStderr: true, Env: []string{"A=1", "B=2"}, WorkingDir: "/data",
+			Cmd: ec.Cmd}).Return(types.IDResponse{ID: "exec0"}, nil).Once()
+		cli.On("ContainerExecAttach", mock.Anything, "exec0", mock.Anything).Return(
+			types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(&out)}, nil).Once()
+		cli.On("ContainerExecInspect", mock.Anything, "exec0").Return(
+			types.ContainerExecInspect{Running: true}, nil).Once()
+		cli.On("ContainerExecInspect", mock.Anything, "exec0").Return(
+			types.ContainerExecInspect{ExitCode: exitCode}, nil).Once()
+
+		ds := NewDockerService(nil, config.Docker{ExecOutputLimit: 5}, nil, logrus.New())
+		res := ds.Exec(context.Background(), entity.DockerCli{Client: cli}, ec)
+		if exitCode == 0 {
+			require.NoError(t, res.Error)
+		} else {
+			assert.EqualError(t, res.Error,
+				`command "geth attach --exec eth.blockNumber" exited with 1 instead of 0: warni`)
+			assert.True(t, res.IsFatal())
+		}
+		assert.Equal(t, entity.ExecOutput{ExitCode: exitCode, Stdout: "12345", Stderr: "warni",
+			Truncated: true}, res.Meta[entity.OutputKey])
+		cli.AssertExpectations(t)
+		conn.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,94 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+	"github.com/whiteblock/definition/command"
+)
+
+// healthPollInterval is how often a container is inspected while waiting for it to become healthy
+const healthPollInterval = time.Second
+
+// WaitHealthy waits until a container reports that it is healthy, for at most the timeout, which
+// defaults to command.DefaultTimeout. The result is an error, which can be retried, if the container
+// exits or is still not healthy once the timeout is reached. The output of the last healthcheck is
+// placed in the meta of the result, under "healthcheck".
+func (ds dockerService) WaitHealthy(ctx context.Context, cli entity.DockerCli, name string,
+	timeout command.Timeout) entity.Result {
+
+	if !timeout.IsInfinite() {
+		dur := timeout.Duration
+		if dur == 0 {
+			dur = command.DefaultTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dur)
+		defer cancel()
+	}
+	log := ds.withFields(cli, logrus.Fields{"name": name, "timeout": timeout})
+	log.Debug("waiting for the container to become healthy")
+
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+
+	status := types.Starting
+	last := ""
+	for {
+		cntr, err := cli.ContainerInspect(ctx, name)
+		if err != nil && ctx.Err() == nil {
+			return entity.NewErrorResult(err).InjectMeta(map[string]interface{}{
+				"name": name,
+				"type": "WaitHealthy",
+			})
+		}
+		if err == nil {
+			if cntr.State == nil || !cntr.State.Running {
+				exitCode := 0
+				if cntr.State != nil {
+					exitCode = cntr.State.ExitCode
+				}
+				return entity.NewErrorResult(fmt.Errorf(
+					"container %q exited with %d before becoming healthy: %s", name, exitCode, last),
+				).InjectMeta(map[string]interface{}{"name": name, "healthcheck": last})
+			}
+			if cntr.State.Health == nil {
+				return entity.NewFatalResult(fmt.Errorf("container %q does not have a healthcheck",
+					name)).InjectMeta(map[string]interface{}{"name": name})
+			}
+			status = cntr.State.Health.Status
+			if logs := cntr.State.Health.Log; len(logs) > 0 && logs[len(logs)-1] != nil {
+				last = strings.TrimSpace(logs[len(logs)-1].Output)
+			}
+			if status == types.Healthy {
+				log.Debug("the container is healthy")
+				return entity.NewSuccessResult().InjectMeta(map[string]interface{}{
+					"name":        name,
+					"healthcheck": last,
+				})
+			}
+			log.WithFields(logrus.Fields{"status": status, "healthcheck": last}).Trace(
+				"the container is not healthy yet")
+		}
+
+		select {
+		case <-ctx.Done():
+			return entity.NewErrorResult(fmt.Errorf(
+				"container %q did not become healthy in time, it is %s: %s", name, status, last),
+			).InjectMeta(map[string]interface{}{"name": name, "healthcheck": last})
+		case <-ticker.C:
+		}
+	}
+}
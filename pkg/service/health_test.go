@@ -0,0 +1,82 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/whiteblock/definition/command"
+)
+
+func healthState(running bool, status string, output string) types.ContainerJSON {
+	state := &types.ContainerState{Running: running, ExitCode: 1}
+	if len(status) > 0 {
+		state.Health = &types.Health{Status: status, Log: []*types.HealthcheckResult{
+			{ExitCode: 1, Output: output + "\n"}}}
+	}
+	return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{State: state}}
+}
+
+func TestDockerService_WaitHealthy(t *testing.T) {
+	timeout := command.Timeout{}
+	timeout.Duration = 50 * time.Millisecond
+
+	for _, tc := range []struct {
+		states []types.ContainerJSON
+		err    string
+		fatal  bool
+	}{
+		{states: []types.ContainerJSON{healthState(true, types.Healthy, "ok")}},
+		{states: []types.ContainerJSON{healthState(true, types.Starting, "refused"),
+			healthState(true, types.Healthy, "ok")}},
+		{
+			states: []types.ContainerJSON{healthState(true, types.Unhealthy, "refused")},
+			err:    `container "node0" did not become healthy in time, it is unhealthy: refused`,
+		},
+		{
+			states: []types.ContainerJSON{healthState(false, types.Unhealthy, "refused")},
+			err:    `container "node0" exited with 1 before becoming healthy: `,
+		},
+		{
+			states: []types.ContainerJSON{healthState(true, "", "")},
+			err:    `container "node0" does not have a healthcheck`,
+			fatal:  true,
+		},
+	} {
+		cli := new(entityMock.Client)
+		for i, state := range tc.states {
+			call := cli.On("ContainerInspect", mock.Anything, "node0").Return(state, nil)
+			if i < len(tc.states)-1 {
+				call.Once()
+			}
+		}
+		wait := timeout
+		if len(tc.states) > 1 {
+			wait.Duration = 2 * healthPollInterval
+		}
+		ds := NewDockerService(nil, config.Docker{}, nil, logrus.New())
+		res := ds.WaitHealthy(context.Background(), entity.DockerCli{Client: cli}, "node0", wait)
+		if len(tc.err) == 0 {
+			assert.NoError(t, res.Error)
+			assert.Equal(t, "ok", res.Meta["healthcheck"])
+		} else {
+			assert.EqualError(t, res.Error, tc.err)
+			assert.Equal(t, tc.fatal, res.IsFatal())
+		}
+		cli.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,172 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/repository"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+)
+
+// logTail gets the last lines logged by a container, both to stdout and stderr, up to the size
+// limit of the output of an exec
+func (ds dockerService) logTail(ctx context.Context, cli entity.DockerCli, name string) string {
+	if ds.conf.ExitLogTail <= 0 {
+		return ""
+	}
+	log := ds.withFields(cli, logrus.Fields{"name": name})
+	info, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		log.WithField("error", err).Warn("failed to inspect the container for its logs")
+		return ""
+	}
+	rdr, err := cli.ContainerLogs(ctx, name, types.ContainerLogsOptions{ShowStdout: true,
+		ShowStderr: true, Tail: strconv.Itoa(ds.conf.ExitLogTail)})
+	if err != nil {
+		log.WithField("error", err).Warn("failed to get the logs of the container")
+		return ""
+	}
+	defer rdr.Close()
+
+	out := &cappedBuffer{limit: ds.conf.ExecOutputLimit}
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(out, rdr)
+	} else {
+		_, err = stdcopy.StdCopy(out, out, rdr)
+	}
+	if err != nil {
+		log.WithField("error", err).Warn("failed to read the logs of the container")
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// logWriter splits what is written to it into lines, and emits each of them
+type logWriter struct {
+	stream  string
+	partial []byte
+	emit    func(stream string, text string) error
+}
+
+func (lw *logWriter) Write(p []byte) (int, error) {
+	lw.partial = append(lw.partial, p...)
+	for {
+		i := bytes.IndexByte(lw.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		text := string(lw.partial[:i])
+		lw.partial = lw.partial[i+1:]
+		err := lw.emit(lw.stream, text)
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush emits what is left of a line which did not end with a newline
+func (lw *logWriter) Flush() error {
+	if len(lw.partial) == 0 {
+		return nil
+	}
+	text := string(lw.partial)
+	lw.partial = nil
+	return lw.emit(lw.stream, text)
+}
+
+// splitLogTimestamp splits the timestamp the docker daemon puts in front of each line from the
+// rest of the line
+func splitLogTimestamp(text string) (time.Time, string, bool) {
+	parts := strings.SplitN(text, " ", 2)
+	ts, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, text, false
+	}
+	if len(parts) == 1 {
+		return ts, "", true
+	}
+	return ts, parts[1], true
+}
+
+// logLabels gets the labels which are passed on to the log driver from the labels of a container
+func (ds dockerService) logLabels(labels map[string]string) map[string]string {
+	out := map[string]string{}
+	for _, key := range strings.Split(ds.conf.LogLabels, ",") {
+		key = strings.TrimSpace(key)
+		if val, exists := labels[key]; exists {
+			out[key] = val
+		}
+	}
+	return out
+}
+
+// FollowLogs forwards the lines logged by a container after the given time to the sink, until
+// the container stops or ctx is done. It returns the time of the last line forwarded.
+func (ds dockerService) FollowLogs(ctx context.Context, cli entity.DockerCli, name string,
+	since time.Time, sink repository.LogSink) (time.Time, error) {
+
+	info, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return since, err
+	}
+	options := types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Follow: true, Timestamps: true}
+	if !since.IsZero() {
+		options.Since = since.Format(time.RFC3339Nano)
+	}
+	rdr, err := cli.ContainerLogs(ctx, name, options)
+	if err != nil {
+		return since, err
+	}
+	defer rdr.Close()
+
+	labels := map[string]string{}
+	tty := false
+	if info.Config != nil {
+		labels = ds.logLabels(info.Config.Labels)
+		tty = info.Config.Tty
+	}
+	name = strings.TrimPrefix(info.Name, "/")
+	log := ds.withFields(cli, logrus.Fields{"container": name, "since": since})
+	log.Debug("following the logs of a container")
+
+	last := since
+	emit := func(stream string, text string) error {
+		ts, text, ok := splitLogTimestamp(text)
+		if !ok {
+			ts = time.Now().UTC()
+		} else if !ts.After(last) {
+			return nil // the since filter of the daemon includes the last line forwarded
+		}
+		last = ts
+		return sink.Write(entity.LogLine{Container: name, Labels: labels, Stream: stream, Time: ts,
+			Line: strings.TrimSuffix(text, "\r")})
+	}
+	stdout := &logWriter{stream: "stdout", emit: emit}
+	stderr := &logWriter{stream: "stderr", emit: emit}
+	if tty {
+		_, err = io.Copy(stdout, rdr)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, rdr)
+	}
+	if err == nil {
+		err = stdout.Flush()
+	}
+	if err == nil {
+		err = stderr.Flush()
+	}
+	log.WithField("last", last).Debug("stopped following the logs of a container")
+	return last, err
+}
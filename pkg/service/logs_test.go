@@ -0,0 +1,88 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
+	repoMock "github.com/whiteblock/genesis/mocks/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogWriter(t *testing.T) {
+	lines := []string{}
+	lw := &logWriter{stream: "stdout", emit: func(stream string, text string) error {
+		assert.Equal(t, "stdout", stream)
+		lines = append(lines, text)
+		return nil
+	}}
+	n, err := lw.Write([]byte("a\nb"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	_, err = lw.Write([]byte("c\n\nd"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "bc", ""}, lines)
+	require.NoError(t, lw.Flush())
+	require.NoError(t, lw.Flush())
+	assert.Equal(t, []string{"a", "bc", "", "d"}, lines)
+}
+
+func TestSplitLogTimestamp(t *testing.T) {
+	ts, text, ok := splitLogTimestamp("2020-01-02T03:04:05.000000006Z block 1 imported")
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC), ts)
+	assert.Equal(t, "block 1 imported", text)
+
+	_, text, ok = splitLogTimestamp("block 1 imported")
+	assert.False(t, ok)
+	assert.Equal(t, "block 1 imported", text)
+}
+
+func TestDockerService_FollowLogs(t *testing.T) {
+	since := time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC)
+	var out bytes.Buffer
+	stdcopy.NewStdWriter(&out, stdcopy.Stdout).Write([]byte(
+		"2020-01-01T00:00:01Z already forwarded\n2020-01-01T00:00:02Z started\n"))
+	stdcopy.NewStdWriter(&out, stdcopy.Stderr).Write([]byte("2020-01-01T00:00:03Z failed"))
+
+	cli := new(entityMock.Client)
+	cli.On("ContainerInspect", mock.Anything, "node0").Return(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{Name: "/node0"},
+		Config: &container.Config{Labels: map[string]string{"org": "org0", "testRun": "test0",
+			"other": "x"}}}, nil).Once()
+	cli.On("ContainerLogs", mock.Anything, "node0", types.ContainerLogsOptions{ShowStdout: true,
+		ShowStderr: true, Follow: true, Timestamps: true, Since: "2020-01-01T00:00:01Z"}).Return(
+		ioutil.NopCloser(&out), nil).Once()
+
+	labels := map[string]string{"org": "org0", "testRun": "test0"}
+	sink := new(repoMock.LogSink)
+	sink.On("Write", entity.LogLine{Container: "node0", Labels: labels, Stream: "stdout",
+		Time: time.Date(2020, 1, 1, 0, 0, 2, 0, time.UTC), Line: "started"}).Return(nil).Once()
+	sink.On("Write", entity.LogLine{Container: "node0", Labels: labels, Stream: "stderr",
+		Time: time.Date(2020, 1, 1, 0, 0, 3, 0, time.UTC), Line: "failed"}).Return(nil).Once()
+
+	ds := NewDockerService(nil, config.Docker{LogLabels: "org,testRun,phase"}, nil, logrus.New())
+	last, err := ds.FollowLogs(context.Background(), entity.DockerCli{Client: cli}, "node0", since, sink)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 3, 0, time.UTC), last)
+	cli.AssertExpectations(t)
+	sink.AssertExpectations(t)
+}
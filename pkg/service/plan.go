@@ -0,0 +1,276 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/file"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/simulator"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/sirupsen/logrus"
+)
+
+// plannedGlusterImage is the image of the gluster containers which are assumed to be running in a plan
+const plannedGlusterImage = "gluster"
+
+//NewPlanningDockerService creates a DockerService which makes its docker calls against the
+//clients of the given recorder, instead of a docker daemon. Files are looked up at their source,
+//instead of being fetched.
+func NewPlanningDockerService(
+	repo repository.DockerRepository,
+	conf config.Docker,
+	remote file.RemoteSources,
+	plan *DockerCallRecorder,
+	log logrus.Ext1FieldLogger) DockerService {
+
+	return NewDockerServiceWithClients(repo, conf, plannedSources{RemoteSources: remote},
+		func(host string) (entity.Client, error) {
+			return plan.Client(host), nil
+		}, log)
+}
+
+// plannedSources looks files up at their source in place of fetching them, so that a plan never
+// opens them. Their archives are empty, and only declare the size of the file.
+type plannedSources struct {
+	file.RemoteSources
+}
+
+func (ps plannedSources) GetTarReader(testnetID string, f entity.File) (file.TarReader, error) {
+	size, err := ps.Stat(testnetID, f)
+	if err != nil {
+		return nil, err
+	}
+	return plannedArchive{size: size}, nil
+}
+
+// GetTemplateTarReader looks the template up, as GetTarReader does, so it is not rendered
+func (ps plannedSources) GetTemplateTarReader(testnetID string, f entity.File,
+	_ entity.TemplateContext) (file.TarReader, error) {
+	return ps.GetTarReader(testnetID, f)
+}
+
+// plannedArchive is the archive of a file which is looked up in a plan, which has no content
+type plannedArchive struct {
+	size int64
+}
+
+func (pa plannedArchive) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (pa plannedArchive) Close() error {
+	return nil
+}
+
+func (pa plannedArchive) Sha256() string {
+	return ""
+}
+
+func (pa plannedArchive) Size() int64 {
+	return pa.size
+}
+
+// DockerCallRecorder records the docker calls made through its clients, in place of making them.
+// The calls are made against simulated docker daemons instead, which start out empty, so that
+// they go as they would on hosts which start out empty.
+type DockerCallRecorder struct {
+	mux    sync.Mutex
+	calls  []entity.DockerCall
+	docker *simulator.Docker
+
+	// gluster is the gluster volumes which have been created on each host
+	gluster map[string]map[string]bool
+	// waited is the containers on each host which are to exit once they start
+	waited map[string]map[string]bool
+	// attached is the containers on each host which were attached to, and so exit on their own
+	attached map[string]map[string]bool
+}
+
+// NewDockerCallRecorder creates a new DockerCallRecorder
+func NewDockerCallRecorder() *DockerCallRecorder {
+	out := &DockerCallRecorder{
+		docker:   simulator.NewDocker(),
+		gluster:  map[string]map[string]bool{},
+		waited:   map[string]map[string]bool{},
+		attached: map[string]map[string]bool{},
+	}
+	out.docker.SetExecHandler(out.exec)
+	return out
+}
+
+// Client gets a client which records its calls as being made against the given host
+func (dcr *DockerCallRecorder) Client(host string) entity.Client {
+	return &trafficClient{
+		cli:    &planClient{Client: dcr.docker.Client(host), host: host, rec: dcr},
+		host:   host,
+		handle: dcr.record,
+	}
+}
+
+// Take gets the calls recorded since the last call to Take
+func (dcr *DockerCallRecorder) Take() []entity.DockerCall {
+	dcr.mux.Lock()
+	defer dcr.mux.Unlock()
+	out := dcr.calls
+	dcr.calls = nil
+	if out == nil {
+		return []entity.DockerCall{}
+	}
+	return out
+}
+
+func (dcr *DockerCallRecorder) record(call entity.DockerCall, _ interface{}, do func() error) error {
+	dcr.mux.Lock()
+	dcr.calls = append(dcr.calls, call)
+	dcr.mux.Unlock()
+	return do()
+}
+
+// exec decides the outcome of the commands run in the planned containers. They all succeed, except
+// for the check for whether a gluster volume exists, which fails until the volume is created.
+func (dcr *DockerCallRecorder) exec(host string, _ string, cmd []string) simulator.Output {
+	if len(cmd) < 4 || cmd[0] != "gluster" || cmd[1] != "volume" {
+		return simulator.Output{}
+	}
+	dcr.mux.Lock()
+	defer dcr.mux.Unlock()
+	switch cmd[2] {
+	case "create":
+		mark(dcr.gluster, host, cmd[3], true)
+	case "status":
+		if !dcr.gluster[host][cmd[3]] {
+			return simulator.Output{ExitCode: 1}
+		}
+	}
+	return simulator.Output{}
+}
+
+// mark sets whether the given name is in the set of the given host. The caller must hold the lock.
+func mark(sets map[string]map[string]bool, host string, name string, val bool) {
+	if _, exists := sets[host]; !exists {
+		sets[host] = map[string]bool{}
+	}
+	sets[host][name] = val
+}
+
+// planClient is a client of an in-memory docker daemon, which stands in for a host in a plan.
+// As nothing runs, containers are healthy as soon as they start, and exit with 0 as soon as they
+// are waited on. Files are not copied into containers, so that they are never read.
+type planClient struct {
+	entity.Client
+	host string
+	rec  *DockerCallRecorder
+}
+
+// ensureGluster starts the gluster container, if it is not running, as volume shares are assumed
+// to have been set up before the plan when volumes are created
+func (pc *planClient) ensureGluster(ctx context.Context) error {
+	if _, err := pc.Client.ContainerInspect(ctx, GlusterContainerName); err == nil {
+		return nil
+	}
+	pc.rec.docker.AddImage(pc.host, plannedGlusterImage)
+	_, err := pc.Client.ContainerCreate(ctx, &container.Config{Image: plannedGlusterImage},
+		&container.HostConfig{NetworkMode: "host"}, nil, GlusterContainerName)
+	if err != nil {
+		return err
+	}
+	return pc.Client.ContainerStart(ctx, GlusterContainerName, types.ContainerStartOptions{})
+}
+
+func (pc *planClient) ContainerAttach(ctx context.Context, container string,
+	options types.ContainerAttachOptions) (types.HijackedResponse, error) {
+
+	out, err := pc.Client.ContainerAttach(ctx, container, options)
+	if err == nil {
+		pc.rec.mux.Lock()
+		mark(pc.rec.attached, pc.host, container, true)
+		pc.rec.mux.Unlock()
+	}
+	return out, err
+}
+
+func (pc *planClient) ContainerExecCreate(ctx context.Context, container string,
+	config types.ExecConfig) (types.IDResponse, error) {
+
+	if container == GlusterContainerName {
+		err := pc.ensureGluster(ctx)
+		if err != nil {
+			return types.IDResponse{}, err
+		}
+	}
+	return pc.Client.ContainerExecCreate(ctx, container, config)
+}
+
+// ContainerStart starts the container, which becomes healthy right away if it has a healthcheck,
+// and exits with 0 right away if it is waited on
+func (pc *planClient) ContainerStart(ctx context.Context, containerID string,
+	options types.ContainerStartOptions) error {
+
+	err := pc.Client.ContainerStart(ctx, containerID, options)
+	if err != nil {
+		return err
+	}
+	pc.rec.mux.Lock()
+	waited := pc.rec.waited[pc.host][containerID] && !pc.rec.attached[pc.host][containerID]
+	mark(pc.rec.waited, pc.host, containerID, false)
+	mark(pc.rec.attached, pc.host, containerID, false)
+	pc.rec.mux.Unlock()
+	if waited {
+		return pc.rec.docker.Exit(pc.host, containerID, 0)
+	}
+	cntr, err := pc.Client.ContainerInspect(ctx, containerID)
+	if err != nil || cntr.State.Health == nil {
+		return nil // it may have been removed once it exited
+	}
+	return pc.rec.docker.SetHealth(pc.host, containerID, types.Healthy, "")
+}
+
+// ContainerWait waits for the container to exit, which it does right away if it is running and
+// as soon as it starts otherwise
+func (pc *planClient) ContainerWait(ctx context.Context, containerID string,
+	condition container.WaitCondition) (<-chan container.ContainerWaitOKBody, <-chan error) {
+
+	resC, errC := pc.Client.ContainerWait(ctx, containerID, condition)
+	cntr, err := pc.Client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return resC, errC
+	}
+	pc.rec.mux.Lock()
+	attached := pc.rec.attached[pc.host][containerID]
+	if !cntr.State.Running {
+		mark(pc.rec.waited, pc.host, containerID, true)
+	}
+	pc.rec.mux.Unlock()
+	if cntr.State.Running && !attached {
+		pc.rec.docker.Exit(pc.host, containerID, 0)
+	}
+	return resC, errC
+}
+
+// CopyToContainer checks that the container exists, without reading the content
+func (pc *planClient) CopyToContainer(ctx context.Context, containerID, dstPath string,
+	content io.Reader, options types.CopyToContainerOptions) error {
+
+	_, err := pc.Client.ContainerInspect(ctx, containerID)
+	return err
+}
+
+// ImageLoad does nothing, as the images which would be loaded are not known
+func (pc *planClient) ImageLoad(ctx context.Context, input io.Reader,
+	quiet bool) (types.ImageLoadResponse, error) {
+	return types.ImageLoadResponse{Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+}
@@ -0,0 +1,151 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	fileMock "github.com/whiteblock/genesis/mocks/pkg/file"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/file"
+	"github.com/whiteblock/genesis/pkg/repository"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/whiteblock/definition/command"
+)
+
+func plannedExecs(calls []entity.DockerCall, host string) []string {
+	out := []string{}
+	for _, call := range calls {
+		if call.Method == "ContainerExecCreate" && call.Host == host {
+			out = append(out, strings.Join(call.Args["config"].(types.ExecConfig).Cmd, " "))
+		}
+	}
+	return out
+}
+
+func TestDockerService_Plan_CreateVolume_Gluster(t *testing.T) {
+	rec := NewDockerCallRecorder()
+	ds := NewPlanningDockerService(repository.NewDockerRepository(logrus.New()),
+		config.Docker{GlusterDriver: "glusterfs"}, nil, rec, logrus.New())
+
+	cli, err := ds.CreateClient("10.0.0.1", "")
+	require.NoError(t, err)
+	res := ds.CreateVolume(context.Background(), entity.DockerCli{Client: cli}, command.Volume{
+		Name: "vol", Global: true, Hosts: []string{"10.0.0.1", "10.0.0.2"}})
+	require.NoError(t, res.Error)
+
+	calls := rec.Take()
+	assert.Equal(t, []string{
+		"mkdir -p /var/bricks/vol",
+		"gluster volume status vol",
+		"gluster volume status vol", // retried once
+		"gluster volume create vol replica 2 biome--0:/var/bricks/vol biome--1:/var/bricks/vol force",
+		"gluster volume start vol",
+		"gluster volume set vol ctime off",
+		"gluster volume set vol auth.allow 10.0.0.1,10.0.0.2,127.0.0.1",
+	}, plannedExecs(calls, "10.0.0.1"))
+	assert.Equal(t, []string{"mkdir -p /var/bricks/vol"}, plannedExecs(calls, "10.0.0.2"))
+	assert.Empty(t, rec.Take())
+}
+
+func TestDockerService_Plan_TestResources(t *testing.T) {
+	rec := NewDockerCallRecorder()
+	ds := NewPlanningDockerService(repository.NewDockerRepository(logrus.New()),
+		config.Docker{}, nil, rec, logrus.New())
+
+	cli, err := ds.CreateClient("10.0.0.1", "")
+	require.NoError(t, err)
+	docker := entity.DockerCli{Client: cli, Labels: map[string]string{command.TestIDKey: "test"}}
+	require.NoError(t, ds.CreateNetwork(context.Background(), docker, command.Network{Name: "net0"}).Error)
+	require.NoError(t, ds.CreateContainer(context.Background(), docker, entity.Container{Container: command.Container{
+		Name: "node0", Image: "alpine", Cpus: "1", Memory: "1GB"}}).Error)
+
+	res := ds.CreateContainer(context.Background(), docker, entity.Container{Container: command.Container{
+		Name: "node0", Image: "alpine", Cpus: "1", Memory: "1GB"}})
+	assert.NoError(t, res.Error, "an existing container is not an error")
+
+	found, err := ds.ListTestResources(context.Background(), docker, "test")
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, []string{"node0"}, found[0].Containers)
+	assert.Equal(t, []string{"net0"}, found[0].Networks)
+
+	require.NoError(t, ds.RemoveTestResources(context.Background(), docker, found[0]).Error)
+	found, err = ds.ListTestResources(context.Background(), docker, "test")
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func TestDockerService_Plan_WaitHealthy(t *testing.T) {
+	rec := NewDockerCallRecorder()
+	ds := NewPlanningDockerService(repository.NewDockerRepository(logrus.New()),
+		config.Docker{}, nil, rec, logrus.New())
+
+	cli, err := ds.CreateClient("10.0.0.1", "")
+	require.NoError(t, err)
+	docker := entity.DockerCli{Client: cli, Labels: map[string]string{}}
+	require.NoError(t, ds.CreateContainer(context.Background(), docker, entity.Container{
+		Container:   command.Container{Name: "node0", Image: "alpine", Cpus: "1", Memory: "1GB"},
+		Healthcheck: &entity.Healthcheck{Command: []string{"true"}}}).Error)
+	require.NoError(t, ds.CreateContainer(context.Background(), docker, entity.Container{
+		Container: command.Container{Name: "node1", Image: "alpine", Cpus: "1", Memory: "1GB"}}).Error)
+	for _, name := range []string{"node0", "node1"} {
+		require.NoError(t, ds.StartContainer(context.Background(), docker, entity.StartContainer{
+			StartContainer: command.StartContainer{Name: name}}).Error)
+	}
+
+	assert.NoError(t, ds.WaitHealthy(context.Background(), docker, "node0", command.Timeout{}).Error)
+	assert.Error(t, ds.WaitHealthy(context.Background(), docker, "node1", command.Timeout{}).Error)
+}
+
+func TestDockerService_Plan_PlaceFileInContainer(t *testing.T) {
+	const size = 16 << 20
+	src := new(fileMock.Source)
+	src.On("Stat", "def0", mock.Anything).Return(int64(size), nil).Once()
+	remote := file.NewRemoteSourcesWithSources(config.Config{},
+		map[string]file.Source{file.SchemeHTTPS: src}, logrus.New())
+
+	rec := NewDockerCallRecorder()
+	ds := NewPlanningDockerService(repository.NewDockerRepository(logrus.New()),
+		config.Docker{}, remote, rec, logrus.New())
+	cli, err := ds.CreateClient("10.0.0.1", "")
+	require.NoError(t, err)
+	docker := entity.DockerCli{Client: cli, Labels: map[string]string{command.DefinitionIDKey: "def0"}}
+	require.NoError(t, ds.CreateContainer(context.Background(), docker, entity.Container{Container: command.Container{
+		Name: "node0", Image: "alpine", Cpus: "1", Memory: "1GB"}}).Error)
+	rec.Take()
+
+	res := ds.PlaceFileInContainer(context.Background(), docker, "node0", entity.File{
+		File: command.File{ID: "https://example.com/genesis", Destination: "/opt/"}})
+	require.NoError(t, res.Error)
+
+	var copied *entity.DockerCall
+	for _, call := range rec.Take() {
+		if call.Method == "CopyToContainer" {
+			copied = &call
+		}
+	}
+	require.NotNil(t, copied)
+	assert.Equal(t, "https://example.com/genesis", copied.Args["source"])
+	assert.Equal(t, int64(size), copied.Args["size"])
+	src.AssertExpectations(t)
+	src.AssertNotCalled(t, "Open", mock.Anything, mock.Anything)
+
+	src.On("Stat", "def0", mock.Anything).Return(int64(0), errors.New("404 Not Found")).Once()
+	res = ds.PlaceFileInContainer(context.Background(), docker, "node0", entity.File{
+		File: command.File{ID: "https://example.com/missing", Destination: "/opt/"}})
+	assert.EqualError(t, res.Error, "404 Not Found")
+}
@@ -0,0 +1,119 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/helper"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/sirupsen/logrus"
+	"github.com/whiteblock/definition/command"
+)
+
+func testLabelFilter(testID string) filters.Args {
+	if len(testID) == 0 {
+		return filters.NewArgs(filters.Arg("label", command.TestIDKey))
+	}
+	return filters.NewArgs(filters.Arg("label", command.TestIDKey+"="+testID))
+}
+
+// ListTestResources lists the resources on the host which are labeled as belonging to a test,
+// grouped by test, along with when the newest of them was created. If testID is not empty, only
+// the resources of that test are listed.
+func (ds dockerService) ListTestResources(ctx context.Context, cli entity.DockerCli,
+	testID string) ([]entity.TestResources, error) {
+
+	byTest := map[string]*entity.TestResources{}
+	get := func(labels map[string]string) *entity.TestResources {
+		id := labels[command.TestIDKey]
+		if _, exists := byTest[id]; !exists {
+			res := entity.NewTestResources(id)
+			byTest[id] = &res
+		}
+		return byTest[id]
+	}
+	filter := testLabelFilter(testID)
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: filter})
+	if err != nil {
+		return nil, err
+	}
+	for _, cntr := range containers {
+		if len(cntr.Names) == 0 {
+			continue
+		}
+		res := get(cntr.Labels)
+		res.Containers = append(res.Containers, strings.TrimPrefix(cntr.Names[0], "/"))
+		res.AddCreated(time.Unix(cntr.Created, 0))
+	}
+
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{Filters: filter})
+	if err != nil {
+		return nil, err
+	}
+	for _, net := range networks {
+		res := get(net.Labels)
+		res.Networks = append(res.Networks, net.Name)
+		res.AddCreated(net.Created)
+	}
+
+	volumes, err := cli.VolumeList(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, vol := range volumes.Volumes {
+		res := get(vol.Labels)
+		res.Volumes = append(res.Volumes, vol.Name)
+		if created, err := time.Parse(time.RFC3339, vol.CreatedAt); err == nil {
+			res.AddCreated(created)
+		}
+	}
+
+	out := make([]entity.TestResources, 0, len(byTest))
+	for _, res := range byTest {
+		out = append(out, *res)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].TestID < out[j].TestID })
+	ds.withFields(cli, logrus.Fields{"test": testID, "found": len(out)}).Debug("listed the test resources")
+	return out, nil
+}
+
+// RemoveTestResources removes the given containers, then networks, then volumes
+func (ds dockerService) RemoveTestResources(ctx context.Context, cli entity.DockerCli,
+	res entity.TestResources) entity.Result {
+
+	ds.withFields(cli, logrus.Fields{"test": res.TestID, "containers": res.Containers,
+		"networks": res.Networks, "volumes": res.Volumes}).Info("removing the resources of a test")
+
+	result := ds.RemoveContainer(ctx, cli, res.Containers...)
+	if !result.IsSuccess() {
+		return result
+	}
+
+	var err error
+	for _, net := range res.Networks {
+		e := cli.NetworkRemove(ctx, net)
+		if e != nil && !strings.Contains(e.Error(), "not found") &&
+			!strings.Contains(e.Error(), "No such network") {
+			err = helper.JoinErrors(err, e)
+		}
+	}
+	for _, vol := range res.Volumes {
+		e := cli.VolumeRemove(ctx, vol, true)
+		if e != nil && !strings.Contains(e.Error(), "No such volume") {
+			err = helper.JoinErrors(err, e)
+		}
+	}
+	return entity.NewResult(err)
+}
@@ -0,0 +1,104 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	dockerVolume "github.com/docker/docker/api/types/volume"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/whiteblock/definition/command"
+)
+
+func TestDockerService_ListTestResources(t *testing.T) {
+	labels := func(test string) map[string]string {
+		return map[string]string{command.TestIDKey: test}
+	}
+	cli := new(entityMock.Client)
+	cli.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{
+		{Names: []string{"/node0"}, Labels: labels("b"), Created: 100},
+		{Names: []string{"/node1"}, Labels: labels("a"), Created: 300},
+	}, nil).Run(func(args mock.Arguments) {
+		opts := args.Get(1).(types.ContainerListOptions)
+		assert.True(t, opts.All)
+		assert.True(t, opts.Filters.ExactMatch("label", command.TestIDKey))
+	}).Once()
+	cli.On("NetworkList", mock.Anything, mock.Anything).Return([]types.NetworkResource{
+		{Name: "net0", Labels: labels("a"), Created: time.Unix(200, 0)},
+	}, nil).Once()
+	cli.On("VolumeList", mock.Anything, mock.Anything).Return(dockerVolume.VolumeListOKBody{
+		Volumes: []*types.Volume{{Name: "vol0", Labels: labels("b"),
+			CreatedAt: time.Unix(400, 0).Format(time.RFC3339)}},
+	}, nil).Once()
+
+	ds := NewDockerService(nil, config.Docker{}, nil, logrus.New())
+	res, err := ds.ListTestResources(context.Background(), entity.DockerCli{Client: cli}, "")
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+
+	assert.Equal(t, "a", res[0].TestID)
+	assert.Equal(t, []string{"node1"}, res[0].Containers)
+	assert.Equal(t, []string{"net0"}, res[0].Networks)
+	assert.Empty(t, res[0].Volumes)
+	assert.Equal(t, int64(300), res[0].Created.Unix())
+
+	assert.Equal(t, "b", res[1].TestID)
+	assert.Equal(t, []string{"node0"}, res[1].Containers)
+	assert.Empty(t, res[1].Networks)
+	assert.Equal(t, []string{"vol0"}, res[1].Volumes)
+	assert.Equal(t, int64(400), res[1].Created.Unix())
+	cli.AssertExpectations(t)
+}
+
+func TestDockerService_ListTestResources_Failure(t *testing.T) {
+	cli := new(entityMock.Client)
+	cli.On("ContainerList", mock.Anything, mock.Anything).Return(nil, fmt.Errorf("err")).Once()
+
+	ds := NewDockerService(nil, config.Docker{}, nil, logrus.New())
+	_, err := ds.ListTestResources(context.Background(), entity.DockerCli{Client: cli}, "test")
+	assert.Error(t, err)
+	cli.AssertExpectations(t)
+}
+
+func TestDockerService_RemoveTestResources(t *testing.T) {
+	var order []string
+	cli := new(entityMock.Client)
+	cli.On("ContainerRemove", mock.Anything, "node0", mock.Anything).Return(nil).Run(
+		func(_ mock.Arguments) { order = append(order, "container") }).Once()
+	cli.On("NetworkRemove", mock.Anything, "net0").Return(nil).Run(
+		func(_ mock.Arguments) { order = append(order, "network") }).Once()
+	cli.On("VolumeRemove", mock.Anything, "vol0", true).Return(
+		fmt.Errorf("No such volume: vol0")).Run(
+		func(_ mock.Arguments) { order = append(order, "volume") }).Once()
+
+	ds := NewDockerService(nil, config.Docker{}, nil, logrus.New())
+	res := ds.RemoveTestResources(context.Background(), entity.DockerCli{Client: cli}, entity.TestResources{
+		TestID:     "test",
+		Containers: []string{"node0"},
+		Networks:   []string{"net0"},
+		Volumes:    []string{"vol0"},
+	})
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []string{"container", "network", "volume"}, order)
+	cli.AssertExpectations(t)
+}
+
+func TestTestLabelFilter(t *testing.T) {
+	assert.Equal(t, filters.NewArgs(filters.Arg("label", command.TestIDKey+"=foo")), testLabelFilter("foo"))
+}
@@ -0,0 +1,679 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/file"
+	"github.com/whiteblock/genesis/pkg/repository"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrNotRecorded is returned by a replayed call which has no recorded counterpart left
+	ErrNotRecorded = errors.New("the call was not recorded")
+
+	// ErrNotReplayable is returned by the replayed calls which return streams, as the streams
+	// are not recorded
+	ErrNotReplayable = errors.New("this call cannot be replayed")
+)
+
+//NewRecordingDockerService creates a DockerService which records the docker calls it makes on
+//behalf of each test
+func NewRecordingDockerService(
+	repo repository.DockerRepository,
+	conf config.Docker,
+	remote file.RemoteSources,
+	recordings repository.RecordingRepository,
+	log logrus.Ext1FieldLogger) DockerService {
+
+	return dockerService{
+		conf:       conf,
+		repo:       repo,
+		remote:     remote,
+		recordings: recordings,
+		log:        log}
+}
+
+//NewReplayingDockerService creates a DockerService which gets the outcomes of its docker calls
+//from the given replayer, instead of a docker daemon
+func NewReplayingDockerService(
+	repo repository.DockerRepository,
+	conf config.Docker,
+	remote file.RemoteSources,
+	replay *Replayer,
+	log logrus.Ext1FieldLogger) DockerService {
+
+	return NewDockerServiceWithClients(repo, conf, remote, func(host string) (entity.Client, error) {
+		return replay.Client(host), nil
+	}, log)
+}
+
+// callHandler handles a call made through a trafficClient. The outcome of the call is placed in
+// result, which may be nil, and the returned error. do makes the call against docker.
+type callHandler func(call entity.DockerCall, result interface{}, do func() error) error
+
+// trafficClient is an entity.Client which passes each call, along with its arguments, through a
+// callHandler. The arguments are named as they are in a plan.
+type trafficClient struct {
+	cli    entity.Client
+	host   string
+	handle callHandler
+}
+
+// NewRecordingClient wraps the given client for the given host so that every call it makes is
+// added to the recording of the given test. Failing to record a call does not fail the call.
+func NewRecordingClient(
+	cli entity.Client,
+	host string,
+	testID string,
+	recordings repository.RecordingRepository,
+	log logrus.Ext1FieldLogger) entity.Client {
+
+	return &trafficClient{cli: cli, host: host, handle: func(call entity.DockerCall,
+		result interface{}, do func() error) error {
+
+		rec := entity.RecordedCall{DockerCall: call, Test: testID, Time: time.Now()}
+		err := do()
+		if err != nil {
+			rec.Error = err.Error()
+		} else if result != nil {
+			data, e := json.Marshal(result)
+			if e != nil {
+				log.WithFields(logrus.Fields{"method": call.Method, "error": e}).Warn(
+					"failed to record the result of a docker call")
+			}
+			rec.Result = data
+		}
+		e := recordings.Append(rec)
+		if e != nil {
+			log.WithFields(logrus.Fields{"method": call.Method, "test": testID, "error": e}).Warn(
+				"failed to record a docker call")
+		}
+		return err
+	}}
+}
+
+// Replayer serves the outcomes of recorded docker calls back, in place of docker daemons.
+// A call gets the outcome of the first unused recorded call with the same host, method and
+// arguments, or else the first unused one with the same host and method, so that calls made
+// concurrently, or with random arguments, are still replayed.
+type Replayer struct {
+	mux   sync.Mutex
+	calls []entity.RecordedCall
+	args  []string
+	used  []bool
+}
+
+// NewReplayer creates a Replayer for the given recorded calls
+func NewReplayer(calls []entity.RecordedCall) (*Replayer, error) {
+	out := &Replayer{calls: calls, args: make([]string, len(calls)), used: make([]bool, len(calls))}
+	for i := range calls {
+		args, err := canonicalArgs(calls[i].Args)
+		if err != nil {
+			return nil, err
+		}
+		out.args[i] = args
+	}
+	return out, nil
+}
+
+// Client gets a client which replays the calls recorded for the given host
+func (r *Replayer) Client(host string) entity.Client {
+	return &trafficClient{host: host, handle: func(call entity.DockerCall,
+		result interface{}, _ func() error) error {
+		return r.replay(call, result)
+	}}
+}
+
+// Unused gets the recorded calls which have not been replayed
+func (r *Replayer) Unused() []entity.RecordedCall {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	out := []entity.RecordedCall{}
+	for i := range r.calls {
+		if !r.used[i] {
+			out = append(out, r.calls[i])
+		}
+	}
+	return out
+}
+
+func (r *Replayer) replay(call entity.DockerCall, result interface{}) error {
+	args, err := canonicalArgs(call.Args)
+	if err != nil {
+		return err
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	match := -1
+	for i := range r.calls {
+		if r.used[i] || r.calls[i].Host != call.Host || r.calls[i].Method != call.Method {
+			continue
+		}
+		if r.args[i] == args {
+			match = i
+			break
+		}
+		if match == -1 {
+			match = i
+		}
+	}
+	if match == -1 {
+		return fmt.Errorf("%w: %s on %s", ErrNotRecorded, call.Method, call.Host)
+	}
+	r.used[match] = true
+	rec := r.calls[match]
+	if len(rec.Error) > 0 {
+		return errors.New(rec.Error)
+	}
+	if result != nil && len(rec.Result) > 0 {
+		return json.Unmarshal(rec.Result, result)
+	}
+	return nil
+}
+
+// canonicalArgs encodes the arguments of a call the same way, whether they are the originals or
+// have been decoded from a recording
+func canonicalArgs(args map[string]interface{}) (string, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	var decoded interface{}
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		return "", err
+	}
+	data, err = json.Marshal(decoded)
+	return string(data), err
+}
+
+func (tc *trafficClient) call(method string, args map[string]interface{}, result interface{},
+	do func() error) error {
+	return tc.handle(entity.DockerCall{Host: tc.host, Method: method, Args: args}, result, do)
+}
+
+func (tc *trafficClient) Close() error {
+	if tc.cli == nil {
+		return nil
+	}
+	return tc.cli.Close()
+}
+
+func (tc *trafficClient) ContainerAttach(ctx context.Context, container string,
+	options types.ContainerAttachOptions) (out types.HijackedResponse, err error) {
+
+	if tc.cli == nil {
+		return out, ErrNotReplayable
+	}
+	err = tc.call("ContainerAttach", map[string]interface{}{"container": container, "options": options},
+		nil, func() error {
+			out, err = tc.cli.ContainerAttach(ctx, container, options)
+			return err
+		})
+	return
+}
+
+func (tc *trafficClient) ContainerCreate(ctx context.Context, config *container.Config,
+	hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig,
+	containerName string) (out container.ContainerCreateCreatedBody, err error) {
+
+	err = tc.call("ContainerCreate", map[string]interface{}{"name": containerName, "config": config,
+		"hostConfig": hostConfig, "networkingConfig": networkingConfig}, &out, func() error {
+		out, err = tc.cli.ContainerCreate(ctx, config, hostConfig, networkingConfig, containerName)
+		return err
+	})
+	return
+}
+
+func (tc *trafficClient) ContainerExecAttach(ctx context.Context, execID string,
+	config types.ExecStartCheck) (out types.HijackedResponse, err error) {
+
+	if tc.cli == nil {
+		return out, ErrNotReplayable
+	}
+	err = tc.call("ContainerExecAttach", map[string]interface{}{"execID": execID}, nil, func() error {
+		out, err = tc.cli.ContainerExecAttach(ctx, execID, config)
+		return err
+	})
+	return
+}
+
+func (tc *trafficClient) ContainerExecCreate(ctx context.Context, container string,
+	config types.ExecConfig) (out types.IDResponse, err error) {
+
+	err = tc.call("ContainerExecCreate", map[string]interface{}{"container": container, "config": config},
+		&out, func() error {
+			out, err = tc.cli.ContainerExecCreate(ctx, container, config)
+			return err
+		})
+	return
+}
+
+func (tc *trafficClient) ContainerExecInspect(ctx context.Context,
+	execID string) (out types.ContainerExecInspect, err error) {
+
+	err = tc.call("ContainerExecInspect", map[string]interface{}{"execID": execID}, &out, func() error {
+		out, err = tc.cli.ContainerExecInspect(ctx, execID)
+		return err
+	})
+	return
+}
+
+func (tc *trafficClient) ContainerExecStart(ctx context.Context, execID string,
+	config types.ExecStartCheck) error {
+
+	return tc.call("ContainerExecStart", map[string]interface{}{"execID": execID}, nil, func() error {
+		return tc.cli.ContainerExecStart(ctx, execID, config)
+	})
+}
+
+func (tc *trafficClient) ContainerInspect(ctx context.Context,
+	containerID string) (out types.ContainerJSON, err error) {
+
+	err = tc.call("ContainerInspect", map[string]interface{}{"container": containerID}, &out, func() error {
+		out, err = tc.cli.ContainerInspect(ctx, containerID)
+		return err
+	})
+	return
+}
+
+func (tc *trafficClient) ContainerKill(ctx context.Context, containerID, signal string) error {
+	return tc.call("ContainerKill", map[string]interface{}{"container": containerID, "signal": signal},
+		nil, func() error {
+			return tc.cli.ContainerKill(ctx, containerID, signal)
+		})
+}
+
+func (tc *trafficClient) ContainerList(ctx context.Context,
+	options types.ContainerListOptions) (out []types.Container, err error) {
+
+	err = tc.call("ContainerList", map[string]interface{}{"all": options.All,
+		"labels": options.Filters.Get("label")}, &out, func() error {
+		out, err = tc.cli.ContainerList(ctx, options)
+		return err
+	})
+	return
+}
+
+func (tc *trafficClient) ContainerLogs(ctx context.Context, container string,
+	options types.ContainerLogsOptions) (out io.ReadCloser, err error) {
+
+	if tc.cli == nil {
+		return out, ErrNotReplayable
+	}
+	err = tc.call("ContainerLogs", map[string]interface{}{"container": container, "options": options},
+		nil, func() error {
+			out, err = tc.cli.ContainerLogs(ctx, container, options)
+			return err
+		})
+	return
+}
+
+func (tc *trafficClient) ContainerPause(ctx context.Context, containerID string) error {
+	return tc.call("ContainerPause", map[string]interface{}{"container": containerID}, nil, func() error {
+		return tc.cli.ContainerPause(ctx, containerID)
+	})
+}
+
+func (tc *trafficClient) ContainerRemove(ctx context.Context, containerID string,
+	options types.ContainerRemoveOptions) error {
+
+	return tc.call("ContainerRemove", map[string]interface{}{"container": containerID, "options": options},
+		nil, func() error {
+			return tc.cli.ContainerRemove(ctx, containerID, options)
+		})
+}
+
+func (tc *trafficClient) ContainerRestart(ctx context.Context, containerID string,
+	timeout *time.Duration) error {
+
+	return tc.call("ContainerRestart", map[string]interface{}{"container": containerID, "timeout": timeout},
+		nil, func() error {
+			return tc.cli.ContainerRestart(ctx, containerID, timeout)
+		})
+}
+
+func (tc *trafficClient) ContainerStart(ctx context.Context, containerID string,
+	options types.ContainerStartOptions) error {
+
+	return tc.call("ContainerStart", map[string]interface{}{"container": containerID, "options": options},
+		nil, func() error {
+			return tc.cli.ContainerStart(ctx, containerID, options)
+		})
+}
+
+func (tc *trafficClient) ContainerStats(ctx context.Context, container string,
+	stream bool) (out types.ContainerStats, err error) {
+
+	if tc.cli == nil {
+		return out, ErrNotReplayable
+	}
+	err = tc.call("ContainerStats", map[string]interface{}{"container": container, "stream": stream},
+		nil, func() error {
+			out, err = tc.cli.ContainerStats(ctx, container, stream)
+			return err
+		})
+	return
+}
+
+func (tc *trafficClient) ContainerStatPath(ctx context.Context, containerID,
+	path string) (out types.ContainerPathStat, err error) {
+
+	err = tc.call("ContainerStatPath", map[string]interface{}{"container": containerID, "path": path},
+		&out, func() error {
+			out, err = tc.cli.ContainerStatPath(ctx, containerID, path)
+			return err
+		})
+	return
+}
+
+func (tc *trafficClient) ContainerStop(ctx context.Context, containerID string,
+	timeout *time.Duration) error {
+
+	return tc.call("ContainerStop", map[string]interface{}{"container": containerID, "timeout": timeout},
+		nil, func() error {
+			return tc.cli.ContainerStop(ctx, containerID, timeout)
+		})
+}
+
+func (tc *trafficClient) ContainerUnpause(ctx context.Context, containerID string) error {
+	return tc.call("ContainerUnpause", map[string]interface{}{"container": containerID}, nil, func() error {
+		return tc.cli.ContainerUnpause(ctx, containerID)
+	})
+}
+
+// ContainerWait waits for the acknowledgement of the wait before returning, as the docker client
+// does, then handles the call once the wait is over
+func (tc *trafficClient) ContainerWait(ctx context.Context, containerID string,
+	condition container.WaitCondition) (<-chan container.ContainerWaitOKBody, <-chan error) {
+
+	var waitC <-chan container.ContainerWaitOKBody
+	var waitErrC <-chan error
+	if tc.cli != nil {
+		waitC, waitErrC = tc.cli.ContainerWait(ctx, containerID, condition)
+	}
+	resC := make(chan container.ContainerWaitOKBody, 1)
+	errC := make(chan error, 1)
+	go func() {
+		var out container.ContainerWaitOKBody
+		err := tc.call("ContainerWait", map[string]interface{}{"container": containerID,
+			"condition": condition}, &out, func() error {
+			select {
+			case out = <-waitC:
+				return nil
+			case err := <-waitErrC:
+				return err
+			}
+		})
+		if err != nil {
+			errC <- err
+			return
+		}
+		resC <- out
+	}()
+	return resC, errC
+}
+
+// declaredContent is content which declares where it comes from and its size up front, such as
+// the archive of a file placed in a container
+type declaredContent interface {
+	io.Reader
+	Source() string
+	Size() int64
+}
+
+// CopyToContainer streams the content to docker. The source and size of the content are recorded
+// as it declares them, if it does. Otherwise, it is counted as it goes so that its size can be
+// recorded without holding the content in memory. The size is only known once the copy is over, so
+// it is added to the arguments of the call after the fact.
+func (tc *trafficClient) CopyToContainer(ctx context.Context, containerID, dstPath string,
+	content io.Reader, options types.CopyToContainerOptions) error {
+
+	args := map[string]interface{}{"container": containerID, "path": dstPath, "options": options}
+	declared, isDeclared := content.(declaredContent)
+	if isDeclared {
+		args["source"], args["size"] = declared.Source(), declared.Size()
+	}
+	if tc.cli == nil {
+		if !isDeclared {
+			size, err := io.Copy(ioutil.Discard, content)
+			if err != nil {
+				return err
+			}
+			args["size"] = size
+		}
+		return tc.call("CopyToContainer", args, nil, nil)
+	}
+	return tc.call("CopyToContainer", args, nil, func() error {
+		if isDeclared {
+			return tc.cli.CopyToContainer(ctx, containerID, dstPath, content, options)
+		}
+		counter := &countingReader{rdr: content}
+		err := tc.cli.CopyToContainer(ctx, containerID, dstPath, counter, options)
+		args["size"] = counter.n
+		return err
+	})
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	rdr io.Reader
+	n   int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.rdr.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (tc *trafficClient) DaemonHost() string {
+	if tc.cli == nil {
+		return tc.host
+	}
+	return tc.cli.DaemonHost()
+}
+
+func (tc *trafficClient) HTTPClient() *http.Client {
+	if tc.cli == nil {
+		return &http.Client{}
+	}
+	return tc.cli.HTTPClient()
+}
+
+func (tc *trafficClient) ImageList(ctx context.Context,
+	options types.ImageListOptions) (out []types.ImageSummary, err error) {
+
+	err = tc.call("ImageList", map[string]interface{}{"all": options.All}, &out, func() error {
+		out, err = tc.cli.ImageList(ctx, options)
+		return err
+	})
+	return
+}
+
+// ImageLoad buffers the input, so that its size can be recorded, and the response body, so
+// that it can be recorded
+func (tc *trafficClient) ImageLoad(ctx context.Context, input io.Reader,
+	quiet bool) (types.ImageLoadResponse, error) {
+
+	data, err := ioutil.ReadAll(input)
+	if err != nil {
+		return types.ImageLoadResponse{}, err
+	}
+	var body string
+	var isJSON bool
+	err = tc.call("ImageLoad", map[string]interface{}{"size": len(data), "quiet": quiet},
+		&body, func() error {
+			res, err := tc.cli.ImageLoad(ctx, bytes.NewReader(data), quiet)
+			if err != nil {
+				return err
+			}
+			defer res.Body.Close()
+			isJSON = res.JSON
+			out, err := ioutil.ReadAll(res.Body)
+			body = string(out)
+			return err
+		})
+	return types.ImageLoadResponse{Body: ioutil.NopCloser(bytes.NewBufferString(body)), JSON: isJSON}, err
+}
+
+// ImagePull buffers the response body, so that it can be recorded
+func (tc *trafficClient) ImagePull(ctx context.Context, refStr string,
+	options types.ImagePullOptions) (io.ReadCloser, error) {
+
+	var body string
+	err := tc.call("ImagePull", map[string]interface{}{"image": refStr, "platform": options.Platform,
+		"all": options.All, "usingAuth": options.RegistryAuth != ""}, &body, func() error {
+		rdr, err := tc.cli.ImagePull(ctx, refStr, options)
+		if err != nil {
+			return err
+		}
+		defer rdr.Close()
+		out, err := ioutil.ReadAll(rdr)
+		body = string(out)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewBufferString(body)), nil
+}
+
+func (tc *trafficClient) NetworkCreate(ctx context.Context, name string,
+	options types.NetworkCreate) (out types.NetworkCreateResponse, err error) {
+
+	err = tc.call("NetworkCreate", map[string]interface{}{"name": name, "options": options}, &out,
+		func() error {
+			out, err = tc.cli.NetworkCreate(ctx, name, options)
+			return err
+		})
+	return
+}
+
+func (tc *trafficClient) NetworkConnect(ctx context.Context, networkID, containerID string,
+	config *network.EndpointSettings) error {
+
+	return tc.call("NetworkConnect", map[string]interface{}{"network": networkID,
+		"container": containerID, "config": config}, nil, func() error {
+		return tc.cli.NetworkConnect(ctx, networkID, containerID, config)
+	})
+}
+
+func (tc *trafficClient) NetworkDisconnect(ctx context.Context, networkID, containerID string,
+	force bool) error {
+
+	return tc.call("NetworkDisconnect", map[string]interface{}{"network": networkID,
+		"container": containerID, "force": force}, nil, func() error {
+		return tc.cli.NetworkDisconnect(ctx, networkID, containerID, force)
+	})
+}
+
+func (tc *trafficClient) NetworkInspect(ctx context.Context, networkID string,
+	options types.NetworkInspectOptions) (out types.NetworkResource, err error) {
+
+	err = tc.call("NetworkInspect", map[string]interface{}{"network": networkID, "options": options},
+		&out, func() error {
+			out, err = tc.cli.NetworkInspect(ctx, networkID, options)
+			return err
+		})
+	return
+}
+
+func (tc *trafficClient) NetworkRemove(ctx context.Context, networkID string) error {
+	return tc.call("NetworkRemove", map[string]interface{}{"network": networkID}, nil, func() error {
+		return tc.cli.NetworkRemove(ctx, networkID)
+	})
+}
+
+func (tc *trafficClient) NetworkList(ctx context.Context,
+	options types.NetworkListOptions) (out []types.NetworkResource, err error) {
+
+	err = tc.call("NetworkList", map[string]interface{}{"labels": options.Filters.Get("label")}, &out,
+		func() error {
+			out, err = tc.cli.NetworkList(ctx, options)
+			return err
+		})
+	return
+}
+
+func (tc *trafficClient) Ping(ctx context.Context) (out types.Ping, err error) {
+	err = tc.call("Ping", nil, &out, func() error {
+		out, err = tc.cli.Ping(ctx)
+		return err
+	})
+	return
+}
+
+func (tc *trafficClient) SwarmInit(ctx context.Context, req swarm.InitRequest) (out string, err error) {
+	err = tc.call("SwarmInit", map[string]interface{}{"request": req}, &out, func() error {
+		out, err = tc.cli.SwarmInit(ctx, req)
+		return err
+	})
+	return
+}
+
+func (tc *trafficClient) SwarmJoin(ctx context.Context, req swarm.JoinRequest) error {
+	return tc.call("SwarmJoin", map[string]interface{}{"request": req}, nil, func() error {
+		return tc.cli.SwarmJoin(ctx, req)
+	})
+}
+
+func (tc *trafficClient) SwarmInspect(ctx context.Context) (out swarm.Swarm, err error) {
+	err = tc.call("SwarmInspect", nil, &out, func() error {
+		out, err = tc.cli.SwarmInspect(ctx)
+		return err
+	})
+	return
+}
+
+func (tc *trafficClient) VolumeCreate(ctx context.Context,
+	options volume.VolumeCreateBody) (out types.Volume, err error) {
+
+	err = tc.call("VolumeCreate", map[string]interface{}{"options": options}, &out, func() error {
+		out, err = tc.cli.VolumeCreate(ctx, options)
+		return err
+	})
+	return
+}
+
+func (tc *trafficClient) VolumeList(ctx context.Context,
+	filter filters.Args) (out volume.VolumeListOKBody, err error) {
+
+	err = tc.call("VolumeList", map[string]interface{}{"labels": filter.Get("label")}, &out, func() error {
+		out, err = tc.cli.VolumeList(ctx, filter)
+		return err
+	})
+	return
+}
+
+func (tc *trafficClient) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	return tc.call("VolumeRemove", map[string]interface{}{"volume": volumeID, "force": force}, nil,
+		func() error {
+			return tc.cli.VolumeRemove(ctx, volumeID, force)
+		})
+}
@@ -0,0 +1,167 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
+	repoMock "github.com/whiteblock/genesis/mocks/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingClient(t *testing.T) {
+	cli := new(entityMock.Client)
+	cli.On("NetworkCreate", mock.Anything, "net0", mock.Anything).Return(
+		types.NetworkCreateResponse{ID: "id0"}, nil).Once()
+	cli.On("ContainerRemove", mock.Anything, "node0", mock.Anything).Return(
+		fmt.Errorf("Error: No such container: node0")).Once()
+
+	recorded := []entity.RecordedCall{}
+	recordings := new(repoMock.RecordingRepository)
+	recordings.On("Append", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		recorded = append(recorded, args.Get(0).(entity.RecordedCall))
+	}).Twice()
+
+	rec := NewRecordingClient(cli, "10.0.0.1", "test0", recordings, logrus.New())
+	res, err := rec.NetworkCreate(context.Background(), "net0", types.NetworkCreate{Driver: "bridge"})
+	require.NoError(t, err)
+	assert.Equal(t, "id0", res.ID)
+	err = rec.ContainerRemove(context.Background(), "node0", types.ContainerRemoveOptions{Force: true})
+	assert.EqualError(t, err, "Error: No such container: node0")
+
+	require.Len(t, recorded, 2)
+	assert.Equal(t, "test0", recorded[0].Test)
+	assert.Equal(t, "10.0.0.1", recorded[0].Host)
+	assert.Equal(t, "NetworkCreate", recorded[0].Method)
+	assert.Equal(t, "net0", recorded[0].Args["name"])
+	assert.JSONEq(t, `{"Id":"id0","Warning":""}`, string(recorded[0].Result))
+	assert.Empty(t, recorded[0].Error)
+
+	assert.Equal(t, "ContainerRemove", recorded[1].Method)
+	assert.Equal(t, "Error: No such container: node0", recorded[1].Error)
+	assert.Empty(t, recorded[1].Result)
+
+	cli.AssertExpectations(t)
+	recordings.AssertExpectations(t)
+}
+
+func TestRecordingClient_AppendFailure(t *testing.T) {
+	cli := new(entityMock.Client)
+	cli.On("NetworkRemove", mock.Anything, "net0").Return(nil).Once()
+	recordings := new(repoMock.RecordingRepository)
+	recordings.On("Append", mock.Anything).Return(fmt.Errorf("disk full")).Once()
+
+	rec := NewRecordingClient(cli, "10.0.0.1", "test0", recordings, logrus.New())
+	assert.NoError(t, rec.NetworkRemove(context.Background(), "net0"))
+	recordings.AssertExpectations(t)
+}
+
+func TestRecordingClient_CopyToContainer(t *testing.T) {
+	cli := new(entityMock.Client)
+	cli.On("CopyToContainer", mock.Anything, "node0", "/tmp", mock.Anything, mock.Anything).Return(nil).Run(
+		func(args mock.Arguments) {
+			data, err := ioutil.ReadAll(args.Get(3).(io.Reader))
+			require.NoError(t, err)
+			assert.Equal(t, "content", string(data))
+		}).Once()
+	var recorded entity.RecordedCall
+	recordings := new(repoMock.RecordingRepository)
+	recordings.On("Append", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		recorded = args.Get(0).(entity.RecordedCall)
+	}).Once()
+
+	rec := NewRecordingClient(cli, "10.0.0.1", "test0", recordings, logrus.New())
+	err := rec.CopyToContainer(context.Background(), "node0", "/tmp", strings.NewReader("content"),
+		types.CopyToContainerOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), recorded.Args["size"])
+
+	replayer, err := NewReplayer([]entity.RecordedCall{recorded})
+	require.NoError(t, err)
+	err = replayer.Client("10.0.0.1").CopyToContainer(context.Background(), "node0", "/tmp",
+		strings.NewReader("content"), types.CopyToContainerOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, replayer.Unused())
+
+	cli.AssertExpectations(t)
+	recordings.AssertExpectations(t)
+}
+
+func recordedCall(method string, args map[string]interface{}, result string, err string) entity.RecordedCall {
+	return entity.RecordedCall{
+		DockerCall: entity.DockerCall{Host: "10.0.0.1", Method: method, Args: args},
+		Test:       "test0",
+		Result:     json.RawMessage(result),
+		Error:      err,
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	replayer, err := NewReplayer([]entity.RecordedCall{
+		recordedCall("ContainerInspect", map[string]interface{}{"container": "node0"},
+			`{"Name":"/node0"}`, ""),
+		recordedCall("ContainerInspect", map[string]interface{}{"container": "node1"},
+			"", "Error: No such container: node1"),
+		recordedCall("ImagePull", map[string]interface{}{"image": "alpine", "platform": "", "all": false,
+			"usingAuth": false}, `"pulled"`, ""),
+	})
+	require.NoError(t, err)
+	cli := replayer.Client("10.0.0.1")
+	ctx := context.Background()
+
+	_, err = cli.ContainerInspect(ctx, "node1")
+	assert.EqualError(t, err, "Error: No such container: node1")
+
+	// falls back to the first call with the same method
+	res, err := cli.ContainerInspect(ctx, "node2")
+	require.NoError(t, err)
+	assert.Equal(t, "/node0", res.Name)
+
+	_, err = cli.ContainerInspect(ctx, "node0")
+	assert.True(t, errors.Is(err, ErrNotRecorded))
+	_, err = replayer.Client("10.0.0.2").ImagePull(ctx, "alpine", types.ImagePullOptions{})
+	assert.True(t, errors.Is(err, ErrNotRecorded))
+
+	require.Len(t, replayer.Unused(), 1)
+	rdr, err := cli.ImagePull(ctx, "alpine", types.ImagePullOptions{})
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(rdr)
+	require.NoError(t, err)
+	assert.Equal(t, "pulled", string(body))
+	assert.Empty(t, replayer.Unused())
+
+	_, err = cli.ContainerAttach(ctx, "node0", types.ContainerAttachOptions{})
+	assert.Equal(t, ErrNotReplayable, err)
+}
+
+func TestDockerService_CreateClient_Recording(t *testing.T) {
+	recordings := new(repoMock.RecordingRepository)
+	ds := NewRecordingDockerService(nil, config.Docker{LocalMode: true}, nil, recordings, logrus.New())
+
+	cli, err := ds.CreateClient("127.0.0.1", "test0")
+	require.NoError(t, err)
+	assert.IsType(t, &trafficClient{}, cli)
+
+	cli, err = ds.CreateClient("127.0.0.1", "")
+	require.NoError(t, err)
+	assert.NotEqual(t, fmt.Sprintf("%T", &trafficClient{}), fmt.Sprintf("%T", cli))
+}
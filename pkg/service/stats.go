@@ -0,0 +1,81 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+)
+
+// cpuPercent gets the cpu usage between the two samples in the stats, the same way the docker
+// cli does
+func cpuPercent(stats types.StatsJSON) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	cpus := float64(stats.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// memoryUsage gets the memory used, without the page cache, the same way the docker cli does
+func memoryUsage(stats types.MemoryStats) uint64 {
+	cache, exists := stats.Stats["total_inactive_file"] // cgroup v1
+	if !exists {
+		cache = stats.Stats["inactive_file"] // cgroup v2
+	}
+	if cache > stats.Usage {
+		return 0
+	}
+	return stats.Usage - cache
+}
+
+// SampleStats takes a sample of the resource usage of a container
+func (ds dockerService) SampleStats(ctx context.Context, cli entity.DockerCli,
+	name string) (entity.StatsSample, error) {
+
+	res, err := cli.ContainerStats(ctx, name, false)
+	if err != nil {
+		return entity.StatsSample{}, err
+	}
+	defer res.Body.Close()
+	var stats types.StatsJSON
+	err = json.NewDecoder(res.Body).Decode(&stats)
+	if err != nil {
+		return entity.StatsSample{}, err
+	}
+
+	out := entity.StatsSample{
+		Container:   name,
+		Time:        stats.Read,
+		CPU:         cpuPercent(stats),
+		Memory:      memoryUsage(stats.MemoryStats),
+		MemoryLimit: stats.MemoryStats.Limit,
+	}
+	for _, net := range stats.Networks {
+		out.NetRx += net.RxBytes
+		out.NetTx += net.TxBytes
+	}
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			out.BlockRead += entry.Value
+		case "write":
+			out.BlockWrite += entry.Value
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,63 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerService_SampleStats(t *testing.T) {
+	read := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats := types.StatsJSON{Stats: types.Stats{
+		Read: read,
+		CPUStats: types.CPUStats{CPUUsage: types.CPUUsage{TotalUsage: 3000}, SystemUsage: 20000,
+			OnlineCPUs: 4},
+		PreCPUStats: types.CPUStats{CPUUsage: types.CPUUsage{TotalUsage: 1000}, SystemUsage: 10000},
+		MemoryStats: types.MemoryStats{Usage: 500, Limit: 1000,
+			Stats: map[string]uint64{"total_inactive_file": 100}},
+		BlkioStats: types.BlkioStats{IoServiceBytesRecursive: []types.BlkioStatEntry{
+			{Op: "Read", Value: 10}, {Op: "Write", Value: 20}, {Op: "read", Value: 1}, {Op: "Total", Value: 31}}},
+	}, Networks: map[string]types.NetworkStats{
+		"eth0": {RxBytes: 1, TxBytes: 2},
+		"eth1": {RxBytes: 10, TxBytes: 20},
+	}}
+	data, err := json.Marshal(stats)
+	require.NoError(t, err)
+
+	cli := new(entityMock.Client)
+	cli.On("ContainerStats", mock.Anything, "node0", false).Return(
+		types.ContainerStats{Body: ioutil.NopCloser(bytes.NewReader(data))}, nil).Once()
+
+	ds := NewDockerService(nil, config.Docker{}, nil, logrus.New())
+	sample, err := ds.SampleStats(context.Background(), entity.DockerCli{Client: cli}, "node0")
+	require.NoError(t, err)
+	assert.Equal(t, entity.StatsSample{Container: "node0", Time: read, CPU: 80, Memory: 400,
+		MemoryLimit: 1000, NetRx: 11, NetTx: 22, BlockRead: 11, BlockWrite: 20}, sample)
+	cli.AssertExpectations(t)
+}
+
+func TestMemoryUsage(t *testing.T) {
+	assert.Equal(t, uint64(400), memoryUsage(types.MemoryStats{Usage: 500,
+		Stats: map[string]uint64{"inactive_file": 100}}))
+	assert.Equal(t, uint64(0), memoryUsage(types.MemoryStats{Usage: 50,
+		Stats: map[string]uint64{"inactive_file": 100}}))
+}
@@ -0,0 +1,106 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/file"
+
+	"github.com/docker/docker/api/types"
+	"github.com/whiteblock/definition/command"
+)
+
+// templateContext gets the context which files placed in the given container are rendered with.
+// The peers of the container are the containers on the networks of its test, which are the
+// networks labeled with the ID of the test.
+func (ds dockerService) templateContext(ctx context.Context, cli entity.DockerCli,
+	name string) (entity.TemplateContext, error) {
+
+	out := entity.TemplateContext{
+		Labels:    cli.Labels,
+		Container: entity.TemplateContainer{Name: name, IPs: map[string]string{}},
+		Peers:     []entity.TemplateContainer{},
+	}
+	cntr, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return out, err
+	}
+	if cntr.NetworkSettings == nil {
+		return out, nil
+	}
+	networks := []string{}
+	for network := range cntr.NetworkSettings.Networks {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+
+	testID := cli.Labels[command.TestIDKey]
+	peers := map[string]*entity.TemplateContainer{}
+	for _, network := range networks {
+		if endpoint := cntr.NetworkSettings.Networks[network]; endpoint != nil &&
+			len(endpoint.IPAddress) > 0 {
+			addContainerIP(&out.Container, network, endpoint.IPAddress)
+		}
+		if len(testID) == 0 {
+			continue
+		}
+		res, err := cli.NetworkInspect(ctx, network, types.NetworkInspectOptions{})
+		if err != nil {
+			return out, err
+		}
+		if res.Labels[command.TestIDKey] != testID {
+			continue
+		}
+		for _, endpoint := range res.Containers {
+			if endpoint.Name == name || len(endpoint.IPv4Address) == 0 {
+				continue
+			}
+			peer, ok := peers[endpoint.Name]
+			if !ok {
+				peer = &entity.TemplateContainer{Name: endpoint.Name, IPs: map[string]string{}}
+				peers[endpoint.Name] = peer
+			}
+			// the address is in CIDR notation
+			addContainerIP(peer, network, strings.Split(endpoint.IPv4Address, "/")[0])
+		}
+	}
+	for _, peer := range peers {
+		out.Peers = append(out.Peers, *peer)
+	}
+	sort.Slice(out.Peers, func(i, j int) bool {
+		return out.Peers[i].Name < out.Peers[j].Name
+	})
+	return out, nil
+}
+
+// addContainerIP adds the address of the container on the network, which must be added in the
+// order of the names of the networks
+func addContainerIP(cntr *entity.TemplateContainer, network string, ip string) {
+	if len(cntr.IP) == 0 {
+		cntr.IP = ip
+	}
+	cntr.IPs[network] = ip
+}
+
+// getTarReader gets the archive of the file to place in the container, rendering it first if it
+// is a template
+func (ds dockerService) getTarReader(ctx context.Context, cli entity.DockerCli, containerName string,
+	src entity.File) (file.TarReader, error) {
+
+	if !src.Template {
+		return ds.remote.GetTarReader(cli.Labels[command.DefinitionIDKey], src)
+	}
+	data, err := ds.templateContext(ctx, cli, containerName)
+	if err != nil {
+		return nil, err
+	}
+	return ds.remote.GetTemplateTarReader(cli.Labels[command.DefinitionIDKey], src, data)
+}
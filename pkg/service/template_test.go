@@ -0,0 +1,73 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package service
+
+import (
+	"context"
+	"testing"
+
+	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/whiteblock/definition/command"
+)
+
+func TestDockerService_templateContext(t *testing.T) {
+	cli := new(entityMock.Client)
+	cli.On("ContainerInspect", mock.Anything, "node0").Return(types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{Name: "/node0"},
+		NetworkSettings: &types.NetworkSettings{Networks: map[string]*network.EndpointSettings{
+			"net1":   {IPAddress: "10.2.0.2"},
+			"net0":   {IPAddress: "10.1.0.2"},
+			"bridge": {IPAddress: "172.17.0.2"},
+		}},
+	}, nil).Once()
+	endpoint := func(name string, ip string) types.EndpointResource {
+		return types.EndpointResource{Name: name, IPv4Address: ip}
+	}
+	cli.On("NetworkInspect", mock.Anything, "net0", mock.Anything).Return(types.NetworkResource{
+		Labels: map[string]string{command.TestIDKey: "test0"},
+		Containers: map[string]types.EndpointResource{
+			"a": endpoint("node0", "10.1.0.2/16"),
+			"b": endpoint("node2", "10.1.0.4/16"),
+			"c": endpoint("node1", "10.1.0.3/16"),
+		},
+	}, nil).Once()
+	cli.On("NetworkInspect", mock.Anything, "net1", mock.Anything).Return(types.NetworkResource{
+		Labels: map[string]string{command.TestIDKey: "test0"},
+		Containers: map[string]types.EndpointResource{
+			"a": endpoint("node0", "10.2.0.2/16"),
+			"c": endpoint("node1", "10.2.0.3/16"),
+		},
+	}, nil).Once()
+	cli.On("NetworkInspect", mock.Anything, "bridge", mock.Anything).Return(types.NetworkResource{
+		Containers: map[string]types.EndpointResource{"d": endpoint("other", "172.17.0.3/16")},
+	}, nil).Once()
+
+	ds := dockerService{conf: config.Docker{}, log: logrus.New()}
+	labels := map[string]string{command.TestIDKey: "test0"}
+	out, err := ds.templateContext(context.Background(), entity.DockerCli{Client: cli, Labels: labels},
+		"node0")
+	require.NoError(t, err)
+	assert.Equal(t, entity.TemplateContext{
+		Labels: labels,
+		Container: entity.TemplateContainer{Name: "node0", IP: "172.17.0.2", IPs: map[string]string{
+			"bridge": "172.17.0.2", "net0": "10.1.0.2", "net1": "10.2.0.2"}},
+		Peers: []entity.TemplateContainer{
+			{Name: "node1", IP: "10.1.0.3", IPs: map[string]string{"net0": "10.1.0.3", "net1": "10.2.0.3"}},
+			{Name: "node2", IP: "10.1.0.4", IPs: map[string]string{"net0": "10.1.0.4"}},
+		},
+	}, out)
+	cli.AssertExpectations(t)
+}
@@ -0,0 +1,995 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package simulator
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ErrNotSupported is returned by the calls which the simulated docker daemon does not support
+var ErrNotSupported = errors.New("not supported by the simulated docker daemon")
+
+// client is an entity.Client for one of the simulated daemons
+type client struct {
+	host   string
+	docker *Docker
+}
+
+// call locks the daemons for the duration of a call to the given method, returning the error
+// queued for it by FailNext, if any. The returned function must be called to unlock them.
+func (cli *client) call(method string) (*daemon, func(), error) {
+	cli.docker.mux.Lock()
+	return cli.docker.daemon(cli.host), cli.docker.mux.Unlock, cli.docker.failure(method)
+}
+
+func daemonError(format string, args ...interface{}) error {
+	return fmt.Errorf("Error response from daemon: "+format, args...)
+}
+
+// matches checks the given labels and name against the label and name filters
+func matches(args filters.Args, name string, labels map[string]string) bool {
+	for _, filter := range args.Get("label") {
+		kv := strings.SplitN(filter, "=", 2)
+		val, exists := labels[kv[0]]
+		if !exists || (len(kv) == 2 && val != kv[1]) {
+			return false
+		}
+	}
+	for _, filter := range args.Get("name") {
+		if !strings.Contains(name, filter) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cli *client) Close() error {
+	return nil
+}
+
+// ContainerAttach gets the stream of a container. Once the container is started, the output
+// given by the exec handler is written to it, and the container exits.
+func (cli *client) ContainerAttach(ctx context.Context, container string,
+	options types.ContainerAttachOptions) (types.HijackedResponse, error) {
+
+	dmn, unlock, err := cli.call("ContainerAttach")
+	defer unlock()
+	if err != nil {
+		return types.HijackedResponse{}, err
+	}
+	cntr, err := dmn.container(container)
+	if err != nil {
+		return types.HijackedResponse{}, err
+	}
+	conn, attached := net.Pipe()
+	cntr.attached = attached
+	return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(conn)}, nil
+}
+
+func (cli *client) ContainerCreate(ctx context.Context, config *container.Config,
+	hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig,
+	containerName string) (container.ContainerCreateCreatedBody, error) {
+
+	dmn, unlock, err := cli.call("ContainerCreate")
+	defer unlock()
+	if err != nil {
+		return container.ContainerCreateCreatedBody{}, err
+	}
+	if existing, err := dmn.container(containerName); err == nil && len(containerName) > 0 {
+		return container.ContainerCreateCreatedBody{}, errdefs.Conflict(daemonError(
+			`Conflict. The container name "/%s" is already in use by container "%s". You have to `+
+				`remove (or rename) that container to be able to reuse that name.`, containerName, existing.ID))
+	}
+	if !dmn.hasImage(config.Image) {
+		return container.ContainerCreateCreatedBody{}, noSuch("image", config.Image)
+	}
+	if hostConfig == nil {
+		hostConfig = &container.HostConfig{}
+	}
+	if hostConfig.NetworkMode.IsContainer() {
+		_, err := dmn.container(hostConfig.NetworkMode.ConnectedContainer())
+		if err != nil {
+			return container.ContainerCreateCreatedBody{}, err
+		}
+	}
+	for _, mnt := range hostConfig.Mounts {
+		if mnt.Type != "volume" || len(mnt.Source) == 0 {
+			continue
+		}
+		if _, exists := dmn.volumes[mnt.Source]; !exists {
+			dmn.volumes[mnt.Source] = &types.Volume{Name: mnt.Source, Driver: "local",
+				Labels: map[string]string{}, Scope: "local"}
+		}
+	}
+
+	id := newID()
+	if len(containerName) == 0 {
+		containerName = id[:12]
+	}
+	cntr := &fakeContainer{
+		ContainerJSON: types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:         id,
+				Created:    time.Now().Format(time.RFC3339Nano),
+				Name:       "/" + containerName,
+				Image:      config.Image,
+				State:      &types.ContainerState{Status: "created"},
+				HostConfig: hostConfig,
+			},
+			Config: config,
+			NetworkSettings: &types.NetworkSettings{
+				Networks: map[string]*network.EndpointSettings{},
+			},
+		},
+		config: config,
+		files:  map[string][]byte{},
+	}
+	if networkingConfig != nil {
+		for name, endpoint := range networkingConfig.EndpointsConfig {
+			err = dmn.connect(name, cntr, endpoint)
+			if err != nil {
+				dmn.removeContainer(cntr)
+				return container.ContainerCreateCreatedBody{}, err
+			}
+		}
+	}
+	dmn.containers[id] = cntr
+	return container.ContainerCreateCreatedBody{ID: id}, nil
+}
+
+// ContainerExecAttach runs the exec to completion, with the outcome given by the exec handler,
+// and returns a stream of its output
+func (cli *client) ContainerExecAttach(ctx context.Context, execID string,
+	config types.ExecStartCheck) (types.HijackedResponse, error) {
+
+	dmn, unlock, err := cli.call("ContainerExecAttach")
+	defer unlock()
+	if err != nil {
+		return types.HijackedResponse{}, err
+	}
+	exec, exists := dmn.execs[execID]
+	if !exists {
+		return types.HijackedResponse{}, noSuch("exec instance", execID)
+	}
+	out := cli.docker.handler(cli.host, exec.Container, exec.Cmd)
+	exec.ExitCode = out.ExitCode
+	conn, attached := net.Pipe()
+	go writeOutput(attached, out)
+	return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(conn)}, nil
+}
+
+func (cli *client) ContainerExecCreate(ctx context.Context, container string,
+	config types.ExecConfig) (types.IDResponse, error) {
+
+	dmn, unlock, err := cli.call("ContainerExecCreate")
+	defer unlock()
+	if err != nil {
+		return types.IDResponse{}, err
+	}
+	cntr, err := dmn.container(container)
+	if err != nil {
+		return types.IDResponse{}, err
+	}
+	if !cntr.State.Running {
+		return types.IDResponse{}, errdefs.Conflict(daemonError("Container %s is not running", cntr.ID))
+	}
+	exec := &Exec{ID: newID(), Container: strings.TrimPrefix(cntr.Name, "/"), Cmd: config.Cmd, ExitCode: -1}
+	dmn.execs[exec.ID] = exec
+	dmn.execOrder = append(dmn.execOrder, exec.ID)
+	return types.IDResponse{ID: exec.ID}, nil
+}
+
+func (cli *client) ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error) {
+	dmn, unlock, err := cli.call("ContainerExecInspect")
+	defer unlock()
+	if err != nil {
+		return types.ContainerExecInspect{}, err
+	}
+	exec, exists := dmn.execs[execID]
+	if !exists {
+		return types.ContainerExecInspect{}, noSuch("exec instance", execID)
+	}
+	return types.ContainerExecInspect{ExecID: exec.ID, ContainerID: exec.Container,
+		Running: false, ExitCode: exec.ExitCode}, nil
+}
+
+// ContainerExecStart runs the exec to completion, with the exit code given by the exec handler
+func (cli *client) ContainerExecStart(ctx context.Context, execID string, config types.ExecStartCheck) error {
+	dmn, unlock, err := cli.call("ContainerExecStart")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	exec, exists := dmn.execs[execID]
+	if !exists {
+		return noSuch("exec instance", execID)
+	}
+	exec.ExitCode = cli.docker.handler(cli.host, exec.Container, exec.Cmd).ExitCode
+	return nil
+}
+
+func (cli *client) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	dmn, unlock, err := cli.call("ContainerInspect")
+	defer unlock()
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+	return cntr.inspect(), nil
+}
+
+// ContainerKill sends a signal to a running container. SIGKILL, SIGTERM and SIGINT make it exit,
+// with the exit code a shell would give, any other signal is ignored.
+func (cli *client) ContainerKill(ctx context.Context, containerID, signal string) error {
+	dmn, unlock, err := cli.call("ContainerKill")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return err
+	}
+	if !cntr.State.Running {
+		return errdefs.Conflict(daemonError("Container %s is not running", cntr.ID))
+	}
+	switch strings.TrimPrefix(strings.ToUpper(signal), "SIG") {
+	case "", "KILL", "9":
+		dmn.exit(cntr, 137)
+	case "TERM", "15":
+		dmn.exit(cntr, 143)
+	case "INT", "2":
+		dmn.exit(cntr, 130)
+	}
+	return nil
+}
+
+func (cli *client) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	dmn, unlock, err := cli.call("ContainerList")
+	defer unlock()
+	if err != nil {
+		return nil, err
+	}
+	out := []types.Container{}
+	for _, cntr := range dmn.containers {
+		if !options.All && !cntr.State.Running {
+			continue
+		}
+		if !matches(options.Filters, cntr.Name, cntr.config.Labels) {
+			continue
+		}
+		settings := &types.SummaryNetworkSettings{Networks: map[string]*network.EndpointSettings{}}
+		for name, endpoint := range cntr.NetworkSettings.Networks {
+			copied := *endpoint
+			settings.Networks[name] = &copied
+		}
+		created, _ := time.Parse(time.RFC3339Nano, cntr.Created)
+		out = append(out, types.Container{
+			ID:              cntr.ID,
+			Names:           []string{cntr.Name},
+			Created:         created.Unix(),
+			Image:           cntr.Image,
+			Labels:          cntr.config.Labels,
+			State:           cntr.State.Status,
+			NetworkSettings: settings,
+		})
+	}
+	return out, nil
+}
+
+// ContainerLogs gets the lines logged by a container since the given time, which must be in
+// RFC 3339 format if it is given. If the logs are followed, the stream gets the lines the
+// container logs until it stops.
+func (cli *client) ContainerLogs(ctx context.Context, container string,
+	options types.ContainerLogsOptions) (io.ReadCloser, error) {
+
+	dmn, unlock, err := cli.call("ContainerLogs")
+	defer unlock()
+	if err != nil {
+		return nil, err
+	}
+	cntr, err := dmn.container(container)
+	if err != nil {
+		return nil, err
+	}
+	var since time.Time
+	if len(options.Since) > 0 {
+		since, err = time.Parse(time.RFC3339Nano, options.Since)
+		if err != nil {
+			return nil, daemonError("invalid value for \"since\": %v", err)
+		}
+	}
+	entries := []logEntry{}
+	for _, entry := range cntr.logs {
+		if !entry.time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	if len(options.Tail) > 0 && options.Tail != "all" {
+		tail, err := strconv.Atoi(options.Tail)
+		if err != nil {
+			return nil, daemonError("invalid value for \"tail\": %v", err)
+		}
+		if tail >= 0 && tail < len(entries) {
+			entries = entries[len(entries)-tail:]
+		}
+	}
+	stream := newLogStream()
+	for _, entry := range entries {
+		stream.Write(cntr.formatLog(entry, options))
+	}
+	if !options.Follow || !cntr.State.Running {
+		stream.Close()
+		return stream, nil
+	}
+	cntr.followers = append(cntr.followers, logFollower{stream: stream, options: options})
+	go func() {
+		select {
+		case <-ctx.Done():
+			stream.Close()
+		case <-stream.done:
+		}
+	}()
+	return stream, nil
+}
+
+func (cli *client) ContainerPause(ctx context.Context, containerID string) error {
+	dmn, unlock, err := cli.call("ContainerPause")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return err
+	}
+	if !cntr.State.Running {
+		return errdefs.Conflict(daemonError("Container %s is not running", cntr.ID))
+	}
+	if cntr.State.Paused {
+		return errdefs.Conflict(daemonError("Container %s is already paused", cntr.ID))
+	}
+	cntr.State.Paused = true
+	cntr.State.Status = "paused"
+	return nil
+}
+
+func (cli *client) ContainerRemove(ctx context.Context, containerID string,
+	options types.ContainerRemoveOptions) error {
+
+	dmn, unlock, err := cli.call("ContainerRemove")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return err
+	}
+	if cntr.State.Running && !options.Force {
+		return errdefs.Conflict(daemonError("You cannot remove a running container %s. Stop the "+
+			"container before attempting removal or force remove", cntr.ID))
+	}
+	dmn.removeContainer(cntr)
+	return nil
+}
+
+// ContainerRestart stops the container if it is running, then starts it
+func (cli *client) ContainerRestart(ctx context.Context, containerID string, timeout *time.Duration) error {
+	dmn, unlock, err := cli.call("ContainerRestart")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return err
+	}
+	cntr.start()
+	return nil
+}
+
+func (cli *client) ContainerStart(ctx context.Context, containerID string,
+	options types.ContainerStartOptions) error {
+
+	dmn, unlock, err := cli.call("ContainerStart")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return err
+	}
+	if cntr.State.Running {
+		return nil // the daemon responds with 304 Not Modified, which is not an error
+	}
+	cntr.start()
+	if cntr.attached != nil {
+		cmd := append(append([]string{}, cntr.config.Entrypoint...), cntr.config.Cmd...)
+		go cli.run(cntr, cntr.attached, cli.docker.handler(cli.host, strings.TrimPrefix(cntr.Name, "/"), cmd))
+		cntr.attached = nil
+	}
+	return nil
+}
+
+// writeOutput writes the output to a stream the way the docker daemon does, then closes it
+func writeOutput(attached net.Conn, out Output) {
+	if len(out.Stdout) > 0 {
+		stdcopy.NewStdWriter(attached, stdcopy.Stdout).Write([]byte(out.Stdout))
+	}
+	if len(out.Stderr) > 0 {
+		stdcopy.NewStdWriter(attached, stdcopy.Stderr).Write([]byte(out.Stderr))
+	}
+	attached.Close()
+}
+
+// run writes the output of an attached container to its stream, then makes it exit
+func (cli *client) run(cntr *fakeContainer, attached net.Conn, out Output) {
+	writeOutput(attached, out)
+
+	cli.docker.mux.Lock()
+	defer cli.docker.mux.Unlock()
+	dmn := cli.docker.daemon(cli.host)
+	if dmn.containers[cntr.ID] == cntr {
+		dmn.exit(cntr, out.ExitCode)
+	}
+}
+
+// ContainerStats gets the stats set for the container with SetStats, or empty stats if it is not
+// running. Streaming the stats is not supported.
+func (cli *client) ContainerStats(ctx context.Context, container string,
+	stream bool) (types.ContainerStats, error) {
+
+	dmn, unlock, err := cli.call("ContainerStats")
+	defer unlock()
+	if err != nil {
+		return types.ContainerStats{}, err
+	}
+	if stream {
+		return types.ContainerStats{}, ErrNotSupported
+	}
+	cntr, err := dmn.container(container)
+	if err != nil {
+		return types.ContainerStats{}, err
+	}
+	stats := types.StatsJSON{}
+	if cntr.State.Running {
+		stats = cntr.stats
+		stats.Read = time.Now().UTC()
+	}
+	stats.Name = cntr.Name
+	stats.ID = cntr.ID
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return types.ContainerStats{}, err
+	}
+	return types.ContainerStats{Body: ioutil.NopCloser(bytes.NewReader(data)), OSType: "linux"}, nil
+}
+
+func (cli *client) ContainerStatPath(ctx context.Context, containerID,
+	filePath string) (types.ContainerPathStat, error) {
+
+	dmn, unlock, err := cli.call("ContainerStatPath")
+	defer unlock()
+	if err != nil {
+		return types.ContainerPathStat{}, err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return types.ContainerPathStat{}, err
+	}
+	filePath = path.Clean("/" + filePath)
+	if data, exists := cntr.files[filePath]; exists {
+		return types.ContainerPathStat{Name: path.Base(filePath), Size: int64(len(data)), Mode: 0644}, nil
+	}
+	for name := range cntr.files {
+		if filePath == "/" || strings.HasPrefix(name, filePath+"/") {
+			return types.ContainerPathStat{Name: path.Base(filePath), Mode: os.ModeDir | 0755}, nil
+		}
+	}
+	return types.ContainerPathStat{}, errdefs.NotFound(daemonError(
+		"Could not find the file %s in container %s", filePath, containerID))
+}
+
+// ContainerStop makes a running container exit with 0. Stopping a container which is not running
+// does nothing.
+func (cli *client) ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error {
+	dmn, unlock, err := cli.call("ContainerStop")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return err
+	}
+	if cntr.State.Running {
+		dmn.exit(cntr, 0)
+	}
+	return nil
+}
+
+// ContainerWait waits for the container to exit, or to be removed if the condition is "removed"
+func (cli *client) ContainerWait(ctx context.Context, containerID string,
+	condition container.WaitCondition) (<-chan container.ContainerWaitOKBody, <-chan error) {
+
+	resC := make(chan container.ContainerWaitOKBody, 1)
+	errC := make(chan error, 1)
+	dmn, unlock, err := cli.call("ContainerWait")
+	defer unlock()
+	if err != nil {
+		errC <- err
+		return resC, errC
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		errC <- err
+		return resC, errC
+	}
+	done := make(chan int64, 1)
+	if (condition == "" || condition == container.WaitConditionNotRunning) && !cntr.State.Running {
+		done <- int64(cntr.State.ExitCode)
+	} else {
+		cntr.waiters = append(cntr.waiters, containerWaiter{condition: condition, done: done})
+	}
+	go func() {
+		select {
+		case code := <-done:
+			resC <- container.ContainerWaitOKBody{StatusCode: code}
+		case <-ctx.Done():
+			errC <- ctx.Err()
+		}
+	}()
+	return resC, errC
+}
+
+func (cli *client) ContainerUnpause(ctx context.Context, containerID string) error {
+	dmn, unlock, err := cli.call("ContainerUnpause")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return err
+	}
+	if !cntr.State.Paused {
+		return errdefs.Conflict(daemonError("Container %s is not paused", cntr.ID))
+	}
+	cntr.State.Paused = false
+	cntr.State.Status = "running"
+	return nil
+}
+
+// CopyToContainer extracts the regular files of the given tar archive into the container
+func (cli *client) CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader,
+	options types.CopyToContainerOptions) error {
+
+	files := map[string][]byte{}
+	rdr := tar.NewReader(content)
+	for {
+		hdr, err := rdr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := ioutil.ReadAll(rdr)
+		if err != nil {
+			return err
+		}
+		files[path.Clean(path.Join("/", dstPath, hdr.Name))] = data
+	}
+
+	dmn, unlock, err := cli.call("CopyToContainer")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return err
+	}
+	for name, data := range files {
+		cntr.files[name] = data
+	}
+	return nil
+}
+
+func (cli *client) DaemonHost() string {
+	return "tcp://" + cli.host
+}
+
+func (cli *client) HTTPClient() *http.Client {
+	return &http.Client{}
+}
+
+func (cli *client) ImageList(ctx context.Context, options types.ImageListOptions) ([]types.ImageSummary, error) {
+	dmn, unlock, err := cli.call("ImageList")
+	defer unlock()
+	if err != nil {
+		return nil, err
+	}
+	out := []types.ImageSummary{}
+	for _, image := range dmn.images {
+		out = append(out, image)
+	}
+	return out, nil
+}
+
+func (cli *client) ImageLoad(ctx context.Context, input io.Reader, quiet bool) (types.ImageLoadResponse, error) {
+	return types.ImageLoadResponse{}, ErrNotSupported
+}
+
+func (cli *client) ImagePull(ctx context.Context, refStr string,
+	options types.ImagePullOptions) (io.ReadCloser, error) {
+
+	dmn, unlock, err := cli.call("ImagePull")
+	defer unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(path.Base(refStr), ":") && !strings.Contains(refStr, "@") {
+		refStr += ":latest"
+	}
+	dmn.addImage(refStr)
+	return ioutil.NopCloser(strings.NewReader(
+		fmt.Sprintf(`{"status":"Status: Downloaded newer image for %s"}`, refStr))), nil
+}
+
+func overlaps(a *net.IPNet, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func (cli *client) NetworkCreate(ctx context.Context, name string,
+	options types.NetworkCreate) (types.NetworkCreateResponse, error) {
+
+	dmn, unlock, err := cli.call("NetworkCreate")
+	defer unlock()
+	if err != nil {
+		return types.NetworkCreateResponse{}, err
+	}
+	if _, err := dmn.network(name); err == nil && options.CheckDuplicate {
+		return types.NetworkCreateResponse{}, errdefs.Conflict(daemonError(
+			"network with name %s already exists", name))
+	}
+	res := &types.NetworkResource{
+		Name:       name,
+		ID:         newID(),
+		Created:    time.Now(),
+		Scope:      options.Scope,
+		Driver:     options.Driver,
+		EnableIPv6: options.EnableIPv6,
+		Internal:   options.Internal,
+		Attachable: options.Attachable,
+		Ingress:    options.Ingress,
+		Containers: map[string]types.EndpointResource{},
+		Options:    options.Options,
+		Labels:     options.Labels,
+	}
+	if options.IPAM != nil {
+		res.IPAM = *options.IPAM
+		res.IPAM.Config = append([]network.IPAMConfig(nil), options.IPAM.Config...)
+	}
+	for i, conf := range res.IPAM.Config {
+		if len(conf.Subnet) == 0 {
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(conf.Subnet)
+		if err != nil {
+			return types.NetworkCreateResponse{}, errdefs.InvalidParameter(daemonError(
+				"invalid subnet %s: %v", conf.Subnet, err))
+		}
+		for _, other := range dmn.networks {
+			for _, otherConf := range other.IPAM.Config {
+				_, otherSubnet, err := net.ParseCIDR(otherConf.Subnet)
+				if err == nil && overlaps(subnet, otherSubnet) {
+					return types.NetworkCreateResponse{}, errdefs.Forbidden(daemonError(
+						"Pool overlaps with other one on this address space"))
+				}
+			}
+		}
+		if len(conf.Gateway) == 0 && subnet.IP.To4() != nil {
+			res.IPAM.Config[i].Gateway = intToIP(ipToInt(subnet.IP) + 1).String()
+		}
+	}
+	dmn.networks[res.ID] = res
+	return types.NetworkCreateResponse{ID: res.ID}, nil
+}
+
+// connect connects the container to the network, assigning it an address if none is given
+func (dmn *daemon) connect(networkID string, cntr *fakeContainer, config *network.EndpointSettings) error {
+	res, err := dmn.network(networkID)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimPrefix(cntr.Name, "/")
+	if _, exists := res.Containers[cntr.ID]; exists {
+		return errdefs.Forbidden(daemonError("endpoint with name %s already exists in network %s",
+			name, res.Name))
+	}
+	endpoint := network.EndpointSettings{}
+	if config != nil {
+		endpoint = *config
+	}
+	ip := endpoint.IPAddress
+	if endpoint.IPAMConfig != nil && len(endpoint.IPAMConfig.IPv4Address) > 0 {
+		ip = endpoint.IPAMConfig.IPv4Address
+	}
+	if len(ip) > 0 {
+		for _, other := range res.Containers {
+			if strings.Split(other.IPv4Address, "/")[0] == ip {
+				return errdefs.Forbidden(daemonError("Address already in use"))
+			}
+		}
+	} else {
+		ip, err = allocateIP(res)
+		if err != nil {
+			return err
+		}
+	}
+	endpoint.NetworkID = res.ID
+	endpoint.EndpointID = newID()
+	endpoint.IPAddress = ip
+	res.Containers[cntr.ID] = types.EndpointResource{Name: name, EndpointID: endpoint.EndpointID,
+		MacAddress: endpoint.MacAddress, IPv4Address: ip}
+	cntr.NetworkSettings.Networks[res.Name] = &endpoint
+	return nil
+}
+
+func (cli *client) NetworkConnect(ctx context.Context, networkID, containerID string,
+	config *network.EndpointSettings) error {
+
+	dmn, unlock, err := cli.call("NetworkConnect")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return err
+	}
+	return dmn.connect(networkID, cntr, config)
+}
+
+func (cli *client) NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error {
+	dmn, unlock, err := cli.call("NetworkDisconnect")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	res, err := dmn.network(networkID)
+	if err != nil {
+		return err
+	}
+	cntr, err := dmn.container(containerID)
+	if err != nil {
+		return err
+	}
+	if _, exists := res.Containers[cntr.ID]; !exists {
+		return errdefs.Forbidden(daemonError("container %s is not connected to the network %s",
+			cntr.ID, res.Name))
+	}
+	delete(res.Containers, cntr.ID)
+	delete(cntr.NetworkSettings.Networks, res.Name)
+	return nil
+}
+
+func (cli *client) NetworkInspect(ctx context.Context, networkID string,
+	options types.NetworkInspectOptions) (types.NetworkResource, error) {
+
+	dmn, unlock, err := cli.call("NetworkInspect")
+	defer unlock()
+	if err != nil {
+		return types.NetworkResource{}, err
+	}
+	res, err := dmn.network(networkID)
+	if err != nil {
+		return types.NetworkResource{}, err
+	}
+	return copyNetwork(res), nil
+}
+
+func (cli *client) NetworkRemove(ctx context.Context, networkID string) error {
+	dmn, unlock, err := cli.call("NetworkRemove")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	res, err := dmn.network(networkID)
+	if err != nil {
+		return err
+	}
+	if len(res.Containers) > 0 {
+		return errdefs.Forbidden(daemonError("error while removing network: network %s id %s has "+
+			"active endpoints", res.Name, res.ID))
+	}
+	delete(dmn.networks, res.ID)
+	return nil
+}
+
+func (cli *client) NetworkList(ctx context.Context, options types.NetworkListOptions) ([]types.NetworkResource, error) {
+	dmn, unlock, err := cli.call("NetworkList")
+	defer unlock()
+	if err != nil {
+		return nil, err
+	}
+	out := []types.NetworkResource{}
+	for _, res := range dmn.networks {
+		if matches(options.Filters, res.Name, res.Labels) {
+			out = append(out, copyNetwork(res))
+		}
+	}
+	return out, nil
+}
+
+func (cli *client) Ping(ctx context.Context) (types.Ping, error) {
+	_, unlock, err := cli.call("Ping")
+	defer unlock()
+	return types.Ping{APIVersion: "1.40", OSType: "linux"}, err
+}
+
+func (cli *client) SwarmInit(ctx context.Context, req swarm.InitRequest) (string, error) {
+	dmn, unlock, err := cli.call("SwarmInit")
+	defer unlock()
+	if err != nil {
+		return "", err
+	}
+	if len(dmn.swarm) > 0 && !req.ForceNewCluster {
+		return "", errdefs.Unavailable(daemonError("This node is already part of a swarm. Use " +
+			`"docker swarm leave" to leave this swarm and join another one.`))
+	}
+	out := &swarm.Swarm{}
+	out.ID = newID()[:25]
+	out.CreatedAt = time.Now()
+	out.JoinTokens = swarm.JoinTokens{Worker: "SWMTKN-1-" + newID(), Manager: "SWMTKN-1-" + newID()}
+	cli.docker.swarms[out.ID] = out
+	dmn.swarm = out.ID
+	dmn.manager = true
+	return newID()[:25], nil
+}
+
+func (cli *client) SwarmJoin(ctx context.Context, req swarm.JoinRequest) error {
+	dmn, unlock, err := cli.call("SwarmJoin")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	if len(dmn.swarm) > 0 {
+		return errdefs.Unavailable(daemonError("This node is already part of a swarm. Use " +
+			`"docker swarm leave" to leave this swarm and join another one.`))
+	}
+	for id, swm := range cli.docker.swarms {
+		if req.JoinToken == swm.JoinTokens.Worker || req.JoinToken == swm.JoinTokens.Manager {
+			dmn.swarm = id
+			dmn.manager = req.JoinToken == swm.JoinTokens.Manager
+			return nil
+		}
+	}
+	return errdefs.InvalidParameter(daemonError("invalid join token"))
+}
+
+func (cli *client) SwarmInspect(ctx context.Context) (swarm.Swarm, error) {
+	dmn, unlock, err := cli.call("SwarmInspect")
+	defer unlock()
+	if err != nil {
+		return swarm.Swarm{}, err
+	}
+	if len(dmn.swarm) == 0 || !dmn.manager {
+		return swarm.Swarm{}, errdefs.Unavailable(daemonError("This node is not a swarm manager. Use " +
+			`"docker swarm init" or "docker swarm join" to connect this node to swarm and try again.`))
+	}
+	return *cli.docker.swarms[dmn.swarm], nil
+}
+
+func (cli *client) VolumeCreate(ctx context.Context, options volume.VolumeCreateBody) (types.Volume, error) {
+	dmn, unlock, err := cli.call("VolumeCreate")
+	defer unlock()
+	if err != nil {
+		return types.Volume{}, err
+	}
+	if len(options.Driver) == 0 {
+		options.Driver = "local"
+	}
+	if len(options.Name) == 0 {
+		options.Name = newID()
+	}
+	if vol, exists := dmn.volumes[options.Name]; exists {
+		if vol.Driver != options.Driver {
+			return types.Volume{}, errdefs.Conflict(daemonError("create %s: a volume with the name %s "+
+				"already exists with driver \"%s\"", options.Name, options.Name, vol.Driver))
+		}
+		return *vol, nil
+	}
+	vol := &types.Volume{
+		Name:       options.Name,
+		Driver:     options.Driver,
+		Labels:     options.Labels,
+		Options:    options.DriverOpts,
+		Mountpoint: "/var/lib/docker/volumes/" + options.Name + "/_data",
+		Scope:      "local",
+		CreatedAt:  time.Now().Format(time.RFC3339),
+	}
+	dmn.volumes[options.Name] = vol
+	return *vol, nil
+}
+
+func (cli *client) VolumeList(ctx context.Context, filter filters.Args) (volume.VolumeListOKBody, error) {
+	dmn, unlock, err := cli.call("VolumeList")
+	defer unlock()
+	if err != nil {
+		return volume.VolumeListOKBody{}, err
+	}
+	out := volume.VolumeListOKBody{Volumes: []*types.Volume{}, Warnings: []string{}}
+	for _, vol := range dmn.volumes {
+		if matches(filter, vol.Name, vol.Labels) {
+			copied := *vol
+			out.Volumes = append(out.Volumes, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (cli *client) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	dmn, unlock, err := cli.call("VolumeRemove")
+	defer unlock()
+	if err != nil {
+		return err
+	}
+	if _, exists := dmn.volumes[volumeID]; !exists {
+		if force {
+			return nil
+		}
+		return noSuch("volume", volumeID)
+	}
+	users := []string{}
+	for _, cntr := range dmn.containers {
+		for _, mnt := range cntr.HostConfig.Mounts {
+			if mnt.Source == volumeID {
+				users = append(users, cntr.ID)
+			}
+		}
+	}
+	if len(users) > 0 {
+		return errdefs.Conflict(daemonError("remove %s: volume is in use - [%s]", volumeID,
+			strings.Join(users, ", ")))
+	}
+	delete(dmn.volumes, volumeID)
+	return nil
+}
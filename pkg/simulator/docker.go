@@ -0,0 +1,595 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+// Package simulator simulates the docker daemons of a testnet in memory, so that instructions can
+// be planned without touching docker, and whole sets of instructions can be executed in tests
+package simulator
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Output is the outcome of a command run in a container
+type Output struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// ExecHandler decides the outcome of a command run in a container, either as an exec or as the
+// command of a container which was attached to before it was started
+type ExecHandler func(host string, container string, cmd []string) Output
+
+// Exec is a command which was executed in a container
+type Exec struct {
+	ID        string
+	Container string
+	Cmd       []string
+	ExitCode  int
+}
+
+// Docker is a set of in-memory docker daemons, one per host. It keeps track of the containers,
+// networks, volumes, images, execs and swarm membership of each host, and fails the same way
+// the docker daemon does. Containers do not run anything, they run until Exit is called,
+// unless they were attached to before being started, in which case they exit with the outcome
+// given by the exec handler. The health of containers with a healthcheck is starting until
+// SetHealth is called.
+type Docker struct {
+	mux      sync.Mutex
+	hosts    map[string]*daemon
+	swarms   map[string]*swarm.Swarm
+	handler  ExecHandler
+	failures map[string][]error
+}
+
+type daemon struct {
+	images     map[string]types.ImageSummary
+	containers map[string]*fakeContainer
+	networks   map[string]*types.NetworkResource
+	volumes    map[string]*types.Volume
+	execs      map[string]*Exec
+	execOrder  []string
+	// swarm is the id of the swarm this host is a member of, if any
+	swarm   string
+	manager bool
+}
+
+type fakeContainer struct {
+	types.ContainerJSON
+	config *container.Config
+	files  map[string][]byte
+	// attached is the daemon's end of the stream of the container, if it was attached to
+	attached net.Conn
+	// logs are the lines the container has logged, in order
+	logs []logEntry
+	// followers are the log streams which are sent the lines the container logs from now on
+	followers []logFollower
+	// stats are the resource usage stats reported while the container is running
+	stats types.StatsJSON
+	// waiters are the waits for the container to exit or to be removed
+	waiters []containerWaiter
+}
+
+// containerWaiter is a wait for a container to reach a condition, which is sent the exit code
+// of the container once it does
+type containerWaiter struct {
+	condition container.WaitCondition
+	done      chan int64
+}
+
+// logEntry is a line logged by a container
+type logEntry struct {
+	time   time.Time
+	stream stdcopy.StdType
+	text   string
+}
+
+// logFollower is a log stream which is following the logs of a container
+type logFollower struct {
+	stream  *logStream
+	options types.ContainerLogsOptions
+}
+
+// logStream is a stream of logs which the daemon writes to until it is closed, and which can
+// be read until everything written to it has been read
+type logStream struct {
+	mux    sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+	done   chan struct{}
+}
+
+func newLogStream() *logStream {
+	out := &logStream{done: make(chan struct{})}
+	out.cond = sync.NewCond(&out.mux)
+	return out
+}
+
+func (ls *logStream) Write(p []byte) (int, error) {
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	if ls.closed {
+		return 0, io.ErrClosedPipe
+	}
+	defer ls.cond.Broadcast()
+	return ls.buf.Write(p)
+}
+
+func (ls *logStream) Read(p []byte) (int, error) {
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	for ls.buf.Len() == 0 && !ls.closed {
+		ls.cond.Wait()
+	}
+	if ls.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return ls.buf.Read(p)
+}
+
+func (ls *logStream) Close() error {
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	if !ls.closed {
+		ls.closed = true
+		close(ls.done)
+		ls.cond.Broadcast()
+	}
+	return nil
+}
+
+// NewDocker creates a new set of in-memory docker daemons. Every exec succeeds, until
+// SetExecHandler is called.
+func NewDocker() *Docker {
+	return &Docker{
+		hosts:    map[string]*daemon{},
+		swarms:   map[string]*swarm.Swarm{},
+		handler:  func(string, string, []string) Output { return Output{} },
+		failures: map[string][]error{},
+	}
+}
+
+// Client gets a client for the docker daemon on the given host, creating the daemon if needed
+func (d *Docker) Client(host string) entity.Client {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.daemon(host)
+	return &client{host: host, docker: d}
+}
+
+// Dial is a service.ClientFactory which creates clients for this set of daemons
+func (d *Docker) Dial(host string) (entity.Client, error) {
+	return d.Client(host), nil
+}
+
+// daemon gets the daemon for the given host, creating it if it does not exist yet.
+// The caller must hold the lock.
+func (d *Docker) daemon(host string) *daemon {
+	if _, exists := d.hosts[host]; !exists {
+		d.hosts[host] = &daemon{
+			images:     map[string]types.ImageSummary{},
+			containers: map[string]*fakeContainer{},
+			networks:   map[string]*types.NetworkResource{},
+			volumes:    map[string]*types.Volume{},
+			execs:      map[string]*Exec{},
+		}
+	}
+	return d.hosts[host]
+}
+
+// SetExecHandler sets the function which decides the outcome of execs and attached containers
+func (d *Docker) SetExecHandler(handler ExecHandler) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.handler = handler
+}
+
+// FailNext causes the next call to the given client method, on any host, to fail with err
+func (d *Docker) FailNext(method string, err error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.failures[method] = append(d.failures[method], err)
+}
+
+// failure gets the error queued by FailNext for the given method, if any. The caller must hold the lock.
+func (d *Docker) failure(method string) error {
+	errs := d.failures[method]
+	if len(errs) == 0 {
+		return nil
+	}
+	d.failures[method] = errs[1:]
+	return errs[0]
+}
+
+// AddImage makes the given image available on the given host, as if it had been pulled
+func (d *Docker) AddImage(host string, image string) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.daemon(host).addImage(image)
+}
+
+// Exit makes a running container exit with the given code. If the container was created
+// with AutoRemove, it is removed.
+func (d *Docker) Exit(host string, name string, code int) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	dmn := d.daemon(host)
+	cntr, err := dmn.container(name)
+	if err != nil {
+		return err
+	}
+	dmn.exit(cntr, code)
+	return nil
+}
+
+// Log makes a container log the given stdout and stderr, line by line
+func (d *Docker) Log(host string, name string, out Output) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	cntr, err := d.daemon(host).container(name)
+	if err != nil {
+		return err
+	}
+	cntr.log(stdcopy.Stdout, out.Stdout)
+	cntr.log(stdcopy.Stderr, out.Stderr)
+	return nil
+}
+
+// SetStats sets the resource usage stats reported for a container while it is running
+func (d *Docker) SetStats(host string, name string, stats types.StatsJSON) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	cntr, err := d.daemon(host).container(name)
+	if err != nil {
+		return err
+	}
+	cntr.stats = stats
+	return nil
+}
+
+// SetHealth sets the health status of a running container which has a healthcheck, as if the
+// healthcheck had given the output
+func (d *Docker) SetHealth(host string, name string, status string, output string) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	cntr, err := d.daemon(host).container(name)
+	if err != nil {
+		return err
+	}
+	if cntr.State.Health == nil {
+		return errdefs.InvalidParameter(daemonError("container %s does not have a healthcheck", cntr.ID))
+	}
+	exitCode := 0
+	if status != types.Healthy {
+		exitCode = 1
+	}
+	now := time.Now()
+	cntr.State.Health.Status = status
+	cntr.State.Health.Log = append(cntr.State.Health.Log, &types.HealthcheckResult{
+		Start: now, End: now, ExitCode: exitCode, Output: output})
+	return nil
+}
+
+// Containers gets every container on the given host, sorted by name
+func (d *Docker) Containers(host string) []types.ContainerJSON {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	out := []types.ContainerJSON{}
+	for _, cntr := range d.daemon(host).containers {
+		out = append(out, cntr.inspect())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Container gets the container with the given name or id on the given host
+func (d *Docker) Container(host string, name string) (types.ContainerJSON, bool) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	cntr, err := d.daemon(host).container(name)
+	if err != nil {
+		return types.ContainerJSON{}, false
+	}
+	return cntr.inspect(), true
+}
+
+// File gets the contents of a file copied into a container
+func (d *Docker) File(host string, name string, path string) ([]byte, bool) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	cntr, err := d.daemon(host).container(name)
+	if err != nil {
+		return nil, false
+	}
+	data, exists := cntr.files[path]
+	return data, exists
+}
+
+// Networks gets every network on the given host, sorted by name
+func (d *Docker) Networks(host string) []types.NetworkResource {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	out := []types.NetworkResource{}
+	for _, net := range d.daemon(host).networks {
+		out = append(out, copyNetwork(net))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Volumes gets every volume on the given host, sorted by name
+func (d *Docker) Volumes(host string) []types.Volume {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	out := []types.Volume{}
+	for _, vol := range d.daemon(host).volumes {
+		out = append(out, *vol)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Images gets the images on the given host, sorted
+func (d *Docker) Images(host string) []string {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	out := []string{}
+	for image := range d.daemon(host).images {
+		out = append(out, image)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Execs gets the commands executed in the containers on the given host, in order
+func (d *Docker) Execs(host string) []Exec {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	dmn := d.daemon(host)
+	out := []Exec{}
+	for _, id := range dmn.execOrder {
+		out = append(out, *dmn.execs[id])
+	}
+	return out
+}
+
+// Swarm gets the swarm the given host is a member of, whether it is a manager of it and
+// whether it is a member of a swarm at all
+func (d *Docker) Swarm(host string) (swarm.Swarm, bool, bool) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	dmn := d.daemon(host)
+	if len(dmn.swarm) == 0 {
+		return swarm.Swarm{}, false, false
+	}
+	return *d.swarms[dmn.swarm], dmn.manager, true
+}
+
+func newID() string {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+func noSuch(object string, name string) error {
+	return errdefs.NotFound(fmt.Errorf("Error: No such %s: %s", object, name))
+}
+
+func (dmn *daemon) addImage(image string) {
+	dmn.images[image] = types.ImageSummary{ID: "sha256:" + newID(), RepoTags: []string{image}}
+}
+
+func (dmn *daemon) hasImage(image string) bool {
+	if _, exists := dmn.images[image]; exists {
+		return true
+	}
+	if !strings.Contains(image, ":") {
+		_, exists := dmn.images[image+":latest"]
+		return exists
+	}
+	return false
+}
+
+// container finds a container by name or id
+func (dmn *daemon) container(name string) (*fakeContainer, error) {
+	name = strings.TrimPrefix(name, "/")
+	for _, cntr := range dmn.containers {
+		if cntr.ID == name || cntr.Name == "/"+name {
+			return cntr, nil
+		}
+	}
+	return nil, noSuch("container", name)
+}
+
+func (dmn *daemon) exit(cntr *fakeContainer, code int) {
+	cntr.State = &types.ContainerState{Status: "exited", ExitCode: code}
+	cntr.closeLogs()
+	cntr.notifyWaiters(false)
+	if cntr.HostConfig.AutoRemove {
+		dmn.removeContainer(cntr)
+	}
+}
+
+func (dmn *daemon) removeContainer(cntr *fakeContainer) {
+	for name := range cntr.NetworkSettings.Networks {
+		if net, err := dmn.network(name); err == nil {
+			delete(net.Containers, cntr.ID)
+		}
+	}
+	cntr.closeLogs()
+	if cntr.State.Running {
+		cntr.State = &types.ContainerState{Status: "exited", ExitCode: 137}
+	}
+	cntr.notifyWaiters(true)
+	delete(dmn.containers, cntr.ID)
+}
+
+// network finds a network by name or id
+func (dmn *daemon) network(name string) (*types.NetworkResource, error) {
+	for _, net := range dmn.networks {
+		if net.ID == name || net.Name == name {
+			return net, nil
+		}
+	}
+	return nil, noSuch("network", name)
+}
+
+// log adds the lines of text to the logs of the container, and writes them to the followers
+// whose streams are still open
+func (cntr *fakeContainer) log(stream stdcopy.StdType, text string) {
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		entry := logEntry{time: time.Now().UTC(), stream: stream, text: line}
+		cntr.logs = append(cntr.logs, entry)
+		following := cntr.followers[:0]
+		for _, follower := range cntr.followers {
+			_, err := follower.stream.Write(cntr.formatLog(entry, follower.options))
+			if err == nil {
+				following = append(following, follower)
+			}
+		}
+		cntr.followers = following
+	}
+}
+
+// formatLog formats a logged line the way the docker daemon sends it, given the options of the
+// logs call. The line is left out if it was not asked for.
+func (cntr *fakeContainer) formatLog(entry logEntry, options types.ContainerLogsOptions) []byte {
+	if (entry.stream == stdcopy.Stdout && !options.ShowStdout) ||
+		(entry.stream == stdcopy.Stderr && !options.ShowStderr) {
+		return nil
+	}
+	text := entry.text
+	if options.Timestamps {
+		text = entry.time.Format(time.RFC3339Nano) + " " + text
+	}
+	if cntr.config.Tty {
+		return []byte(text)
+	}
+	var out bytes.Buffer
+	stdcopy.NewStdWriter(&out, entry.stream).Write([]byte(text))
+	return out.Bytes()
+}
+
+// notifyWaiters sends the exit code of the container to the waits for it to exit, and to the waits
+// for it to be removed if it was removed
+func (cntr *fakeContainer) notifyWaiters(removed bool) {
+	waiting := cntr.waiters[:0]
+	for _, waiter := range cntr.waiters {
+		if waiter.condition == container.WaitConditionRemoved && !removed {
+			waiting = append(waiting, waiter)
+			continue
+		}
+		waiter.done <- int64(cntr.State.ExitCode)
+	}
+	cntr.waiters = waiting
+}
+
+// closeLogs ends the log streams following the container
+func (cntr *fakeContainer) closeLogs() {
+	for _, follower := range cntr.followers {
+		follower.stream.Close()
+	}
+	cntr.followers = nil
+}
+
+// start sets the state of the container to running. Its health is starting, if it has a healthcheck.
+func (cntr *fakeContainer) start() {
+	cntr.State = &types.ContainerState{Status: "running", Running: true, Pid: 1,
+		StartedAt: time.Now().Format(time.RFC3339Nano)}
+	if hc := cntr.config.Healthcheck; hc != nil && len(hc.Test) > 0 && hc.Test[0] != "NONE" {
+		cntr.State.Health = &types.Health{Status: types.Starting, Log: []*types.HealthcheckResult{}}
+	}
+}
+
+func (cntr *fakeContainer) inspect() types.ContainerJSON {
+	out := cntr.ContainerJSON
+	base := *cntr.ContainerJSONBase
+	out.ContainerJSONBase = &base
+	state := *cntr.State
+	if state.Health != nil {
+		health := *state.Health
+		health.Log = append([]*types.HealthcheckResult{}, health.Log...)
+		state.Health = &health
+	}
+	out.State = &state
+	settings := *cntr.NetworkSettings
+	settings.Networks = map[string]*network.EndpointSettings{}
+	for name, endpoint := range cntr.NetworkSettings.Networks {
+		copied := *endpoint
+		settings.Networks[name] = &copied
+	}
+	out.NetworkSettings = &settings
+	return out
+}
+
+func copyNetwork(net *types.NetworkResource) types.NetworkResource {
+	out := *net
+	out.Containers = map[string]types.EndpointResource{}
+	for id, endpoint := range net.Containers {
+		out.Containers[id] = endpoint
+	}
+	return out
+}
+
+func ipToInt(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func intToIP(n uint32) net.IP {
+	out := make(net.IP, 4)
+	binary.BigEndian.PutUint32(out, n)
+	return out
+}
+
+// allocateIP assigns the next free address of the network's first IPv4 subnet
+func allocateIP(res *types.NetworkResource) (string, error) {
+	used := map[string]bool{}
+	for _, endpoint := range res.Containers {
+		used[strings.Split(endpoint.IPv4Address, "/")[0]] = true
+	}
+	for _, conf := range res.IPAM.Config {
+		_, subnet, err := net.ParseCIDR(conf.Subnet)
+		if err != nil || subnet.IP.To4() == nil {
+			continue
+		}
+		used[conf.Gateway] = true
+		ones, bits := subnet.Mask.Size()
+		first := ipToInt(subnet.IP)
+		for n := first + 2; n < first+(1<<uint(bits-ones))-1; n++ {
+			if ip := intToIP(n).String(); !used[ip] {
+				return ip, nil
+			}
+		}
+		return "", fmt.Errorf("Error response from daemon: no available IPv4 addresses on this network's address pools: %s", res.Name)
+	}
+	return "", nil
+}
@@ -0,0 +1,457 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package simulator
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createContainer(t *testing.T, cli *client, name string, hostConfig *container.HostConfig,
+	networkingConfig *network.NetworkingConfig) string {
+	res, err := cli.ContainerCreate(context.Background(), &container.Config{Image: "alpine",
+		Labels: map[string]string{"test": name}}, hostConfig, networkingConfig, name)
+	require.NoError(t, err)
+	return res.ID
+}
+
+func TestDocker_Containers(t *testing.T) {
+	docker := NewDocker()
+	cli := docker.Client("host0").(*client)
+	ctx := context.Background()
+
+	_, err := cli.ContainerCreate(ctx, &container.Config{Image: "alpine"}, nil, nil, "node0")
+	require.Error(t, err)
+	assert.True(t, errdefs.IsNotFound(err))
+	assert.Contains(t, err.Error(), "No such image: alpine")
+
+	docker.AddImage("host0", "alpine:latest")
+	id := createContainer(t, cli, "node0", nil, nil)
+	_, err = cli.ContainerCreate(ctx, &container.Config{Image: "alpine"}, nil, nil, "node0")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is already in use by container")
+
+	cntrs, err := cli.ContainerList(ctx, types.ContainerListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, cntrs)
+
+	require.NoError(t, cli.ContainerStart(ctx, "node0", types.ContainerStartOptions{}))
+	cntrs, err = cli.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", "test=node0"))})
+	require.NoError(t, err)
+	require.Len(t, cntrs, 1)
+	assert.Equal(t, id, cntrs[0].ID)
+	assert.Equal(t, []string{"/node0"}, cntrs[0].Names)
+
+	err = cli.ContainerRemove(ctx, "node0", types.ContainerRemoveOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "You cannot remove a running container")
+
+	require.NoError(t, cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true}))
+	_, err = cli.ContainerInspect(ctx, "node0")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No such container: node0")
+	assert.Empty(t, docker.Containers("host0"))
+	assert.Empty(t, docker.Containers("host1"))
+}
+
+func TestDocker_Exit(t *testing.T) {
+	docker := NewDocker()
+	docker.AddImage("host0", "alpine")
+	cli := docker.Client("host0").(*client)
+	createContainer(t, cli, "node0", nil, nil)
+	createContainer(t, cli, "node1", &container.HostConfig{AutoRemove: true}, nil)
+	for _, name := range []string{"node0", "node1"} {
+		require.NoError(t, cli.ContainerStart(context.Background(), name, types.ContainerStartOptions{}))
+		require.NoError(t, docker.Exit("host0", name, 2))
+	}
+
+	cntr, exists := docker.Container("host0", "node0")
+	require.True(t, exists)
+	assert.False(t, cntr.State.Running)
+	assert.Equal(t, 2, cntr.State.ExitCode)
+
+	_, exists = docker.Container("host0", "node1")
+	assert.False(t, exists)
+}
+
+func TestDocker_Networks(t *testing.T) {
+	docker := NewDocker()
+	docker.AddImage("host0", "alpine")
+	cli := docker.Client("host0").(*client)
+	ctx := context.Background()
+
+	create := func(name string, subnet string) error {
+		_, err := cli.NetworkCreate(ctx, name, types.NetworkCreate{CheckDuplicate: true,
+			IPAM: &network.IPAM{Config: []network.IPAMConfig{{Subnet: subnet}}}})
+		return err
+	}
+	require.NoError(t, create("net0", "10.1.0.0/24"))
+	err := create("net0", "10.2.0.0/24")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "network with name net0 already exists")
+	err = create("net1", "10.1.0.128/25")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Pool overlaps")
+
+	createContainer(t, cli, "node0", nil, &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{"net0": {IPAddress: "10.1.0.2"}}})
+	_, err = cli.ContainerCreate(ctx, &container.Config{Image: "alpine"}, nil, &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{"net0": {IPAddress: "10.1.0.2"}}}, "node1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Address already in use")
+	_, exists := docker.Container("host0", "node1")
+	assert.False(t, exists)
+
+	createContainer(t, cli, "node1", nil, nil)
+	require.NoError(t, cli.NetworkConnect(ctx, "net0", "node1", nil))
+	err = cli.NetworkConnect(ctx, "net0", "node1", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "endpoint with name node1 already exists in network net0")
+
+	cntr, _ := docker.Container("host0", "node1")
+	assert.Equal(t, "10.1.0.3", cntr.NetworkSettings.Networks["net0"].IPAddress)
+
+	err = cli.NetworkRemove(ctx, "net0")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "has active endpoints")
+
+	require.NoError(t, cli.NetworkDisconnect(ctx, "net0", "node1", false))
+	err = cli.NetworkDisconnect(ctx, "net0", "node1", false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not connected to the network net0")
+
+	require.NoError(t, cli.ContainerRemove(ctx, "node0", types.ContainerRemoveOptions{}))
+	require.NoError(t, cli.NetworkRemove(ctx, "net0"))
+	err = cli.NetworkRemove(ctx, "net0")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No such network: net0")
+}
+
+func TestDocker_Volumes(t *testing.T) {
+	docker := NewDocker()
+	docker.AddImage("host0", "alpine")
+	cli := docker.Client("host0").(*client)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := cli.VolumeCreate(ctx, volume.VolumeCreateBody{Name: "vol0",
+			Labels: map[string]string{"test": "a"}})
+		require.NoError(t, err)
+	}
+	createContainer(t, cli, "node0", &container.HostConfig{Mounts: []mount.Mount{
+		{Type: mount.TypeVolume, Source: "vol0", Target: "/data"},
+		{Type: mount.TypeVolume, Source: "vol1", Target: "/data1"},
+	}}, nil)
+
+	vols, err := cli.VolumeList(ctx, filters.NewArgs(filters.Arg("label", "test")))
+	require.NoError(t, err)
+	require.Len(t, vols.Volumes, 1)
+	assert.Equal(t, "vol0", vols.Volumes[0].Name)
+	assert.Len(t, docker.Volumes("host0"), 2)
+
+	err = cli.VolumeRemove(ctx, "vol0", true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "volume is in use")
+
+	require.NoError(t, cli.ContainerRemove(ctx, "node0", types.ContainerRemoveOptions{}))
+	require.NoError(t, cli.VolumeRemove(ctx, "vol0", false))
+	err = cli.VolumeRemove(ctx, "vol0", false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No such volume: vol0")
+}
+
+func TestDocker_Execs(t *testing.T) {
+	docker := NewDocker()
+	docker.AddImage("host0", "alpine")
+	docker.SetExecHandler(func(host string, container string, cmd []string) Output {
+		assert.Equal(t, "host0", host)
+		assert.Equal(t, "node0", container)
+		return Output{ExitCode: len(cmd)}
+	})
+	cli := docker.Client("host0").(*client)
+	ctx := context.Background()
+	createContainer(t, cli, "node0", nil, nil)
+
+	_, err := cli.ContainerExecCreate(ctx, "node0", types.ExecConfig{Cmd: []string{"ls"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not running")
+
+	require.NoError(t, cli.ContainerStart(ctx, "node0", types.ContainerStartOptions{}))
+	res, err := cli.ContainerExecCreate(ctx, "node0", types.ExecConfig{Cmd: []string{"ls", "/"}})
+	require.NoError(t, err)
+	require.NoError(t, cli.ContainerExecStart(ctx, res.ID, types.ExecStartCheck{}))
+	inspect, err := cli.ContainerExecInspect(ctx, res.ID)
+	require.NoError(t, err)
+	assert.False(t, inspect.Running)
+	assert.Equal(t, 2, inspect.ExitCode)
+
+	execs := docker.Execs("host0")
+	require.Len(t, execs, 1)
+	assert.Equal(t, []string{"ls", "/"}, execs[0].Cmd)
+}
+
+func TestDocker_Attach(t *testing.T) {
+	docker := NewDocker()
+	docker.AddImage("host0", "alpine")
+	docker.SetExecHandler(func(host string, container string, cmd []string) Output {
+		assert.Equal(t, "node0", container)
+		assert.Equal(t, []string{"/bin/sh", "-c", "echo hi"}, cmd)
+		return Output{Stdout: "hi\n", Stderr: "warning\n", ExitCode: 3}
+	})
+	cli := docker.Client("host0").(*client)
+	ctx := context.Background()
+	_, err := cli.ContainerCreate(ctx, &container.Config{Image: "alpine",
+		Entrypoint: []string{"/bin/sh", "-c"}, Cmd: []string{"echo hi"}}, nil, nil, "node0")
+	require.NoError(t, err)
+
+	stream, err := cli.ContainerAttach(ctx, "node0", types.ContainerAttachOptions{Stream: true})
+	require.NoError(t, err)
+	defer stream.Close()
+	require.NoError(t, cli.ContainerStart(ctx, "node0", types.ContainerStartOptions{}))
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	_, err = stdcopy.StdCopy(stdout, stderr, stream.Reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hi\n", stdout.String())
+	assert.Equal(t, "warning\n", stderr.String())
+
+	assert.Eventually(t, func() bool {
+		cntr, exists := docker.Container("host0", "node0")
+		return exists && !cntr.State.Running && cntr.State.ExitCode == 3
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDocker_Logs(t *testing.T) {
+	docker := NewDocker()
+	docker.AddImage("host0", "alpine")
+	cli := docker.Client("host0").(*client)
+	ctx := context.Background()
+	_, err := cli.ContainerCreate(ctx, &container.Config{Image: "alpine"}, nil, nil, "node0")
+	require.NoError(t, err)
+	require.NoError(t, cli.ContainerStart(ctx, "node0", types.ContainerStartOptions{}))
+	require.NoError(t, docker.Log("host0", "node0", Output{Stdout: "a\nb\n", Stderr: "c\n"}))
+
+	rdr, err := cli.ContainerLogs(ctx, "node0", types.ContainerLogsOptions{ShowStdout: true, Follow: true})
+	require.NoError(t, err)
+	defer rdr.Close()
+	require.NoError(t, docker.Log("host0", "node0", Output{Stdout: "d\n"}))
+	require.NoError(t, docker.Exit("host0", "node0", 0))
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	_, err = stdcopy.StdCopy(stdout, stderr, rdr)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\nd\n", stdout.String())
+	assert.Empty(t, stderr.String())
+
+	rdr, err = cli.ContainerLogs(ctx, "node0", types.ContainerLogsOptions{ShowStdout: true,
+		ShowStderr: true, Timestamps: true, Follow: true})
+	require.NoError(t, err)
+	_, err = stdcopy.StdCopy(stdout, stderr, rdr)
+	require.NoError(t, err)
+	assert.Regexp(t, "^[0-9-]+T[0-9:.]+Z c\n$", stderr.String())
+
+	rdr, err = cli.ContainerLogs(ctx, "node0", types.ContainerLogsOptions{ShowStdout: true, Tail: "3"})
+	require.NoError(t, err)
+	stdout.Reset()
+	_, err = stdcopy.StdCopy(stdout, stderr, rdr)
+	require.NoError(t, err)
+	assert.Equal(t, "b\nd\n", stdout.String())
+
+	_, err = cli.ContainerLogs(ctx, "node1", types.ContainerLogsOptions{})
+	assert.True(t, errdefs.IsNotFound(err))
+}
+
+func TestDocker_Stats(t *testing.T) {
+	docker := NewDocker()
+	docker.AddImage("host0", "alpine")
+	cli := docker.Client("host0").(*client)
+	ctx := context.Background()
+	_, err := cli.ContainerCreate(ctx, &container.Config{Image: "alpine"}, nil, nil, "node0")
+	require.NoError(t, err)
+	stats := types.StatsJSON{}
+	stats.MemoryStats.Usage = 100
+	require.NoError(t, docker.SetStats("host0", "node0", stats))
+
+	read := func() types.StatsJSON {
+		res, err := cli.ContainerStats(ctx, "node0", false)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var out types.StatsJSON
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&out))
+		return out
+	}
+	out := read()
+	assert.True(t, out.Read.IsZero())
+	assert.Equal(t, uint64(0), out.MemoryStats.Usage)
+
+	require.NoError(t, cli.ContainerStart(ctx, "node0", types.ContainerStartOptions{}))
+	out = read()
+	assert.False(t, out.Read.IsZero())
+	assert.Equal(t, uint64(100), out.MemoryStats.Usage)
+
+	_, err = cli.ContainerStats(ctx, "node0", true)
+	assert.Equal(t, ErrNotSupported, err)
+	assert.Error(t, docker.SetStats("host0", "node1", stats))
+}
+
+func TestDocker_Health(t *testing.T) {
+	docker := NewDocker()
+	docker.AddImage("host0", "alpine")
+	cli := docker.Client("host0").(*client)
+	ctx := context.Background()
+	_, err := cli.ContainerCreate(ctx, &container.Config{Image: "alpine", Healthcheck: &container.HealthConfig{
+		Test: []string{"CMD", "true"}}}, nil, nil, "node0")
+	require.NoError(t, err)
+	_, err = cli.ContainerCreate(ctx, &container.Config{Image: "alpine"}, nil, nil, "node1")
+	require.NoError(t, err)
+	require.NoError(t, cli.ContainerStart(ctx, "node0", types.ContainerStartOptions{}))
+	require.NoError(t, cli.ContainerStart(ctx, "node1", types.ContainerStartOptions{}))
+
+	res, err := cli.ContainerInspect(ctx, "node0")
+	require.NoError(t, err)
+	require.NotNil(t, res.State.Health)
+	assert.Equal(t, types.Starting, res.State.Health.Status)
+
+	require.NoError(t, docker.SetHealth("host0", "node0", types.Healthy, "ok"))
+	require.NoError(t, cli.ContainerStart(ctx, "node0", types.ContainerStartOptions{}))
+	res, err = cli.ContainerInspect(ctx, "node0")
+	require.NoError(t, err)
+	assert.Equal(t, types.Healthy, res.State.Health.Status)
+	require.Len(t, res.State.Health.Log, 1)
+	assert.Equal(t, "ok", res.State.Health.Log[0].Output)
+
+	res, err = cli.ContainerInspect(ctx, "node1")
+	require.NoError(t, err)
+	assert.Nil(t, res.State.Health)
+	assert.True(t, errdefs.IsInvalidParameter(docker.SetHealth("host0", "node1", types.Healthy, "")))
+}
+
+func TestDocker_Wait(t *testing.T) {
+	docker := NewDocker()
+	docker.AddImage("host0", "alpine")
+	cli := docker.Client("host0").(*client)
+	ctx := context.Background()
+	_, err := cli.ContainerCreate(ctx, &container.Config{Image: "alpine"}, nil, nil, "node0")
+	require.NoError(t, err)
+
+	resC, errC := cli.ContainerWait(ctx, "node0", container.WaitConditionNotRunning)
+	assert.Equal(t, container.ContainerWaitOKBody{}, <-resC, "the container is not running yet")
+
+	resC, _ = cli.ContainerWait(ctx, "node0", container.WaitConditionNextExit)
+	removedC, _ := cli.ContainerWait(ctx, "node0", container.WaitConditionRemoved)
+	require.NoError(t, cli.ContainerStart(ctx, "node0", types.ContainerStartOptions{}))
+	require.NoError(t, docker.Exit("host0", "node0", 3))
+	assert.Equal(t, int64(3), (<-resC).StatusCode)
+	require.Len(t, removedC, 0)
+	require.NoError(t, cli.ContainerRemove(ctx, "node0", types.ContainerRemoveOptions{}))
+	assert.Equal(t, int64(3), (<-removedC).StatusCode)
+
+	canceled, cancel := context.WithCancel(ctx)
+	_, err = cli.ContainerCreate(ctx, &container.Config{Image: "alpine"}, nil, nil, "node1")
+	require.NoError(t, err)
+	_, errC = cli.ContainerWait(canceled, "node1", container.WaitConditionNextExit)
+	cancel()
+	assert.Equal(t, context.Canceled, <-errC)
+
+	_, errC = cli.ContainerWait(ctx, "node2", container.WaitConditionNextExit)
+	assert.True(t, errdefs.IsNotFound(<-errC))
+}
+
+func TestDocker_Files(t *testing.T) {
+	docker := NewDocker()
+	docker.AddImage("host0", "alpine")
+	cli := docker.Client("host0").(*client)
+	ctx := context.Background()
+	createContainer(t, cli, "node0", nil, nil)
+
+	buf := new(bytes.Buffer)
+	wr := tar.NewWriter(buf)
+	require.NoError(t, wr.WriteHeader(&tar.Header{Name: "conf/genesis.json", Mode: 0644, Size: 2,
+		Typeflag: tar.TypeReg}))
+	_, err := wr.Write([]byte("{}"))
+	require.NoError(t, err)
+	require.NoError(t, wr.Close())
+	require.NoError(t, cli.CopyToContainer(ctx, "node0", "/etc", buf, types.CopyToContainerOptions{}))
+
+	data, exists := docker.File("host0", "node0", "/etc/conf/genesis.json")
+	require.True(t, exists)
+	assert.Equal(t, "{}", string(data))
+
+	stat, err := cli.ContainerStatPath(ctx, "node0", "/etc/conf")
+	require.NoError(t, err)
+	assert.True(t, stat.Mode.IsDir())
+	stat, err = cli.ContainerStatPath(ctx, "node0", "/etc/conf/genesis.json")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, stat.Size)
+	_, err = cli.ContainerStatPath(ctx, "node0", "/etc/other")
+	assert.True(t, errdefs.IsNotFound(err))
+}
+
+func TestDocker_Swarm(t *testing.T) {
+	docker := NewDocker()
+	ctx := context.Background()
+	manager := docker.Client("host0")
+	worker := docker.Client("host1")
+
+	_, err := manager.SwarmInspect(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "This node is not a swarm manager")
+
+	_, err = manager.SwarmInit(ctx, swarm.InitRequest{})
+	require.NoError(t, err)
+	_, err = manager.SwarmInit(ctx, swarm.InitRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "This node is already part of a swarm")
+
+	swm, err := manager.SwarmInspect(ctx)
+	require.NoError(t, err)
+
+	err = worker.SwarmJoin(ctx, swarm.JoinRequest{JoinToken: "SWMTKN-1-bad"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid join token")
+	require.NoError(t, worker.SwarmJoin(ctx, swarm.JoinRequest{JoinToken: swm.JoinTokens.Worker}))
+
+	joined, isManager, member := docker.Swarm("host1")
+	assert.True(t, member)
+	assert.False(t, isManager)
+	assert.Equal(t, swm.ID, joined.ID)
+
+	_, err = worker.SwarmInspect(ctx)
+	assert.Error(t, err)
+}
+
+func TestDocker_FailNext(t *testing.T) {
+	docker := NewDocker()
+	cli := docker.Client("host0")
+	ctx := context.Background()
+	docker.FailNext("NetworkList", fmt.Errorf("Cannot connect to the Docker daemon"))
+
+	_, err := cli.NetworkList(ctx, types.NetworkListOptions{})
+	assert.EqualError(t, err, "Cannot connect to the Docker daemon")
+	_, err = cli.NetworkList(ctx, types.NetworkListOptions{})
+	assert.NoError(t, err)
+}
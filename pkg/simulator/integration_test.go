@@ -0,0 +1,729 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package simulator_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/file"
+	"github.com/whiteblock/genesis/pkg/handler/auxillary"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/service"
+	"github.com/whiteblock/genesis/pkg/simulator"
+	"github.com/whiteblock/genesis/pkg/usecase"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/whiteblock/definition/command"
+)
+
+const host = "10.0.0.2"
+
+func cmd(id string, orderType command.OrderType, payload interface{}) command.Command {
+	return command.Command{
+		ID:     id,
+		Target: command.Target{IP: host},
+		Order:  command.Order{Type: orderType, Payload: payload},
+		Meta:   map[string]string{command.TestIDKey: "test0"},
+	}
+}
+
+func newExecutor(docker *simulator.Docker) (auxillary.Executor, service.DockerService) {
+	log := logrus.New()
+	serv := service.NewDockerServiceWithClients(repository.NewDockerRepository(log),
+		config.Docker{ExitLogTail: 20}, file.NewRemoteSources(config.Config{}, log), docker.Dial, log)
+	return auxillary.NewExecutor(config.Execution{
+		LimitPerTest:      10,
+		ConnectionRetries: 1,
+		TimeLimit:         10 * time.Second,
+	}, usecase.NewDockerUseCase(serv, log), log), serv
+}
+
+// execute executes each round of the instructions, stopping at the first which does not succeed
+func execute(exec auxillary.Executor, inst command.Instructions) entity.Result {
+	for {
+		cmds, err := inst.Peek()
+		if errors.Is(err, command.ErrNoCommands) {
+			return entity.NewSuccessResult()
+		}
+		res := exec.ExecuteCommands(context.Background(), cmds)
+		if !res.IsSuccess() || errors.Is(err, command.ErrDone) {
+			return res
+		}
+		inst.Next()
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	node := func(name string, ip string) command.Container {
+		return command.Container{Name: name, Image: "alpine", Cpus: "1", Memory: "1GB",
+			Network: "net0", IP: ip, Volumes: []command.Mount{{Name: "data", Directory: "/data"}}}
+	}
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{
+			cmd("net0", command.Createnetwork, command.Network{Name: "net0", Subnet: "10.1.0.0/16"}),
+			cmd("net1", command.Createnetwork, command.Network{Name: "net1", Subnet: "10.2.0.0/16"}),
+			cmd("vol", command.Createvolume, command.Volume{Name: "data"}),
+		},
+		{
+			cmd("node0", command.Createcontainer, node("node0", "10.1.0.5")),
+			cmd("node1", command.Createcontainer, node("node1", "")),
+		},
+		{
+			cmd("start0", command.Startcontainer, command.StartContainer{Name: "node0"}),
+			cmd("start1", command.Startcontainer, command.StartContainer{Name: "node1"}),
+			cmd("attach", command.Attachnetwork, command.ContainerNetwork{
+				Container: "node1", Network: "net1"}),
+		},
+	}}
+
+	docker := simulator.NewDocker()
+	exec, _ := newExecutor(docker)
+	res := execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+
+	assert.Equal(t, []string{"alpine:latest"}, docker.Images(host))
+
+	networks := docker.Networks(host)
+	require.Len(t, networks, 2)
+	assert.Equal(t, "net0", networks[0].Name)
+	assert.Equal(t, "10.1.0.1", networks[0].IPAM.Config[0].Gateway)
+	assert.Equal(t, "test0", networks[0].Labels[command.TestIDKey])
+	assert.Len(t, networks[0].Containers, 2)
+	assert.Len(t, networks[1].Containers, 1)
+
+	containers := docker.Containers(host)
+	require.Len(t, containers, 2)
+	assert.Equal(t, "/node0", containers[0].Name)
+	assert.True(t, containers[0].State.Running)
+	assert.Equal(t, "10.1.0.5", containers[0].NetworkSettings.Networks["net0"].IPAddress)
+	assert.Equal(t, "/node1", containers[1].Name)
+	assert.True(t, containers[1].State.Running)
+	assert.Equal(t, "10.1.0.2", containers[1].NetworkSettings.Networks["net0"].IPAddress)
+	assert.Equal(t, "10.2.0.2", containers[1].NetworkSettings.Networks["net1"].IPAddress)
+
+	volumes := docker.Volumes(host)
+	require.Len(t, volumes, 1)
+	assert.Equal(t, "data", volumes[0].Name)
+
+	// executing the instructions again is harmless
+	inst.Round = 0
+	res = execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+	assert.Len(t, docker.Containers(host), 2)
+	assert.Len(t, docker.Networks(host), 2)
+}
+
+func TestInstructions_Failure(t *testing.T) {
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("net0", command.Createnetwork, command.Network{Name: "net0", Subnet: "10.1.0.0/16"})},
+		{cmd("net1", command.Createnetwork, command.Network{Name: "net1", Subnet: "10.1.2.0/24"})},
+	}}
+
+	docker := simulator.NewDocker()
+	exec, _ := newExecutor(docker)
+	res := execute(exec, inst)
+	require.False(t, res.IsSuccess())
+	assert.Contains(t, res.Error.Error(), "Pool overlaps")
+	assert.Len(t, docker.Networks(host), 1)
+}
+
+// sidecarScripts makes the sidecars of the given daemons exit with the output given for their script,
+// and records the last script run by a sidecar of each container
+type sidecarScripts struct {
+	mux     sync.Mutex
+	scripts map[string]string
+}
+
+func newSidecarScripts(docker *simulator.Docker, output func(script string) simulator.Output) *sidecarScripts {
+	out := &sidecarScripts{scripts: map[string]string{}}
+	docker.SetExecHandler(func(host string, container string, cmd []string) simulator.Output {
+		if len(cmd) != 3 || cmd[0] != "/bin/sh" {
+			return simulator.Output{}
+		}
+		out.mux.Lock()
+		defer out.mux.Unlock()
+		// sidecars are named after the container whose network they manage
+		out.scripts[strings.SplitN(container, "-", 2)[0]] = cmd[2]
+		return output(cmd[2])
+	})
+	return out
+}
+
+// get gets the last script run by a sidecar of each container
+func (ss *sidecarScripts) get() map[string]string {
+	ss.mux.Lock()
+	defer ss.mux.Unlock()
+	out := map[string]string{}
+	for name, script := range ss.scripts {
+		out[name] = script
+	}
+	return out
+}
+
+func succeed(string) simulator.Output {
+	return simulator.Output{}
+}
+
+func TestInstructions_Emulation(t *testing.T) {
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("net0", command.Createnetwork, command.Network{Name: "net0", Subnet: "10.1.0.0/16"})},
+		{cmd("node0", command.Createcontainer, command.Container{Name: "node0", Image: "alpine",
+			Cpus: "1", Memory: "1GB", Network: "net0"})},
+		{cmd("start0", command.Startcontainer, command.StartContainer{Name: "node0"})},
+		{cmd("netem", command.Emulation, command.Netconf{Container: "node0", Network: "net0", Delay: 100})},
+	}}
+
+	docker := simulator.NewDocker()
+	sidecars := newSidecarScripts(docker, func(script string) simulator.Output {
+		if strings.HasSuffix(script, "tc qdisc show dev $dev") {
+			return simulator.Output{Stdout: "qdisc netem 8001: root refcnt 2 limit 1000 delay 200us\n"}
+		}
+		return simulator.Output{}
+	})
+	exec, _ := newExecutor(docker)
+	res := execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+
+	// the sidecar is removed once its script is done
+	require.Len(t, docker.Containers(host), 1)
+	assert.Contains(t, sidecars.get()["node0"], "delay 100us")
+
+	inst = command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("netem", command.Emulation, command.Netconf{Container: "node0", Network: "net0", Delay: 200})},
+	}}
+	res = execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+	require.Len(t, docker.Containers(host), 1)
+	assert.Contains(t, sidecars.get()["node0"], "tc qdisc replace")
+	assert.Contains(t, sidecars.get()["node0"], "delay 200us")
+
+	inst = command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("read", entity.ReadEmulationOrder, entity.EmulationTarget{Container: "node0", Network: "net0"})},
+		{cmd("clear", entity.ClearEmulationOrder, entity.EmulationTarget{Container: "node0", Network: "net0"})},
+	}}
+	res = execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+	require.Len(t, docker.Containers(host), 1)
+	assert.Contains(t, sidecars.get()["node0"], "tc qdisc del dev $dev root")
+
+	inst.Round = 0
+	inst.Commands = inst.Commands[:1]
+	res = execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+	outputs, ok := res.Meta[entity.OutputsKey].(map[string]interface{})
+	require.True(t, ok, res.Meta)
+	assert.Equal(t, []interface{}{map[string]interface{}{"kind": "netem", "handle": "8001:",
+		"parent": "root", "options": "refcnt 2 limit 1000 delay 200us"}}, outputs["read"])
+}
+
+func TestInstructions_PeerEmulation(t *testing.T) {
+	node := func(name string) command.Command {
+		return cmd(name, command.Createcontainer, command.Container{Name: name, Image: "alpine",
+			Cpus: "1", Memory: "1GB", Network: "net0"})
+	}
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("net0", command.Createnetwork, command.Network{Name: "net0", Subnet: "10.1.0.0/16"})},
+		{node("node0"), node("node1")},
+		{
+			cmd("start0", command.Startcontainer, command.StartContainer{Name: "node0"}),
+			cmd("start1", command.Startcontainer, command.StartContainer{Name: "node1"}),
+		},
+		{cmd("netem", entity.PeerEmulationOrder, entity.PeerEmulation{Network: "net0",
+			Matrix: map[string]map[string]command.Netconf{
+				"node0": {"node1": {Delay: 100}, "10.1.0.50": {Loss: 1}},
+				"node1": {"node0": {Delay: 200}},
+			}})},
+	}}
+
+	docker := simulator.NewDocker()
+	sidecars := newSidecarScripts(docker, succeed)
+	exec, _ := newExecutor(docker)
+	res := execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+
+	containers := docker.Containers(host)
+	require.Len(t, containers, 2)
+	ips := map[string]string{}
+	for _, cntr := range containers {
+		ips[strings.TrimPrefix(cntr.Name, "/")] = cntr.NetworkSettings.Networks["net0"].IPAddress
+	}
+	scripts := sidecars.get()
+	require.Len(t, scripts, 2)
+	assert.Contains(t, scripts["node0"], "u32 match ip dst 10.1.0.50/32")
+	assert.Contains(t, scripts["node0"], "u32 match ip dst "+ips["node1"]+"/32")
+	assert.Contains(t, scripts["node0"], "delay 100us")
+	assert.Contains(t, scripts["node1"], "u32 match ip dst "+ips["node0"]+"/32")
+	assert.Contains(t, scripts["node1"], "delay 200us")
+
+	inst = command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("netem", entity.PeerEmulationOrder, entity.PeerEmulation{Network: "net0",
+			Matrix: map[string]map[string]command.Netconf{"node0": {"node2": {Delay: 100}}}})},
+	}}
+	res = execute(exec, inst)
+	require.False(t, res.IsSuccess())
+	assert.Contains(t, res.Error.Error(), "No such container: node2")
+}
+
+func partitionInstructions(part entity.Partition) command.Instructions {
+	node := func(name string) command.Command {
+		return cmd(name, command.Createcontainer, command.Container{Name: name, Image: "alpine",
+			Cpus: "1", Memory: "1GB", Network: "net0"})
+	}
+	start := func(name string) command.Command {
+		return cmd("start-"+name, command.Startcontainer, command.StartContainer{Name: name})
+	}
+	return command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("net0", command.Createnetwork, command.Network{Name: "net0", Subnet: "10.1.0.0/16"})},
+		{node("node0"), node("node1"), node("node2")},
+		{start("node0"), start("node1"), start("node2")},
+		{cmd("partition", entity.PartitionOrder, part)},
+	}}
+}
+
+func TestInstructions_Partition(t *testing.T) {
+	part := entity.Partition{Network: "net0", Groups: [][]string{{"node0"}, {"node1"}}}
+	inst := partitionInstructions(part)
+
+	docker := simulator.NewDocker()
+	sidecars := newSidecarScripts(docker, succeed)
+	exec, _ := newExecutor(docker)
+	ip := func(name string) string {
+		cntr, ok := docker.Container(host, name)
+		require.True(t, ok)
+		return cntr.NetworkSettings.Networks["net0"].IPAddress
+	}
+
+	for i := 0; i < 2; i++ { // partitioning twice is harmless
+		inst.Round = 0
+		res := execute(exec, inst)
+		require.True(t, res.IsSuccess(), res)
+		scripts := sidecars.get()
+		require.Len(t, scripts, 2)
+		assert.Contains(t, scripts["node0"], "-d "+ip("node1")+" -j DROP")
+		assert.Contains(t, scripts["node1"], "-d "+ip("node0")+" -j DROP")
+		assert.NotContains(t, scripts["node0"], ip("node2")+" -j DROP")
+		assert.Len(t, docker.Containers(host), 3)
+	}
+
+	inst = command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("heal", entity.HealOrder, part)},
+	}}
+	res := execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+	scripts := sidecars.get()
+	require.Len(t, scripts, 2)
+	assert.Contains(t, scripts["node0"], "iptables -X GENESIS-PARTITION-$dev")
+	assert.NotContains(t, scripts["node1"], "DROP")
+}
+
+func TestInstructions_Partition_Failure(t *testing.T) {
+	inst := partitionInstructions(entity.Partition{Network: "net0", Groups: [][]string{{"node0"}, {"node1"}}})
+
+	docker := simulator.NewDocker()
+	newSidecarScripts(docker, func(string) simulator.Output {
+		return simulator.Output{ExitCode: 4,
+			Stderr: "iptables v1.8.4 (legacy): can't initialize iptables table `filter': Permission denied\n"}
+	})
+	exec, _ := newExecutor(docker)
+	res := execute(exec, inst)
+	require.False(t, res.IsSuccess())
+	assert.Contains(t, res.Error.Error(), "exited with 4")
+	assert.Contains(t, res.Error.Error(), "Permission denied")
+	assert.Len(t, docker.Containers(host), 3, "the sidecar is removed")
+}
+
+func TestInstructions_ContainerLifecycle(t *testing.T) {
+	name := command.SimpleName{Name: "node0"}
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("net0", command.Createnetwork, command.Network{Name: "net0", Subnet: "10.1.0.0/16"})},
+		{cmd("node0", command.Createcontainer, command.Container{Name: "node0", Image: "alpine",
+			Cpus: "1", Memory: "1GB", Network: "net0"})},
+		{cmd("start", command.Startcontainer, command.StartContainer{Name: "node0"})},
+		{cmd("pause", entity.PauseContainerOrder, name)},
+		{cmd("pause-again", entity.PauseContainerOrder, name)},
+	}}
+
+	docker := simulator.NewDocker()
+	exec, _ := newExecutor(docker)
+	state := func() *types.ContainerState {
+		cntr, ok := docker.Container(host, "node0")
+		require.True(t, ok)
+		return cntr.State
+	}
+	run := func(cmds ...command.Command) entity.Result {
+		inst.Commands = [][]command.Command{cmds}
+		inst.Round = 0
+		return execute(exec, inst)
+	}
+
+	res := execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+	assert.True(t, state().Paused)
+
+	require.True(t, run(cmd("unpause", entity.UnpauseContainerOrder, name)).IsSuccess())
+	assert.False(t, state().Paused)
+
+	res = run(cmd("kill", entity.KillContainerOrder, entity.KillContainer{Name: "node0", Signal: "SIGTERM"}))
+	require.True(t, res.IsSuccess(), res)
+	assert.False(t, state().Running)
+	assert.Equal(t, 143, state().ExitCode)
+
+	// the container keeps its state, unlike with removeContainer
+	require.True(t, run(cmd("restart", entity.RestartContainerOrder, entity.StopContainer{Name: "node0"})).IsSuccess())
+	assert.True(t, state().Running)
+	assert.Equal(t, "10.1.0.2", docker.Containers(host)[0].NetworkSettings.Networks["net0"].IPAddress)
+
+	require.True(t, run(cmd("stop", entity.StopContainerOrder, entity.StopContainer{Name: "node0"})).IsSuccess())
+	assert.False(t, state().Running)
+	require.True(t, run(cmd("stop", entity.StopContainerOrder, entity.StopContainer{Name: "node0"})).IsSuccess())
+
+	res = run(cmd("kill", entity.KillContainerOrder, entity.KillContainer{Name: "node0"}))
+	require.False(t, res.IsSuccess())
+	assert.Contains(t, res.Error.Error(), "is not running")
+}
+
+func TestInstructions_Exec(t *testing.T) {
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("node0", command.Createcontainer, command.Container{Name: "node0", Image: "alpine",
+			Cpus: "1", Memory: "1GB"})},
+		{cmd("start0", command.Startcontainer, command.StartContainer{Name: "node0"})},
+		{cmd("height", entity.ExecOrder, entity.ExecCommand{Container: "node0", Cmd: []string{"height"}})},
+	}}
+
+	docker := simulator.NewDocker()
+	docker.SetExecHandler(func(host string, container string, cmd []string) simulator.Output {
+		if cmd[0] == "height" {
+			return simulator.Output{Stdout: "1024\n"}
+		}
+		return simulator.Output{Stderr: "not found\n", ExitCode: 127}
+	})
+	exec, _ := newExecutor(docker)
+	res := execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+	outputs, ok := res.Meta[entity.OutputsKey].(map[string]interface{})
+	require.True(t, ok, res.Meta)
+	assert.Equal(t, map[string]interface{}{"exitCode": float64(0), "stdout": "1024\n", "stderr": "",
+		"truncated": false}, outputs["height"])
+
+	inst = command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("missing", entity.ExecOrder, entity.ExecCommand{Container: "node0", Cmd: []string{"missing"}})},
+	}}
+	res = execute(exec, inst)
+	assert.EqualError(t, res.Error, `command "missing" exited with 127 instead of 0: not found`)
+	assert.True(t, res.IsFatal(), "the command is not run again")
+}
+
+func TestInstructions_Healthy(t *testing.T) {
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("node0", command.Createcontainer, entity.Container{Container: command.Container{Name: "node0",
+			Image: "alpine", Cpus: "1", Memory: "1GB"}, Healthcheck: &entity.Healthcheck{
+			Command: []string{"CMD-SHELL", "curl -f localhost:8545"}}})},
+		{cmd("start0", command.Startcontainer, map[string]interface{}{"name": "node0", "healthy": true,
+			"timeout": "10s"})},
+	}}
+
+	docker := simulator.NewDocker()
+	docker.AddImage(host, "alpine")
+	exec, _ := newExecutor(docker)
+	go func() {
+		for {
+			cntr, ok := docker.Container(host, "node0")
+			if ok && cntr.State.Health != nil {
+				docker.SetHealth(host, "node0", types.Healthy, "ready")
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	res := execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+	cntr, ok := docker.Container(host, "node0")
+	require.True(t, ok)
+	assert.Equal(t, []string{"CMD-SHELL", "curl -f localhost:8545"}, cntr.Config.Healthcheck.Test)
+
+	require.NoError(t, docker.SetHealth(host, "node0", types.Unhealthy, "connection refused"))
+	res = execute(exec, command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("start0", command.Startcontainer, map[string]interface{}{"name": "node0", "healthy": true,
+			"timeout": "100ms"})},
+	}})
+	assert.False(t, res.IsFatal())
+	assert.EqualError(t, res.Error,
+		`container "node0" did not become healthy in time, it is unhealthy: connection refused`)
+}
+
+func TestInstructions_Attach(t *testing.T) {
+	docker := simulator.NewDocker()
+	docker.AddImage(host, "alpine")
+	exec, _ := newExecutor(docker)
+
+	// exit makes the container log and exit with the code once it is running
+	exit := func(code int) {
+		for {
+			cntr, ok := docker.Container(host, "genesis")
+			if ok && cntr.State.Running {
+				require.NoError(t, docker.Log(host, "genesis", simulator.Output{
+					Stderr: fmt.Sprintf("exiting with %d\n", code)}))
+				require.NoError(t, docker.Exit(host, "genesis", code))
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	start := func(allowFailure bool) command.Instructions {
+		return command.Instructions{ID: "test0", Commands: [][]command.Command{
+			{cmd("start0", command.Startcontainer, map[string]interface{}{"name": "genesis",
+				"attach": true, "allowFailure": allowFailure, "timeout": "10s"})},
+		}}
+	}
+
+	res := execute(exec, command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("genesis", command.Createcontainer, command.Container{Name: "genesis", Image: "alpine",
+			Cpus: "1", Memory: "1GB"})},
+	}})
+	require.True(t, res.IsSuccess(), res)
+
+	go exit(0)
+	res = execute(exec, start(false))
+	require.True(t, res.IsSuccess(), res)
+	outputs, ok := res.Meta[entity.OutputsKey].(map[string]interface{})
+	require.True(t, ok, res.Meta)
+	assert.Equal(t, map[string]interface{}{"exitCode": float64(0)}, outputs["start0"])
+
+	go exit(2)
+	res = execute(exec, start(false))
+	assert.False(t, res.IsFatal())
+	assert.EqualError(t, res.Error, "container \"genesis\" exited with 2: exiting with 0\nexiting with 2",
+		"the logs of previous runs are kept, as docker does")
+
+	go exit(2)
+	res = execute(exec, start(true))
+	assert.True(t, res.IsSuccess(), res)
+}
+
+func TestInstructions_PutFile(t *testing.T) {
+	docker := simulator.NewDocker()
+	docker.AddImage(host, "alpine")
+	exec, _ := newExecutor(docker)
+	put := func(id string, sha256 string) command.Instructions {
+		return command.Instructions{ID: "test0", Commands: [][]command.Command{
+			{cmd("put0", command.Putfileincontainer, map[string]interface{}{"container": "node0",
+				"file": map[string]interface{}{"id": id, "destination": "/etc/genesis.txt", "mode": 0644,
+					"sha256": sha256}})},
+		}}
+	}
+
+	res := execute(exec, command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("node0", command.Createcontainer, command.Container{Name: "node0", Image: "alpine",
+			Cpus: "1", Memory: "1GB"})},
+	}})
+	require.True(t, res.IsSuccess(), res)
+
+	digest := "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	res = execute(exec, put("data:;base64,aGVsbG8gd29ybGQ=", digest))
+	require.True(t, res.IsSuccess(), res)
+	outputs, ok := res.Meta[entity.OutputsKey].(map[string]interface{})
+	require.True(t, ok, res.Meta)
+	assert.Equal(t, map[string]interface{}{"sha256": digest, "size": float64(11)}, outputs["put0"])
+	data, exists := docker.File(host, "node0", "/etc/genesis.txt")
+	require.True(t, exists)
+	assert.Equal(t, "hello world", string(data))
+
+	res = execute(exec, put("data:,hello%20there", digest))
+	assert.False(t, res.IsFatal())
+	assert.Contains(t, res.Error.Error(), "sha256 mismatch: the file has sha256 ")
+	data, _ = docker.File(host, "node0", "/etc/genesis.txt")
+	assert.Equal(t, "hello world", string(data), "a file which does not match is not copied")
+
+	res = execute(exec, put("data:,hello", "hello"))
+	assert.True(t, res.IsFatal())
+}
+
+func TestInstructions_PutFile_Template(t *testing.T) {
+	node := func(name string) command.Command {
+		return cmd(name, command.Createcontainer, command.Container{Name: name, Image: "alpine",
+			Cpus: "1", Memory: "1GB", Network: "net0"})
+	}
+	docker := simulator.NewDocker()
+	docker.AddImage(host, "alpine")
+	exec, _ := newExecutor(docker)
+	res := execute(exec, command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("net0", command.Createnetwork, command.Network{Name: "net0", Subnet: "10.1.0.0/16"})},
+		{node("node0"), node("node1"), node("node2")},
+	}})
+	require.True(t, res.IsSuccess(), res)
+
+	// the containers of networks which are not of the test are not peers
+	cli := docker.Client(host)
+	_, err := cli.NetworkCreate(context.Background(), "other", types.NetworkCreate{IPAM: &network.IPAM{
+		Config: []network.IPAMConfig{{Subnet: "10.9.0.0/16"}}}})
+	require.NoError(t, err)
+	for _, name := range []string{"node0", "node1"} {
+		require.NoError(t, cli.NetworkConnect(context.Background(), "other", name, nil))
+	}
+
+	tmpl := `{{.Container.Name}} {{.Container.IP}} {{.Container.IPs.other}} {{.Labels.testRun}}` +
+		`{{range .Peers}} {{.Name}}={{.IP}}{{end}}`
+	res = execute(exec, command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("put0", command.Putfileincontainer, map[string]interface{}{"container": "node0",
+			"file": map[string]interface{}{"id": "data:," + tmpl, "destination": "/etc/peers",
+				"template": true}})},
+	}})
+	require.True(t, res.IsSuccess(), res)
+
+	ips := map[string]string{}
+	for _, name := range []string{"node0", "node1", "node2"} {
+		cntr, ok := docker.Container(host, name)
+		require.True(t, ok)
+		ips[name] = cntr.NetworkSettings.Networks["net0"].IPAddress
+	}
+	cntr, _ := docker.Container(host, "node0")
+	data, exists := docker.File(host, "node0", "/etc/peers")
+	require.True(t, exists)
+	assert.Equal(t, fmt.Sprintf("node0 %s %s test0 node1=%s node2=%s", ips["node0"],
+		cntr.NetworkSettings.Networks["other"].IPAddress, ips["node1"], ips["node2"]), string(data))
+
+	res = execute(exec, command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("put0", command.Putfileincontainer, map[string]interface{}{"container": "node0",
+			"file": map[string]interface{}{"id": "data:,{{.Peers", "destination": "/etc/peers",
+				"template": true}})},
+	}})
+	assert.True(t, res.IsFatal())
+	assert.Contains(t, res.Error.Error(), "invalid template")
+}
+
+func TestFollowLogs(t *testing.T) {
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("node0", command.Createcontainer, command.Container{Name: "node0", Image: "alpine",
+			Cpus: "1", Memory: "1GB"})},
+		{cmd("start0", command.Startcontainer, command.StartContainer{Name: "node0"})},
+	}}
+	docker := simulator.NewDocker()
+	exec, _ := newExecutor(docker)
+	res := execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+	require.NoError(t, docker.Log(host, "node0", simulator.Output{Stdout: "started\n"}))
+
+	dir, err := ioutil.TempDir("", "logs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sink, err := repository.NewFileLogSink(dir)
+	require.NoError(t, err)
+	log := logrus.New()
+	serv := service.NewDockerServiceWithClients(repository.NewDockerRepository(log),
+		config.Docker{LogLabels: "name,testRun"}, nil, docker.Dial, log)
+	logs := usecase.NewLogUseCase(config.Logs{PollInterval: 10 * time.Millisecond}, serv, sink, log)
+
+	logs.Follow(inst)
+	require.NoError(t, docker.Log(host, "node0", simulator.Output{Stderr: "stopping\n"}))
+	require.NoError(t, docker.Exit(host, "node0", 0))
+	var lines []string
+	assert.Eventually(t, func() bool {
+		data, err := ioutil.ReadFile(repository.LogPath(dir, "test0", "node0"))
+		lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+		return err == nil && len(lines) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	logs.Stop("test0")
+	require.NoError(t, logs.Close())
+
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"labels":{"name":"node0","testRun":"test0"},"stream":"stdout"`)
+	assert.Contains(t, lines[1], `"stream":"stderr"`)
+	assert.Contains(t, lines[1], `"line":"stopping"`)
+}
+
+func TestRemoveTestResources(t *testing.T) {
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{
+			cmd("net0", command.Createnetwork, command.Network{Name: "net0", Subnet: "10.1.0.0/16"}),
+			cmd("vol", command.Createvolume, command.Volume{Name: "data"}),
+		},
+		{cmd("node0", command.Createcontainer, command.Container{Name: "node0", Image: "alpine",
+			Cpus: "1", Memory: "1GB", Network: "net0",
+			Volumes: []command.Mount{{Name: "data", Directory: "/data"}}})},
+		{cmd("start0", command.Startcontainer, command.StartContainer{Name: "node0"})},
+	}}
+
+	docker := simulator.NewDocker()
+	exec, serv := newExecutor(docker)
+	res := execute(exec, inst)
+	require.True(t, res.IsSuccess(), res)
+
+	cli := entity.DockerCli{Client: docker.Client(host)}
+	found, err := serv.ListTestResources(context.Background(), cli, "test0")
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, []string{"node0"}, found[0].Containers)
+	assert.Equal(t, []string{"net0"}, found[0].Networks)
+	assert.Equal(t, []string{"data"}, found[0].Volumes)
+
+	// net1 does not exist, which is fine
+	found[0].Networks = append(found[0].Networks, "net1")
+
+	res = serv.RemoveTestResources(context.Background(), cli, found[0])
+	require.True(t, res.IsSuccess(), res)
+	assert.Empty(t, docker.Containers(host))
+	assert.Empty(t, docker.Networks(host))
+	assert.Empty(t, docker.Volumes(host))
+}
+
+func TestInstructions_RecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recordings")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	recordings, err := repository.NewRecordingRepository(dir)
+	require.NoError(t, err)
+
+	inst := command.Instructions{ID: "test0", Commands: [][]command.Command{
+		{cmd("net0", command.Createnetwork, command.Network{Name: "net0", Subnet: "10.1.0.0/16"})},
+		{
+			cmd("node0", command.Createcontainer, command.Container{Name: "node0", Image: "alpine",
+				Cpus: "1", Memory: "1GB", Network: "net0"}),
+			cmd("node1", command.Createcontainer, command.Container{Name: "node1", Image: "alpine",
+				Cpus: "1", Memory: "1GB", Network: "net0"}),
+		},
+		{cmd("attach", command.Attachnetwork, command.ContainerNetwork{Container: "node0", Network: "net0"})},
+		{cmd("net1", command.Createnetwork, command.Network{Name: "net1", Subnet: "10.1.2.0/24"})},
+	}}
+
+	docker := simulator.NewDocker()
+	log := logrus.New()
+	exec := auxillary.NewExecutor(config.Execution{LimitPerTest: 10, ConnectionRetries: 1,
+		TimeLimit: 10 * time.Second}, usecase.NewDockerUseCase(service.NewDockerServiceWithClients(
+		repository.NewDockerRepository(log), config.Docker{}, nil, func(host string) (entity.Client, error) {
+			return service.NewRecordingClient(docker.Client(host), host, "test0", recordings, log), nil
+		}, log), log), log)
+	recorded := execute(exec, inst)
+	require.False(t, recorded.IsSuccess())
+	assert.Contains(t, recorded.Error.Error(), "Pool overlaps")
+
+	calls, err := recordings.Load("test0")
+	require.NoError(t, err)
+	replayer, err := service.NewReplayer(calls)
+	require.NoError(t, err)
+
+	exec = auxillary.NewExecutor(config.Execution{LimitPerTest: 10, ConnectionRetries: 1,
+		TimeLimit: 10 * time.Second}, usecase.NewDockerUseCase(service.NewReplayingDockerService(
+		repository.NewDockerRepository(log), config.Docker{}, nil, replayer, log), log), log)
+	replayed := execute(exec, inst)
+	require.False(t, replayed.IsSuccess())
+	assert.Equal(t, recorded.Error.Error(), replayed.Error.Error())
+	assert.Empty(t, replayer.Unused())
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/metrics"
 	"github.com/whiteblock/genesis/pkg/service"
 	"github.com/whiteblock/genesis/pkg/validator"
 
@@ -50,13 +51,30 @@ var (
 	// ErrInvalidTargetIP target IP is not a dest IP or is malformed
 	ErrInvalidTargetIP = entity.NewFatalResult("invalid target ip")
 
+	// ErrAttachHealthy both attach and healthy were given to start a container
+	ErrAttachHealthy = entity.NewFatalResult("attach and healthy cannot both be set")
+
 	// ErrUnknownCommandType the given command is of an unknown type
 	ErrUnknownCommandType = entity.NewFatalResult("unknown command type")
+
+	// ErrEmptyFieldTestID missing a test id, both in the payload and the meta
+	ErrEmptyFieldTestID = entity.NewFatalResult("empty field \"testID\"")
+
+	// ErrEmptyFieldMatrix missing a matrix field, or a container in it
+	ErrEmptyFieldMatrix = entity.NewFatalResult("empty field \"matrix\"")
+
+	// ErrEmptyFieldGroups missing a groups field, or a container in it
+	ErrEmptyFieldGroups = entity.NewFatalResult("empty field \"groups\"")
+
+	// ErrEmptyFieldCmd missing a cmd field
+	ErrEmptyFieldCmd = entity.NewFatalResult("empty field \"cmd\"")
 )
 
 type dockerUseCase struct {
 	service service.DockerService
 	log     logrus.Ext1FieldLogger
+	// planning is true if the orders are only being planned, and so should not be observed
+	planning bool
 }
 
 //NewDockerUseCase creates a DockerUseCase arguments given the proper dep injections
@@ -139,8 +157,15 @@ func (duc dockerUseCase) diagnoseConnIssue(ctx context.Context, cli entity.Clien
 }
 
 // Execute executes the command with the given context
-func (duc dockerUseCase) Execute(ctx context.Context, cmd command.Command) entity.Result {
-	cli, err := duc.service.CreateClient(cmd.Target.IP)
+func (duc dockerUseCase) Execute(ctx context.Context, cmd command.Command) (res entity.Result) {
+	orderType := command.OrderType(strings.ToLower(string(cmd.Order.Type)))
+	defer func() {
+		if !duc.planning {
+			metrics.ObserveOrder(orderType, res)
+		}
+	}()
+
+	cli, err := duc.service.CreateClient(cmd.Target.IP, cmd.Meta[command.TestIDKey])
 	if err != nil {
 		duc.withField(cmd, "dest", cmd.Target.IP).Error("failed to create a client")
 		return entity.NewFatalResult(err)
@@ -152,13 +177,25 @@ func (duc dockerUseCase) Execute(ctx context.Context, cmd command.Command) entit
 	}()
 	duc.withField(cmd, "client", cli).Trace("created a client")
 	duc.withField(cmd, "type", cmd.Order.Type).Trace("routing a command")
-	switch command.OrderType(strings.ToLower(string(cmd.Order.Type))) {
+	switch orderType {
 	case command.Createcontainer:
 		return duc.createContainerShim(ctx, cli, cmd)
 	case command.Startcontainer:
 		return duc.startContainerShim(ctx, cli, cmd)
 	case command.Removecontainer:
 		return duc.removeContainerShim(ctx, cli, cmd)
+	case entity.StopContainerOrder:
+		return duc.stopContainerShim(ctx, cli, cmd)
+	case entity.RestartContainerOrder:
+		return duc.restartContainerShim(ctx, cli, cmd)
+	case entity.KillContainerOrder:
+		return duc.killContainerShim(ctx, cli, cmd)
+	case entity.PauseContainerOrder:
+		return duc.pauseContainerShim(ctx, cli, cmd)
+	case entity.UnpauseContainerOrder:
+		return duc.unpauseContainerShim(ctx, cli, cmd)
+	case entity.ExecOrder:
+		return duc.execShim(ctx, cli, cmd)
 	case command.Createnetwork:
 		return duc.createNetworkShim(ctx, cli, cmd)
 	case command.Attachnetwork:
@@ -176,7 +213,7 @@ func (duc dockerUseCase) Execute(ctx context.Context, cmd command.Command) entit
 	case command.Emulation:
 		return duc.emulationShim(ctx, cli, cmd)
 	case command.SwarmInit:
-		res := duc.swarmSetupShim(ctx, cli, cmd)
+		res = duc.swarmSetupShim(ctx, cli, cmd)
 		if !res.IsSuccess() {
 			duc.diagnoseConnIssue(ctx, cli, cmd)
 		}
@@ -189,6 +226,18 @@ func (duc dockerUseCase) Execute(ctx context.Context, cmd command.Command) entit
 		return duc.pauseExecutionShim(ctx, cli, cmd)
 	case command.Resumeexecution:
 		return duc.resumeExecutionShim(ctx, cli, cmd)
+	case entity.DestroyTestOrder:
+		return duc.destroyTestShim(ctx, cli, cmd)
+	case entity.ClearEmulationOrder:
+		return duc.clearEmulationShim(ctx, cli, cmd)
+	case entity.ReadEmulationOrder:
+		return duc.readEmulationShim(ctx, cli, cmd)
+	case entity.PeerEmulationOrder:
+		return duc.peerEmulationShim(ctx, cli, cmd)
+	case entity.PartitionOrder:
+		return duc.partitionShim(ctx, cli, cmd)
+	case entity.HealOrder:
+		return duc.healShim(ctx, cli, cmd)
 	}
 	return ErrUnknownCommandType.InjectMeta(map[string]interface{}{"type": cmd.Order.Type})
 }
@@ -215,15 +264,21 @@ func (duc dockerUseCase) injectLabels(cli entity.Client, cmd command.Command) en
 func (duc dockerUseCase) createContainerShim(ctx context.Context, cli entity.Client,
 	cmd command.Command) entity.Result {
 
-	var container command.Container
+	var container entity.Container
 	err := cmd.ParseOrderPayloadInto(&container)
 	if err != nil {
 		return entity.NewFatalResult(err)
 	}
-	err = validator.Container(container)
+	err = validator.Container(container.Container)
 	if err != nil {
 		return entity.NewFatalResult(err)
 	}
+	if container.Healthcheck != nil {
+		err = validator.Healthcheck(*container.Healthcheck)
+		if err != nil {
+			return entity.NewFatalResult(err)
+		}
+	}
 
 	docker := duc.injectLabels(cli, cmd)
 	err = mergo.Map(&docker.Labels, container.Labels)
@@ -239,7 +294,7 @@ func (duc dockerUseCase) createContainerShim(ctx context.Context, cli entity.Cli
 func (duc dockerUseCase) startContainerShim(ctx context.Context, cli entity.Client,
 	cmd command.Command) entity.Result {
 
-	var sc command.StartContainer
+	var sc entity.StartContainer
 	err := cmd.ParseOrderPayloadInto(&sc)
 	if err != nil {
 		return entity.NewFatalResult(err)
@@ -247,6 +302,9 @@ func (duc dockerUseCase) startContainerShim(ctx context.Context, cli entity.Clie
 	if len(sc.Name) == 0 {
 		return ErrEmptyFieldName
 	}
+	if sc.Healthy && sc.Attach {
+		return ErrAttachHealthy
+	}
 	return duc.service.StartContainer(ctx, duc.injectLabels(cli, cmd), sc)
 }
 
@@ -264,6 +322,97 @@ func (duc dockerUseCase) removeContainerShim(ctx context.Context, cli entity.Cli
 	return duc.service.RemoveContainer(ctx, duc.injectLabels(cli, cmd), payload.Name)
 }
 
+func (duc dockerUseCase) parseStopContainer(cmd command.Command) (entity.StopContainer, entity.Result) {
+	var payload entity.StopContainer
+	err := cmd.ParseOrderPayloadInto(&payload)
+	if err != nil {
+		return payload, entity.NewFatalResult(err)
+	}
+	if payload.Name == "" {
+		return payload, ErrEmptyFieldName
+	}
+	return payload, entity.NewSuccessResult()
+}
+
+func (duc dockerUseCase) stopContainerShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	payload, res := duc.parseStopContainer(cmd)
+	if !res.IsSuccess() {
+		return res
+	}
+	return duc.service.StopContainer(ctx, duc.injectLabels(cli, cmd), payload)
+}
+
+func (duc dockerUseCase) restartContainerShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	payload, res := duc.parseStopContainer(cmd)
+	if !res.IsSuccess() {
+		return res
+	}
+	return duc.service.RestartContainer(ctx, duc.injectLabels(cli, cmd), payload)
+}
+
+func (duc dockerUseCase) killContainerShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	var payload entity.KillContainer
+	err := cmd.ParseOrderPayloadInto(&payload)
+	if err != nil {
+		return entity.NewFatalResult(err)
+	}
+	if payload.Name == "" {
+		return ErrEmptyFieldName
+	}
+	return duc.service.KillContainer(ctx, duc.injectLabels(cli, cmd), payload)
+}
+
+func (duc dockerUseCase) pauseContainerShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	var payload command.SimpleName
+	err := cmd.ParseOrderPayloadInto(&payload)
+	if err != nil {
+		return entity.NewFatalResult(err)
+	}
+	if payload.Name == "" {
+		return ErrEmptyFieldName
+	}
+	return duc.service.PauseContainer(ctx, duc.injectLabels(cli, cmd), payload.Name)
+}
+
+func (duc dockerUseCase) unpauseContainerShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	var payload command.SimpleName
+	err := cmd.ParseOrderPayloadInto(&payload)
+	if err != nil {
+		return entity.NewFatalResult(err)
+	}
+	if payload.Name == "" {
+		return ErrEmptyFieldName
+	}
+	return duc.service.UnpauseContainer(ctx, duc.injectLabels(cli, cmd), payload.Name)
+}
+
+func (duc dockerUseCase) execShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	var payload entity.ExecCommand
+	err := cmd.ParseOrderPayloadInto(&payload)
+	if err != nil {
+		return entity.NewFatalResult(err)
+	}
+	if payload.Container == "" {
+		return ErrEmptyFieldContainer
+	}
+	if len(payload.Cmd) == 0 {
+		return ErrEmptyFieldCmd
+	}
+	return duc.service.Exec(ctx, duc.injectLabels(cli, cmd), payload)
+}
+
 func (duc dockerUseCase) createNetworkShim(ctx context.Context, cli entity.Client,
 	cmd command.Command) entity.Result {
 	var net command.Network
@@ -358,7 +507,7 @@ func (duc dockerUseCase) removeVolumeShim(ctx context.Context, cli entity.Client
 func (duc dockerUseCase) putFileInContainerShim(ctx context.Context, cli entity.Client,
 	cmd command.Command) entity.Result {
 
-	var payload command.FileAndContainer
+	var payload entity.FileAndContainer
 	err := cmd.ParseOrderPayloadInto(&payload)
 	if err != nil {
 		return entity.NewFatalResult(err)
@@ -366,6 +515,10 @@ func (duc dockerUseCase) putFileInContainerShim(ctx context.Context, cli entity.
 	if len(payload.ContainerName) == 0 {
 		return ErrEmptyFieldContainer
 	}
+	err = validator.File(payload.File)
+	if err != nil {
+		return entity.NewFatalResult(err)
+	}
 	return duc.service.PlaceFileInContainer(ctx, duc.injectLabels(cli, cmd),
 		payload.ContainerName, payload.File)
 }
@@ -381,6 +534,113 @@ func (duc dockerUseCase) emulationShim(ctx context.Context, cli entity.Client,
 	return duc.service.Emulation(ctx, duc.injectLabels(cli, cmd), payload)
 }
 
+func (duc dockerUseCase) parseEmulationTarget(cmd command.Command) (entity.EmulationTarget, entity.Result) {
+	var payload entity.EmulationTarget
+	err := cmd.ParseOrderPayloadInto(&payload)
+	if err != nil {
+		return payload, entity.NewFatalResult(err)
+	}
+	if len(payload.Container) == 0 {
+		return payload, ErrEmptyFieldContainer
+	}
+	if len(payload.Network) == 0 {
+		return payload, ErrEmptyFieldNetwork
+	}
+	return payload, entity.NewSuccessResult()
+}
+
+func (duc dockerUseCase) clearEmulationShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	payload, res := duc.parseEmulationTarget(cmd)
+	if !res.IsSuccess() {
+		return res
+	}
+	return duc.service.ClearEmulation(ctx, duc.injectLabels(cli, cmd), payload)
+}
+
+func (duc dockerUseCase) readEmulationShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	payload, res := duc.parseEmulationTarget(cmd)
+	if !res.IsSuccess() {
+		return res
+	}
+	return duc.service.ReadEmulation(ctx, duc.injectLabels(cli, cmd), payload)
+}
+
+func (duc dockerUseCase) peerEmulationShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	var payload entity.PeerEmulation
+	err := cmd.ParseOrderPayloadInto(&payload)
+	if err != nil {
+		return entity.NewFatalResult(err)
+	}
+	if len(payload.Network) == 0 {
+		return ErrEmptyFieldNetwork
+	}
+	if len(payload.Matrix) == 0 {
+		return ErrEmptyFieldMatrix
+	}
+	for name, peers := range payload.Matrix {
+		if len(name) == 0 || len(peers) == 0 {
+			return ErrEmptyFieldMatrix
+		}
+	}
+	return duc.service.PeerEmulation(ctx, duc.injectLabels(cli, cmd), payload)
+}
+
+func (duc dockerUseCase) parsePartition(cmd command.Command) (entity.Partition, entity.Result) {
+	var payload entity.Partition
+	err := cmd.ParseOrderPayloadInto(&payload)
+	if err != nil {
+		return payload, entity.NewFatalResult(err)
+	}
+	if len(payload.Network) == 0 {
+		return payload, ErrEmptyFieldNetwork
+	}
+	if len(payload.Groups) == 0 {
+		return payload, ErrEmptyFieldGroups
+	}
+	seen := map[string]bool{}
+	for _, group := range payload.Groups {
+		for _, name := range group {
+			if len(name) == 0 {
+				return payload, ErrEmptyFieldGroups
+			}
+			if seen[name] {
+				return payload, entity.NewFatalResult(fmt.Errorf("container %s is in more than one group", name))
+			}
+			seen[name] = true
+		}
+	}
+	return payload, entity.NewSuccessResult()
+}
+
+func (duc dockerUseCase) partitionShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	payload, res := duc.parsePartition(cmd)
+	if !res.IsSuccess() {
+		return res
+	}
+	if len(payload.Groups) < 2 {
+		return entity.NewFatalResult("a partition needs at least two groups")
+	}
+	return duc.service.Partition(ctx, duc.injectLabels(cli, cmd), payload)
+}
+
+func (duc dockerUseCase) healShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	payload, res := duc.parsePartition(cmd)
+	if !res.IsSuccess() {
+		return res
+	}
+	return duc.service.Heal(ctx, duc.injectLabels(cli, cmd), payload)
+}
+
 func (duc dockerUseCase) swarmSetupShim(ctx context.Context, cli entity.Client,
 	cmd command.Command) entity.Result {
 
@@ -409,6 +669,38 @@ func (duc dockerUseCase) pullImageShim(ctx context.Context, cli entity.Client,
 	return duc.service.PullImage(ctx, duc.injectLabels(cli, cmd), payload)
 }
 
+func (duc dockerUseCase) destroyTestShim(ctx context.Context, cli entity.Client,
+	cmd command.Command) entity.Result {
+
+	var payload entity.DestroyTest
+	err := cmd.ParseOrderPayloadInto(&payload)
+	if err != nil {
+		return entity.NewFatalResult(err)
+	}
+	if len(payload.TestID) == 0 {
+		payload.TestID = cmd.Meta[command.TestIDKey]
+	}
+	if len(payload.TestID) == 0 {
+		return ErrEmptyFieldTestID
+	}
+	docker := duc.injectLabels(cli, cmd)
+	found, err := duc.service.ListTestResources(ctx, docker, payload.TestID)
+	if err != nil {
+		return entity.NewErrorResult(err)
+	}
+	res := entity.NewTestResources(payload.TestID)
+	if len(found) > 0 {
+		res = found[0]
+	}
+	res.Host = cmd.Target.IP
+	if payload.DryRun {
+		duc.withField(cmd, "resources", res).Info("dry run, not removing the resources of the test")
+		return entity.NewSuccessResult().InjectMeta(map[string]interface{}{"resources": res})
+	}
+	return duc.service.RemoveTestResources(ctx, docker, res).InjectMeta(
+		map[string]interface{}{"resources": res})
+}
+
 func (duc dockerUseCase) volumeShareShim(ctx context.Context, cli entity.Client,
 	cmd command.Command) entity.Result {
 
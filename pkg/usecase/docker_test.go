@@ -10,6 +10,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	mockService "github.com/whiteblock/genesis/mocks/pkg/service"
 	"github.com/whiteblock/genesis/pkg/entity"
@@ -179,6 +180,85 @@ func TestDockerUseCase_Execute_StartContainer_Failure_EmptyName(t *testing.T) {
 	service.AssertExpectations(t)
 }
 
+func TestDockerUseCase_Execute_CreateContainer_Healthcheck(t *testing.T) {
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil).Twice()
+	service.On("CreateContainer", mock.Anything, mock.Anything, mock.MatchedBy(
+		func(cntr entity.Container) bool {
+			return cntr.Name == "foo" && cntr.Healthcheck != nil &&
+				cntr.Healthcheck.StartPeriod.Duration == 10*time.Second
+		})).Return(entity.NewSuccessResult()).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+
+	payload := map[string]interface{}{"name": "foo", "image": "bar", "cpus": "2.0", "memory": "2GB",
+		"healthcheck": map[string]interface{}{"command": []string{"CMD-SHELL", "curl -f localhost:8545"},
+			"startPeriod": "10s", "retries": 3}}
+	res := usecase.Execute(context.TODO(), command.Command{
+		ID:     "TEST",
+		Target: testTarget,
+		Order:  command.Order{Type: command.Createcontainer, Payload: payload},
+	})
+	assert.NoError(t, res.Error)
+
+	payload["healthcheck"] = map[string]interface{}{"retries": 3}
+	res = usecase.Execute(context.TODO(), command.Command{
+		ID:     "TEST",
+		Target: testTarget,
+		Order:  command.Order{Type: command.Createcontainer, Payload: payload},
+	})
+	assert.True(t, res.IsFatal())
+	service.AssertExpectations(t)
+}
+
+func TestDockerUseCase_Execute_StartContainer_Healthy(t *testing.T) {
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil).Twice()
+	service.On("StartContainer", mock.Anything, mock.Anything, mock.MatchedBy(
+		func(sc entity.StartContainer) bool {
+			return sc.Name == "test" && sc.Healthy && sc.Timeout.Duration == time.Minute
+		})).Return(entity.NewErrorResult("not healthy")).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+
+	for _, tc := range []struct {
+		payload map[string]interface{}
+		fatal   bool
+	}{
+		{payload: map[string]interface{}{"name": "test", "healthy": true, "timeout": "1m"}},
+		{payload: map[string]interface{}{"name": "test", "healthy": true, "attach": true}, fatal: true},
+	} {
+		res := usecase.Execute(context.TODO(), command.Command{
+			ID:     "TEST",
+			Target: testTarget,
+			Order:  command.Order{Type: command.Startcontainer, Payload: tc.payload},
+		})
+		assert.Error(t, res.Error)
+		assert.Equal(t, tc.fatal, res.IsFatal())
+	}
+	service.AssertExpectations(t)
+}
+
+func TestDockerUseCase_Execute_StartContainer_AllowFailure(t *testing.T) {
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil).Once()
+	service.On("StartContainer", mock.Anything, mock.Anything, mock.MatchedBy(
+		func(sc entity.StartContainer) bool {
+			return sc.Name == "test" && sc.Attach && sc.AllowFailure
+		})).Return(entity.NewSuccessResult()).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+
+	res := usecase.Execute(context.TODO(), command.Command{
+		ID:     "TEST",
+		Target: testTarget,
+		Order: command.Order{Type: command.Startcontainer, Payload: map[string]interface{}{
+			"name": "test", "attach": true, "allowFailure": true}},
+	})
+	assert.NoError(t, res.Error)
+	service.AssertExpectations(t)
+}
+
 func TestDockerUseCase_Execute_RemoveContainer_Failure_EmptyName(t *testing.T) {
 	service := new(mockService.DockerService)
 	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil).Once()
@@ -656,7 +736,7 @@ func TestDockerUseCase_Execute_PutFileInContainer_Success(t *testing.T) {
 			assert.NotNil(t, args.Get(0))
 			assert.NotNil(t, args.Get(1))
 			assert.Equal(t, containerName, args.String(2))
-			file, ok := args.Get(3).(command.File)
+			file, ok := args.Get(3).(entity.File)
 			require.True(t, ok)
 			assert.Equal(t, int64(0777), file.Mode)
 			assert.Equal(t, mockFile["destination"], file.Destination)
@@ -682,6 +762,31 @@ func TestDockerUseCase_Execute_PutFileInContainer_Success(t *testing.T) {
 	service.AssertExpectations(t)
 }
 
+func TestDockerUseCase_Execute_PutFileInContainer_Headers(t *testing.T) {
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil)
+	service.On("PlaceFileInContainer", mock.Anything, mock.Anything, "tester", entity.File{
+		File:    command.File{Destination: "/opt/genesis.json", ID: "https://example.com/genesis.json"},
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	}).Return(entity.NewSuccessResult()).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+	res := usecase.Execute(context.TODO(), command.Command{
+		ID:     "TEST",
+		Target: testTarget,
+		Order: command.Order{
+			Type: command.Putfileincontainer,
+			Payload: map[string]interface{}{"container": "tester", "file": map[string]interface{}{
+				"destination": "/opt/genesis.json",
+				"id":          "https://example.com/genesis.json",
+				"headers":     map[string]string{"Authorization": "Bearer token"},
+			}},
+		},
+	})
+	assert.NoError(t, res.Error)
+	service.AssertExpectations(t)
+}
+
 func TestDockerUseCase_putFileInContainerShim_MissingFields(t *testing.T) {
 	service := new(mockService.DockerService)
 	service.On("PlaceFileInContainer", mock.Anything, mock.Anything, mock.Anything,
@@ -757,6 +862,186 @@ func TestDockerUseCase_Execute_Emulation_Failure(t *testing.T) {
 	service.AssertExpectations(t)
 }
 
+func TestDockerUseCase_Execute_ClearEmulation(t *testing.T) {
+	target := entity.EmulationTarget{Container: "node0", Network: "net0"}
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil).Once()
+	service.On("ClearEmulation", mock.Anything, mock.Anything, target).Return(
+		entity.NewSuccessResult()).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+
+	res := usecase.Execute(context.TODO(), command.Command{
+		ID:     "TEST",
+		Target: testTarget,
+		Order:  command.Order{Type: entity.ClearEmulationOrder, Payload: target},
+	})
+	assert.NoError(t, res.Error)
+	service.AssertExpectations(t)
+}
+
+func TestDockerUseCase_Execute_ReadEmulation(t *testing.T) {
+	target := entity.EmulationTarget{Container: "node0", Network: "net0"}
+	qdiscs := []entity.Qdisc{{Kind: "netem", Handle: "8001:", Parent: "root", Options: "delay 100us"}}
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil).Once()
+	service.On("ReadEmulation", mock.Anything, mock.Anything, target).Return(
+		entity.NewSuccessResult().InjectMeta(map[string]interface{}{entity.OutputKey: qdiscs})).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+
+	res := usecase.Execute(context.TODO(), command.Command{
+		ID:     "TEST",
+		Target: testTarget,
+		Order:  command.Order{Type: entity.ReadEmulationOrder, Payload: target},
+	})
+	assert.NoError(t, res.Error)
+	assert.Contains(t, res.Meta, entity.OutputKey)
+	service.AssertExpectations(t)
+}
+
+func TestDockerUseCase_Execute_ReadEmulation_Failure(t *testing.T) {
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil)
+
+	usecase := NewDockerUseCase(service, logrus.New())
+
+	res := usecase.Execute(context.TODO(), command.Command{
+		ID:     "TEST",
+		Target: testTarget,
+		Order: command.Order{Type: entity.ReadEmulationOrder,
+			Payload: entity.EmulationTarget{Network: "net0"}},
+	})
+	assert.Equal(t, ErrEmptyFieldContainer, res)
+
+	res = usecase.Execute(context.TODO(), command.Command{
+		ID:     "TEST",
+		Target: testTarget,
+		Order: command.Order{Type: entity.ClearEmulationOrder,
+			Payload: entity.EmulationTarget{Container: "node0"}},
+	})
+	assert.Equal(t, ErrEmptyFieldNetwork, res)
+	service.AssertExpectations(t)
+}
+
+func TestDockerUseCase_Execute_PeerEmulation(t *testing.T) {
+	payload := entity.PeerEmulation{Network: "net0", Matrix: map[string]map[string]command.Netconf{
+		"node0": {"node1": {Delay: 100}}}}
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil)
+	service.On("PeerEmulation", mock.Anything, mock.Anything, payload).Return(
+		entity.NewSuccessResult()).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+	execute := func(payload entity.PeerEmulation) entity.Result {
+		return usecase.Execute(context.TODO(), command.Command{
+			ID:     "TEST",
+			Target: testTarget,
+			Order:  command.Order{Type: entity.PeerEmulationOrder, Payload: payload},
+		})
+	}
+
+	assert.NoError(t, execute(payload).Error)
+	assert.Equal(t, ErrEmptyFieldNetwork, execute(entity.PeerEmulation{Matrix: payload.Matrix}))
+	assert.Equal(t, ErrEmptyFieldMatrix, execute(entity.PeerEmulation{Network: "net0"}))
+	assert.Equal(t, ErrEmptyFieldMatrix, execute(entity.PeerEmulation{Network: "net0",
+		Matrix: map[string]map[string]command.Netconf{"node0": {}}}))
+	service.AssertExpectations(t)
+}
+
+func TestDockerUseCase_Execute_Partition(t *testing.T) {
+	part := entity.Partition{Network: "net0", Groups: [][]string{{"node0", "node1"}, {"node2"}}}
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil)
+	service.On("Partition", mock.Anything, mock.Anything, part).Return(entity.NewSuccessResult()).Once()
+	service.On("Heal", mock.Anything, mock.Anything, part).Return(entity.NewSuccessResult()).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+	execute := func(orderType command.OrderType, payload entity.Partition) entity.Result {
+		return usecase.Execute(context.TODO(), command.Command{
+			ID:     "TEST",
+			Target: testTarget,
+			Order:  command.Order{Type: orderType, Payload: payload},
+		})
+	}
+
+	assert.NoError(t, execute(entity.PartitionOrder, part).Error)
+	assert.NoError(t, execute(entity.HealOrder, part).Error)
+	assert.Equal(t, ErrEmptyFieldNetwork, execute(entity.PartitionOrder, entity.Partition{Groups: part.Groups}))
+	assert.Equal(t, ErrEmptyFieldGroups, execute(entity.HealOrder, entity.Partition{Network: "net0"}))
+
+	res := execute(entity.PartitionOrder, entity.Partition{Network: "net0", Groups: [][]string{{"node0"}}})
+	assert.Equal(t, entity.FatalType, res.Type)
+	res = execute(entity.PartitionOrder, entity.Partition{Network: "net0",
+		Groups: [][]string{{"node0"}, {"node1", "node0"}}})
+	assert.EqualError(t, res.Error, "container node0 is in more than one group")
+	service.AssertExpectations(t)
+}
+
+func TestDockerUseCase_Execute_ContainerLifecycle(t *testing.T) {
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil)
+	service.On("StopContainer", mock.Anything, mock.Anything, mock.MatchedBy(
+		func(sc entity.StopContainer) bool {
+			return sc.Name == "node0" && sc.Timeout.Duration == 5*time.Second
+		})).Return(entity.NewSuccessResult()).Once()
+	service.On("RestartContainer", mock.Anything, mock.Anything, mock.MatchedBy(
+		func(sc entity.StopContainer) bool {
+			return sc.Name == "node0" && sc.Timeout.Empty()
+		})).Return(entity.NewSuccessResult()).Once()
+	service.On("KillContainer", mock.Anything, mock.Anything,
+		entity.KillContainer{Name: "node0", Signal: "SIGTERM"}).Return(entity.NewSuccessResult()).Once()
+	service.On("PauseContainer", mock.Anything, mock.Anything, "node0").Return(entity.NewSuccessResult()).Once()
+	service.On("UnpauseContainer", mock.Anything, mock.Anything, "node0").Return(entity.NewSuccessResult()).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+	execute := func(orderType command.OrderType, payload interface{}) entity.Result {
+		return usecase.Execute(context.TODO(), command.Command{
+			ID:     "TEST",
+			Target: testTarget,
+			Order:  command.Order{Type: orderType, Payload: payload},
+		})
+	}
+
+	assert.NoError(t, execute(entity.StopContainerOrder,
+		map[string]interface{}{"name": "node0", "timeout": "5s"}).Error)
+	assert.NoError(t, execute(entity.RestartContainerOrder, map[string]interface{}{"name": "node0"}).Error)
+	assert.NoError(t, execute(entity.KillContainerOrder,
+		entity.KillContainer{Name: "node0", Signal: "SIGTERM"}).Error)
+	assert.NoError(t, execute(entity.PauseContainerOrder, command.SimpleName{Name: "node0"}).Error)
+	assert.NoError(t, execute(entity.UnpauseContainerOrder, command.SimpleName{Name: "node0"}).Error)
+
+	for _, orderType := range []command.OrderType{entity.StopContainerOrder, entity.RestartContainerOrder,
+		entity.KillContainerOrder, entity.PauseContainerOrder, entity.UnpauseContainerOrder} {
+		assert.Equal(t, ErrEmptyFieldName, execute(orderType, map[string]interface{}{}), orderType)
+	}
+	service.AssertExpectations(t)
+}
+
+func TestDockerUseCase_Execute_Exec(t *testing.T) {
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil)
+	service.On("Exec", mock.Anything, mock.Anything, mock.MatchedBy(func(ec entity.ExecCommand) bool {
+		return ec.Container == "node0" && ec.Cmd[0] == "ls" && ec.Env["A"] == "1" &&
+			ec.Timeout.Duration == 10*time.Second && ec.ExitCode == 2
+	})).Return(entity.NewSuccessResult()).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+	execute := func(payload interface{}) entity.Result {
+		return usecase.Execute(context.TODO(), command.Command{
+			ID:     "TEST",
+			Target: testTarget,
+			Order:  command.Order{Type: entity.ExecOrder, Payload: payload},
+		})
+	}
+
+	assert.NoError(t, execute(map[string]interface{}{"container": "node0", "cmd": []string{"ls", "/data"},
+		"env": map[string]string{"A": "1"}, "timeout": "10s", "exitCode": 2}).Error)
+	assert.Equal(t, ErrEmptyFieldContainer, execute(map[string]interface{}{"cmd": []string{"ls"}}))
+	assert.Equal(t, ErrEmptyFieldCmd, execute(map[string]interface{}{"container": "node0"}))
+	service.AssertExpectations(t)
+}
+
 func TestDockerUseCase_Execute_UnknownType_Failure(t *testing.T) {
 	service := new(mockService.DockerService)
 	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil).Once()
@@ -837,3 +1122,60 @@ func TestDockerUseCase_Execute_PutFileInContainer_NoName_Fail(t *testing.T) {
 	assert.Error(t, res.Error)
 	service.AssertExpectations(t)
 }
+
+func TestDockerUseCase_Execute_DestroyTest(t *testing.T) {
+	found := entity.NewTestResources("test")
+	found.Containers = []string{"node0"}
+
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil).Twice()
+	service.On("ListTestResources", mock.Anything, mock.Anything, "test").Return(
+		[]entity.TestResources{found}, nil).Twice()
+	service.On("RemoveTestResources", mock.Anything, mock.Anything, mock.Anything).Return(
+		entity.NewSuccessResult()).Run(func(args mock.Arguments) {
+		res, ok := args.Get(2).(entity.TestResources)
+		require.True(t, ok)
+		assert.Equal(t, []string{"node0"}, res.Containers)
+		assert.Equal(t, testTarget.IP, res.Host)
+	}).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+
+	cmd := command.Command{
+		ID:     "TEST",
+		Target: testTarget,
+		Meta:   map[string]string{command.TestIDKey: "test"},
+		Order: command.Order{
+			Type:    "destroyTest",
+			Payload: entity.DestroyTest{},
+		},
+	}
+	res := usecase.Execute(context.TODO(), cmd)
+	assert.NoError(t, res.Error)
+
+	cmd.Order.Payload = entity.DestroyTest{TestID: "test", DryRun: true}
+	res = usecase.Execute(context.TODO(), cmd)
+	assert.NoError(t, res.Error)
+	assert.Equal(t, []string{"node0"}, res.Meta["resources"].(entity.TestResources).Containers)
+
+	service.AssertExpectations(t)
+}
+
+func TestDockerUseCase_Execute_DestroyTest_Failure_NoTestID(t *testing.T) {
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, mock.Anything).Return(nil, nil).Once()
+
+	usecase := NewDockerUseCase(service, logrus.New())
+
+	res := usecase.Execute(context.TODO(), command.Command{
+		ID:     "TEST",
+		Target: testTarget,
+		Order: command.Order{
+			Type:    "destroyTest",
+			Payload: entity.DestroyTest{},
+		},
+	})
+	assert.Error(t, res.Error)
+	assert.True(t, res.IsFatal())
+	service.AssertExpectations(t)
+}
@@ -0,0 +1,254 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package usecase
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/service"
+
+	"github.com/sirupsen/logrus"
+	"github.com/whiteblock/definition/command"
+)
+
+// LogUseCase forwards the logs of the containers of running tests to a sink
+type LogUseCase interface {
+	// Follow starts following the logs of the containers of the test of the given instructions,
+	// on the hosts they target, as they are created. If the test is followed already, the hosts
+	// are added to those followed.
+	Follow(inst command.Instructions)
+
+	// Stop stops following the logs of the containers of the given test
+	Stop(testID string)
+
+	// Close stops following the logs of every test, then closes the clients and the sink
+	Close() error
+}
+
+type follower struct {
+	hosts  map[string]bool
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// wg is done once none of the containers of the test are being followed
+	wg        sync.WaitGroup
+	following map[string]bool
+	// last is the time of the last line forwarded for each container
+	last map[string]time.Time
+}
+
+type logUseCase struct {
+	conf    config.Logs
+	service service.DockerService
+	sink    repository.LogSink
+	log     logrus.Ext1FieldLogger
+
+	mux       sync.Mutex
+	clients   map[string]entity.Client
+	followers map[string]*follower
+}
+
+// NewLogUseCase creates a LogUseCase which forwards the logs to the given sink
+func NewLogUseCase(
+	conf config.Logs,
+	service service.DockerService,
+	sink repository.LogSink,
+	log logrus.Ext1FieldLogger) LogUseCase {
+	return &logUseCase{conf: conf, service: service, sink: sink, log: log,
+		clients: map[string]entity.Client{}, followers: map[string]*follower{}}
+}
+
+func logKey(host string, container string) string {
+	return host + "/" + container
+}
+
+// Follow starts following the logs of the containers of the test of the given instructions,
+// on the hosts they target
+func (luc *logUseCase) Follow(inst command.Instructions) {
+	luc.mux.Lock()
+	defer luc.mux.Unlock()
+	flw, exists := luc.followers[inst.ID]
+	if !exists {
+		flw = &follower{hosts: map[string]bool{}, done: make(chan struct{}),
+			following: map[string]bool{}, last: map[string]time.Time{}}
+		var ctx context.Context
+		ctx, flw.cancel = context.WithCancel(context.Background())
+		luc.followers[inst.ID] = flw
+		luc.log.WithField("test", inst.ID).Info("following the logs of a test")
+		go luc.loop(ctx, inst.ID, flw)
+	}
+	for _, host := range TargetHosts(inst) {
+		flw.hosts[host] = true
+	}
+}
+
+// Stop stops following the logs of the containers of the given test
+func (luc *logUseCase) Stop(testID string) {
+	luc.mux.Lock()
+	flw, exists := luc.followers[testID]
+	delete(luc.followers, testID)
+	luc.mux.Unlock()
+	if exists {
+		flw.cancel()
+		<-flw.done
+	}
+}
+
+// Close stops following the logs of every test, then closes the clients and the sink
+func (luc *logUseCase) Close() error {
+	luc.mux.Lock()
+	tests := make([]string, 0, len(luc.followers))
+	for testID := range luc.followers {
+		tests = append(tests, testID)
+	}
+	luc.mux.Unlock()
+	for _, testID := range tests {
+		luc.Stop(testID)
+	}
+
+	luc.mux.Lock()
+	defer luc.mux.Unlock()
+	for host, cli := range luc.clients {
+		cli.Close()
+		delete(luc.clients, host)
+	}
+	return luc.sink.Close()
+}
+
+// client gets the client for the given host, which is kept open until Close is called
+func (luc *logUseCase) client(host string) (entity.Client, error) {
+	luc.mux.Lock()
+	defer luc.mux.Unlock()
+	if cli, exists := luc.clients[host]; exists {
+		return cli, nil
+	}
+	cli, err := luc.service.CreateClient(host, "")
+	if err != nil {
+		return nil, err
+	}
+	luc.clients[host] = cli
+	return cli, nil
+}
+
+func (luc *logUseCase) hosts(flw *follower) []string {
+	luc.mux.Lock()
+	defer luc.mux.Unlock()
+	out := make([]string, 0, len(flw.hosts))
+	for host := range flw.hosts {
+		out = append(out, host)
+	}
+	return out
+}
+
+// loop looks for containers of the test to follow at every interval, until it is canceled or
+// the test no longer has any containers, after it had some or for longer than the idle timeout.
+// The containers being followed are followed until they stop, or it is canceled.
+func (luc *logUseCase) loop(ctx context.Context, testID string, flw *follower) {
+	defer close(flw.done)
+	defer flw.wg.Wait()
+	ticker := time.NewTicker(luc.conf.PollInterval)
+	defer ticker.Stop()
+	hadContainers := false
+	lastSeen := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		found := 0
+		failed := false
+		for _, host := range luc.hosts(flw) {
+			n, err := luc.followHost(ctx, testID, flw, host)
+			found += n
+			if err != nil && ctx.Err() == nil {
+				failed = true
+				luc.log.WithFields(logrus.Fields{"test": testID, "host": host, "error": err}).Warn(
+					"failed to find the containers to follow on a host")
+			}
+		}
+		if found > 0 {
+			lastSeen = time.Now()
+		}
+		idle := luc.conf.IdleTimeout > 0 && time.Since(lastSeen) > luc.conf.IdleTimeout
+		if (found == 0 && hadContainers && !failed) || idle {
+			luc.log.WithFields(logrus.Fields{"test": testID, "idle": idle}).Info(
+				"the test has no containers left to follow, no longer following it")
+			luc.mux.Lock()
+			if luc.followers[testID] == flw {
+				delete(luc.followers, testID)
+			}
+			luc.mux.Unlock()
+			return
+		}
+		hadContainers = hadContainers || found > 0
+	}
+}
+
+// followHost starts following the containers of the test on the given host which are not
+// being followed yet, returning how many containers the test has on the host
+func (luc *logUseCase) followHost(ctx context.Context, testID string, flw *follower,
+	host string) (int, error) {
+
+	cli, err := luc.client(host)
+	if err != nil {
+		return 0, err
+	}
+	docker := entity.DockerCli{Client: cli}
+	found, err := luc.service.ListTestResources(ctx, docker, testID)
+	if err == nil {
+		err = ctx.Err() // the test may have been stopped while its containers were listed
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	luc.mux.Lock()
+	defer luc.mux.Unlock()
+	existing := map[string]bool{}
+	for _, res := range found {
+		for _, name := range res.Containers {
+			key := logKey(host, name)
+			existing[key] = true
+			if flw.following[key] {
+				continue
+			}
+			flw.following[key] = true
+			flw.wg.Add(1)
+			go luc.follow(ctx, flw, docker, host, name, flw.last[key])
+		}
+	}
+	for key := range flw.last {
+		if !existing[key] && !flw.following[key] && strings.HasPrefix(key, logKey(host, "")) {
+			delete(flw.last, key) // the container was removed
+		}
+	}
+	return len(existing), nil
+}
+
+func (luc *logUseCase) follow(ctx context.Context, flw *follower, cli entity.DockerCli,
+	host string, name string, since time.Time) {
+
+	defer flw.wg.Done()
+	last, err := luc.service.FollowLogs(ctx, cli, name, since, luc.sink)
+	if err != nil && ctx.Err() == nil {
+		luc.log.WithFields(logrus.Fields{"host": host, "container": name, "error": err}).Warn(
+			"failed to follow the logs of a container")
+	}
+
+	luc.mux.Lock()
+	defer luc.mux.Unlock()
+	key := logKey(host, name)
+	flw.last[key] = last
+	delete(flw.following, key)
+}
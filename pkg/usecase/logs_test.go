@@ -0,0 +1,144 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package usecase
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
+	repoMock "github.com/whiteblock/genesis/mocks/pkg/repository"
+	mockService "github.com/whiteblock/genesis/mocks/pkg/service"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogUseCase_Follow(t *testing.T) {
+	found := entity.NewTestResources("test0")
+	found.Containers = []string{"node0", "node1"}
+	last := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	release := make(chan bool)
+
+	var mux sync.Mutex
+	removed := false
+	cli := new(entityMock.Client)
+	cli.On("Close").Return(nil).Once()
+	sink := new(repoMock.LogSink)
+	sink.On("Close").Return(nil).Once()
+	service := new(mockService.DockerService)
+	service.On("CreateClient", "10.0.0.1", "").Return(cli, nil).Once()
+	service.On("ListTestResources", mock.Anything, mock.Anything, "test0").Return(
+		func(context.Context, entity.DockerCli, string) []entity.TestResources {
+			mux.Lock()
+			defer mux.Unlock()
+			if removed {
+				return []entity.TestResources{}
+			}
+			return []entity.TestResources{found}
+		}, nil)
+	service.On("FollowLogs", mock.Anything, mock.Anything, "node0", time.Time{}, sink).Return(
+		last, nil).Run(func(_ mock.Arguments) { <-release }).Once()
+	service.On("FollowLogs", mock.Anything, mock.Anything, "node1", time.Time{}, sink).Return(
+		last, nil).Once()
+	// node1 stopped, so it is followed again from its last line, while node0 is still followed.
+	// Then both are removed.
+	service.On("FollowLogs", mock.Anything, mock.Anything, "node1", last, sink).Return(
+		last, nil).Run(func(_ mock.Arguments) {
+		mux.Lock()
+		defer mux.Unlock()
+		removed = true
+		close(release)
+	}).Once()
+
+	logs := NewLogUseCase(config.Logs{PollInterval: 10 * time.Millisecond}, service, sink, logrus.New())
+	logs.Follow(statsInstructions("test0"))
+
+	// the containers of the test were removed, so it is no longer followed
+	luc := logs.(*logUseCase)
+	assert.Eventually(t, func() bool {
+		luc.mux.Lock()
+		defer luc.mux.Unlock()
+		return len(luc.followers) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, logs.Close())
+	cli.AssertExpectations(t)
+	sink.AssertExpectations(t)
+	service.AssertExpectations(t)
+}
+
+func TestLogUseCase_Stop(t *testing.T) {
+	found := entity.NewTestResources("test0")
+	found.Containers = []string{"node0"}
+	following := make(chan bool, 1)
+
+	cli := new(entityMock.Client)
+	cli.On("Close").Return(nil).Once()
+	sink := new(repoMock.LogSink)
+	sink.On("Close").Return(nil).Once()
+	service := new(mockService.DockerService)
+	service.On("CreateClient", "10.0.0.1", "").Return(cli, nil).Once()
+	service.On("ListTestResources", mock.Anything, mock.Anything, "test0").Return(
+		[]entity.TestResources{found}, nil)
+	service.On("ListTestResources", mock.Anything, mock.Anything, "test1").Return(
+		[]entity.TestResources{}, nil)
+	service.On("FollowLogs", mock.Anything, mock.Anything, "node0", time.Time{}, sink).Return(
+		time.Time{}, context.Canceled).Run(func(args mock.Arguments) {
+		following <- true
+		<-args.Get(0).(context.Context).Done()
+	}).Once()
+
+	logs := NewLogUseCase(config.Logs{PollInterval: time.Millisecond}, service, sink, logrus.New())
+	logs.Follow(statsInstructions("test0"))
+	logs.Follow(statsInstructions("test1"))
+	select {
+	case <-following:
+	case <-time.After(5 * time.Second):
+		t.Fatal("node0 was not followed within 5 seconds")
+	}
+
+	// the test finished, so its containers are no longer followed
+	logs.Stop("test0")
+	luc := logs.(*logUseCase)
+	luc.mux.Lock()
+	assert.Len(t, luc.followers, 1)
+	luc.mux.Unlock()
+
+	require.NoError(t, logs.Close())
+	assert.Empty(t, luc.followers)
+	cli.AssertExpectations(t)
+	sink.AssertExpectations(t)
+}
+
+func TestLogUseCase_Follow_IdleTimeout(t *testing.T) {
+	service := new(mockService.DockerService)
+	service.On("CreateClient", "10.0.0.1", "").Return(nil, assert.AnError)
+	sink := new(repoMock.LogSink)
+	sink.On("Close").Return(nil).Once()
+
+	logs := NewLogUseCase(config.Logs{PollInterval: time.Millisecond, IdleTimeout: 50 * time.Millisecond},
+		service, sink, logrus.New())
+	logs.Follow(statsInstructions("test0"))
+
+	// the host could never be checked, so the test is followed until the idle timeout
+	luc := logs.(*logUseCase)
+	assert.Eventually(t, func() bool {
+		luc.mux.Lock()
+		defer luc.mux.Unlock()
+		return len(luc.followers) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, logs.Close())
+	sink.AssertExpectations(t)
+}
@@ -0,0 +1,88 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/file"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/service"
+
+	"github.com/sirupsen/logrus"
+	"github.com/whiteblock/definition/command"
+)
+
+// PlanUseCase works out what executing a set of instructions would do, without touching docker
+type PlanUseCase interface {
+	// Plan walks through the rounds of the given instructions, validating each command and
+	// recording the docker calls it would make
+	Plan(ctx context.Context, inst command.Instructions) (entity.Plan, error)
+}
+
+type planUseCase struct {
+	conf   config.Docker
+	repo   repository.DockerRepository
+	remote file.RemoteSources
+	log    logrus.Ext1FieldLogger
+}
+
+// NewPlanUseCase creates a PlanUseCase. The remote sources are used to check the files
+// placed in containers.
+func NewPlanUseCase(
+	conf config.Docker,
+	repo repository.DockerRepository,
+	remote file.RemoteSources,
+	log logrus.Ext1FieldLogger) PlanUseCase {
+	return &planUseCase{conf: conf, repo: repo, remote: remote, log: log}
+}
+
+// Plan walks through the rounds of the given instructions, validating each command and
+// recording the docker calls it would make. The hosts are assumed to start out empty.
+func (puc planUseCase) Plan(ctx context.Context, inst command.Instructions) (entity.Plan, error) {
+	rec := service.NewDockerCallRecorder()
+	duc := &dockerUseCase{
+		service:  service.NewPlanningDockerService(puc.repo, puc.conf, puc.remote, rec, puc.log),
+		log:      puc.log,
+		planning: true,
+	}
+	out := entity.Plan{Valid: true, Rounds: []entity.PlannedRound{}}
+	for {
+		cmds, err := inst.Peek()
+		if errors.Is(err, command.ErrNoCommands) {
+			return out, err
+		}
+		round := entity.PlannedRound{Round: len(out.Rounds), Commands: []entity.PlannedCommand{}}
+		trapped := false
+		for _, cmd := range cmds {
+			res := duc.Run(ctx, cmd)
+			round.Commands = append(round.Commands, entity.PlannedCommand{
+				ID:     cmd.ID,
+				Order:  command.OrderType(strings.ToLower(string(cmd.Order.Type))),
+				Target: cmd.Target.IP,
+				Calls:  rec.Take(),
+				Result: res,
+			})
+			trapped = trapped || res.IsTrap()
+			if !res.IsSuccess() && !res.IsTrap() {
+				out.Valid = false
+			}
+		}
+		out.Rounds = append(out.Rounds, round)
+		if !out.Valid || trapped || errors.Is(err, command.ErrDone) {
+			return out, nil
+		}
+		if ctx.Err() != nil {
+			return out, ctx.Err()
+		}
+		inst.Next()
+	}
+}
@@ -0,0 +1,152 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	fileMock "github.com/whiteblock/genesis/mocks/pkg/file"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/file"
+	"github.com/whiteblock/genesis/pkg/repository"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/whiteblock/definition/command"
+)
+
+func planCommand(id string, orderType command.OrderType, payload interface{}) command.Command {
+	return command.Command{
+		ID:     id,
+		Target: testTarget,
+		Order:  command.Order{Type: orderType, Payload: payload},
+		Meta:   map[string]string{command.TestIDKey: "test"},
+	}
+}
+
+func methods(calls []entity.DockerCall) []string {
+	out := []string{}
+	for _, call := range calls {
+		out = append(out, call.Method)
+	}
+	return out
+}
+
+func TestPlanUseCase_Plan(t *testing.T) {
+	inst := command.Instructions{ID: "test", Commands: [][]command.Command{
+		{planCommand("net", command.Createnetwork, command.Network{Name: "net0", Subnet: "10.1.0.0/16"})},
+		{planCommand("node", command.Createcontainer, command.Container{
+			Name: "node0", Image: "alpine", Cpus: "1", Memory: "1GB", Network: "net0"})},
+		{planCommand("start", command.Startcontainer, command.StartContainer{Name: "node0"})},
+		{planCommand("netem", command.Emulation, command.Netconf{
+			Container: "node0", Network: "net0", Delay: 100})},
+	}}
+
+	puc := NewPlanUseCase(config.Docker{}, repository.NewDockerRepository(logrus.New()), nil, logrus.New())
+	plan, err := puc.Plan(context.Background(), inst)
+	require.NoError(t, err)
+	assert.True(t, plan.Valid)
+	require.Len(t, plan.Rounds, 4)
+	_, err = json.Marshal(plan)
+	require.NoError(t, err)
+
+	for i, round := range plan.Rounds {
+		assert.Equal(t, i, round.Round)
+		require.Len(t, round.Commands, 1)
+		assert.True(t, round.Commands[0].Result.IsSuccess(), round.Commands[0].Result)
+		assert.Equal(t, testTarget.IP, round.Commands[0].Target)
+		for _, call := range round.Commands[0].Calls {
+			assert.Equal(t, testTarget.IP, call.Host)
+		}
+	}
+	assert.Equal(t, []string{"NetworkCreate"}, methods(plan.Rounds[0].Commands[0].Calls))
+	assert.Equal(t, []string{"ImageList", "ImagePull", "ContainerCreate"},
+		methods(plan.Rounds[1].Commands[0].Calls))
+	assert.Equal(t, []string{"ContainerStart"}, methods(plan.Rounds[2].Commands[0].Calls))
+
+	netem := plan.Rounds[3].Commands[0]
+	assert.Equal(t, command.Emulation, netem.Order)
+	require.Len(t, netem.Calls, 8)
+	// the image is pulled while the network is looked up
+	assert.ElementsMatch(t, []string{"NetworkList", "ImageList", "ImagePull"}, methods(netem.Calls[:3]))
+	assert.Equal(t, []string{"ContainerRemove", "ContainerCreate"}, methods(netem.Calls[3:5]))
+	// the wait for the sidecar to exit is recorded as the sidecar starts
+	assert.ElementsMatch(t, []string{"ContainerAttach", "ContainerWait", "ContainerStart"},
+		methods(netem.Calls[5:]))
+
+	conf, ok := netem.Calls[4].Args["config"].(*container.Config)
+	require.True(t, ok)
+	entrypoint := strings.Join(conf.Entrypoint, " ")
+	assert.Contains(t, entrypoint, "10.1.0.0/16")
+	assert.Contains(t, entrypoint, "replace")
+	assert.Contains(t, entrypoint, "delay 100us")
+	assert.Equal(t, "test", conf.Labels[command.TestIDKey])
+}
+
+func TestPlanUseCase_Plan_Invalid(t *testing.T) {
+	inst := command.Instructions{Commands: [][]command.Command{
+		{
+			planCommand("node", command.Createcontainer, command.Container{
+				Name: "node0", Image: "alpine", Cpus: "1", Memory: "1GB"}),
+			planCommand("node", command.Createcontainer, command.Container{Name: "node1"}),
+		},
+		{planCommand("start", command.Startcontainer, command.StartContainer{Name: "node0"})},
+	}}
+
+	puc := NewPlanUseCase(config.Docker{}, repository.NewDockerRepository(logrus.New()), nil, logrus.New())
+	plan, err := puc.Plan(context.Background(), inst)
+	require.NoError(t, err)
+	assert.False(t, plan.Valid)
+	require.Len(t, plan.Rounds, 1)
+	require.Len(t, plan.Rounds[0].Commands, 2)
+	assert.True(t, plan.Rounds[0].Commands[0].Result.IsSuccess())
+	assert.True(t, plan.Rounds[0].Commands[1].Result.IsFatal())
+	assert.Empty(t, plan.Rounds[0].Commands[1].Calls)
+}
+
+func TestPlanUseCase_Plan_NoCommands(t *testing.T) {
+	puc := NewPlanUseCase(config.Docker{}, repository.NewDockerRepository(logrus.New()), nil, logrus.New())
+	_, err := puc.Plan(context.Background(), command.Instructions{})
+	assert.Equal(t, command.ErrNoCommands, err)
+}
+
+func TestPlanUseCase_Plan_PutFile(t *testing.T) {
+	put := planCommand("put", command.Putfileincontainer, map[string]interface{}{"container": "node0",
+		"file": map[string]interface{}{"id": "https://example.com/genesis.json", "destination": "/etc/",
+			"mode": 0644, "sha256": strings.Repeat("0", 64)}})
+	put.Meta[command.DefinitionIDKey] = "def0"
+	inst := command.Instructions{ID: "test", Commands: [][]command.Command{
+		{planCommand("node", command.Createcontainer, command.Container{
+			Name: "node0", Image: "alpine", Cpus: "1", Memory: "1GB"})},
+		{put},
+	}}
+	src := new(fileMock.Source)
+	src.On("Stat", "def0", mock.Anything).Return(int64(2), nil).Once()
+	remote := file.NewRemoteSourcesWithSources(config.Config{},
+		map[string]file.Source{file.SchemeHTTPS: src}, logrus.New())
+
+	puc := NewPlanUseCase(config.Docker{}, repository.NewDockerRepository(logrus.New()), remote, logrus.New())
+	plan, err := puc.Plan(context.Background(), inst)
+	require.NoError(t, err)
+	require.True(t, plan.Valid, "the sha256 of the file is not checked, as it is not fetched")
+	src.AssertNotCalled(t, "Open", mock.Anything, mock.Anything)
+	require.Len(t, plan.Rounds, 2)
+	calls := plan.Rounds[1].Commands[0].Calls
+	require.NotEmpty(t, calls)
+	copied := calls[len(calls)-1]
+	assert.Equal(t, "CopyToContainer", copied.Method)
+	assert.Equal(t, "https://example.com/genesis.json", copied.Args["source"])
+	assert.Equal(t, int64(2), copied.Args["size"])
+	src.AssertExpectations(t)
+}
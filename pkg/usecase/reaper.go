@@ -0,0 +1,210 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/helper"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/service"
+
+	"github.com/sirupsen/logrus"
+	"github.com/whiteblock/definition/command"
+)
+
+// ReaperUseCase finds and removes the docker resources left behind by tests
+type ReaperUseCase interface {
+	// Destroy removes the resources of the given test from the given host. If dryRun is true,
+	// the resources are only listed.
+	Destroy(ctx context.Context, host string, testID string, dryRun bool) (entity.TestResources, error)
+
+	// Sweep removes the resources on the configured hosts which belong to tests without a
+	// live run. If dryRun is true, the resources are only listed.
+	Sweep(ctx context.Context, dryRun bool) ([]entity.TestResources, error)
+}
+
+// ErrHostRequired is returned when no host is given, and there is not a single host to default to
+var ErrHostRequired = errors.New("a host must be given")
+
+type reaperUseCase struct {
+	conf    config.Reaper
+	service service.DockerService
+	runs    repository.RunRepository
+	queued  repository.QueuedTestRepository
+	log     logrus.Ext1FieldLogger
+}
+
+// NewReaperUseCase creates a ReaperUseCase. The runs and the tests being run through the
+// command queue are used to determine which tests are still live.
+func NewReaperUseCase(
+	conf config.Reaper,
+	service service.DockerService,
+	runs repository.RunRepository,
+	queued repository.QueuedTestRepository,
+	log logrus.Ext1FieldLogger) ReaperUseCase {
+	return &reaperUseCase{conf: conf, service: service, runs: runs, queued: queued, log: log}
+}
+
+func (ruc reaperUseCase) list(ctx context.Context, cli entity.DockerCli, host string,
+	testID string) ([]entity.TestResources, error) {
+
+	found, err := ruc.service.ListTestResources(ctx, cli, testID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range found {
+		found[i].Host = host
+	}
+	return found, nil
+}
+
+// TargetHosts gets the hosts targeted by the given instructions, sorted. If none are targeted,
+// the only host is the empty string, which stands for the default host.
+func TargetHosts(inst command.Instructions) []string {
+	found := map[string]bool{}
+	for _, round := range inst.Commands {
+		for _, cmd := range round {
+			if len(cmd.Target.IP) > 0 {
+				found[cmd.Target.IP] = true
+			}
+		}
+	}
+	if len(found) == 0 {
+		return []string{""}
+	}
+	out := make([]string, 0, len(found))
+	for host := range found {
+		out = append(out, host)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Destroy removes the resources of the given test from the given host. If dryRun is true,
+// the resources are only listed. If no host is given, the only configured host is used.
+func (ruc reaperUseCase) Destroy(ctx context.Context, host string, testID string,
+	dryRun bool) (entity.TestResources, error) {
+
+	if len(host) == 0 {
+		if len(ruc.conf.Hosts) != 1 {
+			return entity.TestResources{}, ErrHostRequired
+		}
+		host = ruc.conf.Hosts[0]
+	}
+	res := entity.NewTestResources(testID)
+	res.Host = host
+
+	cli, err := ruc.service.CreateClient(host, testID)
+	if err != nil {
+		return res, err
+	}
+	defer cli.Close()
+	docker := entity.DockerCli{Client: cli}
+
+	found, err := ruc.list(ctx, docker, host, testID)
+	if err != nil || len(found) == 0 {
+		return res, err
+	}
+	res = found[0]
+	if dryRun {
+		return res, nil
+	}
+	return res, ruc.service.RemoveTestResources(ctx, docker, res).Error
+}
+
+// liveTests gets which tests are live, along with the tests which have a run
+func (ruc reaperUseCase) liveTests() (live map[string]bool, known map[string]bool, err error) {
+	runs, err := ruc.runs.GetAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	live = map[string]bool{}
+	known = map[string]bool{}
+	for _, run := range runs {
+		known[run.Instructions.ID] = true
+		// runs which trapped are finished, but their tests are still running
+		if !run.Status.Finished || (run.Result != nil && run.Result.IsTrap()) {
+			live[run.Instructions.ID] = true
+		}
+	}
+	for _, testID := range ruc.queued.GetAll() {
+		live[testID] = true
+	}
+	return live, known, nil
+}
+
+// isLive checks whether the test of the given resources is live. The tests without a run
+// may be handled by another replica, or may have been handled before a restart, so they are
+// treated as live until their resources are older than the grace period.
+func (ruc reaperUseCase) isLive(res entity.TestResources, live map[string]bool,
+	known map[string]bool) bool {
+
+	if live[res.TestID] {
+		return true
+	}
+	return !known[res.TestID] && time.Since(res.Created) < ruc.conf.GracePeriod
+}
+
+// Sweep removes the resources on the configured hosts which belong to tests without a
+// live run. If dryRun is true, the resources are only listed.
+func (ruc reaperUseCase) Sweep(ctx context.Context, dryRun bool) ([]entity.TestResources, error) {
+	live, known, err := ruc.liveTests()
+	if err != nil {
+		return nil, err
+	}
+	out := []entity.TestResources{}
+	for _, host := range ruc.conf.Hosts {
+		reaped, e := ruc.sweepHost(ctx, host, live, known, dryRun)
+		out = append(out, reaped...)
+		if e != nil {
+			ruc.log.WithFields(logrus.Fields{"host": host, "error": e}).Error("failed to sweep a host")
+			err = helper.JoinErrors(err, e)
+		}
+	}
+	return out, err
+}
+
+func (ruc reaperUseCase) sweepHost(ctx context.Context, host string, live map[string]bool,
+	known map[string]bool, dryRun bool) ([]entity.TestResources, error) {
+
+	cli, err := ruc.service.CreateClient(host, "")
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+	docker := entity.DockerCli{Client: cli}
+
+	found, err := ruc.list(ctx, docker, host, "")
+	if err != nil {
+		return nil, err
+	}
+	out := []entity.TestResources{}
+	for _, res := range found {
+		if len(res.TestID) == 0 || ruc.isLive(res, live, known) {
+			continue
+		}
+		out = append(out, res)
+		entry := ruc.log.WithFields(logrus.Fields{"host": host, "test": res.TestID,
+			"containers": res.Containers, "networks": res.Networks, "volumes": res.Volumes})
+		if dryRun {
+			entry.Info("dry run, would remove the resources of a test without a live run")
+			continue
+		}
+		entry.Info("removing the resources of a test without a live run")
+		result := ruc.service.RemoveTestResources(ctx, docker, res)
+		if !result.IsSuccess() {
+			return out, result.Error
+		}
+	}
+	return out, nil
+}
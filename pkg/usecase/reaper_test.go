@@ -0,0 +1,111 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
+	mockService "github.com/whiteblock/genesis/mocks/pkg/service"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/whiteblock/definition/command"
+)
+
+func TestReaperUseCase_Destroy(t *testing.T) {
+	found := entity.NewTestResources("test")
+	found.Networks = []string{"net0"}
+
+	cli := new(entityMock.Client)
+	cli.On("Close").Return(nil)
+	service := new(mockService.DockerService)
+	service.On("CreateClient", "127.0.0.1", mock.Anything).Return(cli, nil).Twice()
+	service.On("ListTestResources", mock.Anything, mock.Anything, "test").Return(
+		[]entity.TestResources{found}, nil).Twice()
+	service.On("RemoveTestResources", mock.Anything, mock.Anything, mock.Anything).Return(
+		entity.NewSuccessResult()).Once()
+
+	reaper := NewReaperUseCase(config.Reaper{Hosts: []string{"127.0.0.1"}}, service,
+		repository.NewRunRepository(), repository.NewQueuedTestRepository(), logrus.New())
+
+	res, err := reaper.Destroy(context.Background(), "", "test", true)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", res.Host)
+	assert.Equal(t, found.Networks, res.Networks)
+
+	_, err = reaper.Destroy(context.Background(), "127.0.0.1", "test", false)
+	require.NoError(t, err)
+	service.AssertExpectations(t)
+}
+
+func TestReaperUseCase_Destroy_HostRequired(t *testing.T) {
+	reaper := NewReaperUseCase(config.Reaper{Hosts: []string{"a", "b"}}, nil, nil, nil, logrus.New())
+	_, err := reaper.Destroy(context.Background(), "", "test", false)
+	assert.Equal(t, ErrHostRequired, err)
+}
+
+func TestReaperUseCase_Sweep(t *testing.T) {
+	runs := repository.NewRunRepository()
+	require.NoError(t, runs.Insert(entity.NewRun("1", command.Instructions{ID: "live"})))
+	finished := entity.NewRun("2", command.Instructions{ID: "finished"})
+	finished.Update(command.Instructions{ID: "finished"}, entity.NewAllDoneResult())
+	require.NoError(t, runs.Insert(finished))
+	trapped := entity.NewRun("3", command.Instructions{ID: "trapped"})
+	trapped.Update(command.Instructions{ID: "trapped"}, entity.NewTrapResult())
+	require.NoError(t, runs.Insert(trapped))
+	queued := repository.NewQueuedTestRepository()
+	queued.Add("queued")
+
+	cli := new(entityMock.Client)
+	cli.On("Close").Return(nil)
+	service := new(mockService.DockerService)
+	service.On("CreateClient", "127.0.0.1", mock.Anything).Return(cli, nil).Twice()
+	recent := func(testID string) entity.TestResources {
+		res := entity.NewTestResources(testID)
+		res.Created = time.Now()
+		return res
+	}
+	service.On("ListTestResources", mock.Anything, mock.Anything, "").Return([]entity.TestResources{
+		entity.NewTestResources("live"),
+		entity.NewTestResources("queued"),
+		entity.NewTestResources("trapped"),
+		recent("finished"),
+		entity.NewTestResources("orphan"),
+		recent("unknown"), // may be live on another replica
+	}, nil).Twice()
+	service.On("RemoveTestResources", mock.Anything, mock.Anything, mock.Anything).Return(
+		entity.NewSuccessResult()).Twice()
+
+	reaper := NewReaperUseCase(config.Reaper{Hosts: []string{"127.0.0.1"}, GracePeriod: time.Hour},
+		service, runs, queued, logrus.New())
+
+	for _, dryRun := range []bool{true, false} {
+		reaped, err := reaper.Sweep(context.Background(), dryRun)
+		require.NoError(t, err)
+		require.Len(t, reaped, 2)
+		assert.Equal(t, "finished", reaped[0].TestID)
+		assert.Equal(t, "orphan", reaped[1].TestID)
+	}
+	service.AssertExpectations(t)
+}
+
+func TestTargetHosts(t *testing.T) {
+	assert.Equal(t, []string{""}, TargetHosts(command.Instructions{}))
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, TargetHosts(command.Instructions{
+		Commands: [][]command.Command{
+			{{Target: command.Target{IP: "10.0.0.2"}}, {Target: command.Target{IP: "10.0.0.1"}}},
+			{{Target: command.Target{IP: "10.0.0.2"}}, {}},
+		}}))
+}
@@ -0,0 +1,200 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/service"
+
+	"github.com/sirupsen/logrus"
+	"github.com/whiteblock/definition/command"
+)
+
+// StatsUseCase periodically samples the resource usage of the containers of running tests
+type StatsUseCase interface {
+	// Sample starts sampling the containers of the test of the given instructions, on the hosts
+	// they target. If the test is sampled already, the hosts are added to those sampled.
+	Sample(inst command.Instructions)
+
+	// Stop stops sampling the containers of the given test
+	Stop(testID string)
+
+	// Close stops sampling the containers of every test, then closes the sink
+	Close() error
+}
+
+type sampler struct {
+	hosts  map[string]bool
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type statsUseCase struct {
+	conf    config.Stats
+	service service.DockerService
+	sink    repository.StatsSink
+	log     logrus.Ext1FieldLogger
+
+	mux      sync.Mutex
+	samplers map[string]*sampler
+}
+
+// NewStatsUseCase creates a StatsUseCase which publishes the samples to the given sink
+func NewStatsUseCase(
+	conf config.Stats,
+	service service.DockerService,
+	sink repository.StatsSink,
+	log logrus.Ext1FieldLogger) StatsUseCase {
+	return &statsUseCase{conf: conf, service: service, sink: sink, log: log,
+		samplers: map[string]*sampler{}}
+}
+
+// Sample starts sampling the containers of the test of the given instructions, on the hosts
+// they target
+func (suc *statsUseCase) Sample(inst command.Instructions) {
+	suc.mux.Lock()
+	defer suc.mux.Unlock()
+	smp, exists := suc.samplers[inst.ID]
+	if !exists {
+		smp = &sampler{hosts: map[string]bool{}, done: make(chan struct{})}
+		var ctx context.Context
+		ctx, smp.cancel = context.WithCancel(context.Background())
+		suc.samplers[inst.ID] = smp
+		suc.log.WithField("test", inst.ID).Info("sampling the resource usage of a test")
+		go suc.loop(ctx, inst.ID, smp)
+	}
+	for _, host := range TargetHosts(inst) {
+		smp.hosts[host] = true
+	}
+}
+
+// Stop stops sampling the containers of the given test
+func (suc *statsUseCase) Stop(testID string) {
+	suc.mux.Lock()
+	smp, exists := suc.samplers[testID]
+	delete(suc.samplers, testID)
+	suc.mux.Unlock()
+	if exists {
+		smp.cancel()
+		<-smp.done
+	}
+}
+
+// Close stops sampling the containers of every test, then closes the sink
+func (suc *statsUseCase) Close() error {
+	suc.mux.Lock()
+	tests := make([]string, 0, len(suc.samplers))
+	for testID := range suc.samplers {
+		tests = append(tests, testID)
+	}
+	suc.mux.Unlock()
+	for _, testID := range tests {
+		suc.Stop(testID)
+	}
+	return suc.sink.Close()
+}
+
+func (suc *statsUseCase) hosts(smp *sampler) []string {
+	suc.mux.Lock()
+	defer suc.mux.Unlock()
+	out := make([]string, 0, len(smp.hosts))
+	for host := range smp.hosts {
+		out = append(out, host)
+	}
+	return out
+}
+
+// loop samples the containers of the test at every interval, until it is canceled or the test
+// no longer has any containers, after it had some or for longer than the idle timeout
+func (suc *statsUseCase) loop(ctx context.Context, testID string, smp *sampler) {
+	defer close(smp.done)
+	ticker := time.NewTicker(suc.conf.Interval)
+	defer ticker.Stop()
+	hadContainers := false
+	lastSeen := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		sampled := 0
+		failed := false
+		for _, host := range suc.hosts(smp) {
+			n, err := suc.sampleHost(ctx, testID, host)
+			sampled += n
+			if err != nil && ctx.Err() == nil {
+				failed = true
+				suc.log.WithFields(logrus.Fields{"test": testID, "host": host, "error": err}).Warn(
+					"failed to sample a host")
+			}
+		}
+		if sampled > 0 {
+			lastSeen = time.Now()
+		}
+		idle := suc.conf.IdleTimeout > 0 && time.Since(lastSeen) > suc.conf.IdleTimeout
+		if (sampled == 0 && hadContainers && !failed) || idle {
+			suc.log.WithFields(logrus.Fields{"test": testID, "idle": idle}).Info(
+				"the test has no containers left to sample, no longer sampling it")
+			suc.mux.Lock()
+			if suc.samplers[testID] == smp {
+				delete(suc.samplers, testID)
+			}
+			suc.mux.Unlock()
+			return
+		}
+		hadContainers = hadContainers || sampled > 0
+	}
+}
+
+// sampleHost samples the running containers of the test on the given host, returning how many
+// containers the test has on the host
+func (suc *statsUseCase) sampleHost(ctx context.Context, testID string, host string) (int, error) {
+	cli, err := suc.service.CreateClient(host, "")
+	if err != nil {
+		return 0, err
+	}
+	defer cli.Close()
+	docker := entity.DockerCli{Client: cli}
+
+	found, err := suc.service.ListTestResources(ctx, docker, testID)
+	if err != nil {
+		return 0, err
+	}
+	log := suc.log.WithFields(logrus.Fields{"test": testID, "host": host})
+	sampled := 0
+	for _, res := range found {
+		for _, name := range res.Containers {
+			sampled++
+			sample, err := suc.service.SampleStats(ctx, docker, name)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.WithFields(logrus.Fields{"container": name, "error": err}).Warn(
+						"failed to sample a container")
+				}
+				continue
+			}
+			if sample.Time.IsZero() {
+				continue // the container is not running
+			}
+			sample.Test = testID
+			sample.Host = host
+			err = suc.sink.Write(sample)
+			if err != nil {
+				log.WithFields(logrus.Fields{"container": name, "error": err}).Error(
+					"failed to publish a sample")
+			}
+		}
+	}
+	return sampled, nil
+}
@@ -0,0 +1,134 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	entityMock "github.com/whiteblock/genesis/mocks/pkg/entity"
+	repoMock "github.com/whiteblock/genesis/mocks/pkg/repository"
+	mockService "github.com/whiteblock/genesis/mocks/pkg/service"
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/whiteblock/definition/command"
+)
+
+func statsInstructions(testID string) command.Instructions {
+	return command.Instructions{ID: testID, Commands: [][]command.Command{
+		{{Target: command.Target{IP: "10.0.0.1"}}},
+	}}
+}
+
+func TestStatsUseCase_Sample(t *testing.T) {
+	found := entity.NewTestResources("test0")
+	found.Containers = []string{"node0", "node1"}
+	read := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cli := new(entityMock.Client)
+	cli.On("Close").Return(nil)
+	service := new(mockService.DockerService)
+	service.On("CreateClient", "10.0.0.1", "").Return(cli, nil)
+	service.On("ListTestResources", mock.Anything, mock.Anything, "test0").Return(
+		[]entity.TestResources{found}, nil).Once()
+	service.On("ListTestResources", mock.Anything, mock.Anything, "test0").Return(
+		[]entity.TestResources{}, nil)
+	service.On("SampleStats", mock.Anything, mock.Anything, "node0").Return(
+		entity.StatsSample{Container: "node0", Time: read, CPU: 12.5}, nil).Once()
+	service.On("SampleStats", mock.Anything, mock.Anything, "node1").Return(
+		entity.StatsSample{Container: "node1"}, nil).Once() // not running
+
+	written := make(chan entity.StatsSample, 10)
+	sink := new(repoMock.StatsSink)
+	sink.On("Write", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		written <- args.Get(0).(entity.StatsSample)
+	})
+	sink.On("Close").Return(nil).Once()
+
+	stats := NewStatsUseCase(config.Stats{Interval: 10 * time.Millisecond}, service, sink, logrus.New())
+	stats.Sample(statsInstructions("test0"))
+	select {
+	case sample := <-written:
+		assert.Equal(t, entity.StatsSample{Test: "test0", Host: "10.0.0.1", Container: "node0",
+			Time: read, CPU: 12.5}, sample)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no sample was written within 5 seconds")
+	}
+
+	// the containers of the test were removed, so it is no longer sampled
+	suc := stats.(*statsUseCase)
+	assert.Eventually(t, func() bool {
+		suc.mux.Lock()
+		defer suc.mux.Unlock()
+		return len(suc.samplers) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, stats.Close())
+	assert.Len(t, written, 0)
+	service.AssertExpectations(t)
+	sink.AssertExpectations(t)
+}
+
+func TestStatsUseCase_Sample_IdleTimeout(t *testing.T) {
+	cli := new(entityMock.Client)
+	cli.On("Close").Return(nil)
+	service := new(mockService.DockerService)
+	service.On("CreateClient", "10.0.0.1", "").Return(cli, nil)
+	service.On("ListTestResources", mock.Anything, mock.Anything, "test0").Return(
+		[]entity.TestResources{}, nil)
+	sink := new(repoMock.StatsSink)
+	sink.On("Close").Return(nil).Once()
+
+	stats := NewStatsUseCase(config.Stats{Interval: time.Millisecond, IdleTimeout: 50 * time.Millisecond},
+		service, sink, logrus.New())
+	stats.Sample(statsInstructions("test0"))
+
+	// the test never had any containers, so it is sampled until the idle timeout
+	suc := stats.(*statsUseCase)
+	assert.Eventually(t, func() bool {
+		suc.mux.Lock()
+		defer suc.mux.Unlock()
+		return len(suc.samplers) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, stats.Close())
+	service.AssertExpectations(t)
+	sink.AssertExpectations(t)
+}
+
+func TestStatsUseCase_Stop(t *testing.T) {
+	service := new(mockService.DockerService)
+	service.On("CreateClient", mock.Anything, "").Return(nil, assert.AnError)
+	sink := new(repoMock.StatsSink)
+	sink.On("Close").Return(nil).Once()
+
+	stats := NewStatsUseCase(config.Stats{Interval: time.Millisecond}, service, sink, logrus.New())
+	stats.Sample(statsInstructions("test0"))
+	stats.Sample(command.Instructions{ID: "test0"})
+	stats.Sample(statsInstructions("test1"))
+
+	suc := stats.(*statsUseCase)
+	suc.mux.Lock()
+	require.Len(t, suc.samplers, 2)
+	assert.Equal(t, map[string]bool{"10.0.0.1": true, "": true}, suc.samplers["test0"].hosts)
+	suc.mux.Unlock()
+
+	stats.Stop("test0")
+	stats.Stop("test2")
+	suc.mux.Lock()
+	assert.Len(t, suc.samplers, 1)
+	suc.mux.Unlock()
+
+	require.NoError(t, stats.Close())
+	assert.Empty(t, suc.samplers)
+	sink.AssertExpectations(t)
+}
@@ -7,15 +7,26 @@
 package validator
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strconv"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
 
 	"github.com/whiteblock/definition/command"
 )
 
-// UnixMinEphemeralPort is the lowest ephermeral port number
-const UnixMinEphemeralPort = 49152
+const (
+	// UnixMinEphemeralPort is the lowest ephermeral port number
+	UnixMinEphemeralPort = 49152
+
+	// MinHealthcheckDuration is the shortest interval, timeout or start period docker accepts
+	// for a healthcheck
+	MinHealthcheckDuration = time.Millisecond
+)
 
 var (
 	// ErrMissingName means missing name field
@@ -29,6 +40,22 @@ var (
 
 	// ErrContainerPortTooHigh means the container port number is too high
 	ErrContainerPortTooHigh = fmt.Errorf(`container port mapping cannot exceed %d`, UnixMinEphemeralPort)
+
+	// ErrMissingHealthcheckCommand means the healthcheck is missing its command field
+	ErrMissingHealthcheckCommand = errors.New(`missing field "command" of the healthcheck`)
+
+	// ErrNegativeHealthcheckRetries means the healthcheck has a negative number of retries
+	ErrNegativeHealthcheckRetries = errors.New(`healthcheck retries cannot be negative`)
+
+	// ErrHealthcheckDuration means a duration of the healthcheck is too short or infinite
+	ErrHealthcheckDuration = fmt.Errorf(`healthcheck durations must be finite and at least %s`,
+		MinHealthcheckDuration)
+
+	// ErrMissingDestination means the file is missing its destination field
+	ErrMissingDestination = errors.New(`missing field "destination" of the file`)
+
+	// ErrInvalidSha256 means the sha256 of a file is not a hex encoded sha256 digest
+	ErrInvalidSha256 = errors.New(`field "sha256" of the file must be 64 hexadecimal digits`)
 )
 
 // Container validates a container command payload
@@ -61,3 +88,34 @@ func Container(cntr command.Container) error {
 	}
 	return nil
 }
+
+// Healthcheck validates the healthcheck of a container command payload
+func Healthcheck(hc entity.Healthcheck) error {
+	if len(hc.Command) == 0 {
+		return ErrMissingHealthcheckCommand
+	}
+	if hc.Retries < 0 {
+		return ErrNegativeHealthcheckRetries
+	}
+	for _, dur := range []command.Duration{hc.Interval, hc.Timeout, hc.StartPeriod} {
+		if dur.IsInfinite() || (dur.Duration != 0 && dur.Duration < MinHealthcheckDuration) {
+			return ErrHealthcheckDuration
+		}
+	}
+	return nil
+}
+
+// File validates the file of a file command payload
+func File(file entity.File) error {
+	if len(file.Destination) == 0 {
+		return ErrMissingDestination
+	}
+	if len(file.Sha256) == 0 {
+		return nil
+	}
+	digest, err := hex.DecodeString(file.Sha256)
+	if err != nil || len(digest) != sha256.Size {
+		return ErrInvalidSha256
+	}
+	return nil
+}
@@ -8,6 +8,9 @@ package validator
 
 import (
 	"testing"
+	"time"
+
+	"github.com/whiteblock/genesis/pkg/entity"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/whiteblock/definition/command"
@@ -89,3 +92,39 @@ func TestOrderValidator_ValidateContainer_BadImage(t *testing.T) {
 	}
 	assert.Error(t, Container(testContainer))
 }
+
+func TestOrderValidator_Healthcheck(t *testing.T) {
+	hc := entity.Healthcheck{Command: []string{"CMD-SHELL", "curl -f localhost:8545"}, Retries: 3}
+	hc.Interval.Duration = time.Second
+	assert.NoError(t, Healthcheck(hc))
+
+	assert.Equal(t, ErrMissingHealthcheckCommand, Healthcheck(entity.Healthcheck{}))
+
+	bad := hc
+	bad.Retries = -1
+	assert.Equal(t, ErrNegativeHealthcheckRetries, Healthcheck(bad))
+
+	bad = hc
+	bad.Timeout.Duration = time.Microsecond
+	assert.Equal(t, ErrHealthcheckDuration, Healthcheck(bad))
+}
+
+func TestOrderValidator_File(t *testing.T) {
+	file := entity.File{}
+	assert.Equal(t, ErrMissingDestination, File(file))
+
+	file.Destination = "/etc/genesis.json"
+	assert.NoError(t, File(file))
+
+	file.Sha256 = "B94D27B9934D3E08A52E52D7DA7DABFAC484EFE37A5380EE9088F7ACE2EFCDE9"
+	assert.NoError(t, File(file))
+
+	for _, digest := range []string{
+		"b94d27b9934d3e08",
+		"sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		"z94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+	} {
+		file.Sha256 = digest
+		assert.Equal(t, ErrInvalidSha256, File(file), digest)
+	}
+}
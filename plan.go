@@ -0,0 +1,62 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/file"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/usecase"
+
+	"github.com/whiteblock/definition/command"
+)
+
+// readInstructions reads the instructions in the given file, or stdin if the file is "-"
+func readInstructions(path string) (inst command.Instructions, err error) {
+	var data []byte
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return
+	}
+	return inst, json.Unmarshal(data, &inst)
+}
+
+// plan prints the plan for the instructions in the given file, or stdin if the file is "-".
+// It returns false if the instructions would fail.
+func plan(path string) (bool, error) {
+	conf, err := config.NewConfig()
+	if err != nil {
+		return false, err
+	}
+	inst, err := readInstructions(path)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := usecase.NewPlanUseCase(
+		conf.Docker,
+		repository.NewDockerRepository(conf.GetLogger()),
+		file.NewRemoteSources(
+			conf,
+			conf.GetLogger()),
+		conf.GetLogger()).Plan(context.Background(), inst)
+	if err != nil {
+		return false, err
+	}
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "    ")
+	return res.Valid, out.Encode(res)
+}
@@ -0,0 +1,93 @@
+/**
+ * Copyright 2019 Whiteblock Inc. All rights reserved.
+ * Use of this source code is governed by a BSD-style
+ * license that can be found in the LICENSE file.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/whiteblock/genesis/pkg/config"
+	"github.com/whiteblock/genesis/pkg/entity"
+	"github.com/whiteblock/genesis/pkg/file"
+	handAux "github.com/whiteblock/genesis/pkg/handler/auxillary"
+	"github.com/whiteblock/genesis/pkg/repository"
+	"github.com/whiteblock/genesis/pkg/service"
+	"github.com/whiteblock/genesis/pkg/usecase"
+
+	"github.com/whiteblock/definition/command"
+)
+
+type replayOutput struct {
+	// Rounds are the results of the rounds which were executed
+	Rounds []entity.Result `json:"rounds"`
+	// Unused are the recorded calls which were not replayed
+	Unused []entity.RecordedCall `json:"unused"`
+}
+
+// replay executes the instructions in the given file, or stdin if the file is "-", against the
+// given recording instead of docker, and prints the result of each round. It stops at the first
+// round which does not succeed, and returns false if there is one.
+func replay(recordingPath string, path string) (bool, error) {
+	conf, err := config.NewConfig()
+	if err != nil {
+		return false, err
+	}
+	inst, err := readInstructions(path)
+	if err != nil {
+		return false, err
+	}
+	recording, err := os.Open(recordingPath)
+	if err != nil {
+		return false, err
+	}
+	defer recording.Close()
+	calls, err := repository.ReadRecording(recording)
+	if err != nil {
+		return false, err
+	}
+	replayer, err := service.NewReplayer(calls)
+	if err != nil {
+		return false, err
+	}
+
+	exec := handAux.NewExecutor(
+		conf.Execution,
+		usecase.NewDockerUseCase(
+			service.NewReplayingDockerService(
+				repository.NewDockerRepository(conf.GetLogger()),
+				conf.Docker,
+				file.NewRemoteSources(
+					conf,
+					conf.GetLogger()),
+				replayer,
+				conf.GetLogger()),
+			conf.GetLogger()),
+		conf.GetLogger())
+
+	out := replayOutput{Rounds: []entity.Result{}}
+	ok := true
+	for {
+		cmds, err := inst.Peek()
+		if errors.Is(err, command.ErrNoCommands) {
+			break
+		}
+		res := exec.ExecuteCommands(context.Background(), cmds)
+		out.Rounds = append(out.Rounds, res)
+		if !res.IsSuccess() || res.IsTrap() || errors.Is(err, command.ErrDone) {
+			ok = res.IsSuccess()
+			break
+		}
+		inst.Next()
+	}
+	out.Unused = replayer.Unused()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "    ")
+	return ok, enc.Encode(out)
+}